@@ -0,0 +1,335 @@
+// Command fake-emsp is a minimal OCPI 2.2.1 eMSP for trying out the CPO
+// interface locally. It registers with the CSMS using a token A created
+// through POST /api/v1/ocpi/parties, logs every location, session and CDR
+// pushed to it and fetches the published locations once registered. It can
+// push a token to the CSMS and allows every token in real-time
+// authorizations, unless it is listed with -block.
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+type envelope struct {
+	Data          json.RawMessage `json:"data,omitempty"`
+	StatusCode    int             `json:"status_code"`
+	StatusMessage string          `json:"status_message,omitempty"`
+	Timestamp     time.Time       `json:"timestamp"`
+}
+
+type emsp struct {
+	baseURL     string
+	countryCode string
+	partyID     string
+	tokenB      string
+	tokenC      string
+	blocked     map[string]bool
+	httpClient  *http.Client
+}
+
+func main() {
+	// Command line flags
+	var (
+		listen      = flag.String("listen", ":9090", "Address to serve the eMSP endpoints on")
+		publicURL   = flag.String("url", "http://localhost:9090", "Base URL the CSMS reaches this eMSP at")
+		cpoVersions = flag.String("cpo-versions", "http://localhost:8080/ocpi/versions", "Versions URL of the CSMS")
+		tokenA      = flag.String("token-a", "", "Registration token issued by the CSMS (required)")
+		countryCode = flag.String("country-code", "NL", "Country code of this eMSP")
+		partyID     = flag.String("party-id", "EMS", "Party ID of this eMSP")
+		pushToken   = flag.String("push-token", "", "UID of an RFID token to push to the CSMS after registering")
+		whitelist   = flag.String("whitelist", domain.OCPIWhitelistAllowed, "Whitelist type of the pushed token")
+		block       = flag.String("block", "", "Comma separated token UIDs to refuse in real-time authorizations")
+	)
+	flag.Parse()
+
+	if *tokenA == "" {
+		log.Fatal("-token-a is required")
+	}
+
+	e := &emsp{
+		baseURL:     strings.TrimRight(*publicURL, "/") + "/ocpi",
+		countryCode: *countryCode,
+		partyID:     *partyID,
+		tokenB:      randomToken(),
+		blocked:     make(map[string]bool),
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, uid := range strings.Split(*block, ",") {
+		if uid != "" {
+			e.blocked[strings.TrimSpace(uid)] = true
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /ocpi/versions", e.authorized(e.versions))
+	mux.HandleFunc("GET /ocpi/2.2.1", e.authorized(e.versionDetails))
+	mux.HandleFunc("/ocpi/2.2.1/locations/", e.authorized(e.receive("location")))
+	mux.HandleFunc("/ocpi/2.2.1/sessions/", e.authorized(e.receive("session")))
+	mux.HandleFunc("/ocpi/2.2.1/cdrs", e.authorized(e.receive("CDR")))
+	mux.HandleFunc("POST /ocpi/2.2.1/tokens/{uid}/authorize", e.authorized(e.authorize))
+
+	server := &http.Server{Addr: *listen, Handler: mux}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Failed to serve: %v", err)
+		}
+	}()
+
+	ctx := context.Background()
+	if err := e.register(ctx, *cpoVersions, *tokenA); err != nil {
+		log.Fatalf("Registration failed: %v", err)
+	}
+	log.Printf("Registered, token C is %s", e.tokenC)
+
+	if err := e.fetchLocations(ctx, *cpoVersions); err != nil {
+		log.Printf("Error fetching locations: %v", err)
+	}
+
+	if *pushToken != "" {
+		if err := e.pushToken(ctx, *cpoVersions, *pushToken, *whitelist); err != nil {
+			log.Printf("Error pushing token: %v", err)
+		} else {
+			log.Printf("Pushed token %s", *pushToken)
+		}
+	}
+
+	select {}
+}
+
+// register runs the credentials handshake: it looks up the CPO's
+// credentials endpoint with token A and posts our token B in exchange for
+// token C.
+func (e *emsp) register(ctx context.Context, versionsURL, tokenA string) error {
+	credentialsURL, err := e.endpoint(ctx, versionsURL, tokenA, domain.OCPIModuleCredentials)
+	if err != nil {
+		return err
+	}
+
+	credentials := domain.OCPICredentials{
+		Token: e.tokenB,
+		URL:   e.baseURL + "/versions",
+		Roles: []domain.OCPICredentialsRole{{
+			Role:            "EMSP",
+			BusinessDetails: domain.OCPIBusinessDetails{Name: "Fake eMSP"},
+			PartyID:         e.partyID,
+			CountryCode:     e.countryCode,
+		}},
+	}
+
+	var result domain.OCPICredentials
+	if err := e.call(ctx, http.MethodPost, credentialsURL, tokenA, credentials, &result); err != nil {
+		return err
+	}
+	e.tokenC = result.Token
+	return nil
+}
+
+func (e *emsp) fetchLocations(ctx context.Context, versionsURL string) error {
+	locationsURL, err := e.endpoint(ctx, versionsURL, e.tokenC, domain.OCPIModuleLocations)
+	if err != nil {
+		return err
+	}
+
+	var locations []domain.OCPILocation
+	if err := e.call(ctx, http.MethodGet, locationsURL, e.tokenC, nil, &locations); err != nil {
+		return err
+	}
+
+	for _, location := range locations {
+		log.Printf("Location %s (%s, %s) with %d EVSE(s)", location.ID, location.Address, location.City, len(location.EVSEs))
+	}
+	return nil
+}
+
+func (e *emsp) pushToken(ctx context.Context, versionsURL, uid, whitelist string) error {
+	tokensURL, err := e.endpoint(ctx, versionsURL, e.tokenC, domain.OCPIModuleTokens)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/%s/%s/%s", tokensURL, e.countryCode, e.partyID, uid)
+	return e.call(ctx, http.MethodPut, url, e.tokenC, e.token(uid, whitelist), nil)
+}
+
+func (e *emsp) token(uid, whitelist string) domain.OCPIToken {
+	return domain.OCPIToken{
+		CountryCode: e.countryCode,
+		PartyID:     e.partyID,
+		UID:         uid,
+		Type:        "RFID",
+		ContractID:  fmt.Sprintf("%s-%s-C%s", e.countryCode, e.partyID, strings.ToUpper(uid)),
+		Issuer:      "Fake eMSP",
+		Valid:       true,
+		Whitelist:   whitelist,
+		LastUpdated: time.Now().UTC(),
+	}
+}
+
+// endpoint finds the URL of a module of the CPO's 2.2.1 interface.
+func (e *emsp) endpoint(ctx context.Context, versionsURL, token, module string) (string, error) {
+	var versions []domain.OCPIVersion
+	if err := e.call(ctx, http.MethodGet, versionsURL, token, nil, &versions); err != nil {
+		return "", err
+	}
+
+	for _, version := range versions {
+		if version.Version != domain.OCPIVersion221 {
+			continue
+		}
+
+		var details domain.OCPIVersionDetails
+		if err := e.call(ctx, http.MethodGet, version.URL, token, nil, &details); err != nil {
+			return "", err
+		}
+		for _, endpoint := range details.Endpoints {
+			if endpoint.Identifier == module {
+				return endpoint.URL, nil
+			}
+		}
+		return "", fmt.Errorf("CPO has no %s endpoint", module)
+	}
+
+	return "", fmt.Errorf("CPO does not support OCPI %s", domain.OCPIVersion221)
+}
+
+func (e *emsp) call(ctx context.Context, method, url, token string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token "+base64.StdEncoding.EncodeToString([]byte(token)))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result envelope
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("%s %s: %s", method, url, resp.Status)
+	}
+	if result.StatusCode < 1000 || result.StatusCode >= 2000 {
+		return fmt.Errorf("%s %s: OCPI status %d: %s", method, url, result.StatusCode, result.StatusMessage)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(result.Data, out)
+}
+
+// authorized only lets the CSMS through, i.e. requests with our token B.
+func (e *emsp) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Token ")
+		if decoded, err := base64.StdEncoding.DecodeString(token); err == nil {
+			token = string(decoded)
+		}
+		if token != e.tokenB {
+			respond(w, http.StatusUnauthorized, 2000, nil)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (e *emsp) versions(w http.ResponseWriter, r *http.Request) {
+	respond(w, http.StatusOK, 1000, []domain.OCPIVersion{{
+		Version: domain.OCPIVersion221,
+		URL:     e.baseURL + "/2.2.1",
+	}})
+}
+
+func (e *emsp) versionDetails(w http.ResponseWriter, r *http.Request) {
+	base := e.baseURL + "/2.2.1"
+	respond(w, http.StatusOK, 1000, domain.OCPIVersionDetails{
+		Version: domain.OCPIVersion221,
+		Endpoints: []domain.OCPIEndpoint{
+			{Identifier: domain.OCPIModuleCredentials, Role: domain.OCPIRoleSender, URL: base + "/credentials"},
+			{Identifier: domain.OCPIModuleLocations, Role: domain.OCPIRoleReceiver, URL: base + "/locations"},
+			{Identifier: domain.OCPIModuleSessions, Role: domain.OCPIRoleReceiver, URL: base + "/sessions"},
+			{Identifier: domain.OCPIModuleCDRs, Role: domain.OCPIRoleReceiver, URL: base + "/cdrs"},
+			{Identifier: domain.OCPIModuleTokens, Role: domain.OCPIRoleSender, URL: base + "/tokens"},
+		},
+	})
+}
+
+// authorize answers a real-time authorization of one of our tokens.
+func (e *emsp) authorize(w http.ResponseWriter, r *http.Request) {
+	uid := r.PathValue("uid")
+	body, _ := io.ReadAll(r.Body)
+	log.Printf("Authorize token %s: %s", uid, body)
+
+	allowed := domain.OCPIAllowed
+	if e.blocked[uid] {
+		allowed = domain.OCPIBlocked
+	}
+	respond(w, http.StatusOK, 1000, domain.OCPIAuthorizationInfo{
+		Allowed: allowed,
+		Token:   e.token(uid, domain.OCPIWhitelistNever),
+	})
+}
+
+// receive logs an object pushed by the CSMS.
+func (e *emsp) receive(object string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			respond(w, http.StatusBadRequest, 2000, nil)
+			return
+		}
+
+		log.Printf("%s %s %s: %s", r.Method, object, r.URL.Path, body)
+		respond(w, http.StatusOK, 1000, nil)
+	}
+}
+
+func respond(w http.ResponseWriter, httpStatus, statusCode int, data interface{}) {
+	result := envelope{StatusCode: statusCode, Timestamp: time.Now().UTC()}
+	if data != nil {
+		payload, err := json.Marshal(data)
+		if err != nil {
+			httpStatus, result.StatusCode = http.StatusInternalServerError, 3000
+		}
+		result.Data = payload
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+func randomToken() string {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		log.Fatalf("Failed to generate token: %v", err)
+	}
+	return hex.EncodeToString(buf)
+}
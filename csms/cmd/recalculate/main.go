@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/application/service"
+	"github.com/malikkhoiri/csms/internal/config"
+	"github.com/malikkhoiri/csms/internal/domain"
+	"github.com/malikkhoiri/csms/internal/infrastructure/database"
+	"github.com/malikkhoiri/csms/internal/infrastructure/payment"
+	"github.com/malikkhoiri/csms/internal/infrastructure/repository"
+)
+
+func main() {
+	// Command line flags
+	var (
+		tariffID      = flag.Uint("tariff", 0, "ID of the tariff version to price the transactions with (required)")
+		ids           = flag.String("ids", "", "Comma separated transaction IDs")
+		chargePointID = flag.Uint("charge-point", 0, "Only transactions of this charge point ID")
+		site          = flag.String("site", "", "Only transactions of charge points at this site")
+		userGroup     = flag.String("user-group", "", "Only transactions of users in this group, e.g. a fleet account")
+		from          = flag.String("from", "", "Only transactions started at or after this date (YYYY-MM-DD or RFC3339)")
+		to            = flag.String("to", "", "Only transactions started before this date (YYYY-MM-DD or RFC3339)")
+		reason        = flag.String("reason", "", "Reason recorded with the adjustments (required with -apply)")
+		apply         = flag.Bool("apply", false, "Write the new costs; without it only the diff report is printed")
+	)
+	flag.Parse()
+
+	if *tariffID == 0 {
+		log.Fatal("-tariff is required")
+	}
+
+	request := &domain.RecalculationRequest{
+		TariffID: uint(*tariffID),
+		Reason:   *reason,
+		Apply:    *apply,
+	}
+
+	if *ids != "" {
+		for _, value := range strings.Split(*ids, ",") {
+			id, err := strconv.ParseUint(strings.TrimSpace(value), 10, 32)
+			if err != nil {
+				log.Fatalf("Invalid transaction ID %q", value)
+			}
+			request.Filter.IDs = append(request.Filter.IDs, uint(id))
+		}
+	}
+	if *chargePointID != 0 {
+		id := uint(*chargePointID)
+		request.Filter.ChargePointID = &id
+	}
+	request.Filter.Site = *site
+	request.Filter.UserGroup = *userGroup
+	request.Filter.From = parseDate("from", *from)
+	request.Filter.To = parseDate("to", *to)
+
+	// Load configuration
+	cfg, err := config.Load("config.yaml")
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	// Initialize database
+	postgresDB, err := database.NewPostgresDB(&cfg.Database)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer postgresDB.Close()
+	if err := database.MigrateLegacyCosts(postgresDB.DB, cfg.Tariff.Currency); err != nil {
+		log.Fatalf("Failed to migrate transaction costs: %v", err)
+	}
+
+	// Initialize repositories and services
+	transactionRepo := repository.NewTransactionRepository(postgresDB.DB)
+	tariffRepo := repository.NewTariffRepository(postgresDB.DB)
+	tariffService := service.NewTariffService(
+		tariffRepo,
+		repository.NewChargePointRepository(postgresDB.DB),
+		repository.NewUserRepository(postgresDB.DB),
+		cfg.Tariff,
+	)
+	// Adjusted card payments are refunded or captured further, which needs
+	// no charge point, so no commander is given.
+	var gateway domain.PaymentGateway
+	switch cfg.Payment.Provider {
+	case "", "fake":
+		gateway = payment.NewFakeGateway(cfg.Payment.WebhookSecret)
+	default:
+		log.Fatalf("Unknown payment provider %q", cfg.Payment.Provider)
+	}
+	paymentService := service.NewPaymentService(
+		repository.NewPaymentRepository(postgresDB.DB),
+		repository.NewChargePointRepository(postgresDB.DB),
+		repository.NewConnectorRepository(postgresDB.DB),
+		repository.NewIDTagRepository(postgresDB.DB),
+		gateway,
+		nil,
+		tariffService,
+		cfg.Payment,
+	)
+	recalculationService := service.NewCostRecalculationService(
+		transactionRepo,
+		repository.NewTransactionAdjustmentRepository(postgresDB.DB),
+		tariffRepo,
+		repository.NewWalletRepository(postgresDB.DB),
+		tariffService,
+		paymentService,
+		repository.NewUnitOfWork(postgresDB.DB),
+	)
+
+	report, err := recalculationService.RecalculateCosts(context.Background(), request, nil)
+	if report != nil {
+		printReport(report)
+	}
+	if err != nil {
+		log.Fatalf("Recalculation failed: %v", err)
+	}
+}
+
+func parseDate(name, value string) *time.Time {
+	if value == "" {
+		return nil
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if date, err := time.Parse(layout, value); err == nil {
+			return &date
+		}
+	}
+
+	log.Fatalf("Invalid -%s date %q", name, value)
+	return nil
+}
+
+func printReport(report *domain.RecalculationReport) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "ID\tOCPP ID\tkWh before\tkWh after\tgross before\tgross after\tdifference\tpaid by\t")
+	for _, item := range report.Items {
+		fmt.Fprintf(w, "%d\t%d\t%.3f\t%.3f\t%d %s\t%d %s\t%+d\t%s\t\n",
+			item.TransactionID, item.OCPPTransactionID,
+			item.OriginalEnergyConsumed, item.EnergyConsumed,
+			item.OriginalGrossAmount, item.OriginalCurrency, item.GrossAmount, item.Currency,
+			item.Difference, item.SettledBy)
+	}
+	w.Flush()
+
+	fmt.Printf("\nTariff %d: %d transactions, %d changed, %d of them settled with their wallet or payment\n",
+		report.TariffID, report.Count, report.Changed, report.Settled)
+	currencies := make([]string, 0, len(report.TotalDifference))
+	for currency := range report.TotalDifference {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	for _, currency := range currencies {
+		fmt.Printf("Total difference %+d (%s minor units)\n", report.TotalDifference[currency], currency)
+	}
+	if report.Applied {
+		fmt.Printf("Applied as batch %s\n", report.BatchID)
+	} else {
+		fmt.Println("Dry run, nothing was written. Re-run with -apply -reason \"...\" to apply.")
+	}
+}
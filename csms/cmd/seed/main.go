@@ -41,7 +41,10 @@ func main() {
 
 	chargePointRepo := repository.NewChargePointRepository(postgresDB.DB)
 	connectorRepo := repository.NewConnectorRepository(postgresDB.DB)
-	chargePointService := service.NewChargePointService(chargePointRepo, connectorRepo)
+	connectionChangeRepo := repository.NewChargePointConnectionChangeRepository(postgresDB.DB)
+	unitOfWork := repository.NewUnitOfWork(postgresDB.DB)
+	eventBus := service.NewEventBus(repository.NewEventRepository(postgresDB.DB), nil, cfg.Events)
+	chargePointService := service.NewChargePointService(chargePointRepo, connectorRepo, connectionChangeRepo, unitOfWork, eventBus)
 
 	ctx := context.Background()
 
@@ -118,7 +121,7 @@ func seedChargePoints(ctx context.Context, chargePointService domain.ChargePoint
 			Imsi:                    "310260123456789",
 			MeterType:               "AC",
 			MeterSerialNumber:       "MTR-001",
-			Status:                  "Available",
+			Status:                  domain.ChargePointStatusAvailable,
 			LastHeartbeat:           time.Now(),
 			LastBootNotification:    time.Now(),
 		},
@@ -132,7 +135,7 @@ func seedChargePoints(ctx context.Context, chargePointService domain.ChargePoint
 			Imsi:                    "310260123456790",
 			MeterType:               "DC",
 			MeterSerialNumber:       "MTR-002",
-			Status:                  "Available",
+			Status:                  domain.ChargePointStatusAvailable,
 			LastHeartbeat:           time.Now(),
 			LastBootNotification:    time.Now(),
 		},
@@ -146,7 +149,7 @@ func seedChargePoints(ctx context.Context, chargePointService domain.ChargePoint
 			Imsi:                    "310260123456791",
 			MeterType:               "AC",
 			MeterSerialNumber:       "MTR-003",
-			Status:                  "Available",
+			Status:                  domain.ChargePointStatusAvailable,
 			LastHeartbeat:           time.Now(),
 			LastBootNotification:    time.Now(),
 		},
@@ -160,7 +163,7 @@ func seedChargePoints(ctx context.Context, chargePointService domain.ChargePoint
 			Imsi:                    "310260123456792",
 			MeterType:               "DC",
 			MeterSerialNumber:       "MTR-004",
-			Status:                  "Available",
+			Status:                  domain.ChargePointStatusAvailable,
 			LastHeartbeat:           time.Now(),
 			LastBootNotification:    time.Now(),
 		},
@@ -174,7 +177,7 @@ func seedChargePoints(ctx context.Context, chargePointService domain.ChargePoint
 			Imsi:                    "310260123456793",
 			MeterType:               "AC",
 			MeterSerialNumber:       "MTR-005",
-			Status:                  "Available",
+			Status:                  domain.ChargePointStatusAvailable,
 			LastHeartbeat:           time.Now(),
 			LastBootNotification:    time.Now(),
 		},
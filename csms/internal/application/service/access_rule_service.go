@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+type AccessRuleService struct {
+	accessRuleRepo domain.AccessRuleRepository
+}
+
+func NewAccessRuleService(accessRuleRepo domain.AccessRuleRepository) domain.AccessRuleService {
+	return &AccessRuleService{
+		accessRuleRepo: accessRuleRepo,
+	}
+}
+
+func (s *AccessRuleService) CreateAccessRule(ctx context.Context, rule *domain.AccessRule) error {
+	if err := validateAccessRule(rule); err != nil {
+		return err
+	}
+
+	return s.accessRuleRepo.Create(ctx, rule)
+}
+
+func (s *AccessRuleService) GetAccessRule(ctx context.Context, id uint) (*domain.AccessRule, error) {
+	return s.accessRuleRepo.GetByID(ctx, id)
+}
+
+func (s *AccessRuleService) UpdateAccessRule(ctx context.Context, rule *domain.AccessRule) error {
+	existingRule, err := s.accessRuleRepo.GetByID(ctx, rule.ID)
+	if err != nil {
+		return errors.New("access rule not found")
+	}
+
+	if err := validateAccessRule(rule); err != nil {
+		return err
+	}
+
+	rule.CreatedAt = existingRule.CreatedAt
+	return s.accessRuleRepo.Update(ctx, rule)
+}
+
+func (s *AccessRuleService) DeleteAccessRule(ctx context.Context, id uint) error {
+	_, err := s.accessRuleRepo.GetByID(ctx, id)
+	if err != nil {
+		return errors.New("access rule not found")
+	}
+
+	return s.accessRuleRepo.Delete(ctx, id)
+}
+
+func (s *AccessRuleService) ListAccessRules(ctx context.Context, limit, offset int) ([]domain.AccessRule, error) {
+	return s.accessRuleRepo.List(ctx, limit, offset)
+}
+
+func validateAccessRule(rule *domain.AccessRule) error {
+	if rule.IDTagID == nil && rule.ParentIDTag == "" {
+		return errors.New("access rule must reference an idTag or a parent idTag")
+	}
+
+	if (rule.StartTime == "") != (rule.EndTime == "") {
+		return errors.New("access rule time window needs both startTime and endTime")
+	}
+
+	if rule.StartTime != "" {
+		if _, err := parseClock(rule.StartTime); err != nil {
+			return err
+		}
+		if _, err := parseClock(rule.EndTime); err != nil {
+			return err
+		}
+	}
+
+	if _, err := time.LoadLocation(rule.TimeZone); err != nil {
+		return fmt.Errorf("invalid time zone %q", rule.TimeZone)
+	}
+
+	if rule.MaxSessionMinutes < 0 {
+		return errors.New("maxSessionMinutes cannot be negative")
+	}
+
+	return nil
+}
+
+// evaluateAccessRules returns the first rule permitting a session on the
+// charge point at the given time, or the reason none of them does.
+func evaluateAccessRules(rules []domain.AccessRule, chargePoint *domain.ChargePoint, at time.Time) (*domain.AccessRule, string) {
+	reason := ""
+	for i := range rules {
+		rule := &rules[i]
+
+		if !ruleMatchesChargePoint(rule, chargePoint) {
+			reason = fmt.Sprintf("charge point %s is not allowed by access rule %d", chargePoint.ChargePointCode, rule.ID)
+			continue
+		}
+
+		inWindow, err := ruleWindowContains(rule, at)
+		if err != nil {
+			reason = fmt.Sprintf("access rule %d is misconfigured: %v", rule.ID, err)
+			continue
+		}
+		if !inWindow {
+			reason = fmt.Sprintf("outside allowed hours %s-%s (%s) of access rule %d", rule.StartTime, rule.EndTime, rule.TimeZone, rule.ID)
+			continue
+		}
+
+		return rule, ""
+	}
+
+	return nil, reason
+}
+
+func ruleMatchesChargePoint(rule *domain.AccessRule, chargePoint *domain.ChargePoint) bool {
+	if rule.ChargePointID != nil && *rule.ChargePointID != chargePoint.ID {
+		return false
+	}
+	if rule.Site != "" && rule.Site != chargePoint.Site {
+		return false
+	}
+	if rule.ChargePointGroup != "" && rule.ChargePointGroup != chargePoint.ChargePointGroup {
+		return false
+	}
+	return true
+}
+
+// ruleWindowContains checks the rule's daily time window in its time zone.
+func ruleWindowContains(rule *domain.AccessRule, at time.Time) (bool, error) {
+	location, err := time.LoadLocation(rule.TimeZone)
+	if err != nil {
+		return false, err
+	}
+
+	return clockWindowContains(rule.StartTime, rule.EndTime, at.In(location))
+}
+
+// parseClock converts "HH:MM" into minutes since midnight.
+func parseClock(value string) (int, error) {
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return clock.Hour()*60 + clock.Minute(), nil
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+// AlertMonitor periodically evaluates the alert rules, opening and
+// resolving maintenance tickets.
+type AlertMonitor struct {
+	maintenanceService domain.MaintenanceService
+	interval           time.Duration
+}
+
+// NewAlertMonitor creates a new alert monitor
+func NewAlertMonitor(maintenanceService domain.MaintenanceService, interval time.Duration) *AlertMonitor {
+	return &AlertMonitor{
+		maintenanceService: maintenanceService,
+		interval:           interval,
+	}
+}
+
+// Run evaluates the alert rules until the context is cancelled
+func (m *AlertMonitor) Run(ctx context.Context) {
+	if m.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			opened, resolved, err := m.maintenanceService.EvaluateAlerts(ctx, time.Now())
+			if err != nil {
+				log.Printf("Error evaluating alert rules: %v", err)
+				continue
+			}
+			if opened > 0 || resolved > 0 {
+				log.Printf("Alert rules opened %d and resolved %d ticket(s)", opened, resolved)
+			}
+		}
+	}
+}
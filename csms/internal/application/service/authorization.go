@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/config"
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+// tagAuthorizer holds the idTag checks shared by Authorize and
+// StartTransaction.
+type tagAuthorizer struct {
+	idTagRepo       domain.IDTagRepository
+	transactionRepo domain.TransactionRepository
+	chargePointRepo domain.ChargePointRepository
+	accessRuleRepo  domain.AccessRuleRepository
+	walletRepo      domain.WalletRepository
+	chain           domain.AuthorizationChain
+	config          config.AuthorizationConfig
+}
+
+// tagAuthorization is the outcome of authorizing a tag on a charge point.
+// IDTag is nil when the tag is unknown. OCPIPartyID is set for roaming
+// drivers.
+type tagAuthorization struct {
+	IDTag              *domain.IDTag
+	Info               domain.IDTagInfo
+	MaxSessionDuration time.Duration
+	OCPIPartyID        *uint
+}
+
+func newTagAuthorizer(
+	idTagRepo domain.IDTagRepository,
+	transactionRepo domain.TransactionRepository,
+	chargePointRepo domain.ChargePointRepository,
+	accessRuleRepo domain.AccessRuleRepository,
+	walletRepo domain.WalletRepository,
+	chain domain.AuthorizationChain,
+	authorizationConfig config.AuthorizationConfig,
+) *tagAuthorizer {
+	return &tagAuthorizer{
+		idTagRepo:       idTagRepo,
+		transactionRepo: transactionRepo,
+		chargePointRepo: chargePointRepo,
+		accessRuleRepo:  accessRuleRepo,
+		walletRepo:      walletRepo,
+		chain:           chain,
+		config:          authorizationConfig,
+	}
+}
+
+// authorize evaluates the tag for a session on the charge point at the given
+// moment. The authorization chain tells who the tag is and its status; the
+// checks after it apply to every backend.
+func (a *tagAuthorizer) authorize(ctx context.Context, tag string, chargePointID uint, at time.Time) *tagAuthorization {
+	decision, err := a.chain.AuthorizeTag(ctx, tag, chargePointID)
+	if err != nil {
+		log.Printf("Error authorizing tag %s: %v", tag, err)
+	}
+	if err != nil || decision == nil {
+		return &tagAuthorization{
+			Info: domain.IDTagInfo{
+				Status: domain.AuthorizeStatusInvalid,
+			},
+		}
+	}
+
+	idTag := decision.IDTag
+	result := &tagAuthorization{
+		IDTag: idTag,
+		Info: domain.IDTagInfo{
+			Status:      decision.Status,
+			ParentIDTag: decision.ParentIDTag,
+		},
+		OCPIPartyID: decision.OCPIPartyID,
+	}
+
+	if decision.ExpiryDate != nil {
+		expiryDate := decision.ExpiryDate.UTC()
+		result.Info.ExpiryDate = &expiryDate
+	}
+
+	if decision.Status != domain.AuthorizeStatusAccepted {
+		return result
+	}
+
+	if decision.ExpiryDate != nil && decision.ExpiryDate.Before(at) {
+		result.Info.Status = domain.AuthorizeStatusExpired
+		return result
+	}
+
+	rule, reason, err := a.checkAccessRules(ctx, idTag, chargePointID, at)
+	if err != nil {
+		log.Printf("Error evaluating access rules for tag %s: %v", tag, err)
+		result.Info.Status = domain.AuthorizeStatusInvalid
+		return result
+	}
+	if reason != "" {
+		log.Printf("Tag %s blocked: %s", tag, reason)
+		result.Info.Status = domain.AuthorizeStatusBlocked
+		return result
+	}
+	if rule != nil && rule.MaxSessionMinutes > 0 {
+		result.MaxSessionDuration = time.Duration(rule.MaxSessionMinutes) * time.Minute
+	}
+
+	if wallet, err := a.walletRepo.GetByUserID(ctx, idTag.UserID); err == nil && wallet.Balance < wallet.MinBalance {
+		log.Printf("Tag %s blocked: wallet %d balance %s is below the minimum of %s", tag, wallet.ID,
+			formatMoney(wallet.Balance, wallet.Currency), formatMoney(wallet.MinBalance, wallet.Currency))
+		result.Info.Status = domain.AuthorizeStatusBlocked
+		return result
+	}
+
+	concurrent, err := a.hasActiveTransaction(ctx, idTag)
+	if err != nil {
+		result.Info.Status = domain.AuthorizeStatusInvalid
+		return result
+	}
+	if concurrent {
+		result.Info.Status = domain.AuthorizeStatusConcurrentTx
+		return result
+	}
+
+	result.Info.Status = domain.AuthorizeStatusAccepted
+	return result
+}
+
+// checkAccessRules returns the rule permitting the session, or the reason the
+// tag is blocked. Tags without rules are unrestricted.
+func (a *tagAuthorizer) checkAccessRules(ctx context.Context, idTag *domain.IDTag, chargePointID uint, at time.Time) (*domain.AccessRule, string, error) {
+	rules, err := a.accessRuleRepo.ListForTag(ctx, idTag.ID, groupOf(idTag))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(rules) == 0 {
+		return nil, "", nil
+	}
+
+	chargePoint, err := a.chargePointRepo.GetByID(ctx, chargePointID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	rule, reason := evaluateAccessRules(rules, chargePoint, at)
+	return rule, reason, nil
+}
+
+// hasActiveTransaction reports whether the tag, or when configured any tag of
+// its parent group, is already used by an active transaction.
+func (a *tagAuthorizer) hasActiveTransaction(ctx context.Context, idTag *domain.IDTag) (bool, error) {
+	idTagIDs := []uint{idTag.ID}
+
+	if a.config.GroupConcurrentTx && idTag.ParentIDTag != "" {
+		members, err := a.groupMembers(ctx, idTag.ParentIDTag)
+		if err != nil {
+			return false, err
+		}
+		for _, member := range members {
+			idTagIDs = append(idTagIDs, member.ID)
+		}
+	}
+
+	count, err := a.transactionRepo.CountActiveByIDTags(ctx, idTagIDs)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// groupMembers returns the parent tag itself and every tag pointing to it.
+func (a *tagAuthorizer) groupMembers(ctx context.Context, parentIDTag string) ([]domain.IDTag, error) {
+	members, err := a.idTagRepo.ListByParent(ctx, parentIDTag)
+	if err != nil {
+		return nil, err
+	}
+
+	if parent, err := a.idTagRepo.GetByTag(ctx, parentIDTag); err == nil {
+		members = append(members, *parent)
+	}
+
+	return members, nil
+}
+
+// sameGroup reports whether two tags are the same card or share a parent
+// group, which allows one to stop a transaction started by the other.
+func sameGroup(a, b *domain.IDTag) bool {
+	return a.Tag == b.Tag || groupOf(a) == groupOf(b)
+}
+
+func groupOf(idTag *domain.IDTag) string {
+	if idTag.ParentIDTag != "" {
+		return idTag.ParentIDTag
+	}
+	return idTag.Tag
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+// AuthorizationCache remembers the decisions of the backends after it in the
+// chain, so repeated presentations of a card do not hit the database or a
+// remote party again. Decisions expire after the TTL and are dropped as soon
+// as the tag changes.
+type AuthorizationCache struct {
+	store domain.TagDecisionStore
+	ttl   time.Duration
+
+	mu    sync.Mutex
+	stats domain.AuthorizationCacheStats
+}
+
+func NewAuthorizationCache(store domain.TagDecisionStore, ttl time.Duration) domain.AuthorizationCache {
+	return &AuthorizationCache{
+		store: store,
+		ttl:   ttl,
+		stats: domain.AuthorizationCacheStats{
+			Store:      store.Name(),
+			TTLSeconds: ttl.Seconds(),
+		},
+	}
+}
+
+func (c *AuthorizationCache) Name() string {
+	return domain.AuthorizerCache
+}
+
+func (c *AuthorizationCache) AuthorizeTag(ctx context.Context, tag string, chargePointID uint) (*domain.TagDecision, error) {
+	decision, err := c.store.Get(ctx, tag)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case err != nil:
+		c.stats.Errors++
+	case decision == nil:
+		c.stats.Misses++
+	default:
+		c.stats.Hits++
+	}
+	return decision, err
+}
+
+func (c *AuthorizationCache) Store(ctx context.Context, tag string, decision *domain.TagDecision) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	err := c.store.Set(ctx, tag, cacheableDecision(decision), c.ttl)
+	if err != nil {
+		log.Printf("Error caching the authorization of tag %s: %v", tag, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil {
+		c.stats.Errors++
+	} else {
+		c.stats.Stores++
+	}
+}
+
+func (c *AuthorizationCache) Invalidate(ctx context.Context, tag string) {
+	err := c.store.Delete(ctx, tag)
+	if err != nil {
+		log.Printf("Error invalidating the cached authorization of tag %s: %v", tag, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil {
+		c.stats.Errors++
+	} else {
+		c.stats.Invalidations++
+	}
+}
+
+func (c *AuthorizationCache) Stats() domain.AuthorizationCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(lookups)
+	}
+	return stats
+}
+
+// cacheableDecision copies the decision without the associations of its
+// IDTag, which the authorization checks do not need.
+func cacheableDecision(decision *domain.TagDecision) *domain.TagDecision {
+	copied := *decision
+	if decision.IDTag != nil {
+		idTag := *decision.IDTag
+		idTag.User = domain.User{}
+		idTag.Transactions = nil
+		copied.IDTag = &idTag
+	}
+	return &copied
+}
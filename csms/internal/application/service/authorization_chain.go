@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/config"
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+// AuthorizationBackend is a backend of the chain and the policy it runs
+// under.
+type AuthorizationBackend struct {
+	Authorizer domain.TagAuthorizer
+	// Timeout bounds one call; zero means none.
+	Timeout time.Duration
+	OnError string
+}
+
+// AuthorizationChain asks its backends in order until one knows the tag, and
+// keeps per-backend counters.
+type AuthorizationChain struct {
+	idTagRepo domain.IDTagRepository
+	userRepo  domain.UserRepository
+	backends  []AuthorizationBackend
+	userEmail string
+
+	mu      sync.Mutex
+	stats   []domain.AuthorizerStats
+	latency []time.Duration
+}
+
+func NewAuthorizationChain(
+	idTagRepo domain.IDTagRepository,
+	userRepo domain.UserRepository,
+	backends []AuthorizationBackend,
+	authorizationConfig config.AuthorizationConfig,
+) domain.AuthorizationChain {
+	stats := make([]domain.AuthorizerStats, len(backends))
+	for i, backend := range backends {
+		stats[i].Backend = backend.Authorizer.Name()
+	}
+
+	return &AuthorizationChain{
+		idTagRepo: idTagRepo,
+		userRepo:  userRepo,
+		backends:  backends,
+		userEmail: authorizationConfig.UserEmail,
+		stats:     stats,
+		latency:   make([]time.Duration, len(backends)),
+	}
+}
+
+func (c *AuthorizationChain) AuthorizeTag(ctx context.Context, tag string, chargePointID uint) (*domain.TagDecision, error) {
+	for i, backend := range c.backends {
+		decision, err := c.ask(ctx, i, tag, chargePointID)
+		if err != nil {
+			if backend.OnError == domain.AuthorizerOnErrorReject {
+				return nil, fmt.Errorf("%s authorization failed: %w", backend.Authorizer.Name(), err)
+			}
+			log.Printf("Error authorizing tag %s with %s, asking the next backend: %v", tag, backend.Authorizer.Name(), err)
+			continue
+		}
+		if decision == nil {
+			continue
+		}
+
+		if decision.IDTag == nil {
+			idTag, err := c.externalIDTag(ctx, tag, decision)
+			if err != nil {
+				return nil, err
+			}
+			decision.IDTag = idTag
+		}
+
+		c.remember(ctx, i, tag, decision)
+		return decision, nil
+	}
+	return nil, nil
+}
+
+// ask calls one backend within its timeout and counts the outcome.
+func (c *AuthorizationChain) ask(ctx context.Context, i int, tag string, chargePointID uint) (*domain.TagDecision, error) {
+	backend := c.backends[i]
+	if backend.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, backend.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	decision, err := backend.Authorizer.AuthorizeTag(ctx, tag, chargePointID)
+	elapsed := time.Since(start)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := &c.stats[i]
+	stats.Requests++
+	c.latency[i] += elapsed
+	switch {
+	case err != nil && (errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded)):
+		stats.Timeouts++
+	case err != nil:
+		stats.Errors++
+	case decision == nil:
+		stats.Unknown++
+	case decision.Status == domain.AuthorizeStatusAccepted:
+		stats.Accepted++
+	default:
+		stats.Rejected++
+	}
+
+	return decision, err
+}
+
+// remember hands the decision to the caches asked before the backend that
+// made it.
+func (c *AuthorizationChain) remember(ctx context.Context, decided int, tag string, decision *domain.TagDecision) {
+	for _, backend := range c.backends[:decided] {
+		if cache, ok := backend.Authorizer.(domain.AuthorizationCache); ok {
+			cache.Store(ctx, tag, decision)
+		}
+	}
+}
+
+// externalIDTag returns the IDTag to record a decision of a backend that
+// keeps none against, creating it on first use and keeping it in line with
+// the latest decision.
+func (c *AuthorizationChain) externalIDTag(ctx context.Context, tag string, decision *domain.TagDecision) (*domain.IDTag, error) {
+	var expiryDate time.Time
+	if decision.ExpiryDate != nil {
+		expiryDate = *decision.ExpiryDate
+	}
+
+	idTag, err := c.idTagRepo.GetByTag(ctx, tag)
+	if err != nil {
+		user, err := c.externalUser(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		idTag = &domain.IDTag{
+			Tag:         tag,
+			ParentIDTag: decision.ParentIDTag,
+			Status:      decision.Status,
+			ExpiryDate:  expiryDate,
+			UserID:      user.ID,
+			Source:      domain.IDTagSourceExternal,
+		}
+		if err := c.idTagRepo.Create(ctx, idTag); err != nil {
+			return nil, err
+		}
+		return idTag, nil
+	}
+
+	if idTag.Source == domain.IDTagSourceExternal &&
+		(idTag.Status != decision.Status || idTag.ParentIDTag != decision.ParentIDTag || !idTag.ExpiryDate.Equal(expiryDate)) {
+		idTag.Status = decision.Status
+		idTag.ParentIDTag = decision.ParentIDTag
+		idTag.ExpiryDate = expiryDate
+		if err := c.idTagRepo.Update(ctx, idTag); err != nil {
+			return nil, err
+		}
+	}
+	return idTag, nil
+}
+
+// externalUser returns the user the IDTags of externally authorized drivers
+// belong to, creating it on first use. Like the guest user it cannot sign
+// in.
+func (c *AuthorizationChain) externalUser(ctx context.Context) (*domain.User, error) {
+	if user, err := c.userRepo.GetByEmail(ctx, c.userEmail); err == nil {
+		return user, nil
+	}
+
+	user := &domain.User{
+		Name:     "External",
+		Email:    c.userEmail,
+		Password: "!",
+		Role:     "external",
+		Status:   domain.UserStatusActive,
+	}
+	if err := c.userRepo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (c *AuthorizationChain) Stats() []domain.AuthorizerStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := make([]domain.AuthorizerStats, len(c.stats))
+	copy(stats, c.stats)
+	for i := range stats {
+		if stats[i].Requests > 0 {
+			stats[i].AverageLatencyMs = float64(c.latency[i].Microseconds()) / 1000 / float64(stats[i].Requests)
+		}
+	}
+	return stats
+}
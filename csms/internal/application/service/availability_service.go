@@ -0,0 +1,386 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+type AvailabilityService struct {
+	historyRepo    domain.ConnectorStatusChangeRepository
+	connectionRepo domain.ChargePointConnectionChangeRepository
+	connectorRepo  domain.ConnectorRepository
+}
+
+func NewAvailabilityService(
+	historyRepo domain.ConnectorStatusChangeRepository,
+	connectionRepo domain.ChargePointConnectionChangeRepository,
+	connectorRepo domain.ConnectorRepository,
+) domain.AvailabilityService {
+	return &AvailabilityService{
+		historyRepo:    historyRepo,
+		connectionRepo: connectionRepo,
+		connectorRepo:  connectorRepo,
+	}
+}
+
+func (s *AvailabilityService) GetStatusHistory(ctx context.Context, filter domain.StatusHistoryFilter) ([]domain.ConnectorStatusChange, error) {
+	return s.historyRepo.List(ctx, filter)
+}
+
+// GetAvailabilityReport computes the availability KPIs of every selected
+// connector, and of every site, over the filter's period. A period reaching
+// into the future ends now.
+func (s *AvailabilityService) GetAvailabilityReport(ctx context.Context, filter domain.AvailabilityFilter) (*domain.AvailabilityReport, error) {
+	if now := time.Now(); filter.To.After(now) {
+		filter.To = now
+	}
+	if !filter.From.Before(filter.To) {
+		return nil, errors.New("from must be before to")
+	}
+
+	connectors, err := s.connectorRepo.ListWithChargePoint(ctx, filter.ChargePointID, filter.Site)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &domain.AvailabilityReport{
+		From:       filter.From,
+		To:         filter.To,
+		Connectors: []domain.ConnectorAvailability{},
+		Sites:      []domain.SiteAvailability{},
+	}
+
+	if len(connectors) == 0 {
+		return report, nil
+	}
+
+	chargePointIDs := make([]uint, 0, len(connectors))
+	for _, connector := range connectors {
+		chargePointIDs = append(chargePointIDs, connector.ChargePointID)
+	}
+
+	initial, err := s.historyRepo.ListLatestBefore(ctx, chargePointIDs, filter.From)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := s.historyRepo.ListBetween(ctx, chargePointIDs, filter.From, filter.To)
+	if err != nil {
+		return nil, err
+	}
+	initialConnections, err := s.connectionRepo.ListLatestBefore(ctx, chargePointIDs, filter.From)
+	if err != nil {
+		return nil, err
+	}
+	connectionChanges, err := s.connectionRepo.ListBetween(ctx, chargePointIDs, filter.From, filter.To)
+	if err != nil {
+		return nil, err
+	}
+	offline := offlinePeriods(connectors, initialConnections, connectionChanges, filter.From, filter.To)
+
+	type connectorKey struct {
+		chargePointID uint
+		connectorID   int
+	}
+	initialStatus := make(map[connectorKey]string, len(initial))
+	for _, change := range initial {
+		initialStatus[connectorKey{change.ChargePointID, change.ConnectorID}] = change.ToStatus
+	}
+	changesByConnector := make(map[connectorKey][]domain.ConnectorStatusChange)
+	for _, change := range changes {
+		key := connectorKey{change.ChargePointID, change.ConnectorID}
+		changesByConnector[key] = append(changesByConnector[key], change)
+	}
+
+	// The charge point's own status, reported as connector 0, takes its
+	// connectors out of order with it.
+	stations := make(map[uint][]availabilityPeriod)
+	for _, connector := range connectors {
+		if _, done := stations[connector.ChargePointID]; done {
+			continue
+		}
+		chargePoint := connector.ChargePoint
+		key := connectorKey{connector.ChargePointID, 0}
+		status, known := initialStatus[key]
+		stationChanges := changesByConnector[key]
+		if !known && len(stationChanges) == 0 && chargePoint.StatusChangedAt != nil && chargePoint.StatusChangedAt.Before(filter.To) {
+			if chargePoint.StatusChangedAt.After(filter.From) {
+				stationChanges = []domain.ConnectorStatusChange{{ToStatus: chargePoint.Status, ChangedAt: *chargePoint.StatusChangedAt}}
+			} else {
+				status, known = chargePoint.Status, true
+			}
+		}
+		stations[connector.ChargePointID] = stationPeriods(status, known, stationChanges, filter.From, filter.To)
+	}
+
+	sites := make(map[string]*availabilityTotals)
+	siteConnectors := make(map[string]int)
+	for _, connector := range connectors {
+		key := connectorKey{connector.ChargePointID, connector.ConnectorID}
+
+		status, known := initialStatus[key]
+		connectorChanges := changesByConnector[key]
+		if !known && len(connectorChanges) == 0 && connector.StatusChangedAt != nil && connector.StatusChangedAt.Before(filter.To) {
+			// Connectors that have not changed since before the history was
+			// kept are known from their current status.
+			if connector.StatusChangedAt.After(filter.From) {
+				connectorChanges = []domain.ConnectorStatusChange{{ToStatus: connector.Status, ChangedAt: *connector.StatusChangedAt}}
+			} else {
+				status, known = connector.Status, true
+			}
+		}
+		totals := newAvailabilityTotals()
+		totals.walk(status, known, connectorChanges, filter.From, filter.To, offline[connector.ChargePointID], stations[connector.ChargePointID])
+
+		site := connector.ChargePoint.Site
+		report.Connectors = append(report.Connectors, domain.ConnectorAvailability{
+			ChargePointID:     connector.ChargePointID,
+			ChargePointCode:   connector.ChargePoint.ChargePointCode,
+			Site:              site,
+			ConnectorID:       connector.ConnectorID,
+			AvailabilityStats: totals.stats(),
+		})
+
+		if sites[site] == nil {
+			sites[site] = newAvailabilityTotals()
+		}
+		sites[site].merge(totals)
+		siteConnectors[site]++
+	}
+
+	for site, totals := range sites {
+		report.Sites = append(report.Sites, domain.SiteAvailability{
+			Site:              site,
+			Connectors:        siteConnectors[site],
+			AvailabilityStats: totals.stats(),
+		})
+	}
+	sort.Slice(report.Sites, func(i, j int) bool {
+		return report.Sites[i].Site < report.Sites[j].Site
+	})
+
+	return report, nil
+}
+
+// availabilityPeriod is the span of time [from, to) the connectors of a
+// charge point spent in the status because of the charge point itself.
+type availabilityPeriod struct {
+	from   time.Time
+	to     time.Time
+	status string
+}
+
+// offlinePeriods returns when each of the connectors' charge points was
+// disconnected from from to to, oldest first, as Offline periods. A charge
+// point not known to have been disconnected is taken as connected.
+func offlinePeriods(connectors []domain.Connector, initial, changes []domain.ChargePointConnectionChange, from, to time.Time) map[uint][]availabilityPeriod {
+	initialOnline := make(map[uint]bool, len(initial))
+	for _, change := range initial {
+		initialOnline[change.ChargePointID] = change.Online
+	}
+	changesByChargePoint := make(map[uint][]domain.ChargePointConnectionChange)
+	for _, change := range changes {
+		changesByChargePoint[change.ChargePointID] = append(changesByChargePoint[change.ChargePointID], change)
+	}
+
+	periods := make(map[uint][]availabilityPeriod)
+	seen := make(map[uint]bool)
+	for _, connector := range connectors {
+		chargePoint := connector.ChargePoint
+		if seen[connector.ChargePointID] {
+			continue
+		}
+		seen[connector.ChargePointID] = true
+
+		online, known := initialOnline[connector.ChargePointID]
+		chargePointChanges := changesByChargePoint[connector.ChargePointID]
+		if !known && len(chargePointChanges) == 0 && chargePoint.OnlineChangedAt != nil && chargePoint.OnlineChangedAt.Before(to) {
+			// Charge points that have not connected or disconnected since
+			// before the history was kept are known from their current state.
+			if chargePoint.OnlineChangedAt.After(from) {
+				chargePointChanges = []domain.ChargePointConnectionChange{{Online: chargePoint.Online, ChangedAt: *chargePoint.OnlineChangedAt}}
+			} else {
+				online, known = chargePoint.Online, true
+			}
+		}
+		if !known {
+			online = true
+		}
+
+		offlineSince := from
+		for _, change := range chargePointChanges {
+			switch {
+			case online && !change.Online:
+				offlineSince = change.ChangedAt
+			case !online && change.Online:
+				periods[connector.ChargePointID] = append(periods[connector.ChargePointID], availabilityPeriod{offlineSince, change.ChangedAt, domain.AvailabilityStatusOffline})
+			}
+			online = change.Online
+		}
+		if !online {
+			periods[connector.ChargePointID] = append(periods[connector.ChargePointID], availabilityPeriod{offlineSince, to, domain.AvailabilityStatusOffline})
+		}
+	}
+	return periods
+}
+
+// stationPeriods returns when the charge point's own status was Faulted or
+// Unavailable from from to to, starting in the status if it is known and
+// following the changes, which must lie in the period, oldest first.
+func stationPeriods(status string, known bool, changes []domain.ConnectorStatusChange, from, to time.Time) []availabilityPeriod {
+	var periods []availabilityPeriod
+	cursor := from
+	for _, change := range changes {
+		if known && stationDown(status) && change.ToStatus != status {
+			periods = append(periods, availabilityPeriod{cursor, change.ChangedAt, status})
+		}
+		if !known || change.ToStatus != status {
+			cursor = change.ChangedAt
+		}
+		status, known = change.ToStatus, true
+	}
+	if known && stationDown(status) {
+		periods = append(periods, availabilityPeriod{cursor, to, status})
+	}
+	return periods
+}
+
+func stationDown(status string) bool {
+	return status == domain.ChargePointStatusFaulted || status == domain.ChargePointStatusUnavailable
+}
+
+// availabilityTotals accumulates the time spent in each status and the
+// faults of one or more connectors.
+type availabilityTotals struct {
+	timeInStatus map[string]time.Duration
+	monitored    time.Duration
+	uptime       time.Duration
+	faulted      time.Duration
+	failures     int
+	faults       int
+}
+
+func newAvailabilityTotals() *availabilityTotals {
+	return &availabilityTotals{timeInStatus: make(map[string]time.Duration)}
+}
+
+// walk adds the time from from to to, starting in the status if it is known
+// and following the changes, which must lie in the period, oldest first.
+// Time in the charge point's periods is added in their status instead, the
+// earlier list of periods taking precedence; a Faulted period is a failure
+// of the connector too.
+func (t *availabilityTotals) walk(status string, known bool, changes []domain.ConnectorStatusChange, from, to time.Time, chargePoint ...[]availabilityPeriod) {
+	if known && status == domain.ChargePointStatusFaulted {
+		t.faults++
+	}
+	for _, periods := range chargePoint {
+		for _, period := range periods {
+			if period.status != domain.ChargePointStatusFaulted {
+				continue
+			}
+			if period.from.After(from) {
+				t.failures++
+			}
+			t.faults++
+		}
+	}
+
+	cursor := from
+	for _, change := range changes {
+		if known {
+			t.addPeriod(status, cursor, change.ChangedAt, chargePoint)
+		}
+		if change.ToStatus == domain.ChargePointStatusFaulted && status != domain.ChargePointStatusFaulted {
+			t.failures++
+			t.faults++
+		}
+		status, known = change.ToStatus, true
+		cursor = change.ChangedAt
+	}
+	if known {
+		t.addPeriod(status, cursor, to, chargePoint)
+	}
+}
+
+// addPeriod adds the time from from to to in the status, except for the time
+// in the charge point's periods, which is added in theirs. Each list of
+// periods must be ordered and takes precedence over the lists after it.
+func (t *availabilityTotals) addPeriod(status string, from, to time.Time, chargePoint [][]availabilityPeriod) {
+	if len(chargePoint) == 0 {
+		t.add(status, to.Sub(from))
+		return
+	}
+
+	for _, period := range chargePoint[0] {
+		start, end := period.from, period.to
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if !end.After(start) {
+			continue
+		}
+
+		t.addPeriod(status, from, start, chargePoint[1:])
+		t.add(period.status, end.Sub(start))
+		from = end
+	}
+	t.addPeriod(status, from, to, chargePoint[1:])
+}
+
+func (t *availabilityTotals) add(status string, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+
+	t.timeInStatus[status] += d
+	t.monitored += d
+	switch status {
+	case domain.ChargePointStatusFaulted:
+		t.faulted += d
+	case domain.ChargePointStatusUnavailable, domain.AvailabilityStatusOffline:
+		// Down, but not a fault waiting to be repaired.
+	default:
+		t.uptime += d
+	}
+}
+
+func (t *availabilityTotals) merge(other *availabilityTotals) {
+	for status, d := range other.timeInStatus {
+		t.timeInStatus[status] += d
+	}
+	t.monitored += other.monitored
+	t.uptime += other.uptime
+	t.faulted += other.faulted
+	t.failures += other.failures
+	t.faults += other.faults
+}
+
+func (t *availabilityTotals) stats() domain.AvailabilityStats {
+	stats := domain.AvailabilityStats{
+		MonitoredSeconds: int64(t.monitored.Seconds()),
+		UptimeSeconds:    int64(t.uptime.Seconds()),
+		TimeInStatus:     make(map[string]int64, len(t.timeInStatus)),
+		Failures:         t.failures,
+	}
+	for status, d := range t.timeInStatus {
+		stats.TimeInStatus[status] = int64(d.Seconds())
+	}
+	if t.monitored > 0 {
+		stats.AvailabilityPercent = float64(t.uptime) / float64(t.monitored) * 100
+	}
+	if t.failures > 0 {
+		mtbf := int64(t.uptime.Seconds()) / int64(t.failures)
+		stats.MTBFSeconds = &mtbf
+	}
+	if t.faults > 0 {
+		mttr := int64(t.faulted.Seconds()) / int64(t.faults)
+		stats.MTTRSeconds = &mttr
+	}
+	return stats
+}
@@ -0,0 +1,278 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+var availabilityStart = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+// hour returns the time h hours into the test period.
+func hour(h int) time.Time {
+	return availabilityStart.Add(time.Duration(h) * time.Hour)
+}
+
+func TestOfflinePeriods(t *testing.T) {
+	before := hour(-5)
+	during := hour(6)
+
+	tests := []struct {
+		name        string
+		chargePoint domain.ChargePoint
+		initial     []domain.ChargePointConnectionChange
+		changes     []domain.ChargePointConnectionChange
+		want        []availabilityPeriod
+	}{
+		{
+			name:        "no history",
+			chargePoint: domain.ChargePoint{Online: true},
+		},
+		{
+			name:    "offline at start, reconnects",
+			initial: []domain.ChargePointConnectionChange{{ChargePointID: 1, Online: false}},
+			changes: []domain.ChargePointConnectionChange{{ChargePointID: 1, Online: true, ChangedAt: hour(3)}},
+			want:    []availabilityPeriod{{hour(0), hour(3), domain.AvailabilityStatusOffline}},
+		},
+		{
+			name:    "disconnects and stays offline",
+			initial: []domain.ChargePointConnectionChange{{ChargePointID: 1, Online: true}},
+			changes: []domain.ChargePointConnectionChange{{ChargePointID: 1, Online: false, ChangedAt: hour(4)}},
+			want:    []availabilityPeriod{{hour(4), hour(10), domain.AvailabilityStatusOffline}},
+		},
+		{
+			name: "disconnects twice",
+			changes: []domain.ChargePointConnectionChange{
+				{ChargePointID: 1, Online: false, ChangedAt: hour(1)},
+				{ChargePointID: 1, Online: true, ChangedAt: hour(2)},
+				{ChargePointID: 1, Online: false, ChangedAt: hour(8)},
+			},
+			want: []availabilityPeriod{
+				{hour(1), hour(2), domain.AvailabilityStatusOffline},
+				{hour(8), hour(10), domain.AvailabilityStatusOffline},
+			},
+		},
+		{
+			name:        "disconnected before the history was kept",
+			chargePoint: domain.ChargePoint{Online: false, OnlineChangedAt: &before},
+			want:        []availabilityPeriod{{hour(0), hour(10), domain.AvailabilityStatusOffline}},
+		},
+		{
+			name:        "disconnected in the period before the history was kept",
+			chargePoint: domain.ChargePoint{Online: false, OnlineChangedAt: &during},
+			want:        []availabilityPeriod{{hour(6), hour(10), domain.AvailabilityStatusOffline}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connectors := []domain.Connector{
+				{ChargePointID: 1, ConnectorID: 1, ChargePoint: tt.chargePoint},
+				{ChargePointID: 1, ConnectorID: 2, ChargePoint: tt.chargePoint},
+			}
+
+			got := offlinePeriods(connectors, tt.initial, tt.changes, hour(0), hour(10))
+			if !reflect.DeepEqual(got[1], tt.want) {
+				t.Errorf("offlinePeriods = %v, want %v", got[1], tt.want)
+			}
+		})
+	}
+}
+
+func TestStationPeriods(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  string
+		known   bool
+		changes []domain.ConnectorStatusChange
+		want    []availabilityPeriod
+	}{
+		{name: "unknown"},
+		{name: "available", status: domain.ChargePointStatusAvailable, known: true},
+		{
+			name:   "faulted all period",
+			status: domain.ChargePointStatusFaulted, known: true,
+			want: []availabilityPeriod{{hour(0), hour(10), domain.ChargePointStatusFaulted}},
+		},
+		{
+			name:   "repaired",
+			status: domain.ChargePointStatusFaulted, known: true,
+			changes: []domain.ConnectorStatusChange{{ToStatus: domain.ChargePointStatusAvailable, ChangedAt: hour(2)}},
+			want:    []availabilityPeriod{{hour(0), hour(2), domain.ChargePointStatusFaulted}},
+		},
+		{
+			name:   "made unavailable for a while",
+			status: domain.ChargePointStatusAvailable, known: true,
+			changes: []domain.ConnectorStatusChange{
+				{ToStatus: domain.ChargePointStatusUnavailable, ChangedAt: hour(3)},
+				{ToStatus: domain.ChargePointStatusAvailable, ChangedAt: hour(5)},
+			},
+			want: []availabilityPeriod{{hour(3), hour(5), domain.ChargePointStatusUnavailable}},
+		},
+		{
+			name:   "repeated fault",
+			status: domain.ChargePointStatusFaulted, known: true,
+			changes: []domain.ConnectorStatusChange{
+				{ToStatus: domain.ChargePointStatusFaulted, ChangedAt: hour(2)},
+				{ToStatus: domain.ChargePointStatusAvailable, ChangedAt: hour(4)},
+			},
+			want: []availabilityPeriod{{hour(0), hour(4), domain.ChargePointStatusFaulted}},
+		},
+		{
+			name:    "faults after an unknown start",
+			changes: []domain.ConnectorStatusChange{{ToStatus: domain.ChargePointStatusFaulted, ChangedAt: hour(6)}},
+			want:    []availabilityPeriod{{hour(6), hour(10), domain.ChargePointStatusFaulted}},
+		},
+		{
+			name:   "fault turns into unavailable",
+			status: domain.ChargePointStatusFaulted, known: true,
+			changes: []domain.ConnectorStatusChange{{ToStatus: domain.ChargePointStatusUnavailable, ChangedAt: hour(3)}},
+			want: []availabilityPeriod{
+				{hour(0), hour(3), domain.ChargePointStatusFaulted},
+				{hour(3), hour(10), domain.ChargePointStatusUnavailable},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := stationPeriods(tt.status, tt.known, tt.changes, hour(0), hour(10))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("stationPeriods = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAvailabilityTotalsWalk(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       string
+		known        bool
+		changes      []domain.ConnectorStatusChange
+		offline      []availabilityPeriod
+		station      []availabilityPeriod
+		wantInStatus map[string]time.Duration
+		wantUptime   time.Duration
+		wantFailures int
+		wantFaults   int
+	}{
+		{
+			name:   "available all period",
+			status: domain.ChargePointStatusAvailable, known: true,
+			wantInStatus: map[string]time.Duration{domain.ChargePointStatusAvailable: 10 * time.Hour},
+			wantUptime:   10 * time.Hour,
+		},
+		{
+			name:   "connector faults and is repaired",
+			status: domain.ChargePointStatusAvailable, known: true,
+			changes: []domain.ConnectorStatusChange{
+				{ToStatus: domain.ChargePointStatusFaulted, ChangedAt: hour(2)},
+				{ToStatus: domain.ChargePointStatusAvailable, ChangedAt: hour(5)},
+			},
+			wantInStatus: map[string]time.Duration{
+				domain.ChargePointStatusAvailable: 7 * time.Hour,
+				domain.ChargePointStatusFaulted:   3 * time.Hour,
+			},
+			wantUptime:   7 * time.Hour,
+			wantFailures: 1,
+			wantFaults:   1,
+		},
+		{
+			name:   "faulted from the start",
+			status: domain.ChargePointStatusFaulted, known: true,
+			changes: []domain.ConnectorStatusChange{{ToStatus: domain.ChargePointStatusAvailable, ChangedAt: hour(4)}},
+			wantInStatus: map[string]time.Duration{
+				domain.ChargePointStatusFaulted:   4 * time.Hour,
+				domain.ChargePointStatusAvailable: 6 * time.Hour,
+			},
+			wantUptime: 6 * time.Hour,
+			wantFaults: 1,
+		},
+		{
+			name:         "unknown until the first change",
+			changes:      []domain.ConnectorStatusChange{{ToStatus: domain.ChargePointStatusAvailable, ChangedAt: hour(4)}},
+			wantInStatus: map[string]time.Duration{domain.ChargePointStatusAvailable: 6 * time.Hour},
+			wantUptime:   6 * time.Hour,
+		},
+		{
+			name:   "charge point offline",
+			status: domain.ChargePointStatusAvailable, known: true,
+			offline: []availabilityPeriod{{hour(2), hour(4), domain.AvailabilityStatusOffline}},
+			wantInStatus: map[string]time.Duration{
+				domain.ChargePointStatusAvailable: 8 * time.Hour,
+				domain.AvailabilityStatusOffline:  2 * time.Hour,
+			},
+			wantUptime: 8 * time.Hour,
+		},
+		{
+			name:   "charge point faults",
+			status: domain.ChargePointStatusAvailable, known: true,
+			station: []availabilityPeriod{{hour(6), hour(8), domain.ChargePointStatusFaulted}},
+			wantInStatus: map[string]time.Duration{
+				domain.ChargePointStatusAvailable: 8 * time.Hour,
+				domain.ChargePointStatusFaulted:   2 * time.Hour,
+			},
+			wantUptime:   8 * time.Hour,
+			wantFailures: 1,
+			wantFaults:   1,
+		},
+		{
+			name:   "charge point faulted from the start",
+			status: domain.ChargePointStatusAvailable, known: true,
+			station: []availabilityPeriod{{hour(0), hour(3), domain.ChargePointStatusFaulted}},
+			wantInStatus: map[string]time.Duration{
+				domain.ChargePointStatusAvailable: 7 * time.Hour,
+				domain.ChargePointStatusFaulted:   3 * time.Hour,
+			},
+			wantUptime: 7 * time.Hour,
+			wantFaults: 1,
+		},
+		{
+			name:   "offline takes precedence over a charge point fault",
+			status: domain.ChargePointStatusAvailable, known: true,
+			offline: []availabilityPeriod{{hour(1), hour(3), domain.AvailabilityStatusOffline}},
+			station: []availabilityPeriod{{hour(2), hour(5), domain.ChargePointStatusFaulted}},
+			wantInStatus: map[string]time.Duration{
+				domain.ChargePointStatusAvailable: 6 * time.Hour,
+				domain.AvailabilityStatusOffline:  2 * time.Hour,
+				domain.ChargePointStatusFaulted:   2 * time.Hour,
+			},
+			wantUptime:   6 * time.Hour,
+			wantFailures: 1,
+			wantFaults:   1,
+		},
+		{
+			name:   "charge point unavailable while the connector charges",
+			status: domain.ChargePointStatusCharging, known: true,
+			station: []availabilityPeriod{{hour(5), hour(10), domain.ChargePointStatusUnavailable}},
+			wantInStatus: map[string]time.Duration{
+				domain.ChargePointStatusCharging:    5 * time.Hour,
+				domain.ChargePointStatusUnavailable: 5 * time.Hour,
+			},
+			wantUptime: 5 * time.Hour,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			totals := newAvailabilityTotals()
+			totals.walk(tt.status, tt.known, tt.changes, hour(0), hour(10), tt.offline, tt.station)
+
+			if !reflect.DeepEqual(totals.timeInStatus, tt.wantInStatus) {
+				t.Errorf("time in status = %v, want %v", totals.timeInStatus, tt.wantInStatus)
+			}
+			if totals.uptime != tt.wantUptime {
+				t.Errorf("uptime = %s, want %s", totals.uptime, tt.wantUptime)
+			}
+			if totals.failures != tt.wantFailures {
+				t.Errorf("failures = %d, want %d", totals.failures, tt.wantFailures)
+			}
+			if totals.faults != tt.wantFaults {
+				t.Errorf("faults = %d, want %d", totals.faults, tt.wantFaults)
+			}
+		})
+	}
+}
@@ -2,6 +2,10 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/malikkhoiri/csms/internal/domain"
@@ -10,15 +14,24 @@ import (
 type ChargePointService struct {
 	chargePointRepo domain.ChargePointRepository
 	connectorRepo   domain.ConnectorRepository
+	connectionRepo  domain.ChargePointConnectionChangeRepository
+	unitOfWork      domain.UnitOfWork
+	events          domain.EventBus
 }
 
 func NewChargePointService(
 	chargePointRepo domain.ChargePointRepository,
 	connectorRepo domain.ConnectorRepository,
+	connectionRepo domain.ChargePointConnectionChangeRepository,
+	unitOfWork domain.UnitOfWork,
+	eventBus domain.EventBus,
 ) domain.ChargePointService {
 	return &ChargePointService{
 		chargePointRepo: chargePointRepo,
 		connectorRepo:   connectorRepo,
+		connectionRepo:  connectionRepo,
+		unitOfWork:      unitOfWork,
+		events:          eventBus,
 	}
 }
 
@@ -41,11 +54,16 @@ func (s *ChargePointService) RegisterChargePoint(ctx context.Context, request *d
 		existingCP.MeterType = request.MeterType
 		existingCP.MeterSerialNumber = request.MeterSerialNumber
 		existingCP.LastBootNotification = now
-		existingCP.Status = "Available"
 
 		if err := s.chargePointRepo.Update(ctx, existingCP); err != nil {
 			return nil, err
 		}
+		err := s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+			return setStationStatus(ctx, s.chargePointRepo, s.connectorRepo, s.events, existingCP, domain.ChargePointStatusAvailable)
+		})
+		if err != nil {
+			return nil, err
+		}
 	} else {
 		cp := &domain.ChargePoint{
 			ChargePointCode:         chargePointCode,
@@ -58,7 +76,8 @@ func (s *ChargePointService) RegisterChargePoint(ctx context.Context, request *d
 			Imsi:                    request.Imsi,
 			MeterType:               request.MeterType,
 			MeterSerialNumber:       request.MeterSerialNumber,
-			Status:                  "Available",
+			Status:                  domain.ChargePointStatusAvailable,
+			AggregateStatus:         domain.ChargePointStatusAvailable,
 			LastBootNotification:    now,
 			LastHeartbeat:           now,
 		}
@@ -70,7 +89,8 @@ func (s *ChargePointService) RegisterChargePoint(ctx context.Context, request *d
 		connector := &domain.Connector{
 			ChargePointID: cp.ID,
 			ConnectorID:   1,
-			Status:        "Available",
+			PublicCode:    newPublicCode(),
+			Status:        domain.ChargePointStatusAvailable,
 		}
 
 		if err := s.connectorRepo.Create(ctx, connector); err != nil {
@@ -87,8 +107,21 @@ func (s *ChargePointService) RegisterChargePoint(ctx context.Context, request *d
 	return response, nil
 }
 
+// UpdateChargePointStatus sets the charge point's own status, as if it had
+// reported it for connector 0.
 func (s *ChargePointService) UpdateChargePointStatus(ctx context.Context, chargePointID uint, status string) error {
-	return s.chargePointRepo.UpdateStatus(ctx, chargePointID, status)
+	if !slices.Contains(domain.StationStatuses, status) {
+		return fmt.Errorf("invalid charge point status %q", status)
+	}
+
+	chargePoint, err := s.chargePointRepo.GetByID(ctx, chargePointID)
+	if err != nil {
+		return errors.New("charge point not found")
+	}
+
+	return s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		return setStationStatus(ctx, s.chargePointRepo, s.connectorRepo, s.events, chargePoint, status)
+	})
 }
 
 func (s *ChargePointService) GetChargePoint(ctx context.Context, id uint) (*domain.ChargePoint, error) {
@@ -107,6 +140,43 @@ func (s *ChargePointService) UpdateHeartbeat(ctx context.Context, chargePointID
 	return s.chargePointRepo.UpdateHeartbeat(ctx, chargePointID)
 }
 
+// UpdateConnection records, keeps in the connection history and publishes
+// the charge point going online or offline.
+// Stations connecting before their first BootNotification are unknown and
+// ignored.
+func (s *ChargePointService) UpdateConnection(ctx context.Context, chargePointCode string, online bool) error {
+	chargePoint, err := s.chargePointRepo.GetByCode(ctx, chargePointCode)
+	if err != nil {
+		return nil
+	}
+
+	eventType := domain.EventChargePointOffline
+	if online {
+		eventType = domain.EventChargePointOnline
+	}
+	now := time.Now()
+	return s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		if err := s.chargePointRepo.UpdateConnection(ctx, chargePoint.ID, online, now); err != nil {
+			return err
+		}
+		if err := s.connectionRepo.Create(ctx, &domain.ChargePointConnectionChange{
+			ChargePointID: chargePoint.ID,
+			Online:        online,
+			ChangedAt:     now,
+		}); err != nil {
+			return err
+		}
+		return s.events.Publish(ctx, eventType, chargePoint.ID, &domain.ChargePointEventData{
+			ChargePointID:   chargePoint.ID,
+			ChargePointCode: chargePoint.ChargePointCode,
+		})
+	})
+}
+
+func (s *ChargePointService) ListOnlineSince(ctx context.Context, before time.Time) ([]domain.ChargePoint, error) {
+	return s.chargePointRepo.ListOnlineSince(ctx, before)
+}
+
 func (s *ChargePointService) DeleteChargePoint(ctx context.Context, id uint) error {
 	_, err := s.chargePointRepo.GetByID(ctx, id)
 	if err != nil {
@@ -115,3 +185,98 @@ func (s *ChargePointService) DeleteChargePoint(ctx context.Context, id uint) err
 
 	return s.chargePointRepo.Delete(ctx, id)
 }
+
+func (s *ChargePointService) UpdateChargePointSite(ctx context.Context, id uint, site, group string) error {
+	_, err := s.chargePointRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return s.chargePointRepo.UpdateSite(ctx, id, site, group)
+}
+
+func (s *ChargePointService) UpdateChargePointLocation(ctx context.Context, id uint, location *domain.ChargePointLocation) error {
+	_, err := s.chargePointRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	location.Country = strings.ToUpper(location.Country)
+	return s.chargePointRepo.UpdateLocation(ctx, id, location)
+}
+
+// setStationStatus sets the charge point's own status and derives its
+// aggregate status from its connectors', publishing any change. It is meant
+// to run in a unit of work.
+func setStationStatus(
+	ctx context.Context,
+	chargePointRepo domain.ChargePointRepository,
+	connectorRepo domain.ConnectorRepository,
+	events domain.EventBus,
+	chargePoint *domain.ChargePoint,
+	status string,
+) error {
+	connectors, err := connectorRepo.ListByChargePoint(ctx, chargePoint.ID)
+	if err != nil {
+		return err
+	}
+
+	data := &domain.ChargePointEventData{
+		ChargePointID:           chargePoint.ID,
+		ChargePointCode:         chargePoint.ChargePointCode,
+		Status:                  status,
+		PreviousStatus:          chargePoint.Status,
+		AggregateStatus:         stationStatus(status, connectors),
+		PreviousAggregateStatus: chargePoint.AggregateStatus,
+	}
+	if data.Status == data.PreviousStatus && data.AggregateStatus == data.PreviousAggregateStatus {
+		return nil
+	}
+
+	if data.Status != data.PreviousStatus {
+		now := time.Now()
+		if err := chargePointRepo.UpdateStatus(ctx, chargePoint.ID, data.Status, now); err != nil {
+			return err
+		}
+		chargePoint.StatusChangedAt = &now
+	}
+	if data.AggregateStatus != data.PreviousAggregateStatus {
+		if err := chargePointRepo.UpdateAggregateStatus(ctx, chargePoint.ID, data.AggregateStatus); err != nil {
+			return err
+		}
+	}
+	chargePoint.Status, chargePoint.AggregateStatus = data.Status, data.AggregateStatus
+
+	return events.Publish(ctx, domain.EventChargePointStatus, chargePoint.ID, data)
+}
+
+// stationStatus derives a charge point's aggregate status. The charge point
+// being Unavailable or Faulted itself takes its connectors with it.
+// Otherwise it is Available while any connector is, Faulted or Unavailable
+// when all connectors are out of order, and Occupied in between.
+func stationStatus(status string, connectors []domain.Connector) string {
+	if status == domain.ChargePointStatusUnavailable || status == domain.ChargePointStatusFaulted || len(connectors) == 0 {
+		return status
+	}
+
+	faulted, unavailable := 0, 0
+	for _, connector := range connectors {
+		switch connector.Status {
+		case domain.ChargePointStatusAvailable:
+			return domain.ChargePointStatusAvailable
+		case domain.ChargePointStatusFaulted:
+			faulted++
+		case domain.ChargePointStatusUnavailable:
+			unavailable++
+		}
+	}
+
+	switch {
+	case faulted == len(connectors):
+		return domain.ChargePointStatusFaulted
+	case faulted+unavailable == len(connectors):
+		return domain.ChargePointStatusUnavailable
+	default:
+		return domain.ChargePointStatusOccupied
+	}
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+func TestStationStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     string
+		connectors []string
+		want       string
+	}{
+		{name: "no connectors", status: domain.ChargePointStatusAvailable, want: domain.ChargePointStatusAvailable},
+		{
+			name:       "one connector available",
+			status:     domain.ChargePointStatusAvailable,
+			connectors: []string{domain.ChargePointStatusCharging, domain.ChargePointStatusAvailable},
+			want:       domain.ChargePointStatusAvailable,
+		},
+		{
+			name:       "all connectors in use",
+			status:     domain.ChargePointStatusAvailable,
+			connectors: []string{domain.ChargePointStatusCharging, domain.ChargePointStatusPreparing},
+			want:       domain.ChargePointStatusOccupied,
+		},
+		{
+			name:       "in use or out of order",
+			status:     domain.ChargePointStatusAvailable,
+			connectors: []string{domain.ChargePointStatusCharging, domain.ChargePointStatusFaulted},
+			want:       domain.ChargePointStatusOccupied,
+		},
+		{
+			name:       "all connectors faulted",
+			status:     domain.ChargePointStatusAvailable,
+			connectors: []string{domain.ChargePointStatusFaulted, domain.ChargePointStatusFaulted},
+			want:       domain.ChargePointStatusFaulted,
+		},
+		{
+			name:       "connectors faulted or unavailable",
+			status:     domain.ChargePointStatusAvailable,
+			connectors: []string{domain.ChargePointStatusFaulted, domain.ChargePointStatusUnavailable},
+			want:       domain.ChargePointStatusUnavailable,
+		},
+		{
+			name:       "charge point faulted",
+			status:     domain.ChargePointStatusFaulted,
+			connectors: []string{domain.ChargePointStatusAvailable},
+			want:       domain.ChargePointStatusFaulted,
+		},
+		{
+			name:       "charge point unavailable",
+			status:     domain.ChargePointStatusUnavailable,
+			connectors: []string{domain.ChargePointStatusAvailable, domain.ChargePointStatusCharging},
+			want:       domain.ChargePointStatusUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connectors := make([]domain.Connector, 0, len(tt.connectors))
+			for i, status := range tt.connectors {
+				connectors = append(connectors, domain.Connector{ConnectorID: i + 1, Status: status})
+			}
+
+			if got := stationStatus(tt.status, connectors); got != tt.want {
+				t.Errorf("stationStatus = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,47 +2,243 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"log"
+	"slices"
+	"time"
 
 	"github.com/malikkhoiri/csms/internal/domain"
 )
 
 // ConnectorService implements domain.ConnectorService
 type ConnectorService struct {
-	connectorRepo domain.ConnectorRepository
+	connectorRepo   domain.ConnectorRepository
+	chargePointRepo domain.ChargePointRepository
+	errorRepo       domain.ConnectorErrorRepository
+	historyRepo     domain.ConnectorStatusChangeRepository
+	unitOfWork      domain.UnitOfWork
+	events          domain.EventBus
+	notifier        domain.NotificationService
 }
 
 // NewConnectorService creates a new connector service
-func NewConnectorService(connectorRepo domain.ConnectorRepository) domain.ConnectorService {
+func NewConnectorService(
+	connectorRepo domain.ConnectorRepository,
+	chargePointRepo domain.ChargePointRepository,
+	errorRepo domain.ConnectorErrorRepository,
+	historyRepo domain.ConnectorStatusChangeRepository,
+	unitOfWork domain.UnitOfWork,
+	eventBus domain.EventBus,
+	notifier domain.NotificationService,
+) domain.ConnectorService {
 	return &ConnectorService{
-		connectorRepo: connectorRepo,
+		connectorRepo:   connectorRepo,
+		chargePointRepo: chargePointRepo,
+		errorRepo:       errorRepo,
+		historyRepo:     historyRepo,
+		unitOfWork:      unitOfWork,
+		events:          eventBus,
+		notifier:        notifier,
 	}
 }
 
-// UpdateConnectorStatus updates the status of a connector
+// UpdateConnectorStatus updates the status of a connector, or of the charge
+// point itself for connector 0
 func (s *ConnectorService) UpdateConnectorStatus(ctx context.Context, request *domain.StatusNotificationRequest, chargePointID uint) error {
+	if request.ConnectorId == 0 {
+		return s.updateStationStatus(ctx, request, chargePointID)
+	}
+	if !slices.Contains(domain.ChargePointStatuses, request.Status) {
+		return fmt.Errorf("invalid connector status %q", request.Status)
+	}
+
+	now := time.Now()
 	connector, err := s.connectorRepo.GetByChargePointAndConnectorID(ctx, chargePointID, request.ConnectorId)
 	if err != nil {
 		// Create new connector if it doesn't exist
 		connector = &domain.Connector{
 			ChargePointID:   chargePointID,
 			ConnectorID:     request.ConnectorId,
+			PublicCode:      newPublicCode(),
 			Status:          request.Status,
 			ErrorCode:       request.ErrorCode,
 			Info:            request.Info,
 			VendorID:        request.VendorId,
 			VendorErrorCode: request.VendorErrorCode,
+			StatusChangedAt: &now,
 		}
-		return s.connectorRepo.Create(ctx, connector)
+		err := s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+			if err := s.connectorRepo.Create(ctx, connector); err != nil {
+				return err
+			}
+			if err := s.recordError(ctx, chargePointID, request, ""); err != nil {
+				return err
+			}
+			if err := s.recordChange(ctx, chargePointID, "", request, now); err != nil {
+				return err
+			}
+			if err := s.publishStatus(ctx, connector, ""); err != nil {
+				return err
+			}
+			return s.refreshStation(ctx, chargePointID)
+		})
+		if err != nil {
+			return err
+		}
+		s.notifyStatus(ctx, connector)
+		return nil
 	}
 
 	// Update existing connector
+	previousStatus, previousErrorCode := connector.Status, connector.ErrorCode
 	connector.Status = request.Status
 	connector.ErrorCode = request.ErrorCode
 	connector.Info = request.Info
 	connector.VendorID = request.VendorId
 	connector.VendorErrorCode = request.VendorErrorCode
+	if connector.Status != previousStatus || connector.StatusChangedAt == nil {
+		connector.StatusChangedAt = &now
+	}
 
-	return s.connectorRepo.Update(ctx, connector)
+	err = s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		if err := s.connectorRepo.Update(ctx, connector); err != nil {
+			return err
+		}
+		if err := s.recordError(ctx, chargePointID, request, previousErrorCode); err != nil {
+			return err
+		}
+		// Stations repeat their status, e.g. after reconnecting; only
+		// changes are events.
+		if connector.Status == previousStatus && connector.ErrorCode == previousErrorCode {
+			return nil
+		}
+		if err := s.recordChange(ctx, chargePointID, previousStatus, request, now); err != nil {
+			return err
+		}
+		if err := s.publishStatus(ctx, connector, previousStatus); err != nil {
+			return err
+		}
+		if connector.Status == previousStatus {
+			return nil
+		}
+		return s.refreshStation(ctx, chargePointID)
+	})
+	if err != nil {
+		return err
+	}
+	s.notifyStatus(ctx, connector)
+	return nil
+}
+
+// updateStationStatus sets the charge point's own status and error code from
+// a StatusNotification for connector 0, keeping its errors and history like
+// a connector's.
+func (s *ConnectorService) updateStationStatus(ctx context.Context, request *domain.StatusNotificationRequest, chargePointID uint) error {
+	if !slices.Contains(domain.StationStatuses, request.Status) {
+		return fmt.Errorf("invalid charge point status %q", request.Status)
+	}
+
+	chargePoint, err := s.chargePointRepo.GetByID(ctx, chargePointID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	previousStatus, previousErrorCode := chargePoint.Status, chargePoint.ErrorCode
+	return s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		if err := setStationStatus(ctx, s.chargePointRepo, s.connectorRepo, s.events, chargePoint, request.Status); err != nil {
+			return err
+		}
+		if request.ErrorCode != previousErrorCode {
+			if err := s.chargePointRepo.UpdateErrorCode(ctx, chargePointID, request.ErrorCode); err != nil {
+				return err
+			}
+		}
+		if err := s.recordError(ctx, chargePointID, request, previousErrorCode); err != nil {
+			return err
+		}
+		if request.Status == previousStatus && request.ErrorCode == previousErrorCode {
+			return nil
+		}
+		return s.recordChange(ctx, chargePointID, previousStatus, request, now)
+	})
+}
+
+// refreshStation derives the charge point's aggregate status again after one
+// of its connectors changed status.
+func (s *ConnectorService) refreshStation(ctx context.Context, chargePointID uint) error {
+	chargePoint, err := s.chargePointRepo.GetByID(ctx, chargePointID)
+	if err != nil {
+		return err
+	}
+	return setStationStatus(ctx, s.chargePointRepo, s.connectorRepo, s.events, chargePoint, chargePoint.Status)
+}
+
+// recordError keeps the error code the connector, or connector 0, started
+// reporting; repeats of the code it already had are not new errors.
+func (s *ConnectorService) recordError(ctx context.Context, chargePointID uint, request *domain.StatusNotificationRequest, previousErrorCode string) error {
+	if request.ErrorCode == "" || request.ErrorCode == domain.ConnectorErrorCodeNoError || request.ErrorCode == previousErrorCode {
+		return nil
+	}
+
+	return s.errorRepo.Create(ctx, &domain.ConnectorError{
+		ChargePointID:   chargePointID,
+		ConnectorID:     request.ConnectorId,
+		ErrorCode:       request.ErrorCode,
+		VendorErrorCode: request.VendorErrorCode,
+		Info:            request.Info,
+		Status:          request.Status,
+	})
+}
+
+// recordChange adds the status or error code reported for a connector, or
+// connector 0, to its history.
+func (s *ConnectorService) recordChange(ctx context.Context, chargePointID uint, previousStatus string, request *domain.StatusNotificationRequest, changedAt time.Time) error {
+	change := &domain.ConnectorStatusChange{
+		ChargePointID:   chargePointID,
+		ConnectorID:     request.ConnectorId,
+		FromStatus:      previousStatus,
+		ToStatus:        request.Status,
+		ErrorCode:       request.ErrorCode,
+		Info:            request.Info,
+		VendorID:        request.VendorId,
+		VendorErrorCode: request.VendorErrorCode,
+		ChangedAt:       changedAt,
+	}
+	if !request.Timestamp.IsZero() {
+		change.ReportedAt = &request.Timestamp
+	}
+	return s.historyRepo.Create(ctx, change)
+}
+
+// publishStatus records the status change of the connector and, when it
+// just became Faulted, the fault.
+func (s *ConnectorService) publishStatus(ctx context.Context, connector *domain.Connector, previousStatus string) error {
+	data := &domain.ConnectorEventData{
+		ChargePointID:   connector.ChargePointID,
+		ConnectorID:     connector.ConnectorID,
+		Status:          connector.Status,
+		PreviousStatus:  previousStatus,
+		ErrorCode:       connector.ErrorCode,
+		Info:            connector.Info,
+		VendorErrorCode: connector.VendorErrorCode,
+	}
+	if err := s.events.Publish(ctx, domain.EventConnectorStatusChanged, connector.ChargePointID, data); err != nil {
+		return err
+	}
+
+	if connector.Status == domain.ChargePointStatusFaulted && previousStatus != domain.ChargePointStatusFaulted {
+		return s.events.Publish(ctx, domain.EventConnectorFaulted, connector.ChargePointID, data)
+	}
+	return nil
+}
+
+// notifyStatus reports a connector status change; a failing notifier does
+// not fail the StatusNotification.
+func (s *ConnectorService) notifyStatus(ctx context.Context, connector *domain.Connector) {
+	if err := s.notifier.SendStatusNotification(ctx, connector.ChargePointID, connector.ConnectorID, connector.Status); err != nil {
+		log.Printf("Error sending status notification for charge point %d connector %d: %v", connector.ChargePointID, connector.ConnectorID, err)
+	}
 }
 
 // GetConnector gets a connector by ID
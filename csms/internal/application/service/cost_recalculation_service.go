@@ -0,0 +1,260 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+type CostRecalculationService struct {
+	transactionRepo domain.TransactionRepository
+	adjustmentRepo  domain.TransactionAdjustmentRepository
+	tariffRepo      domain.TariffRepository
+	walletRepo      domain.WalletRepository
+	tariffService   domain.TariffService
+	paymentService  domain.PaymentService
+	unitOfWork      domain.UnitOfWork
+}
+
+func NewCostRecalculationService(
+	transactionRepo domain.TransactionRepository,
+	adjustmentRepo domain.TransactionAdjustmentRepository,
+	tariffRepo domain.TariffRepository,
+	walletRepo domain.WalletRepository,
+	tariffService domain.TariffService,
+	paymentService domain.PaymentService,
+	unitOfWork domain.UnitOfWork,
+) domain.CostRecalculationService {
+	return &CostRecalculationService{
+		transactionRepo: transactionRepo,
+		adjustmentRepo:  adjustmentRepo,
+		tariffRepo:      tariffRepo,
+		walletRepo:      walletRepo,
+		tariffService:   tariffService,
+		paymentService:  paymentService,
+		unitOfWork:      unitOfWork,
+	}
+}
+
+// RecalculateCosts reprices completed transactions with the requested tariff
+// version. Without Apply it only reports what would change; with Apply every
+// changed transaction is updated and an adjustment keeping its original
+// figures is recorded.
+//
+// The difference on sessions already paid is booked the way they were paid:
+// the wallet is charged or credited it, and a card payment is partially
+// refunded or captured further. A paid session cannot be repriced in
+// another currency.
+func (s *CostRecalculationService) RecalculateCosts(ctx context.Context, request *domain.RecalculationRequest, userID *uint) (*domain.RecalculationReport, error) {
+	if isEmptyFilter(request.Filter) {
+		return nil, errors.New("filter is required, e.g. ids, chargePointId, site, userGroup or from/to")
+	}
+	if request.Apply && request.Reason == "" {
+		return nil, errors.New("reason is required to apply a recalculation")
+	}
+
+	tariff, err := s.tariffRepo.GetByID(ctx, request.TariffID)
+	if err != nil {
+		return nil, errors.New("tariff not found")
+	}
+
+	transactions, err := s.transactionRepo.ListCompleted(ctx, request.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &domain.RecalculationReport{
+		TariffID:        tariff.ID,
+		Applied:         request.Apply,
+		Count:           len(transactions),
+		TotalDifference: map[string]int64{},
+		Items:           []domain.RecalculationItem{},
+	}
+	if request.Apply {
+		report.BatchID = fmt.Sprintf("RC-%s", time.Now().UTC().Format("20060102150405.000"))
+	}
+
+	for i := range transactions {
+		original := transactions[i]
+
+		recalculated := original
+		recalculated.TariffID = &tariff.ID
+		recalculated.EnergyConsumed = (original.StopMeterValue - original.StartMeterValue) / 1000
+
+		stopTime := original.StartTime
+		if original.StopTime != nil {
+			stopTime = *original.StopTime
+		}
+
+		breakdown, err := s.tariffService.PriceTransaction(ctx, &recalculated, stopTime)
+		if err != nil {
+			return nil, fmt.Errorf("pricing transaction %d: %w", original.ID, err)
+		}
+
+		setTransactionPrice(&recalculated, breakdown)
+
+		originalCurrency := original.Currency
+		if originalCurrency == "" {
+			originalCurrency = recalculated.Currency
+		}
+		item := domain.RecalculationItem{
+			TransactionID:          original.ID,
+			OCPPTransactionID:      original.TransactionID,
+			OriginalEnergyConsumed: original.EnergyConsumed,
+			EnergyConsumed:         recalculated.EnergyConsumed,
+			OriginalCurrency:       originalCurrency,
+			OriginalGrossAmount:    original.GrossAmount,
+			Currency:               recalculated.Currency,
+			GrossAmount:            recalculated.GrossAmount,
+		}
+		if originalCurrency == recalculated.Currency {
+			item.Difference = recalculated.GrossAmount - original.GrossAmount
+		}
+
+		if !costChanged(&original, &recalculated) {
+			report.Items = append(report.Items, item)
+			continue
+		}
+
+		item.SettledBy, err = s.settledBy(ctx, &original)
+		if err != nil {
+			return report, err
+		}
+		if item.SettledBy != "" {
+			if original.Currency != "" && original.Currency != recalculated.Currency {
+				return nil, fmt.Errorf("transaction %d was paid in %s and cannot be repriced in %s",
+					original.ID, original.Currency, recalculated.Currency)
+			}
+			report.Settled++
+		}
+		report.Items = append(report.Items, item)
+
+		report.Changed++
+		report.TotalDifference[recalculated.Currency] += recalculated.GrossAmount
+		report.TotalDifference[originalCurrency] -= original.GrossAmount
+
+		if !request.Apply {
+			continue
+		}
+
+		adjustment := &domain.TransactionAdjustment{
+			TransactionID:          original.ID,
+			BatchID:                report.BatchID,
+			TariffID:               tariff.ID,
+			Currency:               recalculated.Currency,
+			OriginalTariffID:       original.TariffID,
+			OriginalCurrency:       original.Currency,
+			OriginalEnergyConsumed: original.EnergyConsumed,
+			OriginalEnergyCost:     original.EnergyCost,
+			OriginalTimeCost:       original.TimeCost,
+			OriginalFlatCost:       original.FlatCost,
+			OriginalIdleCost:       original.IdleCost,
+			OriginalNetAmount:      original.NetAmount,
+			OriginalTaxAmount:      original.TaxAmount,
+			OriginalGrossAmount:    original.GrossAmount,
+			EnergyConsumed:         recalculated.EnergyConsumed,
+			EnergyCost:             recalculated.EnergyCost,
+			TimeCost:               recalculated.TimeCost,
+			FlatCost:               recalculated.FlatCost,
+			IdleCost:               recalculated.IdleCost,
+			NetAmount:              recalculated.NetAmount,
+			TaxAmount:              recalculated.TaxAmount,
+			GrossAmount:            recalculated.GrossAmount,
+			Reason:                 request.Reason,
+			UserID:                 userID,
+		}
+		err = s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+			if err := s.adjustmentRepo.Apply(ctx, &recalculated, adjustment); err != nil {
+				return err
+			}
+			if item.SettledBy == domain.SettledByWallet {
+				return s.chargeWallet(ctx, &recalculated, report.BatchID)
+			}
+			return nil
+		})
+		if err != nil {
+			return report, fmt.Errorf("applying adjustment to transaction %d: %w", original.ID, err)
+		}
+		// The gateway is called once the adjustment is saved.
+		if item.SettledBy == domain.SettledByPayment {
+			if _, err := s.paymentService.CapturePayment(ctx, &recalculated); err != nil {
+				return report, fmt.Errorf("settling adjusted transaction %d with its payment: %w", original.ID, err)
+			}
+		}
+	}
+
+	if request.Apply {
+		differences := make([]string, 0, len(report.TotalDifference))
+		for currency, difference := range report.TotalDifference {
+			differences = append(differences, formatMoney(difference, currency))
+		}
+		sort.Strings(differences)
+		log.Printf("Recalculation %s applied tariff %d to %d of %d transactions, %d of them settled with their wallet or payment, difference %s",
+			report.BatchID, tariff.ID, report.Changed, report.Count, report.Settled, strings.Join(differences, ", "))
+	}
+
+	return report, nil
+}
+
+func (s *CostRecalculationService) ListAdjustments(ctx context.Context, transactionID uint) ([]domain.TransactionAdjustment, error) {
+	return s.adjustmentRepo.ListByTransaction(ctx, transactionID)
+}
+
+// settledBy tells how the transaction was already paid for, if it was.
+func (s *CostRecalculationService) settledBy(ctx context.Context, transaction *domain.Transaction) (string, error) {
+	if transaction.Payment != nil && transaction.Payment.CapturedAmount > 0 {
+		return domain.SettledByPayment, nil
+	}
+
+	charged, err := s.walletRepo.ChargedAmount(ctx, transaction.ID)
+	if err != nil {
+		return "", err
+	}
+	if charged != 0 {
+		return domain.SettledByWallet, nil
+	}
+	return "", nil
+}
+
+// chargeWallet books the difference of an adjusted transaction on the wallet
+// it was charged to.
+func (s *CostRecalculationService) chargeWallet(ctx context.Context, transaction *domain.Transaction, batchID string) error {
+	wallet, err := s.walletRepo.GetByUserID(ctx, transaction.IDTag.UserID)
+	if err != nil {
+		return errors.New("wallet not found")
+	}
+	if wallet.Currency != transaction.Currency {
+		return fmt.Errorf("wallet %d is in %s, transaction in %s", wallet.ID, wallet.Currency, transaction.Currency)
+	}
+
+	description := fmt.Sprintf("Charging session %d, recalculated in %s", transaction.TransactionID, batchID)
+	if _, err := s.walletRepo.ChargeTransaction(ctx, wallet.ID, transaction.ID, transaction.GrossAmount, description); err != nil {
+		return fmt.Errorf("charging wallet %d: %w", wallet.ID, err)
+	}
+	return nil
+}
+
+func isEmptyFilter(filter domain.TransactionFilter) bool {
+	return len(filter.IDs) == 0 && filter.ChargePointID == nil && filter.Site == "" &&
+		filter.UserGroup == "" && filter.From == nil && filter.To == nil
+}
+
+func costChanged(original, recalculated *domain.Transaction) bool {
+	return math.Abs(original.EnergyConsumed-recalculated.EnergyConsumed) > 1e-9 ||
+		original.Currency != recalculated.Currency ||
+		original.EnergyCost != recalculated.EnergyCost ||
+		original.TimeCost != recalculated.TimeCost ||
+		original.FlatCost != recalculated.FlatCost ||
+		original.IdleCost != recalculated.IdleCost ||
+		original.NetAmount != recalculated.NetAmount ||
+		original.TaxAmount != recalculated.TaxAmount ||
+		original.GrossAmount != recalculated.GrossAmount ||
+		original.TariffID == nil || *original.TariffID != *recalculated.TariffID
+}
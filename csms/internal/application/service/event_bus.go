@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/config"
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+// EventBus records domain events in the outbox and dispatches them to the
+// in-process subscribers and external sinks. An event is only marked
+// dispatched once every one of them took it, so a failure redelivers it to
+// all of them.
+type EventBus struct {
+	eventRepo domain.EventRepository
+	sinks     []domain.EventSink
+	config    config.EventsConfig
+
+	mu          sync.RWMutex
+	subscribers map[string][]domain.EventHandler
+}
+
+func NewEventBus(eventRepo domain.EventRepository, sinks []domain.EventSink, eventsConfig config.EventsConfig) domain.EventBus {
+	return &EventBus{
+		eventRepo:   eventRepo,
+		sinks:       sinks,
+		config:      eventsConfig,
+		subscribers: make(map[string][]domain.EventHandler),
+	}
+}
+
+func (b *EventBus) Publish(ctx context.Context, eventType string, chargePointID uint, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	event := &domain.Event{
+		Type:          eventType,
+		Data:          payload,
+		Status:        domain.EventStatusPending,
+		NextAttemptAt: time.Now(),
+	}
+	if chargePointID != 0 {
+		event.ChargePointID = &chargePointID
+	}
+	return b.eventRepo.Create(ctx, event)
+}
+
+func (b *EventBus) Subscribe(eventType string, handler domain.EventHandler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.subscribers[eventType] = append(b.subscribers[eventType], handler)
+}
+
+func (b *EventBus) Dispatch(ctx context.Context) (int, error) {
+	now := time.Now()
+	events, err := b.eventRepo.ClaimDue(ctx, now, b.config.BatchSize, b.config.Lease)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range events {
+		event := &events[i]
+		if err := b.deliver(ctx, event); err != nil {
+			b.retry(ctx, event, err)
+			continue
+		}
+		if err := b.eventRepo.MarkDispatched(ctx, event.ID, time.Now()); err != nil {
+			log.Printf("Error marking event %d dispatched: %v", event.ID, err)
+		}
+	}
+	return len(events), nil
+}
+
+// deliver hands the event to its subscribers and every sink.
+func (b *EventBus) deliver(ctx context.Context, event *domain.Event) error {
+	b.mu.RLock()
+	handlers := append(append([]domain.EventHandler{}, b.subscribers[event.Type]...), b.subscribers["*"]...)
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, handler := range handlers {
+		if err := handler(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, sink := range b.sinks {
+		if err := sink.Deliver(ctx, event); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sink.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// retry schedules the next attempt with exponential backoff, or gives the
+// event up once it ran out of attempts.
+func (b *EventBus) retry(ctx context.Context, event *domain.Event, cause error) {
+	event.Attempts++
+	event.LastError = cause.Error()
+
+	if b.config.MaxAttempts > 0 && event.Attempts >= b.config.MaxAttempts {
+		event.Status = domain.EventStatusFailed
+		log.Printf("Giving up %s event %d after %d attempts: %v", event.Type, event.ID, event.Attempts, cause)
+	} else {
+		backoff := retryBackoff(b.config.RetryBackoff, event.Attempts)
+		event.NextAttemptAt = time.Now().Add(backoff)
+		log.Printf("Error dispatching %s event %d, retrying in %s: %v", event.Type, event.ID, backoff, cause)
+	}
+
+	if err := b.eventRepo.Reschedule(ctx, event); err != nil {
+		log.Printf("Error rescheduling event %d: %v", event.ID, err)
+	}
+}
+
+// maxRetryBackoff caps the exponential backoff between attempts.
+const maxRetryBackoff = time.Hour
+
+// retryBackoff returns the delay after the given number of failed attempts:
+// base, doubled for every further attempt.
+func retryBackoff(base time.Duration, attempts int) time.Duration {
+	backoff := base << (attempts - 1)
+	if backoff <= 0 || backoff > maxRetryBackoff {
+		return maxRetryBackoff
+	}
+	return backoff
+}
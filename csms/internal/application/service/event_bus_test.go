@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/config"
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+// fakeEventRepo keeps the outbox in memory and claims events the way the
+// database does: pending and due, oldest first, held for the lease.
+type fakeEventRepo struct {
+	domain.EventRepository
+
+	mu     sync.Mutex
+	events map[uint]*domain.Event
+}
+
+func newFakeEventRepo(events ...domain.Event) *fakeEventRepo {
+	r := &fakeEventRepo{events: make(map[uint]*domain.Event)}
+	for i := range events {
+		r.events[events[i].ID] = &events[i]
+	}
+	return r
+}
+
+func (r *fakeEventRepo) ClaimDue(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]domain.Event, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var claimed []domain.Event
+	for _, event := range r.events {
+		if event.Status == domain.EventStatusPending && !event.NextAttemptAt.After(now) {
+			claimed = append(claimed, *event)
+		}
+	}
+	sort.Slice(claimed, func(i, j int) bool { return claimed[i].ID < claimed[j].ID })
+	if len(claimed) > limit {
+		claimed = claimed[:limit]
+	}
+	for _, event := range claimed {
+		r.events[event.ID].NextAttemptAt = now.Add(lease)
+	}
+	return claimed, nil
+}
+
+func (r *fakeEventRepo) MarkDispatched(ctx context.Context, id uint, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events[id].Status = domain.EventStatusDispatched
+	r.events[id].LastError = ""
+	return nil
+}
+
+func (r *fakeEventRepo) Reschedule(ctx context.Context, event *domain.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := r.events[event.ID]
+	stored.Status = event.Status
+	stored.Attempts = event.Attempts
+	stored.NextAttemptAt = event.NextAttemptAt
+	stored.LastError = event.LastError
+	return nil
+}
+
+func (r *fakeEventRepo) get(id uint) domain.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return *r.events[id]
+}
+
+type fakeEventSink struct {
+	err       error
+	delivered int
+}
+
+func (s *fakeEventSink) Name() string { return "fake" }
+
+func (s *fakeEventSink) Deliver(ctx context.Context, event *domain.Event) error {
+	s.delivered++
+	return s.err
+}
+
+var testEventsConfig = config.EventsConfig{
+	BatchSize:    10,
+	Lease:        time.Minute,
+	RetryBackoff: time.Second,
+	MaxAttempts:  3,
+}
+
+func TestEventBusDispatch(t *testing.T) {
+	failure := errors.New("unavailable")
+
+	tests := []struct {
+		name         string
+		attempts     int
+		handlerErr   error
+		sinkErr      error
+		wantStatus   string
+		wantAttempts int
+		wantBackoff  time.Duration
+	}{
+		{name: "delivered", wantStatus: domain.EventStatusDispatched},
+		{name: "handler fails", handlerErr: failure, wantStatus: domain.EventStatusPending, wantAttempts: 1, wantBackoff: time.Second},
+		{name: "sink fails", sinkErr: failure, wantStatus: domain.EventStatusPending, wantAttempts: 1, wantBackoff: time.Second},
+		{name: "second failure backs off longer", attempts: 1, handlerErr: failure, wantStatus: domain.EventStatusPending, wantAttempts: 2, wantBackoff: 2 * time.Second},
+		{name: "last attempt fails", attempts: 2, sinkErr: failure, wantStatus: domain.EventStatusFailed, wantAttempts: 3},
+		{name: "delivered on retry", attempts: 2, wantStatus: domain.EventStatusDispatched, wantAttempts: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeEventRepo(domain.Event{
+				ID:       1,
+				Type:     domain.EventTransactionStopped,
+				Status:   domain.EventStatusPending,
+				Attempts: tt.attempts,
+			})
+			sink := &fakeEventSink{err: tt.sinkErr}
+			bus := NewEventBus(repo, []domain.EventSink{sink}, testEventsConfig)
+
+			handled := 0
+			bus.Subscribe(domain.EventTransactionStopped, func(ctx context.Context, event *domain.Event) error {
+				handled++
+				return tt.handlerErr
+			})
+
+			before := time.Now()
+			count, err := bus.Dispatch(context.Background())
+			if err != nil {
+				t.Fatalf("Dispatch: %v", err)
+			}
+			if count != 1 {
+				t.Fatalf("dispatched %d events, want 1", count)
+			}
+			// A failure on one side still delivers to the other.
+			if handled != 1 || sink.delivered != 1 {
+				t.Errorf("handled %d, delivered to sink %d, want 1 each", handled, sink.delivered)
+			}
+
+			event := repo.get(1)
+			if event.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", event.Status, tt.wantStatus)
+			}
+			if event.Attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", event.Attempts, tt.wantAttempts)
+			}
+			if tt.wantStatus != domain.EventStatusDispatched && event.LastError == "" {
+				t.Error("last error not recorded")
+			}
+			if tt.wantBackoff > 0 {
+				if next := event.NextAttemptAt.Sub(before); next < tt.wantBackoff || next > tt.wantBackoff+time.Second {
+					t.Errorf("next attempt in %s, want %s", next, tt.wantBackoff)
+				}
+			}
+		})
+	}
+}
+
+func TestEventBusLease(t *testing.T) {
+	repo := newFakeEventRepo(
+		domain.Event{ID: 1, Type: domain.EventTransactionStopped, Status: domain.EventStatusPending},
+		domain.Event{ID: 2, Type: domain.EventTransactionStopped, Status: domain.EventStatusPending},
+	)
+	bus := NewEventBus(repo, nil, testEventsConfig)
+
+	var handled []uint
+	bus.Subscribe(domain.EventTransactionStopped, func(ctx context.Context, event *domain.Event) error {
+		handled = append(handled, event.ID)
+		return nil
+	})
+
+	// Another instance claimed event 1 and stopped before dispatching it.
+	if _, err := repo.ClaimDue(context.Background(), time.Now(), 1, testEventsConfig.Lease); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := bus.Dispatch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(handled) != 1 || handled[0] != 2 {
+		t.Fatalf("handled %v while event 1 is leased, want [2]", handled)
+	}
+
+	// Once the lease ran out the event is dispatched here.
+	repo.events[1].NextAttemptAt = time.Now().Add(-time.Second)
+	if _, err := bus.Dispatch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(handled) != 2 || handled[1] != 1 {
+		t.Errorf("handled %v after the lease ran out, want [2 1]", handled)
+	}
+	if repo.get(1).Status != domain.EventStatusDispatched {
+		t.Errorf("event 1 is %s, want %s", repo.get(1).Status, domain.EventStatusDispatched)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	tests := []struct {
+		base     time.Duration
+		attempts int
+		want     time.Duration
+	}{
+		{base: 10 * time.Second, attempts: 1, want: 10 * time.Second},
+		{base: 10 * time.Second, attempts: 2, want: 20 * time.Second},
+		{base: 10 * time.Second, attempts: 4, want: 80 * time.Second},
+		{base: 10 * time.Second, attempts: 20, want: maxRetryBackoff},
+		{base: 10 * time.Second, attempts: 70, want: maxRetryBackoff},
+	}
+
+	for _, tt := range tests {
+		if got := retryBackoff(tt.base, tt.attempts); got != tt.want {
+			t.Errorf("retryBackoff(%s, %d) = %s, want %s", tt.base, tt.attempts, got, tt.want)
+		}
+	}
+}
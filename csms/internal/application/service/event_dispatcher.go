@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+// EventDispatcher delivers the events recorded in the outbox.
+type EventDispatcher struct {
+	eventBus domain.EventBus
+	interval time.Duration
+}
+
+// NewEventDispatcher creates a new event dispatcher
+func NewEventDispatcher(eventBus domain.EventBus, interval time.Duration) *EventDispatcher {
+	return &EventDispatcher{
+		eventBus: eventBus,
+		interval: interval,
+	}
+}
+
+// Run dispatches due events on every tick until the context is cancelled.
+// While there are due events it keeps going without waiting for the tick.
+func (d *EventDispatcher) Run(ctx context.Context) {
+	if d.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for ctx.Err() == nil {
+				handled, err := d.eventBus.Dispatch(ctx)
+				if err != nil {
+					log.Printf("Error dispatching events: %v", err)
+				}
+				if err != nil || handled == 0 {
+					break
+				}
+			}
+		}
+	}
+}
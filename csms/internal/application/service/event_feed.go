@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+// eventFeedPage is how many events a subscriber reads at a time.
+const eventFeedPage = 100
+
+// EventFeed streams events from the outbox, so subscribers see the events
+// of every instance and can resume from any event still kept. One poller
+// watches for new events and wakes the subscribers, which then read what
+// they are interested in.
+//
+// IDs are drawn when an event is recorded but become visible when its
+// transaction commits, so an event may show up after one with a higher ID.
+// Events are therefore only read once they are older than the lag, by when
+// the transactions recording them are expected to have committed.
+type EventFeed struct {
+	eventRepo domain.EventRepository
+	interval  time.Duration
+	lag       time.Duration
+
+	mu      sync.Mutex
+	lastID  uint
+	changed chan struct{}
+}
+
+// NewEventFeed creates a new event feed
+func NewEventFeed(eventRepo domain.EventRepository, interval, lag time.Duration) *EventFeed {
+	return &EventFeed{
+		eventRepo: eventRepo,
+		interval:  interval,
+		lag:       lag,
+		changed:   make(chan struct{}),
+	}
+}
+
+// Run looks for new events on every tick until the context is cancelled
+func (f *EventFeed) Run(ctx context.Context) {
+	if f.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			lastID, err := f.eventRepo.LastID(ctx, f.until())
+			if err != nil {
+				log.Printf("Error polling events: %v", err)
+				continue
+			}
+
+			f.mu.Lock()
+			if lastID > f.lastID {
+				f.lastID = lastID
+				close(f.changed)
+				f.changed = make(chan struct{})
+			}
+			f.mu.Unlock()
+		}
+	}
+}
+
+func (f *EventFeed) SubscribeEvents(ctx context.Context, filter domain.EventFilter, lastEventID uint) (<-chan domain.Event, error) {
+	if lastEventID == 0 {
+		var err error
+		if lastEventID, err = f.eventRepo.LastID(ctx, f.until()); err != nil {
+			return nil, err
+		}
+	}
+
+	events := make(chan domain.Event, eventFeedPage)
+	go func() {
+		defer close(events)
+
+		cursor := lastEventID
+		for {
+			// Taken before reading so an event recorded meanwhile still
+			// wakes us.
+			f.mu.Lock()
+			changed := f.changed
+			f.mu.Unlock()
+
+			page, err := f.eventRepo.ListAfter(ctx, cursor, f.until(), filter, eventFeedPage)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("Error reading events after %d: %v", cursor, err)
+			}
+			for _, event := range page {
+				select {
+				case <-ctx.Done():
+					return
+				case events <- event:
+					cursor = event.ID
+				}
+			}
+			if len(page) == eventFeedPage {
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-changed:
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// until is the time up to which events are read.
+func (f *EventFeed) until() time.Time {
+	return time.Now().Add(-f.lag)
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+// EventRetentionJob deletes the events kept longer than the retention. Events
+// still pending are kept until they are dispatched or given up.
+type EventRetentionJob struct {
+	eventRepo domain.EventRepository
+	retention time.Duration
+	interval  time.Duration
+}
+
+// NewEventRetentionJob creates a new event retention job
+func NewEventRetentionJob(eventRepo domain.EventRepository, retention, interval time.Duration) *EventRetentionJob {
+	return &EventRetentionJob{
+		eventRepo: eventRepo,
+		retention: retention,
+		interval:  interval,
+	}
+}
+
+// Run deletes the expired events on every tick until the context is
+// cancelled
+func (j *EventRetentionJob) Run(ctx context.Context) {
+	if j.retention <= 0 || j.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := j.eventRepo.DeleteBefore(ctx, time.Now().Add(-j.retention))
+			if err != nil {
+				log.Printf("Error deleting expired events: %v", err)
+				continue
+			}
+			if deleted > 0 {
+				log.Printf("Deleted %d expired events", deleted)
+			}
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/config"
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+type GuestService struct {
+	guestSessionRepo domain.GuestSessionRepository
+	connectorRepo    domain.ConnectorRepository
+	transactionRepo  domain.TransactionRepository
+	idTagRepo        domain.IDTagRepository
+	userRepo         domain.UserRepository
+	paymentService   domain.PaymentService
+	invoiceService   domain.InvoiceService
+	commander        domain.ChargePointCommander
+	tariffConfig     config.TariffConfig
+	paymentConfig    config.PaymentConfig
+	guestConfig      config.GuestConfig
+}
+
+func NewGuestService(
+	guestSessionRepo domain.GuestSessionRepository,
+	connectorRepo domain.ConnectorRepository,
+	transactionRepo domain.TransactionRepository,
+	idTagRepo domain.IDTagRepository,
+	userRepo domain.UserRepository,
+	paymentService domain.PaymentService,
+	invoiceService domain.InvoiceService,
+	commander domain.ChargePointCommander,
+	tariffConfig config.TariffConfig,
+	paymentConfig config.PaymentConfig,
+	guestConfig config.GuestConfig,
+) domain.GuestService {
+	return &GuestService{
+		guestSessionRepo: guestSessionRepo,
+		connectorRepo:    connectorRepo,
+		transactionRepo:  transactionRepo,
+		idTagRepo:        idTagRepo,
+		userRepo:         userRepo,
+		paymentService:   paymentService,
+		invoiceService:   invoiceService,
+		commander:        commander,
+		tariffConfig:     tariffConfig,
+		paymentConfig:    paymentConfig,
+		guestConfig:      guestConfig,
+	}
+}
+
+// GetConnectorLink returns the public QR URL of a connector, assigning its
+// public code on first request for connectors created before codes existed.
+func (s *GuestService) GetConnectorLink(ctx context.Context, connectorID uint) (*domain.ConnectorLink, error) {
+	connector, err := s.connectorRepo.GetByID(ctx, connectorID)
+	if err != nil {
+		return nil, errors.New("connector not found")
+	}
+
+	if connector.PublicCode == nil {
+		connector.PublicCode = newPublicCode()
+		if connector.PublicCode == nil {
+			return nil, errors.New("failed to generate public code")
+		}
+		if err := s.connectorRepo.Update(ctx, connector); err != nil {
+			return nil, err
+		}
+	}
+
+	return &domain.ConnectorLink{
+		ConnectorID: connector.ID,
+		PublicCode:  *connector.PublicCode,
+		URL:         strings.TrimRight(s.guestConfig.PublicBaseURL, "/") + "/charge/" + *connector.PublicCode,
+	}, nil
+}
+
+func (s *GuestService) GetConnector(ctx context.Context, publicCode string) (*domain.GuestConnector, error) {
+	connector, err := s.connectorRepo.GetByPublicCode(ctx, publicCode)
+	if err != nil {
+		return nil, errors.New("connector not found")
+	}
+
+	return &domain.GuestConnector{
+		PublicCode:      publicCode,
+		ChargePointCode: connector.ChargePoint.ChargePointCode,
+		ConnectorID:     connector.ConnectorID,
+		Status:          connector.Status,
+		Site:            connector.ChargePoint.Site,
+		Currency:        strings.ToUpper(s.tariffConfig.Currency),
+		PreAuthAmount:   s.paymentConfig.PreAuthAmount,
+	}, nil
+}
+
+// StartSession creates a virtual idTag for the guest, pre-authorizes the card
+// and starts the connector. The returned status carries the token the guest
+// uses for all further requests.
+func (s *GuestService) StartSession(ctx context.Context, publicCode string, request *domain.GuestSessionRequest) (*domain.GuestSessionStatus, error) {
+	connector, err := s.connectorRepo.GetByPublicCode(ctx, publicCode)
+	if err != nil {
+		return nil, errors.New("connector not found")
+	}
+	if connector.Status != domain.ChargePointStatusAvailable && connector.Status != domain.ChargePointStatusPreparing {
+		return nil, errors.New("connector is not available")
+	}
+
+	guest, err := s.guestUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tag, err := randomHex(9)
+	if err != nil {
+		return nil, err
+	}
+	idTag := &domain.IDTag{
+		Tag:        "G" + strings.ToUpper(tag),
+		Status:     domain.AuthorizeStatusAccepted,
+		ExpiryDate: time.Now().Add(s.guestConfig.TagTTL),
+		UserID:     guest.ID,
+		Source:     domain.IDTagSourceGuest,
+	}
+	if err := s.idTagRepo.Create(ctx, idTag); err != nil {
+		return nil, err
+	}
+
+	token, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+
+	payment, err := s.paymentService.StartPaidSession(ctx, &domain.PaidSessionRequest{
+		ChargePointID: connector.ChargePointID,
+		ConnectorID:   connector.ConnectorID,
+		IDTag:         idTag.Tag,
+		PaymentMethod: request.PaymentMethod,
+		Amount:        request.Amount,
+	}, &guest.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &domain.GuestSession{
+		Token:         token,
+		ChargePointID: connector.ChargePointID,
+		ConnectorID:   connector.ID,
+		IDTag:         idTag.Tag,
+		PaymentID:     payment.ID,
+		ExpiresAt:     time.Now().Add(s.guestConfig.SessionTTL),
+		Connector:     *connector,
+		Payment:       *payment,
+	}
+	if err := s.guestSessionRepo.Create(ctx, session); err != nil {
+		return nil, err
+	}
+
+	status, err := s.statusOf(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	status.Token = token
+	return status, nil
+}
+
+func (s *GuestService) GetSession(ctx context.Context, token string) (*domain.GuestSessionStatus, error) {
+	session, err := s.session(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.statusOf(ctx, session)
+}
+
+// StopSession asks the charge point to end the guest's running session. The
+// payment is captured once the StopTransaction arrives.
+func (s *GuestService) StopSession(ctx context.Context, token string) (*domain.GuestSessionStatus, error) {
+	session, err := s.session(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if session.Payment.TransactionID == nil {
+		return nil, errors.New("session has not started yet")
+	}
+
+	transaction, err := s.transactionRepo.GetByID(ctx, *session.Payment.TransactionID)
+	if err != nil {
+		return nil, errors.New("transaction not found")
+	}
+	if transaction.Status != domain.TransactionStatusActive {
+		return nil, errors.New("session is not running")
+	}
+
+	if err := s.commander.RemoteStopTransaction(ctx, transaction.ChargePointID, transaction.TransactionID); err != nil {
+		return nil, err
+	}
+
+	return s.statusOf(ctx, session)
+}
+
+// GetReceipt serves the receipt behind a one-time link; the link stops
+// working after the first successful request.
+func (s *GuestService) GetReceipt(ctx context.Context, receiptToken string) (*domain.Invoice, error) {
+	session, err := s.guestSessionRepo.GetByReceiptToken(ctx, receiptToken)
+	if err != nil || session.ReceiptViewedAt != nil || time.Now().After(session.ExpiresAt) {
+		return nil, errors.New("receipt link is invalid or already used")
+	}
+	if session.Payment.TransactionID == nil {
+		return nil, errors.New("receipt link is invalid or already used")
+	}
+
+	receipt, err := s.invoiceService.GetReceipt(ctx, *session.Payment.TransactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.guestSessionRepo.MarkReceiptViewed(ctx, session.ID, time.Now()); err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
+
+func (s *GuestService) session(ctx context.Context, token string) (*domain.GuestSession, error) {
+	session, err := s.guestSessionRepo.GetByToken(ctx, token)
+	if err != nil || time.Now().After(session.ExpiresAt) {
+		return nil, errors.New("session not found")
+	}
+	return session, nil
+}
+
+// statusOf describes the session's progress. Once the transaction completes
+// a receipt link is issued, until it has been used.
+func (s *GuestService) statusOf(ctx context.Context, session *domain.GuestSession) (*domain.GuestSessionStatus, error) {
+	status := &domain.GuestSessionStatus{
+		Status:           domain.GuestSessionStarting,
+		ChargePointCode:  session.Connector.ChargePoint.ChargePointCode,
+		ConnectorID:      session.Connector.ConnectorID,
+		PaymentStatus:    session.Payment.Status,
+		Currency:         session.Payment.Currency,
+		AuthorizedAmount: session.Payment.AuthorizedAmount,
+		ExpiresAt:        session.ExpiresAt,
+	}
+
+	if session.Payment.TransactionID == nil {
+		if session.Payment.Status != domain.PaymentStatusAuthorized {
+			status.Status = domain.GuestSessionFailed
+		}
+		return status, nil
+	}
+
+	transaction, err := s.transactionRepo.GetByID(ctx, *session.Payment.TransactionID)
+	if err != nil {
+		return nil, errors.New("transaction not found")
+	}
+
+	status.Status = domain.GuestSessionCharging
+	status.StartTime = &transaction.StartTime
+	status.StopTime = transaction.StopTime
+	status.EnergyConsumed = transaction.EnergyConsumed
+	status.GrossAmount = transaction.GrossAmount
+	if transaction.Payment != nil {
+		status.PaymentStatus = transaction.Payment.Status
+	}
+
+	if transaction.Status != domain.TransactionStatusCompleted {
+		return status, nil
+	}
+	status.Status = domain.GuestSessionCompleted
+
+	if session.ReceiptViewedAt != nil {
+		return status, nil
+	}
+	if session.ReceiptToken == nil {
+		receiptToken, err := randomHex(32)
+		if err != nil {
+			return nil, err
+		}
+		session.ReceiptToken = &receiptToken
+		if err := s.guestSessionRepo.Update(ctx, session); err != nil {
+			return nil, err
+		}
+	}
+	status.ReceiptURL = "/api/v1/public/receipts/" + *session.ReceiptToken
+
+	return status, nil
+}
+
+// guestUser returns the user guest idTags belong to, creating it on first
+// use. Its password is not a valid hash, so nobody can sign in as it.
+func (s *GuestService) guestUser(ctx context.Context) (*domain.User, error) {
+	if user, err := s.userRepo.GetByEmail(ctx, s.guestConfig.UserEmail); err == nil {
+		return user, nil
+	}
+
+	user := &domain.User{
+		Name:     "Guest",
+		Email:    s.guestConfig.UserEmail,
+		Password: "!",
+		Role:     "guest",
+		Status:   domain.UserStatusActive,
+		Group:    s.guestConfig.UserGroup,
+	}
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// newPublicCode returns a random code for a connector's public URL, or nil if
+// none could be generated.
+func newPublicCode() *string {
+	buf := make([]byte, 5)
+	if _, err := rand.Read(buf); err != nil {
+		log.Printf("Error generating connector public code: %v", err)
+		return nil
+	}
+
+	code := base32.StdEncoding.EncodeToString(buf)
+	return &code
+}
+
+func randomHex(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -5,52 +5,38 @@ import (
 	"errors"
 	"time"
 
+	"github.com/malikkhoiri/csms/internal/config"
 	"github.com/malikkhoiri/csms/internal/domain"
 )
 
 type IDTagService struct {
-	idTagRepo domain.IDTagRepository
+	idTagRepo  domain.IDTagRepository
+	authorizer *tagAuthorizer
+	cache      domain.AuthorizationCache
 }
 
-func NewIDTagService(idTagRepo domain.IDTagRepository) domain.IDTagService {
+func NewIDTagService(
+	idTagRepo domain.IDTagRepository,
+	transactionRepo domain.TransactionRepository,
+	chargePointRepo domain.ChargePointRepository,
+	accessRuleRepo domain.AccessRuleRepository,
+	walletRepo domain.WalletRepository,
+	authorizationChain domain.AuthorizationChain,
+	authorizationCache domain.AuthorizationCache,
+	authorizationConfig config.AuthorizationConfig,
+) domain.IDTagService {
 	return &IDTagService{
-		idTagRepo: idTagRepo,
+		idTagRepo:  idTagRepo,
+		authorizer: newTagAuthorizer(idTagRepo, transactionRepo, chargePointRepo, accessRuleRepo, walletRepo, authorizationChain, authorizationConfig),
+		cache:      authorizationCache,
 	}
 }
 
-func (s *IDTagService) Authorize(ctx context.Context, request *domain.AuthorizeRequest) (*domain.AuthorizeResponse, error) {
-	idTag, err := s.idTagRepo.GetByTag(ctx, request.IDTag)
-	if err != nil {
-		return &domain.AuthorizeResponse{
-			IDTagInfo: domain.IDTagInfo{
-				Status: domain.AuthorizeStatusInvalid,
-			},
-		}, nil
-	}
-
-	if idTag.Status != domain.AuthorizeStatusAccepted {
-		return &domain.AuthorizeResponse{
-			IDTagInfo: domain.IDTagInfo{
-				Status: idTag.Status,
-			},
-		}, nil
-	}
-
-	expiryDate := idTag.ExpiryDate.UTC()
-	if !idTag.ExpiryDate.IsZero() && idTag.ExpiryDate.Before(time.Now()) {
-		return &domain.AuthorizeResponse{
-			IDTagInfo: domain.IDTagInfo{
-				Status:     domain.AuthorizeStatusExpired,
-				ExpiryDate: &expiryDate,
-			},
-		}, nil
-	}
+func (s *IDTagService) Authorize(ctx context.Context, request *domain.AuthorizeRequest, chargePointID uint) (*domain.AuthorizeResponse, error) {
+	authorization := s.authorizer.authorize(ctx, request.IDTag, chargePointID, time.Now())
 
 	return &domain.AuthorizeResponse{
-		IDTagInfo: domain.IDTagInfo{
-			Status:     domain.AuthorizeStatusAccepted,
-			ExpiryDate: &expiryDate,
-		},
+		IDTagInfo: authorization.Info,
 	}, nil
 }
 
@@ -84,17 +70,30 @@ func (s *IDTagService) UpdateIDTag(ctx context.Context, idTag *domain.IDTag) err
 		}
 	}
 
-	return s.idTagRepo.Update(ctx, idTag)
+	if err := s.idTagRepo.Update(ctx, idTag); err != nil {
+		return err
+	}
+
+	s.cache.Invalidate(ctx, existingTag.Tag)
+	if idTag.Tag != existingTag.Tag {
+		s.cache.Invalidate(ctx, idTag.Tag)
+	}
+	return nil
 }
 
 func (s *IDTagService) DeleteIDTag(ctx context.Context, id uint) error {
 	// Check if IDTag exists
-	_, err := s.idTagRepo.GetByID(ctx, id)
+	idTag, err := s.idTagRepo.GetByID(ctx, id)
 	if err != nil {
 		return errors.New("IDTag not found")
 	}
 
-	return s.idTagRepo.Delete(ctx, id)
+	if err := s.idTagRepo.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	s.cache.Invalidate(ctx, idTag.Tag)
+	return nil
 }
 
 func (s *IDTagService) ListIDTags(ctx context.Context, limit, offset int) ([]domain.IDTag, error) {
@@ -0,0 +1,431 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/config"
+	"github.com/malikkhoiri/csms/internal/domain"
+	"github.com/malikkhoiri/csms/internal/pkg/pdf"
+)
+
+//go:embed templates/invoice.html
+var invoiceTemplateSource string
+
+var invoiceTemplate = template.Must(template.New("invoice").Parse(invoiceTemplateSource))
+
+var errNoTransactions = errors.New("no unsettled completed transactions for this fleet account in that month")
+
+type InvoiceService struct {
+	invoiceRepo     domain.InvoiceRepository
+	transactionRepo domain.TransactionRepository
+	userRepo        domain.UserRepository
+	tariffService   domain.TariffService
+	config          config.InvoiceConfig
+}
+
+func NewInvoiceService(
+	invoiceRepo domain.InvoiceRepository,
+	transactionRepo domain.TransactionRepository,
+	userRepo domain.UserRepository,
+	tariffService domain.TariffService,
+	invoiceConfig config.InvoiceConfig,
+) domain.InvoiceService {
+	return &InvoiceService{
+		invoiceRepo:     invoiceRepo,
+		transactionRepo: transactionRepo,
+		userRepo:        userRepo,
+		tariffService:   tariffService,
+		config:          invoiceConfig,
+	}
+}
+
+func (s *InvoiceService) GetReceipt(ctx context.Context, transactionID uint) (*domain.Invoice, error) {
+	if invoice, err := s.invoiceRepo.GetReceiptByTransaction(ctx, transactionID); err == nil {
+		return s.withDocuments(ctx, invoice)
+	}
+
+	transaction, err := s.transactionRepo.GetByID(ctx, transactionID)
+	if err != nil {
+		return nil, errors.New("transaction not found")
+	}
+
+	if transaction.Status != domain.TransactionStatusCompleted {
+		return nil, errors.New("receipts are only issued for completed transactions")
+	}
+
+	invoice := &domain.Invoice{
+		Type:          domain.InvoiceTypeReceipt,
+		TransactionID: &transaction.ID,
+		Currency:      transaction.Currency,
+		TaxRate:       transaction.TaxRate,
+		TaxInclusive:  transaction.TaxInclusive,
+		NetAmount:     transaction.NetAmount,
+		TaxAmount:     transaction.TaxAmount,
+		GrossAmount:   transaction.GrossAmount,
+		IssuedAt:      time.Now(),
+		Lines:         s.receiptLines(ctx, transaction),
+	}
+
+	if user, err := s.userRepo.GetByID(ctx, transaction.IDTag.UserID); err == nil {
+		setCustomer(invoice, user)
+	}
+
+	if err := s.invoiceRepo.Create(ctx, invoice, s.series(s.config.ReceiptPrefix, invoice.IssuedAt)); err != nil {
+		// Another request may have issued the receipt meanwhile.
+		if existing, getErr := s.invoiceRepo.GetReceiptByTransaction(ctx, transactionID); getErr == nil {
+			return s.withDocuments(ctx, existing)
+		}
+		return nil, err
+	}
+
+	return s.withDocuments(ctx, invoice)
+}
+
+// receiptLines itemizes the transaction with its tariff. When the tariff has
+// changed since and no longer adds up to the stored amount, the stored
+// component totals are used instead.
+func (s *InvoiceService) receiptLines(ctx context.Context, transaction *domain.Transaction) []domain.InvoiceLine {
+	stopTime := transaction.StartTime
+	if transaction.StopTime != nil {
+		stopTime = *transaction.StopTime
+	}
+
+	priced := *transaction
+	breakdown, err := s.tariffService.PriceTransaction(ctx, &priced, stopTime)
+	if err == nil && breakdown.GrossAmount == transaction.GrossAmount && breakdown.Currency == transaction.Currency {
+		lines := []domain.InvoiceLine{}
+		for _, line := range breakdown.Lines {
+			if line.Amount == 0 && line.Quantity == 0 {
+				continue
+			}
+			lines = append(lines, domain.InvoiceLine{
+				TransactionID: &transaction.ID,
+				Description:   line.Description,
+				Quantity:      roundQuantity(line.Quantity),
+				Unit:          line.Unit,
+				UnitPrice:     line.UnitPrice,
+				Amount:        line.Amount,
+			})
+		}
+		return lines
+	}
+
+	components := []struct {
+		name     string
+		amount   int64
+		quantity float64
+		unit     string
+	}{
+		{domain.TariffComponentEnergy, transaction.EnergyCost, transaction.EnergyConsumed, "kWh"},
+		{domain.TariffComponentTime, transaction.TimeCost, 0, ""},
+		{domain.TariffComponentFlat, transaction.FlatCost, 1, "session"},
+		{domain.TariffComponentIdle, transaction.IdleCost, 0, ""},
+	}
+
+	lines := []domain.InvoiceLine{}
+	for _, component := range components {
+		if component.amount == 0 {
+			continue
+		}
+		lines = append(lines, domain.InvoiceLine{
+			TransactionID: &transaction.ID,
+			Description:   component.name,
+			Quantity:      roundQuantity(component.quantity),
+			Unit:          component.unit,
+			Amount:        component.amount,
+		})
+	}
+	return lines
+}
+
+func (s *InvoiceService) CreateConsolidatedInvoice(ctx context.Context, request *domain.ConsolidatedInvoiceRequest) (*domain.Invoice, error) {
+	periodStart, err := time.ParseInLocation("2006-01", request.Month, time.UTC)
+	if err != nil {
+		return nil, errors.New("invalid month, expected YYYY-MM")
+	}
+
+	if periodStart.AddDate(0, 1, 0).After(time.Now()) {
+		return nil, errors.New("consolidated invoices are issued once the month is over")
+	}
+
+	return s.consolidate(ctx, request.FleetGroup, periodStart, request.UserID)
+}
+
+// IssueMonthlyInvoices issues the previous month's invoice of every fleet
+// account with completed transactions. Accounts already invoiced are skipped,
+// so running it repeatedly is safe.
+func (s *InvoiceService) IssueMonthlyInvoices(ctx context.Context, now time.Time) (int, error) {
+	now = now.UTC()
+	periodStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0)
+
+	groups, err := s.userRepo.ListGroups(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	issued := 0
+	for _, group := range groups {
+		if _, err := s.invoiceRepo.GetConsolidated(ctx, group, periodStart); err == nil {
+			continue
+		}
+
+		if _, err := s.consolidate(ctx, group, periodStart, nil); err != nil {
+			if errors.Is(err, errNoTransactions) {
+				continue
+			}
+			return issued, fmt.Errorf("invoicing fleet %s: %w", group, err)
+		}
+		issued++
+	}
+
+	return issued, nil
+}
+
+// consolidate invoices every completed transaction of the fleet started in
+// the month, one line per transaction at its net amount. Sessions paid by
+// card or from a wallet are settled already and left out.
+func (s *InvoiceService) consolidate(ctx context.Context, fleetGroup string, periodStart time.Time, userID *uint) (*domain.Invoice, error) {
+	if existing, err := s.invoiceRepo.GetConsolidated(ctx, fleetGroup, periodStart); err == nil {
+		return s.withDocuments(ctx, existing)
+	}
+
+	periodEnd := periodStart.AddDate(0, 1, 0)
+	transactions, err := s.transactionRepo.ListCompleted(ctx, domain.TransactionFilter{
+		UserGroup: fleetGroup,
+		From:      &periodStart,
+		To:        &periodEnd,
+		Unsettled: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(transactions) == 0 {
+		return nil, errNoTransactions
+	}
+
+	invoice := &domain.Invoice{
+		Type:         domain.InvoiceTypeConsolidated,
+		FleetGroup:   fleetGroup,
+		PeriodStart:  &periodStart,
+		PeriodEnd:    &periodEnd,
+		CustomerName: fleetGroup,
+		Currency:     transactions[0].Currency,
+		TaxRate:      transactions[0].TaxRate,
+		IssuedAt:     time.Now(),
+		Lines:        []domain.InvoiceLine{},
+	}
+
+	if userID != nil {
+		user, err := s.userRepo.GetByID(ctx, *userID)
+		if err != nil {
+			return nil, errors.New("user not found")
+		}
+		if user.Group != fleetGroup {
+			return nil, fmt.Errorf("user %d does not belong to fleet %s", user.ID, fleetGroup)
+		}
+		setCustomer(invoice, user)
+	}
+
+	for i := range transactions {
+		transaction := &transactions[i]
+		if transaction.Currency != invoice.Currency {
+			return nil, fmt.Errorf("fleet %s has transactions in %s and %s", fleetGroup, invoice.Currency, transaction.Currency)
+		}
+		if transaction.TaxRate != invoice.TaxRate {
+			// Mixed rates are still summed correctly but no single rate applies.
+			invoice.TaxRate = 0
+		}
+
+		invoice.Lines = append(invoice.Lines, domain.InvoiceLine{
+			TransactionID: &transaction.ID,
+			Description: fmt.Sprintf("%s %s #%d",
+				transaction.StartTime.UTC().Format("2006-01-02 15:04"),
+				transaction.ChargePoint.ChargePointCode,
+				transaction.TransactionID),
+			Quantity: roundQuantity(transaction.EnergyConsumed),
+			Unit:     "kWh",
+			Amount:   transaction.NetAmount,
+		})
+		invoice.NetAmount += transaction.NetAmount
+		invoice.TaxAmount += transaction.TaxAmount
+		invoice.GrossAmount += transaction.GrossAmount
+	}
+
+	if err := s.invoiceRepo.Create(ctx, invoice, s.series(s.config.InvoicePrefix, invoice.IssuedAt)); err != nil {
+		if existing, getErr := s.invoiceRepo.GetConsolidated(ctx, fleetGroup, periodStart); getErr == nil {
+			return s.withDocuments(ctx, existing)
+		}
+		return nil, err
+	}
+
+	log.Printf("Issued invoice %s for fleet %s, %d transaction(s)", invoice.Number, fleetGroup, len(transactions))
+	return s.withDocuments(ctx, invoice)
+}
+
+func (s *InvoiceService) GetInvoice(ctx context.Context, id uint) (*domain.Invoice, error) {
+	invoice, err := s.invoiceRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.withDocuments(ctx, invoice)
+}
+
+func (s *InvoiceService) ListInvoices(ctx context.Context, limit, offset int) ([]domain.Invoice, error) {
+	return s.invoiceRepo.List(ctx, limit, offset)
+}
+
+func (s *InvoiceService) series(prefix string, issuedAt time.Time) string {
+	return fmt.Sprintf("%s-%d", prefix, issuedAt.Year())
+}
+
+// withDocuments renders and stores the HTML and PDF of an invoice that does
+// not have them yet. Documents are rendered once and kept as issued.
+func (s *InvoiceService) withDocuments(ctx context.Context, invoice *domain.Invoice) (*domain.Invoice, error) {
+	if invoice.HTML != "" && len(invoice.PDF) > 0 {
+		return invoice, nil
+	}
+
+	view := s.newInvoiceView(invoice)
+
+	var html bytes.Buffer
+	if err := invoiceTemplate.Execute(&html, view); err != nil {
+		return nil, err
+	}
+	invoice.HTML = html.String()
+	invoice.PDF = renderInvoicePDF(view)
+
+	if err := s.invoiceRepo.UpdateDocuments(ctx, invoice); err != nil {
+		return nil, err
+	}
+	return invoice, nil
+}
+
+type invoiceView struct {
+	Title    string
+	Invoice  *domain.Invoice
+	Company  config.InvoiceConfig
+	IssuedAt string
+	Period   string
+	Lines    []invoiceLineView
+	Net      string
+	TaxLabel string
+	Tax      string
+	Gross    string
+}
+
+type invoiceLineView struct {
+	Description string
+	Quantity    string
+	UnitPrice   string
+	Amount      string
+}
+
+func (s *InvoiceService) newInvoiceView(invoice *domain.Invoice) *invoiceView {
+	view := &invoiceView{
+		Title:    "Receipt",
+		Invoice:  invoice,
+		Company:  s.config,
+		IssuedAt: invoice.IssuedAt.UTC().Format("2006-01-02 15:04 MST"),
+		Net:      formatMoney(invoice.NetAmount, invoice.Currency),
+		TaxLabel: "Tax",
+		Tax:      formatMoney(invoice.TaxAmount, invoice.Currency),
+		Gross:    formatMoney(invoice.GrossAmount, invoice.Currency),
+	}
+
+	if invoice.Type == domain.InvoiceTypeConsolidated {
+		view.Title = "Invoice"
+	}
+	if invoice.PeriodStart != nil && invoice.PeriodEnd != nil {
+		view.Period = fmt.Sprintf("%s to %s",
+			invoice.PeriodStart.Format("2006-01-02"), invoice.PeriodEnd.AddDate(0, 0, -1).Format("2006-01-02"))
+	}
+	if invoice.TaxRate > 0 {
+		view.TaxLabel = fmt.Sprintf("Tax %s%%", strconv.FormatFloat(invoice.TaxRate, 'f', -1, 64))
+	}
+
+	for _, line := range invoice.Lines {
+		lineView := invoiceLineView{
+			Description: line.Description,
+			Amount:      formatMoney(line.Amount, invoice.Currency),
+		}
+		if line.Unit != "" {
+			lineView.Quantity = fmt.Sprintf("%s %s", strconv.FormatFloat(line.Quantity, 'f', -1, 64), line.Unit)
+		}
+		if line.UnitPrice != 0 {
+			lineView.UnitPrice = strconv.FormatFloat(line.UnitPrice, 'f', -1, 64)
+		}
+		view.Lines = append(view.Lines, lineView)
+	}
+
+	return view
+}
+
+func renderInvoicePDF(view *invoiceView) []byte {
+	doc := pdf.New()
+
+	doc.Bold(view.Title, 20)
+	doc.Text(fmt.Sprintf("No. %s, issued %s", view.Invoice.Number, view.IssuedAt), 10)
+	if view.Period != "" {
+		doc.Text("Period "+view.Period, 10)
+	}
+	doc.Space(12)
+
+	doc.Bold(view.Company.CompanyName, 11)
+	if view.Company.CompanyAddress != "" {
+		doc.Text(view.Company.CompanyAddress, 10)
+	}
+	if view.Company.CompanyTaxID != "" {
+		doc.Text("Tax ID "+view.Company.CompanyTaxID, 10)
+	}
+	doc.Space(8)
+
+	doc.Text("Bill to", 9)
+	doc.Bold(view.Invoice.CustomerName, 11)
+	for _, detail := range []string{view.Invoice.CustomerEmail, view.Invoice.CustomerPhone} {
+		if detail != "" {
+			doc.Text(detail, 10)
+		}
+	}
+	doc.Space(12)
+
+	row := "%-34.34s %14s %12s %18s"
+	doc.Mono(fmt.Sprintf(row, "Description", "Quantity", "Unit price", "Amount"), 9)
+	doc.Mono(strings.Repeat("-", 81), 9)
+	for _, line := range view.Lines {
+		doc.Mono(fmt.Sprintf(row, line.Description, line.Quantity, line.UnitPrice, line.Amount), 9)
+	}
+	doc.Mono(strings.Repeat("-", 81), 9)
+
+	total := "%62s %18s"
+	doc.Mono(fmt.Sprintf(total, "Net", view.Net), 9)
+	doc.Mono(fmt.Sprintf(total, view.TaxLabel, view.Tax), 9)
+	doc.Mono(fmt.Sprintf(total, "Total", view.Gross), 9)
+
+	if view.Invoice.TaxInclusive {
+		doc.Space(8)
+		doc.Text("Prices include tax.", 9)
+	}
+
+	return doc.Bytes()
+}
+
+func setCustomer(invoice *domain.Invoice, user *domain.User) {
+	invoice.UserID = &user.ID
+	invoice.CustomerName = user.Name
+	invoice.CustomerEmail = user.Email
+	invoice.CustomerPhone = user.Phone
+}
+
+func roundQuantity(quantity float64) float64 {
+	rounded, _ := strconv.ParseFloat(strconv.FormatFloat(quantity, 'f', 3, 64), 64)
+	return rounded
+}
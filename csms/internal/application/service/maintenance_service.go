@@ -0,0 +1,554 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+// ticketTransitions lists the states a ticket may move to from each state.
+var ticketTransitions = map[string][]string{
+	domain.TicketStatusOpen:       {domain.TicketStatusInProgress, domain.TicketStatusResolved, domain.TicketStatusClosed},
+	domain.TicketStatusInProgress: {domain.TicketStatusOpen, domain.TicketStatusResolved, domain.TicketStatusClosed},
+	domain.TicketStatusResolved:   {domain.TicketStatusOpen, domain.TicketStatusClosed},
+}
+
+type MaintenanceService struct {
+	ruleRepo        domain.AlertRuleRepository
+	ticketRepo      domain.MaintenanceTicketRepository
+	chargePointRepo domain.ChargePointRepository
+	connectorRepo   domain.ConnectorRepository
+	errorRepo       domain.ConnectorErrorRepository
+	userRepo        domain.UserRepository
+	unitOfWork      domain.UnitOfWork
+	notifiers       []domain.TicketNotifier
+}
+
+func NewMaintenanceService(
+	ruleRepo domain.AlertRuleRepository,
+	ticketRepo domain.MaintenanceTicketRepository,
+	chargePointRepo domain.ChargePointRepository,
+	connectorRepo domain.ConnectorRepository,
+	errorRepo domain.ConnectorErrorRepository,
+	userRepo domain.UserRepository,
+	unitOfWork domain.UnitOfWork,
+	notifiers []domain.TicketNotifier,
+) domain.MaintenanceService {
+	return &MaintenanceService{
+		ruleRepo:        ruleRepo,
+		ticketRepo:      ticketRepo,
+		chargePointRepo: chargePointRepo,
+		connectorRepo:   connectorRepo,
+		errorRepo:       errorRepo,
+		userRepo:        userRepo,
+		unitOfWork:      unitOfWork,
+		notifiers:       notifiers,
+	}
+}
+
+func (s *MaintenanceService) CreateAlertRule(ctx context.Context, rule *domain.AlertRule) error {
+	if err := validateAlertRule(rule); err != nil {
+		return err
+	}
+	return s.ruleRepo.Create(ctx, rule)
+}
+
+func (s *MaintenanceService) GetAlertRule(ctx context.Context, id uint) (*domain.AlertRule, error) {
+	rule, err := s.ruleRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, errors.New("alert rule not found")
+	}
+	return rule, nil
+}
+
+func (s *MaintenanceService) UpdateAlertRule(ctx context.Context, rule *domain.AlertRule) error {
+	existingRule, err := s.GetAlertRule(ctx, rule.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := validateAlertRule(rule); err != nil {
+		return err
+	}
+
+	rule.CreatedAt = existingRule.CreatedAt
+	return s.ruleRepo.Update(ctx, rule)
+}
+
+// DeleteAlertRule removes the rule; the tickets it opened stay.
+func (s *MaintenanceService) DeleteAlertRule(ctx context.Context, id uint) error {
+	if _, err := s.GetAlertRule(ctx, id); err != nil {
+		return err
+	}
+	return s.ruleRepo.Delete(ctx, id)
+}
+
+func (s *MaintenanceService) ListAlertRules(ctx context.Context, limit, offset int) ([]domain.AlertRule, error) {
+	return s.ruleRepo.List(ctx, limit, offset)
+}
+
+func (s *MaintenanceService) CreateTicket(ctx context.Context, request *domain.TicketRequest, userID uint) (*domain.MaintenanceTicket, error) {
+	if _, err := s.chargePointRepo.GetByID(ctx, request.ChargePointID); err != nil {
+		return nil, errors.New("charge point not found")
+	}
+
+	severity := request.Severity
+	if severity == "" {
+		severity = domain.SeverityMajor
+	}
+	if !validSeverity(severity) {
+		return nil, fmt.Errorf("unknown severity %q", severity)
+	}
+
+	ticket := &domain.MaintenanceTicket{
+		ChargePointID: request.ChargePointID,
+		ConnectorID:   request.ConnectorID,
+		Title:         request.Title,
+		Description:   request.Description,
+		Severity:      severity,
+		Status:        domain.TicketStatusOpen,
+	}
+	err := s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		if err := s.ticketRepo.Create(ctx, ticket); err != nil {
+			return err
+		}
+		return s.comment(ctx, ticket.ID, &userID, "Ticket opened")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.notify(ctx, domain.EventTicketOpened, ticket.ID)
+	return s.ticketRepo.GetByID(ctx, ticket.ID)
+}
+
+func (s *MaintenanceService) GetTicket(ctx context.Context, id uint) (*domain.MaintenanceTicket, error) {
+	ticket, err := s.ticketRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, errors.New("ticket not found")
+	}
+	return ticket, nil
+}
+
+func (s *MaintenanceService) ListTickets(ctx context.Context, filter domain.TicketFilter) ([]domain.MaintenanceTicket, error) {
+	return s.ticketRepo.List(ctx, filter)
+}
+
+func (s *MaintenanceService) AssignTicket(ctx context.Context, id uint, assigneeID *uint, userID uint) (*domain.MaintenanceTicket, error) {
+	ticket, err := s.GetTicket(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if ticket.Status == domain.TicketStatusClosed {
+		return nil, errors.New("ticket is closed")
+	}
+
+	body := "Unassigned"
+	if assigneeID != nil {
+		assignee, err := s.userRepo.GetByID(ctx, *assigneeID)
+		if err != nil {
+			return nil, errors.New("assignee not found")
+		}
+		if assignee.Role != "technician" && assignee.Role != "admin" {
+			return nil, errors.New("tickets can only be assigned to technicians and admins")
+		}
+		body = "Assigned to " + assignee.Name
+	}
+
+	ticket.AssigneeID = assigneeID
+	err = s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		if err := s.ticketRepo.Update(ctx, ticket); err != nil {
+			return err
+		}
+		return s.comment(ctx, ticket.ID, &userID, body)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.notify(ctx, domain.EventTicketUpdated, ticket.ID)
+	return s.ticketRepo.GetByID(ctx, ticket.ID)
+}
+
+func (s *MaintenanceService) TransitionTicket(ctx context.Context, id uint, status, comment string, userID uint) (*domain.MaintenanceTicket, error) {
+	ticket, err := s.GetTicket(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !slices.Contains(ticketTransitions[ticket.Status], status) {
+		return nil, fmt.Errorf("ticket cannot move from %s to %s", ticket.Status, status)
+	}
+
+	body := fmt.Sprintf("Status changed from %s to %s", ticket.Status, status)
+	if comment != "" {
+		body += ": " + comment
+	}
+
+	if err := s.setStatus(ctx, ticket, status, false, &userID, body); err != nil {
+		return nil, err
+	}
+	return s.ticketRepo.GetByID(ctx, ticket.ID)
+}
+
+func (s *MaintenanceService) AddComment(ctx context.Context, id uint, body string, userID uint) (*domain.TicketComment, error) {
+	if body == "" {
+		return nil, errors.New("comment is empty")
+	}
+	if _, err := s.GetTicket(ctx, id); err != nil {
+		return nil, err
+	}
+
+	comment := &domain.TicketComment{
+		TicketID: id,
+		UserID:   &userID,
+		Body:     body,
+	}
+	if err := s.ticketRepo.AddComment(ctx, comment); err != nil {
+		return nil, err
+	}
+
+	s.notify(ctx, domain.EventTicketUpdated, id)
+	return comment, nil
+}
+
+func (s *MaintenanceService) EvaluateAlerts(ctx context.Context, now time.Time) (int, int, error) {
+	rules, err := s.ruleRepo.ListEnabled(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	opened, resolved := 0, 0
+	for i := range rules {
+		rule := &rules[i]
+
+		n, err := s.resolveRecovered(ctx, rule)
+		resolved += n
+		if err != nil {
+			return opened, resolved, err
+		}
+
+		n, err = s.openAlerts(ctx, rule, now)
+		opened += n
+		if err != nil {
+			return opened, resolved, err
+		}
+	}
+
+	return opened, resolved, nil
+}
+
+// alert is a charge point or connector an alert rule's condition holds for,
+// since the start of the episode.
+type alert struct {
+	chargePointID uint
+	connectorID   int
+	since         time.Time
+	description   string
+}
+
+// openAlerts opens a ticket for every charge point or connector the rule's
+// condition holds for, unless one is already being worked on or was opened
+// for the same episode.
+func (s *MaintenanceService) openAlerts(ctx context.Context, rule *domain.AlertRule, now time.Time) (int, error) {
+	alerts, err := s.alerts(ctx, rule, now)
+	if err != nil {
+		return 0, err
+	}
+
+	opened := 0
+	for i := range alerts {
+		target := &alerts[i]
+		latest, err := s.ticketRepo.GetLatestByAlert(ctx, rule.ID, target.chargePointID, target.connectorID)
+		if err == nil {
+			if latest.Status == domain.TicketStatusOpen || latest.Status == domain.TicketStatusInProgress {
+				continue
+			}
+			if latest.CreatedAt.After(target.since) {
+				// Errors counted before the last ticket were dealt with there.
+				if rule.Type != domain.AlertRuleRepeatedError {
+					continue
+				}
+				count, err := s.errorRepo.CountByConnectorSince(ctx, target.chargePointID, target.connectorID, rule.ErrorCode, latest.CreatedAt)
+				if err != nil {
+					return opened, err
+				}
+				if count < int64(rule.Count) {
+					continue
+				}
+			}
+		}
+
+		if err := s.openAlert(ctx, rule, target); err != nil {
+			return opened, err
+		}
+		opened++
+	}
+	return opened, nil
+}
+
+func (s *MaintenanceService) alerts(ctx context.Context, rule *domain.AlertRule, now time.Time) ([]alert, error) {
+	var alerts []alert
+
+	switch rule.Type {
+	case domain.AlertRuleStatusDuration:
+		before := now.Add(-time.Duration(rule.ThresholdMinutes) * time.Minute)
+		connectors, err := s.connectorRepo.ListInStatusSince(ctx, rule.Status, before)
+		if err != nil {
+			return nil, err
+		}
+		for _, connector := range connectors {
+			alerts = append(alerts, alert{
+				chargePointID: connector.ChargePointID,
+				connectorID:   connector.ConnectorID,
+				since:         *connector.StatusChangedAt,
+				description: fmt.Sprintf("Connector %d has been %s since %s (error code %s)",
+					connector.ConnectorID, connector.Status, connector.StatusChangedAt.UTC().Format(time.RFC3339), connector.ErrorCode),
+			})
+		}
+		// A charge point reporting the status for itself, as connector 0,
+		// is in it with all its connectors.
+		chargePoints, err := s.chargePointRepo.ListInStatusSince(ctx, rule.Status, before)
+		if err != nil {
+			return nil, err
+		}
+		for _, chargePoint := range chargePoints {
+			alerts = append(alerts, alert{
+				chargePointID: chargePoint.ID,
+				since:         *chargePoint.StatusChangedAt,
+				description: fmt.Sprintf("Charge point has been %s since %s (error code %s)",
+					chargePoint.Status, chargePoint.StatusChangedAt.UTC().Format(time.RFC3339), chargePoint.ErrorCode),
+			})
+		}
+
+	case domain.AlertRuleRepeatedError:
+		since := now.Add(-time.Duration(rule.WindowMinutes) * time.Minute)
+		counts, err := s.errorRepo.CountSince(ctx, rule.ErrorCode, since)
+		if err != nil {
+			return nil, err
+		}
+		for _, count := range counts {
+			if count.Count < rule.Count {
+				continue
+			}
+			reporter := fmt.Sprintf("Connector %d", count.ConnectorID)
+			if count.ConnectorID == 0 {
+				reporter = "Charge point"
+			}
+			alerts = append(alerts, alert{
+				chargePointID: count.ChargePointID,
+				connectorID:   count.ConnectorID,
+				since:         since,
+				description: fmt.Sprintf("%s reported %s %d times in the last %d minutes",
+					reporter, rule.ErrorCode, count.Count, rule.WindowMinutes),
+			})
+		}
+
+	case domain.AlertRuleOffline:
+		chargePoints, err := s.chargePointRepo.ListOfflineSince(ctx, now.Add(-time.Duration(rule.ThresholdMinutes)*time.Minute))
+		if err != nil {
+			return nil, err
+		}
+		for _, chargePoint := range chargePoints {
+			alerts = append(alerts, alert{
+				chargePointID: chargePoint.ID,
+				since:         *chargePoint.OnlineChangedAt,
+				description:   fmt.Sprintf("Charge point has been offline since %s", chargePoint.OnlineChangedAt.UTC().Format(time.RFC3339)),
+			})
+		}
+	}
+
+	return alerts, nil
+}
+
+func (s *MaintenanceService) openAlert(ctx context.Context, rule *domain.AlertRule, target *alert) error {
+	title := rule.Name
+	if chargePoint, err := s.chargePointRepo.GetByID(ctx, target.chargePointID); err == nil {
+		title = fmt.Sprintf("%s: %s", rule.Name, chargePoint.ChargePointCode)
+	}
+	if target.connectorID != 0 {
+		title = fmt.Sprintf("%s connector %d", title, target.connectorID)
+	}
+
+	ticket := &domain.MaintenanceTicket{
+		ChargePointID: target.chargePointID,
+		ConnectorID:   target.connectorID,
+		AlertRuleID:   &rule.ID,
+		Title:         title,
+		Description:   target.description,
+		Severity:      rule.Severity,
+		Status:        domain.TicketStatusOpen,
+	}
+	err := s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		if err := s.ticketRepo.Create(ctx, ticket); err != nil {
+			return err
+		}
+		return s.comment(ctx, ticket.ID, nil, fmt.Sprintf("Opened by alert rule %q", rule.Name))
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Opened ticket %d: %s", ticket.ID, ticket.Title)
+	s.notify(ctx, domain.EventTicketOpened, ticket.ID)
+	return nil
+}
+
+// resolveRecovered resolves the rule's tickets whose charge point or
+// connector recovered.
+func (s *MaintenanceService) resolveRecovered(ctx context.Context, rule *domain.AlertRule) (int, error) {
+	tickets, err := s.ticketRepo.ListActiveByAlertRule(ctx, rule.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	resolved := 0
+	for i := range tickets {
+		ticket := &tickets[i]
+
+		recovered, reason := s.recovered(ctx, rule, ticket)
+		if !recovered {
+			continue
+		}
+
+		if err := s.setStatus(ctx, ticket, domain.TicketStatusResolved, true, nil, "Resolved automatically: "+reason); err != nil {
+			return resolved, err
+		}
+		log.Printf("Resolved ticket %d: %s", ticket.ID, reason)
+		resolved++
+	}
+	return resolved, nil
+}
+
+// recovered tells whether the condition that opened the ticket cleared.
+func (s *MaintenanceService) recovered(ctx context.Context, rule *domain.AlertRule, ticket *domain.MaintenanceTicket) (bool, string) {
+	if rule.Type == domain.AlertRuleOffline {
+		chargePoint, err := s.chargePointRepo.GetByID(ctx, ticket.ChargePointID)
+		if err != nil || !chargePoint.Online {
+			return false, ""
+		}
+		return true, "charge point is back online"
+	}
+
+	connector, err := s.connectorRepo.GetByChargePointAndConnectorID(ctx, ticket.ChargePointID, ticket.ConnectorID)
+	if err != nil {
+		return false, ""
+	}
+
+	switch rule.Type {
+	case domain.AlertRuleStatusDuration:
+		if connector.Status != rule.Status {
+			return true, fmt.Sprintf("connector is %s", connector.Status)
+		}
+	case domain.AlertRuleRepeatedError:
+		if connector.ErrorCode != rule.ErrorCode {
+			return true, fmt.Sprintf("connector reports %s", connector.ErrorCode)
+		}
+	}
+	return false, ""
+}
+
+func (s *MaintenanceService) setStatus(ctx context.Context, ticket *domain.MaintenanceTicket, status string, auto bool, userID *uint, body string) error {
+	now := time.Now()
+	ticket.Status = status
+	ticket.AutoResolved = auto
+	switch status {
+	case domain.TicketStatusResolved:
+		ticket.ResolvedAt = &now
+	case domain.TicketStatusClosed:
+		ticket.ClosedAt = &now
+	case domain.TicketStatusOpen:
+		ticket.ResolvedAt = nil
+	}
+
+	err := s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		if err := s.ticketRepo.Update(ctx, ticket); err != nil {
+			return err
+		}
+		return s.comment(ctx, ticket.ID, userID, body)
+	})
+	if err != nil {
+		return err
+	}
+
+	event := domain.EventTicketUpdated
+	if status == domain.TicketStatusResolved {
+		event = domain.EventTicketResolved
+	}
+	s.notify(ctx, event, ticket.ID)
+	return nil
+}
+
+func (s *MaintenanceService) comment(ctx context.Context, ticketID uint, userID *uint, body string) error {
+	return s.ticketRepo.AddComment(ctx, &domain.TicketComment{
+		TicketID: ticketID,
+		UserID:   userID,
+		Body:     body,
+	})
+}
+
+// notify tells every notifier about the ticket's change; failing notifiers
+// are only logged.
+func (s *MaintenanceService) notify(ctx context.Context, event string, ticketID uint) {
+	if len(s.notifiers) == 0 {
+		return
+	}
+
+	ticket, err := s.ticketRepo.GetByID(ctx, ticketID)
+	if err != nil {
+		log.Printf("Error loading ticket %d for notification: %v", ticketID, err)
+		return
+	}
+	// Notifications carry the ticket, not its whole discussion.
+	ticket.Comments = nil
+
+	ticketAlert := &domain.TicketAlert{Event: event, Ticket: ticket}
+	for _, notifier := range s.notifiers {
+		if err := notifier.NotifyTicket(ctx, ticketAlert); err != nil {
+			log.Printf("Error notifying %s about ticket %d: %v", notifier.Name(), ticketID, err)
+		}
+	}
+}
+
+func validateAlertRule(rule *domain.AlertRule) error {
+	if rule.Name == "" {
+		return errors.New("alert rule needs a name")
+	}
+
+	switch rule.Type {
+	case domain.AlertRuleStatusDuration:
+		if rule.Status == "" || rule.ThresholdMinutes <= 0 {
+			return errors.New("status_duration rule needs a status and thresholdMinutes")
+		}
+		if !slices.Contains(domain.ChargePointStatuses, rule.Status) {
+			return fmt.Errorf("invalid connector status %q", rule.Status)
+		}
+	case domain.AlertRuleRepeatedError:
+		if rule.ErrorCode == "" || rule.Count <= 0 || rule.WindowMinutes <= 0 {
+			return errors.New("repeated_error rule needs an errorCode, count and windowMinutes")
+		}
+	case domain.AlertRuleOffline:
+		if rule.ThresholdMinutes <= 0 {
+			return errors.New("offline rule needs thresholdMinutes")
+		}
+	default:
+		return fmt.Errorf("unknown alert rule type %q", rule.Type)
+	}
+
+	if rule.Severity == "" {
+		rule.Severity = domain.SeverityMajor
+	}
+	if !validSeverity(rule.Severity) {
+		return fmt.Errorf("unknown severity %q", rule.Severity)
+	}
+	return nil
+}
+
+func validSeverity(severity string) bool {
+	return severity == domain.SeverityCritical || severity == domain.SeverityMajor || severity == domain.SeverityMinor
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+// toMinorUnits converts an amount in major units to integer minor units with
+// the given rounding mode.
+func toMinorUnits(amount float64, currency string, rounding string) int64 {
+	return roundAmount(amount*math.Pow10(domain.CurrencyExponent(currency)), rounding)
+}
+
+// roundAmount rounds to an integer. The value is first cut to 6 decimals so
+// binary representation noise such as 267.49999999999997 for 2.675*100 does
+// not change the result.
+func roundAmount(value float64, rounding string) int64 {
+	value = math.Round(value*1e6) / 1e6
+
+	switch rounding {
+	case domain.RoundingHalfEven:
+		return int64(math.RoundToEven(value))
+	case domain.RoundingUp:
+		if value < 0 {
+			return int64(math.Floor(value))
+		}
+		return int64(math.Ceil(value))
+	case domain.RoundingDown:
+		return int64(math.Trunc(value))
+	default:
+		return int64(math.Round(value))
+	}
+}
+
+func validRounding(rounding string) bool {
+	switch rounding {
+	case domain.RoundingHalfUp, domain.RoundingHalfEven, domain.RoundingUp, domain.RoundingDown:
+		return true
+	}
+	return false
+}
+
+// formatMoney renders minor units as a decimal amount with its currency, e.g.
+// "2500.00 IDR".
+func formatMoney(amount int64, currency string) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	exponent := domain.CurrencyExponent(currency)
+	if exponent == 0 {
+		return fmt.Sprintf("%s%d %s", sign, amount, currency)
+	}
+
+	scale := int64(math.Pow10(exponent))
+	return fmt.Sprintf("%s%d.%0*d %s", sign, amount/scale, exponent, amount%scale, currency)
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+type MonitoringService struct {
+	chargePointRepo domain.ChargePointRepository
+	connectorRepo   domain.ConnectorRepository
+	transactionRepo domain.TransactionRepository
+	userRepo        domain.UserRepository
+	startedAt       time.Time
+}
+
+func NewMonitoringService(
+	chargePointRepo domain.ChargePointRepository,
+	connectorRepo domain.ConnectorRepository,
+	transactionRepo domain.TransactionRepository,
+	userRepo domain.UserRepository,
+) domain.MonitoringService {
+	return &MonitoringService{
+		chargePointRepo: chargePointRepo,
+		connectorRepo:   connectorRepo,
+		transactionRepo: transactionRepo,
+		userRepo:        userRepo,
+		startedAt:       time.Now(),
+	}
+}
+
+// GetSystemStatus sums up the charge points, connectors and transactions
+// across the whole system.
+func (s *MonitoringService) GetSystemStatus(ctx context.Context) (map[string]interface{}, error) {
+	online, total, err := s.chargePointRepo.CountOnline(ctx)
+	if err != nil {
+		return nil, err
+	}
+	chargePointStatuses, err := s.chargePointRepo.CountByAggregateStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	connectorStatuses, err := s.connectorRepo.CountByStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	transactionStatuses, err := s.transactionRepo.CountByStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"startedAt":     s.startedAt,
+		"uptimeSeconds": int64(time.Since(s.startedAt).Seconds()),
+		"chargePoints": map[string]interface{}{
+			"total":    total,
+			"online":   online,
+			"offline":  total - online,
+			"byStatus": countsByKey(chargePointStatuses),
+		},
+		"connectors": map[string]interface{}{
+			"byStatus": countsByKey(connectorStatuses),
+		},
+		"activeTransactions": countsByKey(transactionStatuses)[domain.TransactionStatusActive],
+	}, nil
+}
+
+// GetChargePointMetrics reports a charge point's state, its connectors and
+// the transactions it completed today and overall.
+func (s *MonitoringService) GetChargePointMetrics(ctx context.Context, chargePointID uint) (map[string]interface{}, error) {
+	chargePoint, err := s.chargePointRepo.GetByID(ctx, chargePointID)
+	if err != nil {
+		return nil, errors.New("charge point not found")
+	}
+
+	active, err := s.transactionRepo.ListActiveByChargePoint(ctx, chargePointID)
+	if err != nil {
+		return nil, err
+	}
+	today := startOfDay(time.Now())
+	todayTotals, err := s.transactionRepo.SumCompleted(ctx, domain.TransactionFilter{ChargePointID: &chargePointID, From: &today})
+	if err != nil {
+		return nil, err
+	}
+	totals, err := s.transactionRepo.SumCompleted(ctx, domain.TransactionFilter{ChargePointID: &chargePointID})
+	if err != nil {
+		return nil, err
+	}
+
+	connectors := make([]map[string]interface{}, 0, len(chargePoint.Connectors))
+	for _, connector := range chargePoint.Connectors {
+		connectors = append(connectors, map[string]interface{}{
+			"connectorId":     connector.ConnectorID,
+			"status":          connector.Status,
+			"errorCode":       connector.ErrorCode,
+			"statusChangedAt": connector.StatusChangedAt,
+		})
+	}
+
+	return map[string]interface{}{
+		"chargePointId":      chargePoint.ID,
+		"chargePointCode":    chargePoint.ChargePointCode,
+		"online":             chargePoint.Online,
+		"status":             chargePoint.Status,
+		"aggregateStatus":    chargePoint.AggregateStatus,
+		"lastHeartbeat":      chargePoint.LastHeartbeat,
+		"connectors":         connectors,
+		"activeTransactions": len(active),
+		"today":              todayTotals,
+		"total":              totals,
+	}, nil
+}
+
+// GetTransactionMetrics counts the transactions by status and totals the
+// completed ones today, over the last 7 days and overall.
+func (s *MonitoringService) GetTransactionMetrics(ctx context.Context) (map[string]interface{}, error) {
+	statuses, err := s.transactionRepo.CountByStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	today := startOfDay(time.Now())
+	lastWeek := today.AddDate(0, 0, -6)
+	todayTotals, err := s.transactionRepo.SumCompleted(ctx, domain.TransactionFilter{From: &today})
+	if err != nil {
+		return nil, err
+	}
+	weekTotals, err := s.transactionRepo.SumCompleted(ctx, domain.TransactionFilter{From: &lastWeek})
+	if err != nil {
+		return nil, err
+	}
+	totals, err := s.transactionRepo.SumCompleted(ctx, domain.TransactionFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	byStatus := countsByKey(statuses)
+	return map[string]interface{}{
+		"byStatus":  byStatus,
+		"active":    byStatus[domain.TransactionStatusActive],
+		"today":     todayTotals,
+		"last7Days": weekTotals,
+		"total":     totals,
+	}, nil
+}
+
+func (s *MonitoringService) GetUserMetrics(ctx context.Context) (map[string]interface{}, error) {
+	roles, err := s.userRepo.CountByRole(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses, err := s.userRepo.CountByStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byRole := countsByKey(roles)
+	var total int64
+	for _, count := range byRole {
+		total += count
+	}
+
+	return map[string]interface{}{
+		"total":    total,
+		"byRole":   byRole,
+		"byStatus": countsByKey(statuses),
+	}, nil
+}
+
+func countsByKey(counts []domain.GroupCount) map[string]int64 {
+	byKey := make(map[string]int64, len(counts))
+	for _, count := range counts {
+		byKey[count.Key] = count.Count
+	}
+	return byKey
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+// MonthlyInvoiceJob issues the consolidated fleet invoices of the previous
+// month once it is over.
+type MonthlyInvoiceJob struct {
+	invoiceService domain.InvoiceService
+	interval       time.Duration
+}
+
+// NewMonthlyInvoiceJob creates a new monthly invoice job
+func NewMonthlyInvoiceJob(invoiceService domain.InvoiceService, interval time.Duration) *MonthlyInvoiceJob {
+	return &MonthlyInvoiceJob{
+		invoiceService: invoiceService,
+		interval:       interval,
+	}
+}
+
+// Run issues missing invoices at start and on every tick until the context
+// is cancelled
+func (j *MonthlyInvoiceJob) Run(ctx context.Context) {
+	if j.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		issued, err := j.invoiceService.IssueMonthlyInvoices(ctx, time.Now())
+		if err != nil {
+			log.Printf("Error issuing monthly invoices: %v", err)
+		} else if issued > 0 {
+			log.Printf("Issued %d monthly invoice(s)", issued)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
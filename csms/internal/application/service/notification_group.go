@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+// NotificationGroup sends every notification to each of its notifiers, so
+// one failing notifier does not keep the others from being told.
+type NotificationGroup struct {
+	notifiers []domain.NotificationService
+}
+
+func NewNotificationGroup(notifiers ...domain.NotificationService) *NotificationGroup {
+	return &NotificationGroup{notifiers: notifiers}
+}
+
+func (g *NotificationGroup) SendTransactionNotification(ctx context.Context, transaction *domain.Transaction) error {
+	var errs []error
+	for _, notifier := range g.notifiers {
+		errs = append(errs, notifier.SendTransactionNotification(ctx, transaction))
+	}
+	return errors.Join(errs...)
+}
+
+func (g *NotificationGroup) SendErrorNotification(ctx context.Context, chargePointID uint, message string) error {
+	var errs []error
+	for _, notifier := range g.notifiers {
+		errs = append(errs, notifier.SendErrorNotification(ctx, chargePointID, message))
+	}
+	return errors.Join(errs...)
+}
+
+func (g *NotificationGroup) SendStatusNotification(ctx context.Context, chargePointID uint, connectorID int, status string) error {
+	var errs []error
+	for _, notifier := range g.notifiers {
+		errs = append(errs, notifier.SendStatusNotification(ctx, chargePointID, connectorID, status))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,340 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+// ocpiMapper converts charge points, transactions and tariffs to OCPI 2.2.1
+// objects published under our country code and party ID. Each connector is
+// an EVSE with a single OCPI connector.
+type ocpiMapper struct {
+	countryCode string
+	partyID     string
+	rules       pricingRules
+}
+
+func (m ocpiMapper) location(cp *domain.ChargePoint) domain.OCPILocation {
+	location := domain.OCPILocation{
+		CountryCode: m.countryCode,
+		PartyID:     m.partyID,
+		ID:          cp.ChargePointCode,
+		Publish:     true,
+		Name:        cp.Site,
+		Address:     cp.Address,
+		City:        cp.City,
+		PostalCode:  cp.PostalCode,
+		Country:     cp.Country,
+		Coordinates: ocpiCoordinates(cp),
+		EVSEs:       []domain.OCPIEVSE{},
+		LastUpdated: cp.UpdatedAt,
+	}
+
+	for i := range cp.Connectors {
+		evse := m.evse(cp, &cp.Connectors[i])
+		location.EVSEs = append(location.EVSEs, evse)
+		if evse.LastUpdated.After(location.LastUpdated) {
+			location.LastUpdated = evse.LastUpdated
+		}
+	}
+
+	return location
+}
+
+func (m ocpiMapper) evse(cp *domain.ChargePoint, connector *domain.Connector) domain.OCPIEVSE {
+	return domain.OCPIEVSE{
+		UID:    ocpiEVSEUID(cp, connector.ConnectorID),
+		EVSEID: m.evseID(cp, connector.ConnectorID),
+		Status: ocpiEVSEStatus(connector.Status),
+		Connectors: []domain.OCPIConnector{{
+			ID:          "1",
+			Standard:    connector.Standard,
+			Format:      connector.Format,
+			PowerType:   connector.PowerType,
+			MaxVoltage:  connector.MaxVoltage,
+			MaxAmperage: connector.MaxAmperage,
+			LastUpdated: connector.UpdatedAt,
+		}},
+		LastUpdated: connector.UpdatedAt,
+	}
+}
+
+// session describes a transaction of a roaming driver. The token may be nil
+// when it is no longer stored.
+func (m ocpiMapper) session(transaction *domain.Transaction, party *domain.OCPIParty, token *domain.RoamingToken) domain.OCPISession {
+	session := domain.OCPISession{
+		CountryCode:   m.countryCode,
+		PartyID:       m.partyID,
+		ID:            strconv.Itoa(transaction.TransactionID),
+		StartDateTime: transaction.StartTime,
+		EndDateTime:   transaction.StopTime,
+		Kwh:           transaction.EnergyConsumed,
+		CdrToken:      ocpiCdrToken(transaction, party, token),
+		AuthMethod:    ocpiAuthMethod(token),
+		LocationID:    transaction.ChargePoint.ChargePointCode,
+		EVSEUID:       ocpiEVSEUID(&transaction.ChargePoint, transaction.ConnectorID),
+		ConnectorID:   "1",
+		Currency:      transaction.Currency,
+		Status:        ocpiSessionStatus(transaction.Status),
+		LastUpdated:   transaction.UpdatedAt,
+	}
+
+	if transaction.Currency != "" {
+		cost := ocpiPrice(transaction)
+		session.TotalCost = &cost
+	}
+
+	return session
+}
+
+// cdr describes a completed transaction. The connector and token may be nil
+// when they no longer exist.
+func (m ocpiMapper) cdr(transaction *domain.Transaction, connector *domain.Connector, party *domain.OCPIParty, token *domain.RoamingToken) domain.OCPICDR {
+	cp := &transaction.ChargePoint
+	end := transaction.StartTime
+	if transaction.StopTime != nil {
+		end = *transaction.StopTime
+	}
+	chargingEnd := end
+	if transaction.ChargingEndTime != nil && transaction.ChargingEndTime.Before(end) {
+		chargingEnd = *transaction.ChargingEndTime
+	}
+
+	totalTime := end.Sub(transaction.StartTime).Hours()
+	parkingTime := end.Sub(chargingEnd).Hours()
+
+	location := domain.OCPICdrLocation{
+		ID:          cp.ChargePointCode,
+		Name:        cp.Site,
+		Address:     cp.Address,
+		City:        cp.City,
+		PostalCode:  cp.PostalCode,
+		Country:     cp.Country,
+		Coordinates: ocpiCoordinates(cp),
+		EVSEUID:     ocpiEVSEUID(cp, transaction.ConnectorID),
+		EVSEID:      m.evseID(cp, transaction.ConnectorID),
+		ConnectorID: "1",
+	}
+	if connector != nil {
+		location.ConnectorStandard = connector.Standard
+		location.ConnectorFormat = connector.Format
+		location.ConnectorPowerType = connector.PowerType
+	}
+
+	dimensions := []domain.OCPICdrDimension{
+		{Type: "ENERGY", Volume: transaction.EnergyConsumed},
+		{Type: "TIME", Volume: chargingEnd.Sub(transaction.StartTime).Hours()},
+	}
+	if parkingTime > 0 {
+		dimensions = append(dimensions, domain.OCPICdrDimension{Type: "PARKING_TIME", Volume: parkingTime})
+	}
+
+	cdr := domain.OCPICDR{
+		CountryCode:   m.countryCode,
+		PartyID:       m.partyID,
+		ID:            fmt.Sprintf("CDR%d", transaction.TransactionID),
+		StartDateTime: transaction.StartTime,
+		EndDateTime:   end,
+		SessionID:     strconv.Itoa(transaction.TransactionID),
+		CdrToken:      ocpiCdrToken(transaction, party, token),
+		AuthMethod:    ocpiAuthMethod(token),
+		CdrLocation:   location,
+		Currency:      transaction.Currency,
+		ChargingPeriods: []domain.OCPIChargingPeriod{{
+			StartDateTime: transaction.StartTime,
+			Dimensions:    dimensions,
+		}},
+		TotalCost:        ocpiPrice(transaction),
+		TotalEnergy:      transaction.EnergyConsumed,
+		TotalTime:        totalTime,
+		TotalParkingTime: parkingTime,
+		LastUpdated:      transaction.UpdatedAt,
+	}
+	if transaction.TariffID != nil {
+		cdr.ChargingPeriods[0].TariffID = strconv.FormatUint(uint64(*transaction.TariffID), 10)
+	}
+
+	return cdr
+}
+
+// tariff publishes a tariff with prices excluding VAT, as OCPI expects. Time
+// and idle prices are per minute here and per hour in OCPI.
+func (m ocpiMapper) tariff(tariff *domain.Tariff) domain.OCPITariff {
+	rules := m.rules.rulesFor(tariff)
+
+	result := domain.OCPITariff{
+		CountryCode:   m.countryCode,
+		PartyID:       m.partyID,
+		ID:            strconv.FormatUint(uint64(tariff.ID), 10),
+		Currency:      strings.ToUpper(rules.currency),
+		Elements:      []domain.OCPITariffElement{},
+		StartDateTime: tariff.ValidFrom,
+		EndDateTime:   tariff.ValidTo,
+		LastUpdated:   tariff.UpdatedAt,
+	}
+	if tariff.Name != "" {
+		result.TariffAltText = []domain.OCPIDisplayText{{Language: "en", Text: tariff.Name}}
+	}
+
+	var vat *float64
+	if rules.taxRate > 0 {
+		rate := rules.taxRate
+		vat = &rate
+	}
+
+	for _, element := range tariff.Elements {
+		price := element.Price
+		if rules.taxInclusive && rules.taxRate > 0 {
+			price = price * 100 / (100 + rules.taxRate)
+		}
+
+		component := domain.OCPIPriceComponent{Price: roundPrice(price), Vat: vat, StepSize: 1}
+		switch element.Component {
+		case domain.TariffComponentEnergy:
+			component.Type = "ENERGY"
+		case domain.TariffComponentTime:
+			component.Type = "TIME"
+			component.Price = roundPrice(price * 60)
+		case domain.TariffComponentFlat:
+			component.Type = "FLAT"
+		case domain.TariffComponentIdle:
+			component.Type = "PARKING_TIME"
+			component.Price = roundPrice(price * 60)
+		default:
+			continue
+		}
+
+		ocpiElement := domain.OCPITariffElement{PriceComponents: []domain.OCPIPriceComponent{component}}
+		if element.StartTime != "" || element.DaysOfWeek != "" {
+			ocpiElement.Restrictions = &domain.OCPITariffRestrictions{
+				StartTime: element.StartTime,
+				EndTime:   element.EndTime,
+				DayOfWeek: ocpiDaysOfWeek(element.DaysOfWeek),
+			}
+		}
+		result.Elements = append(result.Elements, ocpiElement)
+	}
+
+	return result
+}
+
+// evseID builds an eMI3 EVSE ID such as ID*CSM*ECP001*1.
+func (m ocpiMapper) evseID(cp *domain.ChargePoint, connectorID int) string {
+	code := strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return unicode.ToUpper(r)
+		}
+		return -1
+	}, cp.ChargePointCode)
+
+	return fmt.Sprintf("%s*%s*E%s*%d", m.countryCode, m.partyID, code, connectorID)
+}
+
+func ocpiEVSEUID(cp *domain.ChargePoint, connectorID int) string {
+	return fmt.Sprintf("%s-%d", cp.ChargePointCode, connectorID)
+}
+
+func ocpiCoordinates(cp *domain.ChargePoint) domain.OCPIGeoLocation {
+	return domain.OCPIGeoLocation{
+		Latitude:  strconv.FormatFloat(cp.Latitude, 'f', 6, 64),
+		Longitude: strconv.FormatFloat(cp.Longitude, 'f', 6, 64),
+	}
+}
+
+// ocpiCdrToken identifies the driver's token as issued by the party.
+func ocpiCdrToken(transaction *domain.Transaction, party *domain.OCPIParty, token *domain.RoamingToken) domain.OCPICdrToken {
+	if token != nil {
+		return domain.OCPICdrToken{
+			CountryCode: token.CountryCode,
+			PartyID:     token.PartyID,
+			UID:         token.UID,
+			Type:        token.Type,
+			ContractID:  token.ContractID,
+		}
+	}
+
+	return domain.OCPICdrToken{
+		CountryCode: party.CountryCode,
+		PartyID:     party.PartyID,
+		UID:         transaction.IDTag.Tag,
+		Type:        "RFID",
+		ContractID:  transaction.IDTag.Tag,
+	}
+}
+
+// ocpiAuthMethod tells whether the token was accepted from the whitelist or
+// after asking its eMSP.
+func ocpiAuthMethod(token *domain.RoamingToken) string {
+	if token == nil || token.Whitelist == domain.OCPIWhitelistAlways || token.Whitelist == domain.OCPIWhitelistAllowed {
+		return "WHITELIST"
+	}
+	return "AUTH_REQUEST"
+}
+
+func ocpiPrice(transaction *domain.Transaction) domain.OCPIPrice {
+	inclVat := majorUnits(transaction.GrossAmount, transaction.Currency)
+	return domain.OCPIPrice{
+		ExclVat: majorUnits(transaction.NetAmount, transaction.Currency),
+		InclVat: &inclVat,
+	}
+}
+
+// ocpiEVSEStatus maps an OCPP 1.6 connector status to an OCPI EVSE status.
+func ocpiEVSEStatus(status string) string {
+	switch status {
+	case domain.ChargePointStatusAvailable:
+		return "AVAILABLE"
+	case domain.ChargePointStatusPreparing, domain.ChargePointStatusCharging, domain.ChargePointStatusSuspendedEV,
+		domain.ChargePointStatusSuspendedEVSE, domain.ChargePointStatusFinishing, domain.ChargePointStatusOccupied:
+		return "CHARGING"
+	case domain.ChargePointStatusReserved:
+		return "RESERVED"
+	case domain.ChargePointStatusUnavailable:
+		return "INOPERATIVE"
+	case domain.ChargePointStatusFaulted:
+		return "OUTOFORDER"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func ocpiSessionStatus(status string) string {
+	switch status {
+	case domain.TransactionStatusCompleted:
+		return "COMPLETED"
+	case domain.TransactionStatusPending:
+		return "PENDING"
+	case domain.TransactionStatusFailed, domain.TransactionStatusCancelled:
+		return "INVALID"
+	default:
+		return "ACTIVE"
+	}
+}
+
+func ocpiDaysOfWeek(days string) []string {
+	var result []string
+	for _, day := range strings.Split(days, ",") {
+		for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+			if strings.EqualFold(strings.TrimSpace(day), weekday.String()[:3]) {
+				result = append(result, strings.ToUpper(weekday.String()))
+			}
+		}
+	}
+	return result
+}
+
+// majorUnits converts minor units to a decimal amount of the currency.
+func majorUnits(amount int64, currency string) float64 {
+	return float64(amount) / math.Pow10(domain.CurrencyExponent(currency))
+}
+
+// roundPrice keeps the 4 decimals OCPI prices are given with.
+func roundPrice(price float64) float64 {
+	return math.Round(price*1e4) / 1e4
+}
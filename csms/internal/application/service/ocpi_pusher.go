@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/config"
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+// OCPIPusher sends changes to the receiver endpoints of registered parties:
+// EVSE status to every party, sessions and CDRs to the party whose driver is
+// charging. Notifications are queued so OCPP handlers never wait on a
+// roaming partner; when the queue is full updates are dropped and parties
+// catch up by polling.
+type OCPIPusher struct {
+	partyRepo       domain.OCPIPartyRepository
+	chargePointRepo domain.ChargePointRepository
+	connectorRepo   domain.ConnectorRepository
+	transactionRepo domain.TransactionRepository
+	tokenRepo       domain.RoamingTokenRepository
+	client          domain.OCPIClient
+	mapper          ocpiMapper
+	timeout         time.Duration
+	queue           chan func(ctx context.Context) error
+}
+
+// NewOCPIPusher creates a new OCPI pusher
+func NewOCPIPusher(
+	partyRepo domain.OCPIPartyRepository,
+	chargePointRepo domain.ChargePointRepository,
+	connectorRepo domain.ConnectorRepository,
+	transactionRepo domain.TransactionRepository,
+	tokenRepo domain.RoamingTokenRepository,
+	client domain.OCPIClient,
+	ocpiConfig config.OCPIConfig,
+	tariffConfig config.TariffConfig,
+) *OCPIPusher {
+	return &OCPIPusher{
+		partyRepo:       partyRepo,
+		chargePointRepo: chargePointRepo,
+		connectorRepo:   connectorRepo,
+		transactionRepo: transactionRepo,
+		tokenRepo:       tokenRepo,
+		client:          client,
+		mapper:          newOCPIMapper(ocpiConfig, tariffConfig),
+		timeout:         ocpiConfig.PushTimeout,
+		queue:           make(chan func(ctx context.Context) error, ocpiConfig.PushQueueSize),
+	}
+}
+
+// Run sends queued updates one at a time until the context is cancelled
+func (p *OCPIPusher) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case push := <-p.queue:
+			pushCtx, cancel := context.WithTimeout(ctx, p.timeout)
+			if err := push(pushCtx); err != nil {
+				log.Printf("Error pushing OCPI update: %v", err)
+			}
+			cancel()
+		}
+	}
+}
+
+func (p *OCPIPusher) SendTransactionNotification(ctx context.Context, transaction *domain.Transaction) error {
+	if transaction.OCPIPartyID == nil {
+		return nil
+	}
+
+	partyID, id := *transaction.OCPIPartyID, transaction.ID
+	p.enqueue(func(ctx context.Context) error {
+		return p.pushSession(ctx, partyID, id)
+	})
+	return nil
+}
+
+func (p *OCPIPusher) SendErrorNotification(ctx context.Context, chargePointID uint, error string) error {
+	return nil
+}
+
+func (p *OCPIPusher) SendStatusNotification(ctx context.Context, chargePointID uint, connectorID int, status string) error {
+	p.enqueue(func(ctx context.Context) error {
+		return p.pushEVSEStatus(ctx, chargePointID, connectorID, status)
+	})
+	return nil
+}
+
+func (p *OCPIPusher) enqueue(push func(ctx context.Context) error) {
+	select {
+	case p.queue <- push:
+	default:
+		log.Printf("OCPI push queue is full, dropping update")
+	}
+}
+
+// pushSession puts the session to the party and, once the transaction is
+// completed, posts its CDR.
+func (p *OCPIPusher) pushSession(ctx context.Context, partyID, transactionID uint) error {
+	party, err := p.partyRepo.GetByID(ctx, partyID)
+	if err != nil || party.Status != domain.OCPIPartyStatusRegistered {
+		return nil
+	}
+
+	transaction, err := p.transactionRepo.GetByID(ctx, transactionID)
+	if err != nil {
+		return fmt.Errorf("transaction %d not found", transactionID)
+	}
+
+	token, err := p.tokenRepo.GetByPartyAndUID(ctx, party.ID, transaction.IDTag.Tag)
+	if err != nil {
+		token = nil
+	}
+
+	if url := receiverURL(party, domain.OCPIModuleSessions); url != "" {
+		session := p.mapper.session(transaction, party, token)
+		url = fmt.Sprintf("%s/%s/%s/%s", url, p.mapper.countryCode, p.mapper.partyID, session.ID)
+		if err := p.client.Send(ctx, http.MethodPut, url, party.RemoteToken, session); err != nil {
+			return fmt.Errorf("session %s to %s: %w", session.ID, party.Name, err)
+		}
+	}
+
+	if transaction.Status != domain.TransactionStatusCompleted {
+		return nil
+	}
+
+	url := receiverURL(party, domain.OCPIModuleCDRs)
+	if url == "" {
+		return nil
+	}
+
+	connector, err := p.connectorRepo.GetByChargePointAndConnectorID(ctx, transaction.ChargePointID, transaction.ConnectorID)
+	if err != nil {
+		connector = nil
+	}
+	cdr := p.mapper.cdr(transaction, connector, party, token)
+	if err := p.client.Send(ctx, http.MethodPost, url, party.RemoteToken, cdr); err != nil {
+		return fmt.Errorf("CDR %s to %s: %w", cdr.ID, party.Name, err)
+	}
+	return nil
+}
+
+// pushEVSEStatus patches the EVSE's status at every registered party.
+func (p *OCPIPusher) pushEVSEStatus(ctx context.Context, chargePointID uint, connectorID int, status string) error {
+	chargePoint, err := p.chargePointRepo.GetByID(ctx, chargePointID)
+	if err != nil {
+		return fmt.Errorf("charge point %d not found", chargePointID)
+	}
+
+	parties, err := p.partyRepo.ListRegistered(ctx)
+	if err != nil {
+		return err
+	}
+
+	patch := map[string]interface{}{
+		"status":       ocpiEVSEStatus(status),
+		"last_updated": time.Now().UTC(),
+	}
+	for i := range parties {
+		party := &parties[i]
+		url := receiverURL(party, domain.OCPIModuleLocations)
+		if url == "" {
+			continue
+		}
+
+		url = fmt.Sprintf("%s/%s/%s/%s/%s", url, p.mapper.countryCode, p.mapper.partyID,
+			chargePoint.ChargePointCode, ocpiEVSEUID(chargePoint, connectorID))
+		if err := p.client.Send(ctx, http.MethodPatch, url, party.RemoteToken, patch); err != nil {
+			log.Printf("Error pushing EVSE status to %s: %v", party.Name, err)
+		}
+	}
+	return nil
+}
+
+// receiverURL returns the party's receiver endpoint of a module, or "" when
+// it has none.
+func receiverURL(party *domain.OCPIParty, module string) string {
+	for _, endpoint := range party.Endpoints {
+		if endpoint.Identifier == module && endpoint.Role == domain.OCPIRoleReceiver {
+			return strings.TrimRight(endpoint.URL, "/")
+		}
+	}
+	return ""
+}
@@ -0,0 +1,301 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/malikkhoiri/csms/internal/config"
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+type OCPIService struct {
+	partyRepo       domain.OCPIPartyRepository
+	chargePointRepo domain.ChargePointRepository
+	connectorRepo   domain.ConnectorRepository
+	transactionRepo domain.TransactionRepository
+	tariffRepo      domain.TariffRepository
+	tokenRepo       domain.RoamingTokenRepository
+	client          domain.OCPIClient
+	ocpiConfig      config.OCPIConfig
+	mapper          ocpiMapper
+}
+
+func NewOCPIService(
+	partyRepo domain.OCPIPartyRepository,
+	chargePointRepo domain.ChargePointRepository,
+	connectorRepo domain.ConnectorRepository,
+	transactionRepo domain.TransactionRepository,
+	tariffRepo domain.TariffRepository,
+	tokenRepo domain.RoamingTokenRepository,
+	client domain.OCPIClient,
+	ocpiConfig config.OCPIConfig,
+	tariffConfig config.TariffConfig,
+) domain.OCPIService {
+	return &OCPIService{
+		partyRepo:       partyRepo,
+		chargePointRepo: chargePointRepo,
+		connectorRepo:   connectorRepo,
+		transactionRepo: transactionRepo,
+		tariffRepo:      tariffRepo,
+		tokenRepo:       tokenRepo,
+		client:          client,
+		ocpiConfig:      ocpiConfig,
+		mapper:          newOCPIMapper(ocpiConfig, tariffConfig),
+	}
+}
+
+func newOCPIMapper(ocpiConfig config.OCPIConfig, tariffConfig config.TariffConfig) ocpiMapper {
+	return ocpiMapper{
+		countryCode: strings.ToUpper(ocpiConfig.CountryCode),
+		partyID:     strings.ToUpper(ocpiConfig.PartyID),
+		rules: pricingRules{
+			currency:     strings.ToUpper(tariffConfig.Currency),
+			taxRate:      tariffConfig.TaxRate,
+			taxInclusive: tariffConfig.TaxInclusive,
+			rounding:     tariffConfig.Rounding,
+		},
+	}
+}
+
+// CreateParty registers a roaming partner and issues the token A it uses to
+// start the credentials handshake.
+func (s *OCPIService) CreateParty(ctx context.Context, request *domain.OCPIPartyRequest) (*domain.OCPIRegistration, error) {
+	token, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+
+	party := &domain.OCPIParty{
+		Name:   request.Name,
+		Token:  token,
+		Status: domain.OCPIPartyStatusPending,
+	}
+	if err := s.partyRepo.Create(ctx, party); err != nil {
+		return nil, err
+	}
+
+	return &domain.OCPIRegistration{
+		Party:       party,
+		TokenA:      token,
+		VersionsURL: s.versionsURL(),
+	}, nil
+}
+
+func (s *OCPIService) ListParties(ctx context.Context, limit, offset int) ([]domain.OCPIParty, error) {
+	return s.partyRepo.List(ctx, limit, offset)
+}
+
+func (s *OCPIService) DeleteParty(ctx context.Context, id uint) error {
+	if _, err := s.partyRepo.GetByID(ctx, id); err != nil {
+		return errors.New("party not found")
+	}
+	return s.partyRepo.Delete(ctx, id)
+}
+
+func (s *OCPIService) Authenticate(ctx context.Context, token string) (*domain.OCPIParty, error) {
+	if token == "" {
+		return nil, errors.New("missing token")
+	}
+
+	party, err := s.partyRepo.GetByToken(ctx, token)
+	if err != nil {
+		return nil, errors.New("unknown token")
+	}
+	return party, nil
+}
+
+func (s *OCPIService) Versions() []domain.OCPIVersion {
+	return []domain.OCPIVersion{{
+		Version: domain.OCPIVersion221,
+		URL:     s.baseURL() + "/" + domain.OCPIVersion221,
+	}}
+}
+
+// VersionDetails lists the modules we implement: the CPO's sender modules,
+// credentials and the Tokens receiver.
+func (s *OCPIService) VersionDetails() *domain.OCPIVersionDetails {
+	base := s.baseURL() + "/" + domain.OCPIVersion221
+	endpoints := []domain.OCPIEndpoint{{
+		Identifier: domain.OCPIModuleCredentials,
+		Role:       domain.OCPIRoleSender,
+		URL:        base + "/credentials",
+	}}
+	for _, module := range []string{domain.OCPIModuleLocations, domain.OCPIModuleSessions, domain.OCPIModuleCDRs, domain.OCPIModuleTariffs} {
+		endpoints = append(endpoints, domain.OCPIEndpoint{
+			Identifier: module,
+			Role:       domain.OCPIRoleSender,
+			URL:        base + "/" + module,
+		})
+	}
+	endpoints = append(endpoints, domain.OCPIEndpoint{
+		Identifier: domain.OCPIModuleTokens,
+		Role:       domain.OCPIRoleReceiver,
+		URL:        base + "/" + domain.OCPIModuleTokens,
+	})
+
+	return &domain.OCPIVersionDetails{
+		Version:   domain.OCPIVersion221,
+		Endpoints: endpoints,
+	}
+}
+
+func (s *OCPIService) Credentials(party *domain.OCPIParty) *domain.OCPICredentials {
+	return &domain.OCPICredentials{
+		Token: party.Token,
+		URL:   s.versionsURL(),
+		Roles: []domain.OCPICredentialsRole{{
+			Role:            "CPO",
+			BusinessDetails: domain.OCPIBusinessDetails{Name: s.ocpiConfig.BusinessName},
+			PartyID:         s.mapper.partyID,
+			CountryCode:     s.mapper.countryCode,
+		}},
+	}
+}
+
+func (s *OCPIService) RegisterCredentials(ctx context.Context, party *domain.OCPIParty, credentials *domain.OCPICredentials) (*domain.OCPICredentials, error) {
+	if credentials.Token == "" || credentials.URL == "" {
+		return nil, errors.New("token and url are required")
+	}
+	if len(credentials.Roles) == 0 {
+		return nil, errors.New("at least one role is required")
+	}
+
+	versions, err := s.client.GetVersions(ctx, credentials.URL, credentials.Token)
+	if err != nil {
+		return nil, fmt.Errorf("fetching versions: %w", err)
+	}
+
+	var detailsURL string
+	for _, version := range versions {
+		if version.Version == domain.OCPIVersion221 {
+			detailsURL = version.URL
+		}
+	}
+	if detailsURL == "" {
+		return nil, fmt.Errorf("party does not support OCPI %s", domain.OCPIVersion221)
+	}
+
+	details, err := s.client.GetVersionDetails(ctx, detailsURL, credentials.Token)
+	if err != nil {
+		return nil, fmt.Errorf("fetching version details: %w", err)
+	}
+
+	token, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+
+	role := credentials.Roles[0]
+	party.CountryCode = strings.ToUpper(role.CountryCode)
+	party.PartyID = strings.ToUpper(role.PartyID)
+	party.Role = role.Role
+	party.Token = token
+	party.RemoteToken = credentials.Token
+	party.VersionsURL = credentials.URL
+	party.Version = domain.OCPIVersion221
+	party.Status = domain.OCPIPartyStatusRegistered
+	party.Endpoints = make([]domain.OCPIEndpoint, 0, len(details.Endpoints))
+	for _, endpoint := range details.Endpoints {
+		party.Endpoints = append(party.Endpoints, domain.OCPIEndpoint{
+			Identifier: endpoint.Identifier,
+			Role:       endpoint.Role,
+			URL:        endpoint.URL,
+		})
+	}
+
+	if err := s.partyRepo.Update(ctx, party); err != nil {
+		return nil, err
+	}
+	return s.Credentials(party), nil
+}
+
+func (s *OCPIService) UnregisterCredentials(ctx context.Context, party *domain.OCPIParty) error {
+	return s.partyRepo.Delete(ctx, party.ID)
+}
+
+func (s *OCPIService) ListLocations(ctx context.Context, query domain.OCPIQuery) ([]domain.OCPILocation, int64, error) {
+	chargePoints, total, err := s.chargePointRepo.ListUpdated(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	locations := make([]domain.OCPILocation, 0, len(chargePoints))
+	for i := range chargePoints {
+		locations = append(locations, s.mapper.location(&chargePoints[i]))
+	}
+	return locations, total, nil
+}
+
+func (s *OCPIService) GetLocation(ctx context.Context, locationID string) (*domain.OCPILocation, error) {
+	chargePoint, err := s.chargePointRepo.GetByCode(ctx, locationID)
+	if err != nil {
+		return nil, errors.New("location not found")
+	}
+
+	location := s.mapper.location(chargePoint)
+	return &location, nil
+}
+
+func (s *OCPIService) ListSessions(ctx context.Context, party *domain.OCPIParty, query domain.OCPIQuery) ([]domain.OCPISession, int64, error) {
+	transactions, total, err := s.transactionRepo.ListByParty(ctx, party.ID, false, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sessions := make([]domain.OCPISession, 0, len(transactions))
+	for i := range transactions {
+		token := s.token(ctx, &transactions[i])
+		sessions = append(sessions, s.mapper.session(&transactions[i], party, token))
+	}
+	return sessions, total, nil
+}
+
+func (s *OCPIService) ListCDRs(ctx context.Context, party *domain.OCPIParty, query domain.OCPIQuery) ([]domain.OCPICDR, int64, error) {
+	transactions, total, err := s.transactionRepo.ListByParty(ctx, party.ID, true, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	cdrs := make([]domain.OCPICDR, 0, len(transactions))
+	for i := range transactions {
+		transaction := &transactions[i]
+		connector, err := s.connectorRepo.GetByChargePointAndConnectorID(ctx, transaction.ChargePointID, transaction.ConnectorID)
+		if err != nil {
+			connector = nil
+		}
+		cdrs = append(cdrs, s.mapper.cdr(transaction, connector, party, s.token(ctx, transaction)))
+	}
+	return cdrs, total, nil
+}
+
+func (s *OCPIService) ListTariffs(ctx context.Context, query domain.OCPIQuery) ([]domain.OCPITariff, int64, error) {
+	tariffs, total, err := s.tariffRepo.ListPublic(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	result := make([]domain.OCPITariff, 0, len(tariffs))
+	for i := range tariffs {
+		result = append(result, s.mapper.tariff(&tariffs[i]))
+	}
+	return result, total, nil
+}
+
+// token returns the roaming token the transaction was started with, or nil.
+func (s *OCPIService) token(ctx context.Context, transaction *domain.Transaction) *domain.RoamingToken {
+	token, err := s.tokenRepo.GetByPartyAndUID(ctx, *transaction.OCPIPartyID, transaction.IDTag.Tag)
+	if err != nil {
+		return nil
+	}
+	return token
+}
+
+func (s *OCPIService) baseURL() string {
+	return strings.TrimRight(s.ocpiConfig.BaseURL, "/")
+}
+
+func (s *OCPIService) versionsURL() string {
+	return s.baseURL() + "/versions"
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+// PaymentExpiryJob releases card pre-authorizations whose charge point never
+// started the session.
+type PaymentExpiryJob struct {
+	paymentService domain.PaymentService
+	interval       time.Duration
+}
+
+// NewPaymentExpiryJob creates a new payment expiry job
+func NewPaymentExpiryJob(paymentService domain.PaymentService, interval time.Duration) *PaymentExpiryJob {
+	return &PaymentExpiryJob{
+		paymentService: paymentService,
+		interval:       interval,
+	}
+}
+
+// Run releases expired pre-authorizations on every tick until the context is
+// cancelled
+func (j *PaymentExpiryJob) Run(ctx context.Context) {
+	if j.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			released, err := j.paymentService.ReleaseExpiredPayments(ctx, time.Now())
+			if err != nil {
+				log.Printf("Error releasing expired payments: %v", err)
+			} else if released > 0 {
+				log.Printf("Released %d expired payment(s)", released)
+			}
+		}
+	}
+}
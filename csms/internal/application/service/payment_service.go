@@ -0,0 +1,298 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/config"
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+type PaymentService struct {
+	paymentRepo     domain.PaymentRepository
+	chargePointRepo domain.ChargePointRepository
+	connectorRepo   domain.ConnectorRepository
+	idTagRepo       domain.IDTagRepository
+	gateway         domain.PaymentGateway
+	commander       domain.ChargePointCommander
+	tariffService   domain.TariffService
+	paymentConfig   config.PaymentConfig
+}
+
+func NewPaymentService(
+	paymentRepo domain.PaymentRepository,
+	chargePointRepo domain.ChargePointRepository,
+	connectorRepo domain.ConnectorRepository,
+	idTagRepo domain.IDTagRepository,
+	gateway domain.PaymentGateway,
+	commander domain.ChargePointCommander,
+	tariffService domain.TariffService,
+	paymentConfig config.PaymentConfig,
+) domain.PaymentService {
+	return &PaymentService{
+		paymentRepo:     paymentRepo,
+		chargePointRepo: chargePointRepo,
+		connectorRepo:   connectorRepo,
+		idTagRepo:       idTagRepo,
+		gateway:         gateway,
+		commander:       commander,
+		tariffService:   tariffService,
+		paymentConfig:   paymentConfig,
+	}
+}
+
+// StartPaidSession pre-authorizes the card for the requested amount, or the
+// configured default, before sending RemoteStartTransaction. When userID is
+// set the idTag must belong to that user.
+func (s *PaymentService) StartPaidSession(ctx context.Context, request *domain.PaidSessionRequest, userID *uint) (*domain.Payment, error) {
+	chargePoint, err := s.chargePointRepo.GetByID(ctx, request.ChargePointID)
+	if err != nil {
+		return nil, errors.New("charge point not found")
+	}
+
+	if _, err := s.connectorRepo.GetByChargePointAndConnectorID(ctx, chargePoint.ID, request.ConnectorID); err != nil {
+		return nil, errors.New("connector not found")
+	}
+
+	idTag, err := s.idTagRepo.GetByTag(ctx, request.IDTag)
+	if err != nil {
+		return nil, errors.New("id tag not found")
+	}
+	if userID != nil && idTag.UserID != *userID {
+		return nil, errors.New("id tag does not belong to this user")
+	}
+
+	amount := request.Amount
+	if amount <= 0 {
+		amount = s.paymentConfig.PreAuthAmount
+	}
+	// Held in the currency the session will be priced in, so the final cost
+	// can be captured from it.
+	currency, err := s.tariffService.SessionCurrency(ctx, chargePoint.ID, idTag, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	intent, err := s.gateway.PreAuthorize(ctx, &domain.PaymentIntentRequest{
+		Amount:        amount,
+		Currency:      currency,
+		PaymentMethod: request.PaymentMethod,
+		Description:   fmt.Sprintf("Charging at %s, connector %d", chargePoint.ChargePointCode, request.ConnectorID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("payment not authorized: %w", err)
+	}
+
+	payment := &domain.Payment{
+		Provider:         s.gateway.Name(),
+		Reference:        intent.Reference,
+		Status:           domain.PaymentStatusAuthorized,
+		ChargePointID:    chargePoint.ID,
+		ConnectorID:      request.ConnectorID,
+		IDTag:            idTag.Tag,
+		UserID:           &idTag.UserID,
+		Currency:         currency,
+		AuthorizedAmount: intent.Amount,
+	}
+	if err := s.paymentRepo.Create(ctx, payment); err != nil {
+		if cancelErr := s.gateway.Cancel(ctx, intent.Reference); cancelErr != nil {
+			log.Printf("Error releasing payment %s: %v", intent.Reference, cancelErr)
+		}
+		return nil, err
+	}
+
+	if err := s.commander.RemoteStartTransaction(ctx, chargePoint.ID, request.ConnectorID, idTag.Tag); err != nil {
+		s.release(ctx, payment, "remote start failed: "+err.Error())
+		return nil, fmt.Errorf("charge point did not start the session: %w", err)
+	}
+
+	return payment, nil
+}
+
+func (s *PaymentService) AttachTransaction(ctx context.Context, transaction *domain.Transaction, idTag string) (*domain.Payment, error) {
+	since := time.Now().Add(-s.paymentConfig.PendingTimeout)
+	payment, err := s.paymentRepo.GetPending(ctx, transaction.ChargePointID, transaction.ConnectorID, idTag, since)
+	if err != nil {
+		return nil, nil
+	}
+
+	payment.TransactionID = &transaction.ID
+	if err := s.paymentRepo.Update(ctx, payment); err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// CapturePayment captures the transaction's gross amount, at most what was
+// pre-authorized. A session that cost nothing releases the hold instead. When
+// a captured transaction is completed again, a lower cost is refunded and a
+// higher one captured on top, within the pre-authorization.
+func (s *PaymentService) CapturePayment(ctx context.Context, transaction *domain.Transaction) (*domain.Payment, error) {
+	if transaction.PaymentID == nil {
+		return nil, nil
+	}
+
+	payment, err := s.paymentRepo.GetByID(ctx, *transaction.PaymentID)
+	if err != nil {
+		return nil, errors.New("payment not found")
+	}
+
+	if transaction.Currency != payment.Currency {
+		return payment, fmt.Errorf("transaction is priced in %s, payment is in %s", transaction.Currency, payment.Currency)
+	}
+
+	switch payment.Status {
+	case domain.PaymentStatusAuthorized:
+		if transaction.GrossAmount <= 0 {
+			s.release(ctx, payment, "")
+			return payment, nil
+		}
+		return s.capture(ctx, transaction, payment, transaction.GrossAmount)
+	case domain.PaymentStatusCaptured:
+		paid := payment.CapturedAmount - payment.RefundedAmount
+		if overpaid := paid - transaction.GrossAmount; overpaid > 0 {
+			return s.RefundPayment(ctx, payment.ID, &domain.RefundRequest{Amount: overpaid, Reason: "final cost corrected"})
+		}
+		if underpaid := transaction.GrossAmount - paid; underpaid > 0 {
+			return s.capture(ctx, transaction, payment, underpaid)
+		}
+		return payment, nil
+	default:
+		return payment, nil
+	}
+}
+
+// capture charges amount on top of what the payment already captured, at
+// most up to the pre-authorized amount. A failed first capture fails the
+// payment; a failed further capture leaves what was captured before.
+func (s *PaymentService) capture(ctx context.Context, transaction *domain.Transaction, payment *domain.Payment, amount int64) (*domain.Payment, error) {
+	if remaining := payment.AuthorizedAmount - payment.CapturedAmount; amount > remaining {
+		log.Printf("Transaction %d cost %s exceeds the pre-authorized %s, capturing %s",
+			transaction.TransactionID, formatMoney(transaction.GrossAmount, payment.Currency),
+			formatMoney(payment.AuthorizedAmount, payment.Currency), formatMoney(remaining, payment.Currency))
+		amount = remaining
+	}
+	if amount <= 0 {
+		return payment, nil
+	}
+
+	if err := s.gateway.Capture(ctx, payment.Reference, amount); err != nil {
+		if payment.Status == domain.PaymentStatusAuthorized {
+			payment.Status = domain.PaymentStatusFailed
+		}
+		payment.FailureReason = err.Error()
+		if updateErr := s.paymentRepo.Update(ctx, payment); updateErr != nil {
+			log.Printf("Error updating payment %d: %v", payment.ID, updateErr)
+		}
+		return payment, err
+	}
+
+	payment.Status = domain.PaymentStatusCaptured
+	payment.CapturedAmount += amount
+	if err := s.paymentRepo.Update(ctx, payment); err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+func (s *PaymentService) RefundPayment(ctx context.Context, id uint, request *domain.RefundRequest) (*domain.Payment, error) {
+	payment, err := s.paymentRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, errors.New("payment not found")
+	}
+
+	if payment.Status != domain.PaymentStatusCaptured {
+		return nil, fmt.Errorf("payment is %s and cannot be refunded", payment.Status)
+	}
+	if request.Amount <= 0 || request.Amount > payment.CapturedAmount-payment.RefundedAmount {
+		return nil, fmt.Errorf("refund amount must be between 1 and %d", payment.CapturedAmount-payment.RefundedAmount)
+	}
+
+	if err := s.gateway.Refund(ctx, payment.Reference, request.Amount); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Refunded %s of payment %d: %s", formatMoney(request.Amount, payment.Currency), payment.ID, request.Reason)
+	payment.RefundedAmount += request.Amount
+	if payment.RefundedAmount == payment.CapturedAmount {
+		payment.Status = domain.PaymentStatusRefunded
+	}
+	if err := s.paymentRepo.Update(ctx, payment); err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// HandleWebhook applies status changes the provider reports on its own, e.g.
+// an expired pre-authorization or a refund made in its dashboard.
+func (s *PaymentService) HandleWebhook(ctx context.Context, payload []byte, signature string) error {
+	event, err := s.gateway.VerifyWebhook(payload, signature)
+	if err != nil {
+		return err
+	}
+
+	payment, err := s.paymentRepo.GetByReference(ctx, event.Reference)
+	if err != nil {
+		return errors.New("payment not found")
+	}
+
+	switch event.Status {
+	case domain.PaymentStatusCaptured:
+		if payment.Status == domain.PaymentStatusAuthorized {
+			payment.Status = domain.PaymentStatusCaptured
+			payment.CapturedAmount = event.Amount
+		}
+	case domain.PaymentStatusCancelled, domain.PaymentStatusFailed:
+		if payment.Status == domain.PaymentStatusAuthorized {
+			payment.Status = event.Status
+		}
+	case domain.PaymentStatusRefunded:
+		if event.Amount > payment.RefundedAmount {
+			payment.RefundedAmount = event.Amount
+		}
+		if payment.RefundedAmount >= payment.CapturedAmount {
+			payment.Status = domain.PaymentStatusRefunded
+		}
+	default:
+		log.Printf("Ignoring %s webhook for payment %d", event.Status, payment.ID)
+		return nil
+	}
+
+	return s.paymentRepo.Update(ctx, payment)
+}
+
+func (s *PaymentService) ReleaseExpiredPayments(ctx context.Context, now time.Time) (int, error) {
+	payments, err := s.paymentRepo.ListExpired(ctx, now.Add(-s.paymentConfig.PendingTimeout))
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range payments {
+		s.release(ctx, &payments[i], "session did not start")
+	}
+	return len(payments), nil
+}
+
+func (s *PaymentService) GetPayment(ctx context.Context, id uint) (*domain.Payment, error) {
+	return s.paymentRepo.GetByID(ctx, id)
+}
+
+func (s *PaymentService) ListPayments(ctx context.Context, limit, offset int) ([]domain.Payment, error) {
+	return s.paymentRepo.List(ctx, limit, offset)
+}
+
+// release cancels the pre-authorization so the hold is lifted from the card.
+func (s *PaymentService) release(ctx context.Context, payment *domain.Payment, reason string) {
+	if err := s.gateway.Cancel(ctx, payment.Reference); err != nil {
+		log.Printf("Error releasing payment %d: %v", payment.ID, err)
+	}
+
+	payment.Status = domain.PaymentStatusCancelled
+	payment.FailureReason = reason
+	if err := s.paymentRepo.Update(ctx, payment); err != nil {
+		log.Printf("Error updating payment %d: %v", payment.ID, err)
+	}
+}
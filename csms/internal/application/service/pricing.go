@@ -0,0 +1,320 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+// pricedSession is what gets billed for a transaction: energy is delivered
+// between start and chargingEnd, the vehicle stays connected until stop.
+type pricedSession struct {
+	start       time.Time
+	chargingEnd time.Time
+	stop        time.Time
+	energyKWh   float64
+}
+
+// pricingRules turn computed prices into amounts: the currency, the VAT rate
+// in percent, whether prices include it and how amounts are rounded.
+type pricingRules struct {
+	currency     string
+	taxRate      float64
+	taxInclusive bool
+	rounding     string
+}
+
+// rulesFor applies the tariff's own currency and tax settings over the
+// defaults.
+func (r pricingRules) rulesFor(tariff *domain.Tariff) pricingRules {
+	if tariff.Currency != "" {
+		r.currency = tariff.Currency
+	}
+	if tariff.TaxRate != nil {
+		r.taxRate = *tariff.TaxRate
+	}
+	if tariff.TaxInclusive != nil {
+		r.taxInclusive = *tariff.TaxInclusive
+	}
+	return r
+}
+
+// calculatePrice prices a session with the given tariff. The session is split
+// at every tariff period boundary so each part is priced by the element active
+// at that time; energy is spread evenly over the charging time.
+func calculatePrice(tariff *domain.Tariff, session pricedSession, rules pricingRules) *domain.PriceBreakdown {
+	location, err := time.LoadLocation(tariff.TimeZone)
+	if err != nil {
+		location = time.UTC
+	}
+
+	tariffID := tariff.ID
+	rules = rules.rulesFor(tariff)
+	lines := make(map[*domain.TariffElement]*domain.PriceLine)
+	order := []*domain.TariffElement{}
+
+	addLine := func(element *domain.TariffElement, quantity float64, unit string) {
+		line, ok := lines[element]
+		if !ok {
+			line = &domain.PriceLine{
+				Component:   element.Component,
+				Description: describeElement(element),
+				Unit:        unit,
+				UnitPrice:   element.Price,
+			}
+			lines[element] = line
+			order = append(order, element)
+		}
+		line.Quantity += quantity
+	}
+
+	if element := activeElement(tariff.Elements, domain.TariffComponentFlat, session.start, location); element != nil {
+		addLine(element, 1, "session")
+	}
+
+	chargingDuration := session.chargingEnd.Sub(session.start)
+	if chargingDuration <= 0 {
+		if element := activeElement(tariff.Elements, domain.TariffComponentEnergy, session.start, location); element != nil {
+			addLine(element, session.energyKWh, "kWh")
+		}
+	}
+
+	for _, period := range splitPeriods(session.start, session.chargingEnd, tariff.Elements, location) {
+		duration := period.to.Sub(period.from)
+
+		if element := activeElement(tariff.Elements, domain.TariffComponentEnergy, period.from, location); element != nil {
+			addLine(element, session.energyKWh*duration.Seconds()/chargingDuration.Seconds(), "kWh")
+		}
+		if element := activeElement(tariff.Elements, domain.TariffComponentTime, period.from, location); element != nil {
+			addLine(element, duration.Minutes(), "min")
+		}
+	}
+
+	idleStart := session.chargingEnd.Add(time.Duration(idleGraceMinutes(tariff.Elements)) * time.Minute)
+	for _, period := range splitPeriods(idleStart, session.stop, tariff.Elements, location) {
+		if element := activeElement(tariff.Elements, domain.TariffComponentIdle, period.from, location); element != nil {
+			addLine(element, period.to.Sub(period.from).Minutes(), "min")
+		}
+	}
+
+	priced := make([]domain.PriceLine, 0, len(order))
+	for _, element := range order {
+		priced = append(priced, *lines[element])
+	}
+
+	breakdown := summarize(priced, rules)
+	breakdown.TariffID = &tariffID
+	return breakdown
+}
+
+// flatRatePrice is used when no tariff applies to a session.
+func flatRatePrice(pricePerKwh float64, energyKWh float64, rules pricingRules) *domain.PriceBreakdown {
+	return summarize([]domain.PriceLine{
+		{
+			Component:   domain.TariffComponentEnergy,
+			Description: domain.TariffComponentEnergy,
+			Quantity:    energyKWh,
+			Unit:        "kWh",
+			UnitPrice:   pricePerKwh,
+		},
+	}, rules)
+}
+
+// summarize rounds every line to minor units, adds them up per component and
+// derives net, tax and gross amounts. Rounding per line keeps the lines
+// adding up to the totals shown on receipts.
+func summarize(lines []domain.PriceLine, rules pricingRules) *domain.PriceBreakdown {
+	breakdown := &domain.PriceBreakdown{
+		Currency:     rules.currency,
+		TaxRate:      rules.taxRate,
+		TaxInclusive: rules.taxInclusive,
+		Lines:        lines,
+	}
+
+	var subtotal int64
+	for i := range breakdown.Lines {
+		line := &breakdown.Lines[i]
+		line.Amount = toMinorUnits(line.Quantity*line.UnitPrice, rules.currency, rules.rounding)
+
+		switch line.Component {
+		case domain.TariffComponentEnergy:
+			breakdown.EnergyCost += line.Amount
+		case domain.TariffComponentTime:
+			breakdown.TimeCost += line.Amount
+		case domain.TariffComponentFlat:
+			breakdown.FlatCost += line.Amount
+		case domain.TariffComponentIdle:
+			breakdown.IdleCost += line.Amount
+		}
+		subtotal += line.Amount
+	}
+
+	if rules.taxInclusive {
+		breakdown.GrossAmount = subtotal
+		breakdown.NetAmount = roundAmount(float64(subtotal)*100/(100+rules.taxRate), rules.rounding)
+		breakdown.TaxAmount = breakdown.GrossAmount - breakdown.NetAmount
+	} else {
+		breakdown.NetAmount = subtotal
+		breakdown.TaxAmount = roundAmount(float64(subtotal)*rules.taxRate/100, rules.rounding)
+		breakdown.GrossAmount = breakdown.NetAmount + breakdown.TaxAmount
+	}
+
+	return breakdown
+}
+
+type pricePeriod struct {
+	from time.Time
+	to   time.Time
+}
+
+// splitPeriods cuts [from, to) at midnight and at every element start and
+// end time in the tariff's time zone.
+func splitPeriods(from, to time.Time, elements []domain.TariffElement, location *time.Location) []pricePeriod {
+	if !to.After(from) {
+		return nil
+	}
+
+	boundaries := []time.Time{from, to}
+	localFrom := from.In(location)
+	for day := time.Date(localFrom.Year(), localFrom.Month(), localFrom.Day(), 0, 0, 0, 0, location); day.Before(to); day = day.AddDate(0, 0, 1) {
+		candidates := []time.Time{day}
+		for _, element := range elements {
+			for _, clock := range []string{element.StartTime, element.EndTime} {
+				if clock == "" {
+					continue
+				}
+				minutes, err := parseClock(clock)
+				if err != nil {
+					continue
+				}
+				candidates = append(candidates, time.Date(day.Year(), day.Month(), day.Day(), minutes/60, minutes%60, 0, 0, location))
+			}
+		}
+		for _, candidate := range candidates {
+			if candidate.After(from) && candidate.Before(to) {
+				boundaries = append(boundaries, candidate)
+			}
+		}
+	}
+
+	sort.Slice(boundaries, func(i, j int) bool {
+		return boundaries[i].Before(boundaries[j])
+	})
+
+	periods := []pricePeriod{}
+	for i := 1; i < len(boundaries); i++ {
+		if boundaries[i].After(boundaries[i-1]) {
+			periods = append(periods, pricePeriod{from: boundaries[i-1], to: boundaries[i]})
+		}
+	}
+	return periods
+}
+
+// activeElement returns the element pricing the component at the given time.
+// Elements restricted to a period win over unrestricted ones.
+func activeElement(elements []domain.TariffElement, component string, at time.Time, location *time.Location) *domain.TariffElement {
+	local := at.In(location)
+
+	var fallback *domain.TariffElement
+	for i := range elements {
+		element := &elements[i]
+		if element.Component != component {
+			continue
+		}
+
+		if element.StartTime == "" && element.DaysOfWeek == "" {
+			if fallback == nil {
+				fallback = element
+			}
+			continue
+		}
+
+		if element.DaysOfWeek != "" && !containsWeekday(element.DaysOfWeek, local.Weekday()) {
+			continue
+		}
+		if inWindow, err := clockWindowContains(element.StartTime, element.EndTime, local); err != nil || !inWindow {
+			continue
+		}
+		return element
+	}
+
+	return fallback
+}
+
+func idleGraceMinutes(elements []domain.TariffElement) int {
+	grace := 0
+	for _, element := range elements {
+		if element.Component == domain.TariffComponentIdle && element.GraceMinutes > grace {
+			grace = element.GraceMinutes
+		}
+	}
+	return grace
+}
+
+func describeElement(element *domain.TariffElement) string {
+	description := element.Component
+	if element.StartTime != "" {
+		description += fmt.Sprintf(" %s-%s", element.StartTime, element.EndTime)
+	}
+	if element.DaysOfWeek != "" {
+		description += " " + element.DaysOfWeek
+	}
+	return description
+}
+
+// clockWindowContains checks a daily "HH:MM" window against a local time.
+// Windows ending before they start span midnight, e.g. 22:00-06:00. An empty
+// window always matches.
+func clockWindowContains(startTime, endTime string, local time.Time) (bool, error) {
+	if startTime == "" {
+		return true, nil
+	}
+
+	start, err := parseClock(startTime)
+	if err != nil {
+		return false, err
+	}
+	end, err := parseClock(endTime)
+	if err != nil {
+		return false, err
+	}
+
+	minute := local.Hour()*60 + local.Minute()
+	if start <= end {
+		return minute >= start && minute < end, nil
+	}
+	return minute >= start || minute < end, nil
+}
+
+// containsWeekday checks a comma separated list of day abbreviations such as
+// "Mon,Tue,Wed".
+func containsWeekday(days string, weekday time.Weekday) bool {
+	for _, day := range strings.Split(days, ",") {
+		if strings.EqualFold(strings.TrimSpace(day), weekday.String()[:3]) {
+			return true
+		}
+	}
+	return false
+}
+
+func validWeekdays(days string) bool {
+	if days == "" {
+		return true
+	}
+	for _, day := range strings.Split(days, ",") {
+		valid := false
+		for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+			if strings.EqualFold(strings.TrimSpace(day), weekday.String()[:3]) {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return false
+		}
+	}
+	return true
+}
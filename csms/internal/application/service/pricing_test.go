@@ -0,0 +1,250 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+func at(hour, minute int) time.Time {
+	return time.Date(2026, time.October, 19, hour, minute, 0, 0, time.UTC)
+}
+
+func TestActiveElement(t *testing.T) {
+	elements := []domain.TariffElement{
+		{ID: 1, Component: domain.TariffComponentEnergy, Price: 0.30},
+		{ID: 2, Component: domain.TariffComponentEnergy, Price: 0.20, StartTime: "22:00", EndTime: "06:00"},
+		{ID: 3, Component: domain.TariffComponentEnergy, Price: 0.25, DaysOfWeek: "Sat,Sun"},
+		{ID: 4, Component: domain.TariffComponentIdle, Price: 0.10},
+	}
+
+	tests := []struct {
+		name      string
+		component string
+		at        time.Time
+		want      uint
+	}{
+		{"unrestricted element outside any window", domain.TariffComponentEnergy, at(12, 0), 1},
+		{"overnight window before midnight", domain.TariffComponentEnergy, at(23, 0), 2},
+		{"overnight window after midnight", domain.TariffComponentEnergy, at(5, 59), 2},
+		{"overnight window end is exclusive", domain.TariffComponentEnergy, at(6, 0), 1},
+		{"overnight window start is inclusive", domain.TariffComponentEnergy, at(22, 0), 2},
+		{"weekday restriction on a weekend", domain.TariffComponentEnergy, time.Date(2026, time.October, 17, 12, 0, 0, 0, time.UTC), 3},
+		{"other component", domain.TariffComponentIdle, at(23, 0), 4},
+		{"no element for the component", domain.TariffComponentFlat, at(12, 0), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			element := activeElement(elements, tt.component, tt.at, time.UTC)
+			var got uint
+			if element != nil {
+				got = element.ID
+			}
+			if got != tt.want {
+				t.Errorf("activeElement() = element %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitPeriods(t *testing.T) {
+	offPeak := []domain.TariffElement{
+		{Component: domain.TariffComponentEnergy, Price: 0.20, StartTime: "22:00", EndTime: "06:00"},
+	}
+	jakarta := time.FixedZone("WIB", 7*60*60)
+
+	tests := []struct {
+		name     string
+		from     time.Time
+		to       time.Time
+		elements []domain.TariffElement
+		location *time.Location
+		want     []time.Time
+	}{
+		{
+			name:     "empty period",
+			from:     at(10, 0),
+			to:       at(10, 0),
+			location: time.UTC,
+		},
+		{
+			name:     "no boundary inside",
+			from:     at(10, 0),
+			to:       at(11, 0),
+			elements: offPeak,
+			location: time.UTC,
+			want:     []time.Time{at(10, 0), at(11, 0)},
+		},
+		{
+			name:     "overnight cut at window start, midnight and window end",
+			from:     at(21, 0),
+			to:       at(21, 0).Add(10 * time.Hour),
+			elements: offPeak,
+			location: time.UTC,
+			want:     []time.Time{at(21, 0), at(22, 0), at(24, 0), at(30, 0), at(31, 0)},
+		},
+		{
+			name:     "boundaries in the tariff time zone",
+			from:     at(14, 0),
+			to:       at(16, 0),
+			elements: offPeak,
+			location: jakarta,
+			want:     []time.Time{at(14, 0), at(15, 0), at(16, 0)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			periods := splitPeriods(tt.from, tt.to, tt.elements, tt.location)
+			if len(tt.want) == 0 {
+				if len(periods) != 0 {
+					t.Fatalf("splitPeriods() = %v, want none", periods)
+				}
+				return
+			}
+			if len(periods) != len(tt.want)-1 {
+				t.Fatalf("splitPeriods() returned %d periods, want %d", len(periods), len(tt.want)-1)
+			}
+			for i, period := range periods {
+				if !period.from.Equal(tt.want[i]) || !period.to.Equal(tt.want[i+1]) {
+					t.Errorf("period %d = %s-%s, want %s-%s", i, period.from, period.to, tt.want[i], tt.want[i+1])
+				}
+			}
+		})
+	}
+}
+
+func TestCalculatePrice(t *testing.T) {
+	rules := pricingRules{currency: "EUR", rounding: domain.RoundingHalfUp}
+	taxRate := 20.0
+	inclusive := true
+
+	tests := []struct {
+		name    string
+		tariff  domain.Tariff
+		session pricedSession
+		want    domain.PriceBreakdown
+	}{
+		{
+			name: "energy split at a time-of-use boundary",
+			tariff: domain.Tariff{TimeZone: "UTC", Elements: []domain.TariffElement{
+				{Component: domain.TariffComponentEnergy, Price: 0.30},
+				{Component: domain.TariffComponentEnergy, Price: 0.20, StartTime: "22:00", EndTime: "06:00"},
+			}},
+			session: pricedSession{start: at(21, 0), chargingEnd: at(23, 0), stop: at(23, 0), energyKWh: 10},
+			want:    domain.PriceBreakdown{EnergyCost: 250, NetAmount: 250, GrossAmount: 250},
+		},
+		{
+			name: "flat and time fees",
+			tariff: domain.Tariff{TimeZone: "UTC", Elements: []domain.TariffElement{
+				{Component: domain.TariffComponentFlat, Price: 1},
+				{Component: domain.TariffComponentTime, Price: 0.05},
+			}},
+			session: pricedSession{start: at(9, 0), chargingEnd: at(10, 30), stop: at(10, 30), energyKWh: 12},
+			want:    domain.PriceBreakdown{TimeCost: 450, FlatCost: 100, NetAmount: 550, GrossAmount: 550},
+		},
+		{
+			name: "idle fee after the grace period",
+			tariff: domain.Tariff{TimeZone: "UTC", Elements: []domain.TariffElement{
+				{Component: domain.TariffComponentEnergy, Price: 0.30},
+				{Component: domain.TariffComponentIdle, Price: 0.10, GraceMinutes: 15},
+			}},
+			session: pricedSession{start: at(9, 0), chargingEnd: at(10, 0), stop: at(11, 0), energyKWh: 10},
+			want:    domain.PriceBreakdown{EnergyCost: 300, IdleCost: 450, NetAmount: 750, GrossAmount: 750},
+		},
+		{
+			name: "no idle fee within the grace period",
+			tariff: domain.Tariff{TimeZone: "UTC", Elements: []domain.TariffElement{
+				{Component: domain.TariffComponentEnergy, Price: 0.30},
+				{Component: domain.TariffComponentIdle, Price: 0.10, GraceMinutes: 15},
+			}},
+			session: pricedSession{start: at(9, 0), chargingEnd: at(10, 0), stop: at(10, 10), energyKWh: 10},
+			want:    domain.PriceBreakdown{EnergyCost: 300, NetAmount: 300, GrossAmount: 300},
+		},
+		{
+			name: "energy without charging time",
+			tariff: domain.Tariff{TimeZone: "UTC", Elements: []domain.TariffElement{
+				{Component: domain.TariffComponentEnergy, Price: 0.30},
+			}},
+			session: pricedSession{start: at(9, 0), chargingEnd: at(9, 0), stop: at(9, 0), energyKWh: 2},
+			want:    domain.PriceBreakdown{EnergyCost: 60, NetAmount: 60, GrossAmount: 60},
+		},
+		{
+			name: "tax added to net prices",
+			tariff: domain.Tariff{TimeZone: "UTC", TaxRate: &taxRate, Elements: []domain.TariffElement{
+				{Component: domain.TariffComponentEnergy, Price: 0.75},
+			}},
+			session: pricedSession{start: at(9, 0), chargingEnd: at(10, 0), stop: at(10, 0), energyKWh: 10},
+			want:    domain.PriceBreakdown{EnergyCost: 750, NetAmount: 750, TaxAmount: 150, GrossAmount: 900},
+		},
+		{
+			name: "tax included in prices",
+			tariff: domain.Tariff{TimeZone: "UTC", TaxRate: &taxRate, TaxInclusive: &inclusive, Elements: []domain.TariffElement{
+				{Component: domain.TariffComponentEnergy, Price: 0.75},
+			}},
+			session: pricedSession{start: at(9, 0), chargingEnd: at(10, 0), stop: at(10, 0), energyKWh: 10},
+			want:    domain.PriceBreakdown{EnergyCost: 750, NetAmount: 625, TaxAmount: 125, GrossAmount: 750},
+		},
+		{
+			name: "tariff currency",
+			tariff: domain.Tariff{TimeZone: "UTC", Currency: "JPY", Elements: []domain.TariffElement{
+				{Component: domain.TariffComponentEnergy, Price: 45.5},
+			}},
+			session: pricedSession{start: at(9, 0), chargingEnd: at(10, 0), stop: at(10, 0), energyKWh: 3},
+			want:    domain.PriceBreakdown{Currency: "JPY", EnergyCost: 137, NetAmount: 137, GrossAmount: 137},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := calculatePrice(&tt.tariff, tt.session, rules)
+
+			want := tt.want
+			if want.Currency == "" {
+				want.Currency = rules.currency
+			}
+			if got.Currency != want.Currency ||
+				got.EnergyCost != want.EnergyCost || got.TimeCost != want.TimeCost ||
+				got.FlatCost != want.FlatCost || got.IdleCost != want.IdleCost ||
+				got.NetAmount != want.NetAmount || got.TaxAmount != want.TaxAmount ||
+				got.GrossAmount != want.GrossAmount {
+				t.Errorf("calculatePrice() = %s energy=%d time=%d flat=%d idle=%d net=%d tax=%d gross=%d, want %s energy=%d time=%d flat=%d idle=%d net=%d tax=%d gross=%d",
+					got.Currency, got.EnergyCost, got.TimeCost, got.FlatCost, got.IdleCost, got.NetAmount, got.TaxAmount, got.GrossAmount,
+					want.Currency, want.EnergyCost, want.TimeCost, want.FlatCost, want.IdleCost, want.NetAmount, want.TaxAmount, want.GrossAmount)
+			}
+		})
+	}
+}
+
+func TestToMinorUnits(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   float64
+		currency string
+		rounding string
+		want     int64
+	}{
+		{"half up", 0.125, "EUR", domain.RoundingHalfUp, 13},
+		{"half up ignores float noise", 2.675, "EUR", domain.RoundingHalfUp, 268},
+		{"half even rounds to even", 0.125, "EUR", domain.RoundingHalfEven, 12},
+		{"half even rounds odd up", 0.135, "EUR", domain.RoundingHalfEven, 14},
+		{"up", 0.121, "EUR", domain.RoundingUp, 13},
+		{"up on an exact amount", 0.12, "EUR", domain.RoundingUp, 12},
+		{"up on a refund", -0.121, "EUR", domain.RoundingUp, -13},
+		{"down", 0.129, "EUR", domain.RoundingDown, 12},
+		{"down on a refund", -0.129, "EUR", domain.RoundingDown, -12},
+		{"currency without minor unit", 1234.5, "JPY", domain.RoundingHalfEven, 1234},
+		{"currency with three decimals", 1.2345, "KWD", domain.RoundingHalfUp, 1235},
+		{"lower case currency", 1.5, "jpy", domain.RoundingHalfUp, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := toMinorUnits(tt.amount, tt.currency, tt.rounding); got != tt.want {
+				t.Errorf("toMinorUnits(%v, %s, %s) = %d, want %d", tt.amount, tt.currency, tt.rounding, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/config"
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+type RoamingTokenService struct {
+	tokenRepo       domain.RoamingTokenRepository
+	partyRepo       domain.OCPIPartyRepository
+	idTagRepo       domain.IDTagRepository
+	userRepo        domain.UserRepository
+	chargePointRepo domain.ChargePointRepository
+	client          domain.OCPIClient
+	cache           domain.AuthorizationCache
+	ocpiConfig      config.OCPIConfig
+}
+
+func NewRoamingTokenService(
+	tokenRepo domain.RoamingTokenRepository,
+	partyRepo domain.OCPIPartyRepository,
+	idTagRepo domain.IDTagRepository,
+	userRepo domain.UserRepository,
+	chargePointRepo domain.ChargePointRepository,
+	client domain.OCPIClient,
+	authorizationCache domain.AuthorizationCache,
+	ocpiConfig config.OCPIConfig,
+) domain.RoamingTokenService {
+	return &RoamingTokenService{
+		tokenRepo:       tokenRepo,
+		partyRepo:       partyRepo,
+		idTagRepo:       idTagRepo,
+		userRepo:        userRepo,
+		chargePointRepo: chargePointRepo,
+		client:          client,
+		cache:           authorizationCache,
+		ocpiConfig:      ocpiConfig,
+	}
+}
+
+func (s *RoamingTokenService) AuthorizeToken(ctx context.Context, uid string, chargePointID uint) (*domain.RoamingAuthorization, error) {
+	if !s.ocpiConfig.Enabled {
+		return nil, errors.New("roaming is disabled")
+	}
+
+	location := &domain.OCPILocationReferences{}
+	if chargePoint, err := s.chargePointRepo.GetByID(ctx, chargePointID); err == nil {
+		location.LocationID = chargePoint.ChargePointCode
+	}
+
+	token, err := s.tokenRepo.GetByUID(ctx, uid)
+	if err != nil {
+		return s.authorizeUnknown(ctx, uid, location)
+	}
+
+	if token.Whitelist == domain.OCPIWhitelistAlways || token.Whitelist == domain.OCPIWhitelistAllowed {
+		return s.decide(ctx, token, tokenStatus(token))
+	}
+
+	party, err := s.partyRepo.GetByID(ctx, token.OCPIPartyID)
+	if err != nil {
+		return nil, errors.New("token party not found")
+	}
+
+	info, err := s.requestAuthorization(ctx, party, token.UID, token.Type, location)
+	if err != nil {
+		if token.Whitelist == domain.OCPIWhitelistAllowedOffline {
+			log.Printf("Real-time authorization of token %s failed, using the whitelist: %v", uid, err)
+			return s.decide(ctx, token, tokenStatus(token))
+		}
+		log.Printf("Real-time authorization of token %s failed: %v", uid, err)
+		return s.decide(ctx, token, domain.AuthorizeStatusInvalid)
+	}
+
+	return s.decide(ctx, token, allowedStatus(info.Allowed))
+}
+
+// authorizeUnknown offers a token nobody pushed to us to every registered
+// eMSP; the first one that recognises it owns it.
+func (s *RoamingTokenService) authorizeUnknown(ctx context.Context, uid string, location *domain.OCPILocationReferences) (*domain.RoamingAuthorization, error) {
+	parties, err := s.partyRepo.ListRegistered(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range parties {
+		party := &parties[i]
+		info, err := s.requestAuthorization(ctx, party, uid, "RFID", location)
+		if err != nil {
+			continue
+		}
+
+		token := roamingToken(party, &info.Token)
+		if token.UID == "" {
+			token.UID = uid
+		}
+		if err := s.tokenRepo.Upsert(ctx, token); err != nil {
+			return nil, err
+		}
+		return s.decide(ctx, token, allowedStatus(info.Allowed))
+	}
+
+	return nil, errors.New("token unknown to all parties")
+}
+
+// requestAuthorization calls the party's Tokens sender module, if it has
+// one, within the configured timeout.
+func (s *RoamingTokenService) requestAuthorization(ctx context.Context, party *domain.OCPIParty, uid, tokenType string, location *domain.OCPILocationReferences) (*domain.OCPIAuthorizationInfo, error) {
+	endpoint := senderURL(party, domain.OCPIModuleTokens)
+	if endpoint == "" {
+		return nil, fmt.Errorf("%s has no tokens endpoint", party.Name)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.ocpiConfig.AuthorizeTimeout)
+	defer cancel()
+
+	authorizeURL := fmt.Sprintf("%s/%s/authorize?type=%s", endpoint, url.PathEscape(uid), url.QueryEscape(tokenType))
+	return s.client.AuthorizeToken(ctx, authorizeURL, party.RemoteToken, location)
+}
+
+// decide records the status on the IDTag standing in for the token, creating
+// it on first use.
+func (s *RoamingTokenService) decide(ctx context.Context, token *domain.RoamingToken, status string) (*domain.RoamingAuthorization, error) {
+	idTag, err := s.idTagRepo.GetByTag(ctx, token.UID)
+	if err != nil {
+		user, err := s.roamingUser(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		idTag = &domain.IDTag{
+			Tag:         token.UID,
+			ParentIDTag: token.GroupID,
+			Status:      status,
+			UserID:      user.ID,
+			Source:      domain.IDTagSourceOCPI,
+		}
+		if err := s.idTagRepo.Create(ctx, idTag); err != nil {
+			return nil, err
+		}
+	} else if idTag.Source != domain.IDTagSourceOCPI {
+		return nil, errors.New("token is issued locally")
+	} else if idTag.Status != status || idTag.ParentIDTag != token.GroupID {
+		idTag.Status = status
+		idTag.ParentIDTag = token.GroupID
+		if err := s.idTagRepo.Update(ctx, idTag); err != nil {
+			return nil, err
+		}
+	}
+
+	return &domain.RoamingAuthorization{
+		IDTag:       idTag,
+		OCPIPartyID: token.OCPIPartyID,
+	}, nil
+}
+
+func (s *RoamingTokenService) GetToken(ctx context.Context, countryCode, partyID, uid, tokenType string) (*domain.OCPIToken, error) {
+	token, err := s.tokenRepo.Get(ctx, strings.ToUpper(countryCode), strings.ToUpper(partyID), uid, tokenType)
+	if err != nil {
+		return nil, errors.New("token not found")
+	}
+
+	return &domain.OCPIToken{
+		CountryCode:  token.CountryCode,
+		PartyID:      token.PartyID,
+		UID:          token.UID,
+		Type:         token.Type,
+		ContractID:   token.ContractID,
+		VisualNumber: token.VisualNumber,
+		Issuer:       token.Issuer,
+		GroupID:      token.GroupID,
+		Valid:        token.Valid,
+		Whitelist:    token.Whitelist,
+		Language:     token.Language,
+		LastUpdated:  token.LastUpdated,
+	}, nil
+}
+
+func (s *RoamingTokenService) PutToken(ctx context.Context, party *domain.OCPIParty, token *domain.OCPIToken) error {
+	if token.UID == "" || token.Type == "" {
+		return errors.New("uid and type are required")
+	}
+
+	stored := roamingToken(party, token)
+	if existing, err := s.tokenRepo.Get(ctx, stored.CountryCode, stored.PartyID, stored.UID, stored.Type); err == nil && existing.OCPIPartyID != party.ID {
+		return errors.New("token belongs to another party")
+	}
+	if err := s.tokenRepo.Upsert(ctx, stored); err != nil {
+		return err
+	}
+
+	// The eMSP may have just blocked the token; the next authorization must
+	// see it.
+	s.cache.Invalidate(ctx, stored.UID)
+	return nil
+}
+
+// roamingUser returns the user the IDTags of roaming drivers belong to,
+// creating it on first use. Like the guest user it cannot sign in.
+func (s *RoamingTokenService) roamingUser(ctx context.Context) (*domain.User, error) {
+	if user, err := s.userRepo.GetByEmail(ctx, s.ocpiConfig.UserEmail); err == nil {
+		return user, nil
+	}
+
+	user := &domain.User{
+		Name:     "Roaming",
+		Email:    s.ocpiConfig.UserEmail,
+		Password: "!",
+		Role:     "roaming",
+		Status:   domain.UserStatusActive,
+	}
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func roamingToken(party *domain.OCPIParty, token *domain.OCPIToken) *domain.RoamingToken {
+	lastUpdated := token.LastUpdated
+	if lastUpdated.IsZero() {
+		lastUpdated = time.Now()
+	}
+	tokenType := token.Type
+	if tokenType == "" {
+		tokenType = "RFID"
+	}
+	countryCode, partyID := token.CountryCode, token.PartyID
+	if countryCode == "" {
+		countryCode, partyID = party.CountryCode, party.PartyID
+	}
+
+	return &domain.RoamingToken{
+		OCPIPartyID:  party.ID,
+		CountryCode:  strings.ToUpper(countryCode),
+		PartyID:      strings.ToUpper(partyID),
+		UID:          token.UID,
+		Type:         tokenType,
+		ContractID:   token.ContractID,
+		VisualNumber: token.VisualNumber,
+		Issuer:       token.Issuer,
+		GroupID:      token.GroupID,
+		Valid:        token.Valid,
+		Whitelist:    token.Whitelist,
+		Language:     token.Language,
+		LastUpdated:  lastUpdated,
+	}
+}
+
+func tokenStatus(token *domain.RoamingToken) string {
+	if token.Valid {
+		return domain.AuthorizeStatusAccepted
+	}
+	return domain.AuthorizeStatusBlocked
+}
+
+// allowedStatus maps an OCPI authorization outcome to an OCPP status.
+func allowedStatus(allowed string) string {
+	switch allowed {
+	case domain.OCPIAllowed:
+		return domain.AuthorizeStatusAccepted
+	case domain.OCPIExpired:
+		return domain.AuthorizeStatusExpired
+	case domain.OCPIBlocked, domain.OCPINoCredit, domain.OCPINotAllowed:
+		return domain.AuthorizeStatusBlocked
+	default:
+		return domain.AuthorizeStatusInvalid
+	}
+}
+
+// senderURL returns the party's sender endpoint of a module, or "" when it
+// has none.
+func senderURL(party *domain.OCPIParty, module string) string {
+	for _, endpoint := range party.Endpoints {
+		if endpoint.Identifier == module && endpoint.Role == domain.OCPIRoleSender {
+			return strings.TrimRight(endpoint.URL, "/")
+		}
+	}
+	return ""
+}
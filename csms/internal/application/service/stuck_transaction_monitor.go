@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+// StuckTransactionMonitor periodically looks for transactions that never
+// received their StopTransaction.
+type StuckTransactionMonitor struct {
+	transactionService domain.TransactionService
+	interval           time.Duration
+}
+
+// NewStuckTransactionMonitor creates a new stuck transaction monitor
+func NewStuckTransactionMonitor(transactionService domain.TransactionService, interval time.Duration) *StuckTransactionMonitor {
+	return &StuckTransactionMonitor{
+		transactionService: transactionService,
+		interval:           interval,
+	}
+}
+
+// Run checks for stuck transactions until the context is cancelled
+func (m *StuckTransactionMonitor) Run(ctx context.Context) {
+	if m.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			marked, err := m.transactionService.DetectStuckTransactions(ctx)
+			if err != nil {
+				log.Printf("Error detecting stuck transactions: %v", err)
+				continue
+			}
+			if marked > 0 {
+				log.Printf("Marked %d transaction(s) as stuck", marked)
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+	"gorm.io/gorm"
+)
+
+// localTagAuthorizer decides on the tags we issued. Tags standing in for
+// roaming tokens or externally authorized drivers are left to the backends
+// that created them.
+type localTagAuthorizer struct {
+	idTagRepo domain.IDTagRepository
+}
+
+func NewLocalTagAuthorizer(idTagRepo domain.IDTagRepository) domain.TagAuthorizer {
+	return &localTagAuthorizer{idTagRepo: idTagRepo}
+}
+
+func (a *localTagAuthorizer) Name() string {
+	return domain.AuthorizerLocal
+}
+
+func (a *localTagAuthorizer) AuthorizeTag(ctx context.Context, tag string, chargePointID uint) (*domain.TagDecision, error) {
+	idTag, err := a.idTagRepo.GetByTag(ctx, tag)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if idTag.Source == domain.IDTagSourceOCPI || idTag.Source == domain.IDTagSourceExternal {
+		return nil, nil
+	}
+	return idTagDecision(idTag), nil
+}
+
+// roamingTagAuthorizer decides on the tokens of roaming drivers.
+type roamingTagAuthorizer struct {
+	roaming domain.RoamingTokenService
+}
+
+func NewRoamingTagAuthorizer(roaming domain.RoamingTokenService) domain.TagAuthorizer {
+	return &roamingTagAuthorizer{roaming: roaming}
+}
+
+func (a *roamingTagAuthorizer) Name() string {
+	return domain.AuthorizerRoaming
+}
+
+// AuthorizeTag only fails when no party knows the token or roaming is
+// disabled; failing eMSPs are handled by their whitelist, so the chain goes
+// on either way.
+func (a *roamingTagAuthorizer) AuthorizeTag(ctx context.Context, tag string, chargePointID uint) (*domain.TagDecision, error) {
+	roaming, err := a.roaming.AuthorizeToken(ctx, tag, chargePointID)
+	if err != nil {
+		return nil, nil
+	}
+
+	decision := idTagDecision(roaming.IDTag)
+	decision.OCPIPartyID = &roaming.OCPIPartyID
+	return decision, nil
+}
+
+func idTagDecision(idTag *domain.IDTag) *domain.TagDecision {
+	decision := &domain.TagDecision{
+		Status:      idTag.Status,
+		ParentIDTag: idTag.ParentIDTag,
+		IDTag:       idTag,
+	}
+	if !idTag.ExpiryDate.IsZero() {
+		expiryDate := idTag.ExpiryDate
+		decision.ExpiryDate = &expiryDate
+	}
+	return decision
+}
@@ -0,0 +1,300 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/config"
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+type TariffService struct {
+	tariffRepo      domain.TariffRepository
+	chargePointRepo domain.ChargePointRepository
+	userRepo        domain.UserRepository
+	tariffConfig    config.TariffConfig
+	rules           pricingRules
+}
+
+func NewTariffService(
+	tariffRepo domain.TariffRepository,
+	chargePointRepo domain.ChargePointRepository,
+	userRepo domain.UserRepository,
+	tariffConfig config.TariffConfig,
+) domain.TariffService {
+	rounding := tariffConfig.Rounding
+	if !validRounding(rounding) {
+		log.Printf("Unknown tariff rounding %q, using %s", rounding, domain.RoundingHalfUp)
+		rounding = domain.RoundingHalfUp
+	}
+
+	return &TariffService{
+		tariffRepo:      tariffRepo,
+		chargePointRepo: chargePointRepo,
+		userRepo:        userRepo,
+		tariffConfig:    tariffConfig,
+		rules: pricingRules{
+			currency:     strings.ToUpper(tariffConfig.Currency),
+			taxRate:      tariffConfig.TaxRate,
+			taxInclusive: tariffConfig.TaxInclusive,
+			rounding:     rounding,
+		},
+	}
+}
+
+func (s *TariffService) CreateTariff(ctx context.Context, tariff *domain.Tariff) error {
+	if err := validateTariff(tariff); err != nil {
+		return err
+	}
+
+	return s.tariffRepo.Create(ctx, tariff)
+}
+
+func (s *TariffService) GetTariff(ctx context.Context, id uint) (*domain.Tariff, error) {
+	return s.tariffRepo.GetByID(ctx, id)
+}
+
+func (s *TariffService) UpdateTariff(ctx context.Context, tariff *domain.Tariff) error {
+	existingTariff, err := s.tariffRepo.GetByID(ctx, tariff.ID)
+	if err != nil {
+		return errors.New("tariff not found")
+	}
+
+	if err := validateTariff(tariff); err != nil {
+		return err
+	}
+
+	inUse, err := s.tariffRepo.InUse(ctx, existingTariff.ID)
+	if err != nil {
+		return err
+	}
+	if !inUse {
+		tariff.CreatedAt = existingTariff.CreatedAt
+		return s.tariffRepo.Update(ctx, tariff)
+	}
+
+	// Sessions keep the tariff they were priced with, so a tariff in use is
+	// never changed: the update becomes a new version of it, which takes over
+	// from the existing one. Time before the change keeps the old prices.
+	supersededAt := time.Now()
+	if tariff.ValidFrom != nil && tariff.ValidFrom.After(supersededAt) {
+		supersededAt = *tariff.ValidFrom
+	}
+	tariff.ValidFrom = &supersededAt
+	tariff.ID = 0
+	tariff.Code = existingTariff.Code
+	tariff.Version = existingTariff.Version + 1
+	for i := range tariff.Elements {
+		tariff.Elements[i].ID = 0
+		tariff.Elements[i].TariffID = 0
+	}
+	return s.tariffRepo.Supersede(ctx, existingTariff, tariff, supersededAt)
+}
+
+func (s *TariffService) DeleteTariff(ctx context.Context, id uint) error {
+	_, err := s.tariffRepo.GetByID(ctx, id)
+	if err != nil {
+		return errors.New("tariff not found")
+	}
+
+	inUse, err := s.tariffRepo.InUse(ctx, id)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return errors.New("tariff has priced transactions and cannot be deleted, set validTo to retire it")
+	}
+
+	return s.tariffRepo.Delete(ctx, id)
+}
+
+func (s *TariffService) ListTariffs(ctx context.Context, limit, offset int) ([]domain.Tariff, error) {
+	return s.tariffRepo.List(ctx, limit, offset)
+}
+
+// PriceTransaction computes the cost of a transaction with the tariff that was
+// valid when it started. Transactions still running are priced up to until.
+func (s *TariffService) PriceTransaction(ctx context.Context, transaction *domain.Transaction, until time.Time) (*domain.PriceBreakdown, error) {
+	tariff, err := s.resolveTariff(ctx, transaction)
+	if err != nil {
+		return nil, err
+	}
+
+	session := sessionOf(transaction, until)
+	if tariff == nil {
+		return flatRatePrice(s.tariffConfig.PricePerKwh, session.energyKWh, s.rules), nil
+	}
+
+	return calculatePrice(tariff, session, s.rules), nil
+}
+
+func (s *TariffService) SessionCurrency(ctx context.Context, chargePointID uint, idTag *domain.IDTag, at time.Time) (string, error) {
+	tariff, err := s.resolveTariff(ctx, &domain.Transaction{ChargePointID: chargePointID, IDTag: *idTag, StartTime: at})
+	if err != nil {
+		return "", err
+	}
+	if tariff == nil {
+		return s.rules.currency, nil
+	}
+	return s.rules.rulesFor(tariff).currency, nil
+}
+
+// resolveTariff picks the tariff applying to the transaction: highest
+// priority first, then the most specific match (charge point, user group,
+// site), then the latest version. It returns nil when none applies.
+func (s *TariffService) resolveTariff(ctx context.Context, transaction *domain.Transaction) (*domain.Tariff, error) {
+	if transaction.TariffID != nil {
+		return s.tariffRepo.GetByID(ctx, *transaction.TariffID)
+	}
+
+	tariffs, err := s.tariffRepo.ListValidAt(ctx, transaction.StartTime)
+	if err != nil {
+		return nil, err
+	}
+	if len(tariffs) == 0 {
+		return nil, nil
+	}
+
+	chargePoint, err := s.chargePointRepo.GetByID(ctx, transaction.ChargePointID)
+	if err != nil {
+		return nil, err
+	}
+
+	userGroup := ""
+	if user, err := s.userRepo.GetByID(ctx, transaction.IDTag.UserID); err == nil {
+		userGroup = user.Group
+	}
+
+	candidates := []domain.Tariff{}
+	for _, tariff := range tariffs {
+		if tariff.ChargePointID != nil && *tariff.ChargePointID != chargePoint.ID {
+			continue
+		}
+		if tariff.Site != "" && tariff.Site != chargePoint.Site {
+			continue
+		}
+		if tariff.UserGroup != "" && tariff.UserGroup != userGroup {
+			continue
+		}
+		candidates = append(candidates, tariff)
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		if specificity(&a) != specificity(&b) {
+			return specificity(&a) > specificity(&b)
+		}
+		return a.Version > b.Version
+	})
+
+	return &candidates[0], nil
+}
+
+func specificity(tariff *domain.Tariff) int {
+	score := 0
+	if tariff.ChargePointID != nil {
+		score += 4
+	}
+	if tariff.UserGroup != "" {
+		score += 2
+	}
+	if tariff.Site != "" {
+		score++
+	}
+	return score
+}
+
+// sessionOf derives the billed periods of a transaction. Without meter data
+// telling when charging ended, the whole session counts as charging time.
+func sessionOf(transaction *domain.Transaction, until time.Time) pricedSession {
+	stop := until
+	if transaction.StopTime != nil {
+		stop = *transaction.StopTime
+	}
+	if stop.Before(transaction.StartTime) {
+		stop = transaction.StartTime
+	}
+
+	chargingEnd := stop
+	if transaction.ChargingEndTime != nil && transaction.ChargingEndTime.Before(stop) {
+		chargingEnd = *transaction.ChargingEndTime
+	}
+	if chargingEnd.Before(transaction.StartTime) {
+		chargingEnd = transaction.StartTime
+	}
+
+	return pricedSession{
+		start:       transaction.StartTime,
+		chargingEnd: chargingEnd,
+		stop:        stop,
+		energyKWh:   transaction.EnergyConsumed,
+	}
+}
+
+func validateTariff(tariff *domain.Tariff) error {
+	if tariff.Code == "" {
+		return errors.New("tariff code is required")
+	}
+
+	if _, err := time.LoadLocation(tariff.TimeZone); err != nil {
+		return fmt.Errorf("invalid time zone %q", tariff.TimeZone)
+	}
+
+	if tariff.Currency != "" && len(tariff.Currency) != 3 {
+		return fmt.Errorf("invalid currency %q, expected an ISO 4217 code", tariff.Currency)
+	}
+	tariff.Currency = strings.ToUpper(tariff.Currency)
+
+	if tariff.TaxRate != nil && (*tariff.TaxRate < 0 || *tariff.TaxRate > 100) {
+		return errors.New("tariff taxRate must be between 0 and 100")
+	}
+
+	if tariff.ValidFrom != nil && tariff.ValidTo != nil && !tariff.ValidTo.After(*tariff.ValidFrom) {
+		return errors.New("tariff validTo must be after validFrom")
+	}
+
+	if len(tariff.Elements) == 0 {
+		return errors.New("tariff needs at least one element")
+	}
+
+	for _, element := range tariff.Elements {
+		switch element.Component {
+		case domain.TariffComponentEnergy, domain.TariffComponentTime, domain.TariffComponentFlat, domain.TariffComponentIdle:
+		default:
+			return fmt.Errorf("unknown tariff component %q", element.Component)
+		}
+
+		if element.Price < 0 {
+			return errors.New("tariff element price cannot be negative")
+		}
+
+		if (element.StartTime == "") != (element.EndTime == "") {
+			return errors.New("tariff element period needs both startTime and endTime")
+		}
+		if element.StartTime != "" {
+			if _, err := parseClock(element.StartTime); err != nil {
+				return err
+			}
+			if _, err := parseClock(element.EndTime); err != nil {
+				return err
+			}
+		}
+
+		if !validWeekdays(element.DaysOfWeek) {
+			return fmt.Errorf("invalid daysOfWeek %q, expected e.g. Mon,Tue", element.DaysOfWeek)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/config"
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+// fakeTariffRepo keeps tariffs in memory. Methods the tests do not use are
+// left to the embedded interface.
+type fakeTariffRepo struct {
+	domain.TariffRepository
+	tariffs []domain.Tariff
+	inUse   bool
+}
+
+func (r *fakeTariffRepo) GetByID(ctx context.Context, id uint) (*domain.Tariff, error) {
+	for i := range r.tariffs {
+		if r.tariffs[i].ID == id {
+			tariff := r.tariffs[i]
+			return &tariff, nil
+		}
+	}
+	return nil, errors.New("record not found")
+}
+
+func (r *fakeTariffRepo) InUse(ctx context.Context, id uint) (bool, error) {
+	return r.inUse, nil
+}
+
+func (r *fakeTariffRepo) Update(ctx context.Context, tariff *domain.Tariff) error {
+	for i := range r.tariffs {
+		if r.tariffs[i].ID == tariff.ID {
+			r.tariffs[i] = *tariff
+		}
+	}
+	return nil
+}
+
+func (r *fakeTariffRepo) Supersede(ctx context.Context, previous *domain.Tariff, next *domain.Tariff, at time.Time) error {
+	for i := range r.tariffs {
+		if r.tariffs[i].ID == previous.ID && (r.tariffs[i].ValidTo == nil || r.tariffs[i].ValidTo.After(at)) {
+			r.tariffs[i].ValidTo = &at
+		}
+	}
+	next.ID = uint(len(r.tariffs) + 1)
+	r.tariffs = append(r.tariffs, *next)
+	return nil
+}
+
+func (r *fakeTariffRepo) ListValidAt(ctx context.Context, at time.Time) ([]domain.Tariff, error) {
+	var valid []domain.Tariff
+	for _, tariff := range r.tariffs {
+		if (tariff.ValidFrom == nil || !tariff.ValidFrom.After(at)) && (tariff.ValidTo == nil || tariff.ValidTo.After(at)) {
+			valid = append(valid, tariff)
+		}
+	}
+	return valid, nil
+}
+
+type fakeChargePointRepo struct {
+	domain.ChargePointRepository
+}
+
+func (r *fakeChargePointRepo) GetByID(ctx context.Context, id uint) (*domain.ChargePoint, error) {
+	return &domain.ChargePoint{ID: id}, nil
+}
+
+type fakeUserRepo struct {
+	domain.UserRepository
+}
+
+func (r *fakeUserRepo) GetByID(ctx context.Context, id uint) (*domain.User, error) {
+	return nil, errors.New("record not found")
+}
+
+func TestUpdateTariffSupersedes(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Hour)
+
+	tests := []struct {
+		name      string
+		validFrom *time.Time
+		at        time.Time
+		want      int
+	}{
+		{"before an immediate change", nil, now.Add(-time.Hour), 1},
+		{"after an immediate change", nil, now.Add(time.Minute), 2},
+		{"before a scheduled change", &later, now.Add(30 * time.Minute), 1},
+		{"after a scheduled change", &later, now.Add(2 * time.Hour), 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tariffRepo := &fakeTariffRepo{
+				inUse: true,
+				tariffs: []domain.Tariff{{
+					ID: 1, Code: "STANDARD", Version: 1, TimeZone: "UTC",
+					Elements: []domain.TariffElement{{ID: 1, TariffID: 1, Component: domain.TariffComponentEnergy, Price: 0.30}},
+				}},
+			}
+			tariffService := NewTariffService(tariffRepo, &fakeChargePointRepo{}, &fakeUserRepo{}, config.TariffConfig{Currency: "EUR"}).(*TariffService)
+
+			err := tariffService.UpdateTariff(context.Background(), &domain.Tariff{
+				ID: 1, Code: "STANDARD", TimeZone: "UTC", ValidFrom: tt.validFrom,
+				Elements: []domain.TariffElement{{ID: 1, TariffID: 1, Component: domain.TariffComponentEnergy, Price: 0.40}},
+			})
+			if err != nil {
+				t.Fatalf("UpdateTariff() error = %v", err)
+			}
+			if len(tariffRepo.tariffs) != 2 {
+				t.Fatalf("UpdateTariff() left %d tariffs, want a second version", len(tariffRepo.tariffs))
+			}
+
+			tariff, err := tariffService.resolveTariff(context.Background(), &domain.Transaction{ChargePointID: 1, StartTime: tt.at})
+			if err != nil {
+				t.Fatalf("resolveTariff() error = %v", err)
+			}
+			if tariff == nil || tariff.Version != tt.want {
+				t.Errorf("resolveTariff() = %+v, want version %d", tariff, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateTariffInPlace(t *testing.T) {
+	tariffRepo := &fakeTariffRepo{
+		tariffs: []domain.Tariff{{ID: 1, Code: "STANDARD", Version: 1, TimeZone: "UTC"}},
+	}
+	tariffService := NewTariffService(tariffRepo, &fakeChargePointRepo{}, &fakeUserRepo{}, config.TariffConfig{Currency: "EUR"})
+
+	err := tariffService.UpdateTariff(context.Background(), &domain.Tariff{
+		ID: 1, Code: "STANDARD", Version: 1, TimeZone: "UTC",
+		Elements: []domain.TariffElement{{Component: domain.TariffComponentEnergy, Price: 0.40}},
+	})
+	if err != nil {
+		t.Fatalf("UpdateTariff() error = %v", err)
+	}
+	if len(tariffRepo.tariffs) != 1 || tariffRepo.tariffs[0].Elements[0].Price != 0.40 {
+		t.Errorf("UpdateTariff() of an unused tariff = %+v, want it updated in place", tariffRepo.tariffs)
+	}
+}
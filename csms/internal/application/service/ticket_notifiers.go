@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"log"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+// LogTicketNotifier writes ticket changes to the log.
+type LogTicketNotifier struct{}
+
+func NewLogTicketNotifier() domain.TicketNotifier {
+	return &LogTicketNotifier{}
+}
+
+func (n *LogTicketNotifier) Name() string {
+	return "log"
+}
+
+func (n *LogTicketNotifier) NotifyTicket(ctx context.Context, alert *domain.TicketAlert) error {
+	ticket := alert.Ticket
+	assignee := "nobody"
+	if ticket.Assignee != nil {
+		assignee = ticket.Assignee.Name
+	}
+	log.Printf("Ticket %d %s [%s, %s, assigned to %s]: %s", ticket.ID, alert.Event, ticket.Severity, ticket.Status, assignee, ticket.Title)
+	return nil
+}
+
+// EventTicketNotifier publishes ticket changes as domain events, so webhooks
+// and the event stream pass them on.
+type EventTicketNotifier struct {
+	events domain.EventBus
+}
+
+func NewEventTicketNotifier(eventBus domain.EventBus) domain.TicketNotifier {
+	return &EventTicketNotifier{events: eventBus}
+}
+
+func (n *EventTicketNotifier) Name() string {
+	return "events"
+}
+
+func (n *EventTicketNotifier) NotifyTicket(ctx context.Context, alert *domain.TicketAlert) error {
+	return n.events.Publish(ctx, alert.Event, alert.Ticket.ChargePointID, alert.Ticket)
+}
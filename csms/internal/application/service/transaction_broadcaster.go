@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+// TransactionBroadcaster fans transaction updates out to subscribers watching
+// a transaction. Updates come from the transaction events in the outbox, so
+// subscribers on any instance see them. Slow subscribers lose their oldest
+// pending update rather than holding up the others, so the latest state
+// always arrives.
+type TransactionBroadcaster struct {
+	events          domain.EventFeed
+	transactionRepo domain.TransactionRepository
+
+	mu          sync.RWMutex
+	subscribers map[uint]map[chan domain.Transaction]struct{}
+}
+
+func NewTransactionBroadcaster(events domain.EventFeed, transactionRepo domain.TransactionRepository) *TransactionBroadcaster {
+	return &TransactionBroadcaster{
+		events:          events,
+		transactionRepo: transactionRepo,
+		subscribers:     make(map[uint]map[chan domain.Transaction]struct{}),
+	}
+}
+
+// Run follows the transaction events until the context is cancelled and
+// sends the updated transactions to their subscribers.
+func (b *TransactionBroadcaster) Run(ctx context.Context) {
+	filter := domain.EventFilter{Types: []string{
+		domain.EventTransactionStarted,
+		domain.EventTransactionMeterValues,
+		domain.EventTransactionStuck,
+		domain.EventTransactionStopped,
+	}}
+	events, err := b.events.SubscribeEvents(ctx, filter, 0)
+	if err != nil {
+		log.Printf("Error following transaction events: %v", err)
+		return
+	}
+
+	for event := range events {
+		var data domain.TransactionEventData
+		if err := json.Unmarshal(event.Data, &data); err != nil {
+			log.Printf("Error decoding event %d: %v", event.ID, err)
+			continue
+		}
+		if !b.watched(data.ID) {
+			continue
+		}
+
+		transaction, err := b.transactionRepo.GetByID(ctx, data.ID)
+		if err != nil {
+			log.Printf("Error loading transaction %d for its subscribers: %v", data.ID, err)
+			continue
+		}
+		b.broadcast(transaction)
+	}
+}
+
+func (b *TransactionBroadcaster) SubscribeTransaction(id uint) (<-chan domain.Transaction, func()) {
+	ch := make(chan domain.Transaction, 8)
+
+	b.mu.Lock()
+	if b.subscribers[id] == nil {
+		b.subscribers[id] = make(map[chan domain.Transaction]struct{})
+	}
+	b.subscribers[id][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers[id], ch)
+			if len(b.subscribers[id]) == 0 {
+				delete(b.subscribers, id)
+			}
+			b.mu.Unlock()
+		})
+	}
+
+	return ch, unsubscribe
+}
+
+func (b *TransactionBroadcaster) watched(id uint) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subscribers[id]) > 0
+}
+
+func (b *TransactionBroadcaster) broadcast(transaction *domain.Transaction) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.subscribers[transaction.ID] {
+		select {
+		case ch <- *transaction:
+			continue
+		default:
+		}
+
+		select {
+		case <-ch:
+		default:
+		}
+		select {
+		case ch <- *transaction:
+		default:
+		}
+	}
+}
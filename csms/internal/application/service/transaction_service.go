@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -14,57 +15,94 @@ import (
 )
 
 type TransactionService struct {
-	transactionRepo domain.TransactionRepository
-	chargePointRepo domain.ChargePointRepository
-	idTagRepo       domain.IDTagRepository
-	tariffConfig    config.TariffConfig
+	transactionRepo   domain.TransactionRepository
+	chargePointRepo   domain.ChargePointRepository
+	connectorRepo     domain.ConnectorRepository
+	idTagRepo         domain.IDTagRepository
+	auditRepo         domain.TransactionAuditRepository
+	walletRepo        domain.WalletRepository
+	authorizer        *tagAuthorizer
+	tariffService     domain.TariffService
+	paymentService    domain.PaymentService
+	notifier          domain.NotificationService
+	unitOfWork        domain.UnitOfWork
+	events            domain.EventBus
+	commander         domain.ChargePointCommander
+	transactionConfig config.TransactionConfig
 }
 
 func NewTransactionService(
 	transactionRepo domain.TransactionRepository,
 	chargePointRepo domain.ChargePointRepository,
+	connectorRepo domain.ConnectorRepository,
 	idTagRepo domain.IDTagRepository,
-	tariffConfig config.TariffConfig,
+	auditRepo domain.TransactionAuditRepository,
+	accessRuleRepo domain.AccessRuleRepository,
+	walletRepo domain.WalletRepository,
+	tariffService domain.TariffService,
+	paymentService domain.PaymentService,
+	authorizationChain domain.AuthorizationChain,
+	notifier domain.NotificationService,
+	unitOfWork domain.UnitOfWork,
+	eventBus domain.EventBus,
+	commander domain.ChargePointCommander,
+	transactionConfig config.TransactionConfig,
+	authorizationConfig config.AuthorizationConfig,
 ) domain.TransactionService {
 	return &TransactionService{
-		transactionRepo: transactionRepo,
-		chargePointRepo: chargePointRepo,
-		idTagRepo:       idTagRepo,
-		tariffConfig:    tariffConfig,
+		transactionRepo:   transactionRepo,
+		chargePointRepo:   chargePointRepo,
+		connectorRepo:     connectorRepo,
+		idTagRepo:         idTagRepo,
+		auditRepo:         auditRepo,
+		walletRepo:        walletRepo,
+		authorizer:        newTagAuthorizer(idTagRepo, transactionRepo, chargePointRepo, accessRuleRepo, walletRepo, authorizationChain, authorizationConfig),
+		tariffService:     tariffService,
+		paymentService:    paymentService,
+		notifier:          notifier,
+		unitOfWork:        unitOfWork,
+		events:            eventBus,
+		commander:         commander,
+		transactionConfig: transactionConfig,
 	}
 }
 
 func (s *TransactionService) StartTransaction(ctx context.Context, request *domain.StartTransactionRequest, chargePointID uint) (*domain.StartTransactionResponse, error) {
-	activeTransaction, err := s.transactionRepo.GetActiveByConnector(ctx, chargePointID, request.ConnectorId)
-	if err == nil && activeTransaction != nil {
-		return nil, errors.New("connector is already in use")
+	// Stations replaying their offline queue send the original start time,
+	// which is what the session is recorded with.
+	startTime := request.Timestamp
+	if startTime.IsZero() {
+		startTime = time.Now()
 	}
 
-	idTag, err := s.idTagRepo.GetByTag(ctx, request.IDTag)
-	if err != nil {
-		return &domain.StartTransactionResponse{
-			IDTagInfo: domain.IDTagInfo{
-				Status: domain.AuthorizeStatusInvalid,
-			},
-			TransactionId: 0,
-		}, nil
-	}
+	activeTransaction, err := s.transactionRepo.GetActiveByConnector(ctx, chargePointID, request.ConnectorId)
+	if err == nil && activeTransaction != nil {
+		// A retransmitted StartTransaction describes the session we already
+		// created, so answer with the same transaction instead of rejecting it.
+		if isSameStart(activeTransaction, request) {
+			log.Printf("Duplicate StartTransaction for transaction %d, returning existing session", activeTransaction.TransactionID)
+			return &domain.StartTransactionResponse{
+				IDTagInfo: domain.IDTagInfo{
+					Status: domain.AuthorizeStatusAccepted,
+				},
+				TransactionId: activeTransaction.TransactionID,
+			}, nil
+		}
 
-	if idTag.Status != domain.AuthorizeStatusAccepted {
-		return &domain.StartTransactionResponse{
-			IDTagInfo: domain.IDTagInfo{
-				Status: idTag.Status,
-			},
-			TransactionId: 0,
-		}, nil
+		// A newer session on the same connector means the active one ended
+		// without us receiving its StopTransaction.
+		if !activeTransaction.StartTime.Before(startTime) {
+			return nil, errors.New("connector is already in use")
+		}
+		if err := s.closeStaleTransaction(ctx, activeTransaction, domain.TransactionReasonSuperseded); err != nil {
+			return nil, err
+		}
 	}
 
-	if !idTag.ExpiryDate.IsZero() && idTag.ExpiryDate.Before(time.Now()) {
+	authorization := s.authorizer.authorize(ctx, request.IDTag, chargePointID, startTime)
+	if authorization.Info.Status != domain.AuthorizeStatusAccepted {
 		return &domain.StartTransactionResponse{
-			IDTagInfo: domain.IDTagInfo{
-				Status:     domain.AuthorizeStatusExpired,
-				ExpiryDate: &idTag.ExpiryDate,
-			},
+			IDTagInfo:     authorization.Info,
 			TransactionId: 0,
 		}, nil
 	}
@@ -72,22 +110,50 @@ func (s *TransactionService) StartTransaction(ctx context.Context, request *doma
 	transaction := &domain.Transaction{
 		ChargePointID:     chargePointID,
 		ConnectorID:       request.ConnectorId,
-		TransactionID:     int(time.Now().Unix()),
-		IDTagID:           idTag.ID,
+		IDTagID:           authorization.IDTag.ID,
 		StartMeterValue:   float64(request.MeterStart),
 		CurrentMeterValue: float64(request.MeterStart),
-		StartTime:         time.Now(),
+		StartTime:         startTime,
+		OCPIPartyID:       authorization.OCPIPartyID,
 		Status:            domain.TransactionStatusActive,
 	}
 
-	if err := s.transactionRepo.Create(ctx, transaction); err != nil {
+	if authorization.MaxSessionDuration > 0 {
+		maxEndTime := startTime.Add(authorization.MaxSessionDuration)
+		transaction.MaxEndTime = &maxEndTime
+	}
+
+	err = s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		transactionID, err := s.transactionRepo.NextTransactionID(ctx)
+		if err != nil {
+			return err
+		}
+		transaction.TransactionID = transactionID
+		if err := s.transactionRepo.Create(ctx, transaction); err != nil {
+			return err
+		}
+		data := transactionEventData(transaction)
+		data.IDTag = authorization.IDTag.Tag
+		return s.events.Publish(ctx, domain.EventTransactionStarted, chargePointID, data)
+	})
+	if err != nil {
 		return nil, err
 	}
 
+	// Sessions started for a card payment take over its pre-authorization.
+	payment, err := s.paymentService.AttachTransaction(ctx, transaction, request.IDTag)
+	if err != nil {
+		log.Printf("Error attaching payment to transaction %d: %v", transaction.TransactionID, err)
+	} else if payment != nil {
+		transaction.PaymentID = &payment.ID
+		if err := s.transactionRepo.Update(ctx, transaction); err != nil {
+			return nil, err
+		}
+	}
+	s.notify(ctx, transaction)
+
 	response := &domain.StartTransactionResponse{
-		IDTagInfo: domain.IDTagInfo{
-			Status: domain.AuthorizeStatusAccepted,
-		},
+		IDTagInfo:     authorization.Info,
 		TransactionId: transaction.TransactionID,
 	}
 
@@ -104,27 +170,409 @@ func (s *TransactionService) StopTransaction(ctx context.Context, request *domai
 		return nil, errors.New("transaction does not belong to this charge point")
 	}
 
-	now := time.Now()
-	transaction.StopMeterValue = float64(request.MeterStop)
-	transaction.CurrentMeterValue = float64(request.MeterStop)
-	transaction.StopTime = &now
-	transaction.Status = domain.TransactionStatusCompleted
-	transaction.Reason = request.Reason
-	transaction.EnergyConsumed = transaction.StopMeterValue - transaction.StartMeterValue
+	// Transactions we closed ourselves still take the station's final values
+	// once its StopTransaction arrives; anything else is already final.
+	if transaction.Status != domain.TransactionStatusActive &&
+		transaction.Status != domain.TransactionStatusStuck &&
+		!isAutoClosed(transaction) {
+		log.Printf("StopTransaction for already %s transaction %d ignored", transaction.Status, transaction.TransactionID)
+		return &domain.StopTransactionResponse{
+			Status: "Accepted",
+		}, nil
+	}
 
-	transaction.TotalCost = transaction.EnergyConsumed * s.tariffConfig.PricePerKwh
+	stopTime := request.Timestamp
+	if stopTime.IsZero() {
+		stopTime = time.Now()
+	}
 
-	if err := s.transactionRepo.Update(ctx, transaction); err != nil {
+	s.completeTransaction(ctx, transaction, float64(request.MeterStop), stopTime, request.Reason)
+
+	if err := s.saveCompleted(ctx, transaction, nil); err != nil {
 		return nil, err
 	}
+	s.capturePayment(ctx, transaction)
+	s.notify(ctx, transaction)
 
 	response := &domain.StopTransactionResponse{
 		Status: "Accepted",
 	}
 
+	if request.IDTag != "" {
+		response.IDTagInfo = s.stopIDTagInfo(ctx, transaction, request.IDTag)
+	}
+
 	return response, nil
 }
 
+// stopIDTagInfo reports whether the tag presented to stop the transaction
+// may do so: the starting tag or any tag of the same parent group.
+func (s *TransactionService) stopIDTagInfo(ctx context.Context, transaction *domain.Transaction, tag string) *domain.IDTagInfo {
+	if tag == transaction.IDTag.Tag {
+		return &domain.IDTagInfo{
+			Status:      domain.AuthorizeStatusAccepted,
+			ParentIDTag: transaction.IDTag.ParentIDTag,
+		}
+	}
+
+	stopTag, err := s.idTagRepo.GetByTag(ctx, tag)
+	if err != nil || !sameGroup(stopTag, &transaction.IDTag) {
+		log.Printf("Tag %s is not allowed to stop transaction %d", tag, transaction.TransactionID)
+		return &domain.IDTagInfo{
+			Status: domain.AuthorizeStatusInvalid,
+		}
+	}
+
+	return &domain.IDTagInfo{
+		Status:      domain.AuthorizeStatusAccepted,
+		ParentIDTag: stopTag.ParentIDTag,
+	}
+}
+
+// CloseStaleTransactions completes every transaction still active on the
+// charge point, e.g. after it rebooted. A StopTransaction that arrives later
+// for one of them still updates its final values.
+func (s *TransactionService) CloseStaleTransactions(ctx context.Context, chargePointID uint, reason string) error {
+	transactions, err := s.transactionRepo.ListActiveByChargePoint(ctx, chargePointID)
+	if err != nil {
+		return err
+	}
+
+	for i := range transactions {
+		if err := s.closeStaleTransaction(ctx, &transactions[i], reason); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DetectStuckTransactions marks active transactions that show no sign of a
+// running session anymore, so they stop blocking their connector. It returns
+// the number of transactions marked.
+func (s *TransactionService) DetectStuckTransactions(ctx context.Context) (int, error) {
+	transactions, err := s.transactionRepo.ListByStatus(ctx, domain.TransactionStatusActive)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	marked := 0
+	for i := range transactions {
+		transaction := &transactions[i]
+
+		reason := s.stuckReason(ctx, transaction, now)
+		if reason == "" {
+			continue
+		}
+
+		transaction.Status = domain.TransactionStatusStuck
+		audit := &domain.TransactionAudit{
+			TransactionID: transaction.ID,
+			Action:        domain.TransactionAuditMarkedStuck,
+			FromStatus:    domain.TransactionStatusActive,
+			ToStatus:      domain.TransactionStatusStuck,
+			Reason:        reason,
+		}
+		if err := s.saveAudited(ctx, transaction, domain.EventTransactionStuck, audit); err != nil {
+			return marked, err
+		}
+		s.notify(ctx, transaction)
+
+		log.Printf("Transaction %d marked as stuck: %s", transaction.TransactionID, reason)
+		marked++
+	}
+
+	return marked, nil
+}
+
+func (s *TransactionService) stuckReason(ctx context.Context, transaction *domain.Transaction, now time.Time) string {
+	lastActivity := transaction.StartTime
+	if transaction.LastMeterTime != nil {
+		lastActivity = *transaction.LastMeterTime
+	}
+
+	if timeout := s.transactionConfig.MeterInactivityTimeout; timeout > 0 && now.Sub(lastActivity) > timeout {
+		return fmt.Sprintf("no meter activity since %s", lastActivity.UTC().Format(time.RFC3339))
+	}
+
+	connector, err := s.connectorRepo.GetByChargePointAndConnectorID(ctx, transaction.ChargePointID, transaction.ConnectorID)
+	if err != nil {
+		return ""
+	}
+
+	if timeout := s.transactionConfig.AvailableConnectorTimeout; timeout > 0 &&
+		connector.Status == domain.ChargePointStatusAvailable &&
+		connector.StatusChangedAt != nil &&
+		connector.StatusChangedAt.After(transaction.StartTime) &&
+		now.Sub(*connector.StatusChangedAt) > timeout {
+		return fmt.Sprintf("connector Available since %s", connector.StatusChangedAt.UTC().Format(time.RFC3339))
+	}
+
+	return ""
+}
+
+// ForceCloseTransaction lets an operator complete an active or stuck
+// transaction with a final meter value. The action is kept in the audit trail.
+func (s *TransactionService) ForceCloseTransaction(ctx context.Context, id uint, meterStop float64, reason string, userID uint) (*domain.Transaction, error) {
+	transaction, err := s.transactionRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, errors.New("transaction not found")
+	}
+
+	if transaction.Status != domain.TransactionStatusActive && transaction.Status != domain.TransactionStatusStuck {
+		return nil, fmt.Errorf("transaction is %s and cannot be force-closed", transaction.Status)
+	}
+
+	if meterStop < transaction.StartMeterValue {
+		return nil, errors.New("final meter value is lower than the start meter value")
+	}
+
+	fromStatus := transaction.Status
+	s.completeTransaction(ctx, transaction, meterStop, time.Now(), reason)
+
+	audit := &domain.TransactionAudit{
+		TransactionID:  transaction.ID,
+		Action:         domain.TransactionAuditForceClosed,
+		FromStatus:     fromStatus,
+		ToStatus:       transaction.Status,
+		StopMeterValue: &meterStop,
+		Reason:         reason,
+		UserID:         &userID,
+	}
+	if err := s.saveCompleted(ctx, transaction, audit); err != nil {
+		return nil, err
+	}
+	s.capturePayment(ctx, transaction)
+	s.notify(ctx, transaction)
+
+	return transaction, nil
+}
+
+func (s *TransactionService) ListTransactionAudits(ctx context.Context, id uint) ([]domain.TransactionAudit, error) {
+	return s.auditRepo.ListByTransaction(ctx, id)
+}
+
+func (s *TransactionService) closeStaleTransaction(ctx context.Context, transaction *domain.Transaction, reason string) error {
+	stopTime := time.Now()
+	if transaction.LastMeterTime != nil {
+		stopTime = *transaction.LastMeterTime
+	}
+
+	s.completeTransaction(ctx, transaction, transaction.CurrentMeterValue, stopTime, reason)
+
+	log.Printf("Closing stale transaction %d on charge point %d: %s", transaction.TransactionID, transaction.ChargePointID, reason)
+	if err := s.saveCompleted(ctx, transaction, nil); err != nil {
+		return err
+	}
+
+	s.capturePayment(ctx, transaction)
+	s.notify(ctx, transaction)
+	return nil
+}
+
+// save updates the transaction and records the event describing the change
+// in the same database transaction.
+func (s *TransactionService) save(ctx context.Context, transaction *domain.Transaction, eventType string) error {
+	return s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		if err := s.transactionRepo.Update(ctx, transaction); err != nil {
+			return err
+		}
+		return s.events.Publish(ctx, eventType, transaction.ChargePointID, transactionEventData(transaction))
+	})
+}
+
+// saveAudited is save with the audit entry written in the same database
+// transaction.
+func (s *TransactionService) saveAudited(ctx context.Context, transaction *domain.Transaction, eventType string, audit *domain.TransactionAudit) error {
+	return s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		if err := s.save(ctx, transaction, eventType); err != nil {
+			return err
+		}
+		return s.auditRepo.Create(ctx, audit)
+	})
+}
+
+// saveCompleted saves a completed transaction, with the audit entry if
+// given, and debits its cost from the owner's wallet in the same database
+// transaction.
+func (s *TransactionService) saveCompleted(ctx context.Context, transaction *domain.Transaction, audit *domain.TransactionAudit) error {
+	return s.unitOfWork.Do(ctx, func(ctx context.Context) error {
+		if err := s.save(ctx, transaction, domain.EventTransactionStopped); err != nil {
+			return err
+		}
+		if audit != nil {
+			if err := s.auditRepo.Create(ctx, audit); err != nil {
+				return err
+			}
+		}
+		return s.chargeWallet(ctx, transaction)
+	})
+}
+
+func transactionEventData(transaction *domain.Transaction) *domain.TransactionEventData {
+	return &domain.TransactionEventData{
+		ID:             transaction.ID,
+		TransactionID:  transaction.TransactionID,
+		ChargePointID:  transaction.ChargePointID,
+		ConnectorID:    transaction.ConnectorID,
+		IDTag:          transaction.IDTag.Tag,
+		Status:         transaction.Status,
+		StartTime:      transaction.StartTime,
+		StopTime:       transaction.StopTime,
+		MeterStart:     transaction.StartMeterValue,
+		MeterStop:      transaction.StopMeterValue,
+		MeterValue:     transaction.CurrentMeterValue,
+		EnergyConsumed: transaction.EnergyConsumed,
+		Currency:       transaction.Currency,
+		GrossAmount:    transaction.GrossAmount,
+		Reason:         transaction.Reason,
+	}
+}
+
+func (s *TransactionService) completeTransaction(ctx context.Context, transaction *domain.Transaction, meterStop float64, stopTime time.Time, reason string) {
+	if stopTime.Before(transaction.StartTime) {
+		stopTime = transaction.StartTime
+	}
+
+	transaction.StopMeterValue = meterStop
+	transaction.CurrentMeterValue = meterStop
+	transaction.StopTime = &stopTime
+	transaction.Status = domain.TransactionStatusCompleted
+	transaction.Reason = reason
+	transaction.EnergyConsumed = (transaction.StopMeterValue - transaction.StartMeterValue) / 1000
+
+	s.applyPrice(ctx, transaction, stopTime)
+}
+
+// applyPrice stores the cost of the transaction up to the given time. While
+// the session runs this is its running cost; a pricing failure keeps the
+// previous figures.
+func (s *TransactionService) applyPrice(ctx context.Context, transaction *domain.Transaction, until time.Time) {
+	breakdown, err := s.tariffService.PriceTransaction(ctx, transaction, until)
+	if err != nil {
+		log.Printf("Error pricing transaction %d: %v", transaction.TransactionID, err)
+		return
+	}
+
+	setTransactionPrice(transaction, breakdown)
+}
+
+func setTransactionPrice(transaction *domain.Transaction, breakdown *domain.PriceBreakdown) {
+	transaction.TariffID = breakdown.TariffID
+	transaction.Currency = breakdown.Currency
+	transaction.EnergyCost = breakdown.EnergyCost
+	transaction.TimeCost = breakdown.TimeCost
+	transaction.FlatCost = breakdown.FlatCost
+	transaction.IdleCost = breakdown.IdleCost
+	transaction.NetAmount = breakdown.NetAmount
+	transaction.TaxAmount = breakdown.TaxAmount
+	transaction.GrossAmount = breakdown.GrossAmount
+	transaction.TaxRate = breakdown.TaxRate
+	transaction.TaxInclusive = breakdown.TaxInclusive
+}
+
+// capturePayment captures the final cost of a completed transaction paid by
+// card. The gateway is called after the completion is saved, so a failed
+// capture is logged and left for the operator.
+func (s *TransactionService) capturePayment(ctx context.Context, transaction *domain.Transaction) {
+	if transaction.PaymentID == nil {
+		return
+	}
+
+	payment, err := s.paymentService.CapturePayment(ctx, transaction)
+	if err != nil {
+		log.Printf("Error capturing payment for transaction %d: %v", transaction.TransactionID, err)
+	}
+	if payment != nil {
+		transaction.Payment = payment
+	}
+}
+
+// chargeWallet debits the final cost of a completed transaction from the
+// owner's wallet, if they have one and it was not paid by card. Re-completing
+// a transaction, e.g. when the StopTransaction of an auto-closed session
+// arrives, only books the difference.
+func (s *TransactionService) chargeWallet(ctx context.Context, transaction *domain.Transaction) error {
+	if transaction.PaymentID != nil {
+		return nil
+	}
+
+	wallet, err := s.walletRepo.GetByUserID(ctx, transaction.IDTag.UserID)
+	if err != nil {
+		return nil
+	}
+
+	if wallet.Currency != transaction.Currency {
+		log.Printf("Not charging wallet %d for transaction %d: wallet is in %s, transaction in %s",
+			wallet.ID, transaction.TransactionID, wallet.Currency, transaction.Currency)
+		return nil
+	}
+
+	description := fmt.Sprintf("Charging session %d", transaction.TransactionID)
+	if _, err := s.walletRepo.ChargeTransaction(ctx, wallet.ID, transaction.ID, transaction.GrossAmount, description); err != nil {
+		return fmt.Errorf("charging wallet %d: %w", wallet.ID, err)
+	}
+	return nil
+}
+
+// enforceLimits asks the charge point to stop a session whose running cost
+// has used up its card pre-authorization or the owner's wallet, or that ran
+// past its maximum duration. The
+// request is repeated at most once a minute while the session keeps running.
+func (s *TransactionService) enforceLimits(ctx context.Context, transaction *domain.Transaction, now time.Time) {
+	if transaction.StopRequestedAt != nil && now.Sub(*transaction.StopRequestedAt) < time.Minute {
+		return
+	}
+
+	reason := ""
+	if transaction.MaxEndTime != nil && !now.Before(*transaction.MaxEndTime) {
+		reason = "maximum session duration reached"
+	} else if transaction.Payment != nil {
+		if transaction.GrossAmount >= transaction.Payment.AuthorizedAmount {
+			reason = fmt.Sprintf("running cost %s reaches the pre-authorized %s",
+				formatMoney(transaction.GrossAmount, transaction.Currency),
+				formatMoney(transaction.Payment.AuthorizedAmount, transaction.Payment.Currency))
+		}
+	} else if wallet, err := s.walletRepo.GetByUserID(ctx, transaction.IDTag.UserID); err == nil &&
+		wallet.Currency == transaction.Currency &&
+		transaction.GrossAmount > 0 && transaction.GrossAmount >= wallet.Balance {
+		reason = fmt.Sprintf("running cost %s exhausts wallet balance %s",
+			formatMoney(transaction.GrossAmount, transaction.Currency), formatMoney(wallet.Balance, wallet.Currency))
+	}
+	if reason == "" {
+		return
+	}
+
+	transaction.StopRequestedAt = &now
+	if err := s.transactionRepo.Update(ctx, transaction); err != nil {
+		log.Printf("Error recording stop request for transaction %d: %v", transaction.TransactionID, err)
+	}
+
+	log.Printf("Requesting remote stop of transaction %d on charge point %d: %s",
+		transaction.TransactionID, transaction.ChargePointID, reason)
+
+	// The charge point answers on the connection this request came in on,
+	// so the call must not block the handler reading it.
+	chargePointID, transactionID := transaction.ChargePointID, transaction.TransactionID
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		if err := s.commander.RemoteStopTransaction(ctx, chargePointID, transactionID); err != nil {
+			log.Printf("Error stopping transaction %d remotely: %v", transactionID, err)
+		}
+	}()
+}
+
+// notify publishes the transaction's new state; failures are only logged so
+// they never fail the OCPP exchange.
+func (s *TransactionService) notify(ctx context.Context, transaction *domain.Transaction) {
+	if err := s.notifier.SendTransactionNotification(ctx, transaction); err != nil {
+		log.Printf("Error sending notification for transaction %d: %v", transaction.TransactionID, err)
+	}
+}
+
 func (s *TransactionService) GetTransaction(ctx context.Context, id uint) (*domain.Transaction, error) {
 	return s.transactionRepo.GetByID(ctx, id)
 }
@@ -160,50 +608,123 @@ func (s *TransactionService) UpdateMeterValues(ctx context.Context, request *dom
 		return errors.New("transaction does not belong to this charge point")
 	}
 
-	if len(request.MeterValue) > 0 {
-		latestMeterValue := request.MeterValue[len(request.MeterValue)-1] // Get the latest meter value
+	if transaction.Status != domain.TransactionStatusActive && transaction.Status != domain.TransactionStatusStuck {
+		log.Printf("Ignoring meter values for %s transaction %d", transaction.Status, *request.TransactionId)
+		return nil
+	}
 
-		if len(latestMeterValue.SampledValue) > 0 {
-			// Find the energy meter value (usually measured in Wh or kWh)
-			for _, sampledValue := range latestMeterValue.SampledValue {
-				if sampledValue.Measurand == "Energy.Active.Import.Register" ||
-					sampledValue.Measurand == "Energy.Active.Import.Interval" ||
-					sampledValue.Measurand == "Power.Active.Import" {
+	// Queued meter values may arrive in one batch; apply them oldest first and
+	// skip samples older than what the transaction already holds.
+	meterValues := make([]domain.MeterValue, len(request.MeterValue))
+	copy(meterValues, request.MeterValue)
+	sort.SliceStable(meterValues, func(i, j int) bool {
+		return parseMeterTimestamp(meterValues[i].Timestamp).Before(parseMeterTimestamp(meterValues[j].Timestamp))
+	})
+
+	updated := false
+	for _, mv := range meterValues {
+		timestamp := parseMeterTimestamp(mv.Timestamp)
+		if timestamp.IsZero() {
+			timestamp = time.Now()
+		}
 
-					// Parse the meter value
-					meterValue, err := parseMeterValue(sampledValue.Value, sampledValue.Unit)
-					if err != nil {
-						log.Printf("Error parsing meter value: %v", err)
-						continue
-					}
+		if transaction.LastMeterTime != nil && timestamp.Before(*transaction.LastMeterTime) {
+			log.Printf("Skipping meter value from %s for transaction %d, already at %s",
+				timestamp.Format(time.RFC3339), *request.TransactionId, transaction.LastMeterTime.Format(time.RFC3339))
+			continue
+		}
 
-					// Update transaction with current meter value
-					transaction.CurrentMeterValue = meterValue
+		// Find the energy meter value (usually measured in Wh or kWh)
+		for _, sampledValue := range mv.SampledValue {
+			if sampledValue.Measurand == "" ||
+				sampledValue.Measurand == "Energy.Active.Import.Register" ||
+				sampledValue.Measurand == "Energy.Active.Import.Interval" {
+
+				// Parse the meter value, normalized to Wh like meterStart
+				meterValue, err := parseMeterValue(sampledValue.Value, sampledValue.Unit)
+				if err != nil {
+					log.Printf("Error parsing meter value: %v", err)
+					continue
+				}
 
-					// Calculate energy consumed (convert to kWh if needed)
-					energyConsumed := meterValue - transaction.StartMeterValue
-					if sampledValue.Unit == "Wh" {
-						energyConsumed = energyConsumed / 1000 // Convert Wh to kWh
-					}
+				sampledAt := timestamp
 
-					transaction.EnergyConsumed = energyConsumed
+				// Energy still flowing means the vehicle is charging; idle
+				// time is counted from the last increase.
+				if meterValue > transaction.CurrentMeterValue {
+					transaction.ChargingEndTime = &sampledAt
+				}
 
-					if err := s.transactionRepo.Update(ctx, transaction); err != nil {
-						log.Printf("Error updating transaction with meter values: %v", err)
-						return err
-					}
+				// Update transaction with current meter value
+				transaction.CurrentMeterValue = meterValue
+				transaction.EnergyConsumed = (meterValue - transaction.StartMeterValue) / 1000
+				transaction.LastMeterTime = &sampledAt
+				updated = true
 
-					log.Printf("Updated transaction %d with meter value: %.2f %s, energy consumed: %.2f kWh",
-						*request.TransactionId, meterValue, sampledValue.Unit, energyConsumed)
-					break
-				}
+				log.Printf("Updated transaction %d with meter value: %.2f Wh, energy consumed: %.2f kWh",
+					*request.TransactionId, meterValue, transaction.EnergyConsumed)
+				break
 			}
 		}
 	}
 
+	if !updated {
+		return nil
+	}
+
+	s.applyPrice(ctx, transaction, *transaction.LastMeterTime)
+
+	// Meter values for a stuck transaction show the station is still running
+	// it, so it goes back to active.
+	if transaction.Status == domain.TransactionStatusStuck {
+		transaction.Status = domain.TransactionStatusActive
+		audit := &domain.TransactionAudit{
+			TransactionID: transaction.ID,
+			Action:        domain.TransactionAuditRecovered,
+			FromStatus:    domain.TransactionStatusStuck,
+			ToStatus:      domain.TransactionStatusActive,
+			Reason:        "meter values received",
+		}
+		err = s.saveAudited(ctx, transaction, domain.EventTransactionMeterValues, audit)
+	} else {
+		err = s.save(ctx, transaction, domain.EventTransactionMeterValues)
+	}
+	if err != nil {
+		log.Printf("Error updating transaction with meter values: %v", err)
+		return err
+	}
+
+	s.notify(ctx, transaction)
+	s.enforceLimits(ctx, transaction, time.Now())
 	return nil
 }
 
+// isSameStart reports whether a StartTransaction request describes the given
+// active transaction: same connector, idTag, meterStart and timestamp.
+func isSameStart(transaction *domain.Transaction, request *domain.StartTransactionRequest) bool {
+	if request.Timestamp.IsZero() {
+		return false
+	}
+
+	return transaction.ConnectorID == request.ConnectorId &&
+		transaction.IDTag.Tag == request.IDTag &&
+		transaction.StartMeterValue == float64(request.MeterStart) &&
+		transaction.StartTime.Equal(request.Timestamp.Truncate(time.Microsecond))
+}
+
+func isAutoClosed(transaction *domain.Transaction) bool {
+	return transaction.Status == domain.TransactionStatusCompleted &&
+		(transaction.Reason == domain.TransactionReasonRebooted || transaction.Reason == domain.TransactionReasonSuperseded)
+}
+
+func parseMeterTimestamp(value string) time.Time {
+	timestamp, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(value))
+	if err != nil {
+		return time.Time{}
+	}
+	return timestamp
+}
+
 func parseMeterValue(value string, unit string) (float64, error) {
 	value = strings.TrimSpace(value)
 
@@ -212,5 +733,9 @@ func parseMeterValue(value string, unit string) (float64, error) {
 		return 0, fmt.Errorf("invalid meter value format: %s", value)
 	}
 
+	if unit == "kWh" {
+		meterValue *= 1000
+	}
+
 	return meterValue, nil
 }
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/malikkhoiri/csms/internal/config"
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+type WalletService struct {
+	walletRepo   domain.WalletRepository
+	userRepo     domain.UserRepository
+	tariffConfig config.TariffConfig
+}
+
+func NewWalletService(walletRepo domain.WalletRepository, userRepo domain.UserRepository, tariffConfig config.TariffConfig) domain.WalletService {
+	return &WalletService{
+		walletRepo:   walletRepo,
+		userRepo:     userRepo,
+		tariffConfig: tariffConfig,
+	}
+}
+
+// CreateWallet opens an empty wallet for a user, in the default tariff
+// currency unless another one is given.
+func (s *WalletService) CreateWallet(ctx context.Context, wallet *domain.Wallet) error {
+	if _, err := s.userRepo.GetByID(ctx, wallet.UserID); err != nil {
+		return errors.New("user not found")
+	}
+
+	if _, err := s.walletRepo.GetByUserID(ctx, wallet.UserID); err == nil {
+		return errors.New("user already has a wallet")
+	}
+
+	if wallet.Currency == "" {
+		wallet.Currency = s.tariffConfig.Currency
+	}
+	wallet.Currency = strings.ToUpper(wallet.Currency)
+	wallet.Balance = 0
+
+	return s.walletRepo.Create(ctx, wallet)
+}
+
+func (s *WalletService) GetWallet(ctx context.Context, id uint) (*domain.Wallet, error) {
+	return s.walletRepo.GetByID(ctx, id)
+}
+
+func (s *WalletService) GetWalletByUser(ctx context.Context, userID uint) (*domain.Wallet, error) {
+	return s.walletRepo.GetByUserID(ctx, userID)
+}
+
+func (s *WalletService) UpdateMinBalance(ctx context.Context, id uint, minBalance int64) (*domain.Wallet, error) {
+	wallet, err := s.walletRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, errors.New("wallet not found")
+	}
+
+	wallet.MinBalance = minBalance
+	if err := s.walletRepo.Update(ctx, wallet); err != nil {
+		return nil, err
+	}
+	return wallet, nil
+}
+
+func (s *WalletService) ListWallets(ctx context.Context, limit, offset int) ([]domain.Wallet, error) {
+	return s.walletRepo.List(ctx, limit, offset)
+}
+
+func (s *WalletService) TopUp(ctx context.Context, id uint, request *domain.TopUpRequest) (*domain.Wallet, error) {
+	if request.Amount <= 0 {
+		return nil, errors.New("top-up amount must be positive")
+	}
+
+	if _, err := s.walletRepo.GetByID(ctx, id); err != nil {
+		return nil, errors.New("wallet not found")
+	}
+
+	entry := &domain.WalletEntry{
+		Type:        domain.WalletEntryTopUp,
+		Amount:      request.Amount,
+		Reference:   request.Reference,
+		Description: request.Description,
+	}
+	return s.walletRepo.AddEntry(ctx, id, entry)
+}
+
+func (s *WalletService) ListEntries(ctx context.Context, id uint, limit, offset int) ([]domain.WalletEntry, error) {
+	return s.walletRepo.ListEntries(ctx, id, limit, offset)
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+// WebhookDeliveryJob attempts the webhook deliveries that are due.
+type WebhookDeliveryJob struct {
+	webhookService domain.WebhookService
+	interval       time.Duration
+}
+
+// NewWebhookDeliveryJob creates a new webhook delivery job
+func NewWebhookDeliveryJob(webhookService domain.WebhookService, interval time.Duration) *WebhookDeliveryJob {
+	return &WebhookDeliveryJob{
+		webhookService: webhookService,
+		interval:       interval,
+	}
+}
+
+// Run attempts due deliveries on every tick until the context is cancelled.
+// While there are due deliveries it keeps going without waiting for the tick.
+func (j *WebhookDeliveryJob) Run(ctx context.Context) {
+	if j.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for ctx.Err() == nil {
+				attempted, err := j.webhookService.DeliverDue(ctx)
+				if err != nil {
+					log.Printf("Error delivering webhooks: %v", err)
+				}
+				if err != nil || attempted == 0 {
+					break
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/config"
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+// WebhookService tells external systems about domain events. It subscribes
+// to the event bus and queues a delivery per event and subscription; the
+// deliveries are then attempted by WebhookDeliveryJob until the subscriber
+// answers with a 2xx, backing off exponentially in between.
+//
+// It is also a NotificationService, so notifications without an event of
+// their own reach the subscribers the same way.
+type WebhookService struct {
+	subscriptionRepo domain.WebhookSubscriptionRepository
+	deliveryRepo     domain.WebhookDeliveryRepository
+	sender           domain.WebhookSender
+	events           domain.EventBus
+	config           config.WebhookConfig
+}
+
+func NewWebhookService(
+	subscriptionRepo domain.WebhookSubscriptionRepository,
+	deliveryRepo domain.WebhookDeliveryRepository,
+	sender domain.WebhookSender,
+	events domain.EventBus,
+	webhookConfig config.WebhookConfig,
+) domain.WebhookService {
+	return &WebhookService{
+		subscriptionRepo: subscriptionRepo,
+		deliveryRepo:     deliveryRepo,
+		sender:           sender,
+		events:           events,
+		config:           webhookConfig,
+	}
+}
+
+func (s *WebhookService) CreateSubscription(ctx context.Context, request *domain.WebhookSubscriptionRequest) (*domain.WebhookSubscription, error) {
+	if err := validateWebhookSubscription(request); err != nil {
+		return nil, err
+	}
+
+	secret := request.Secret
+	if secret == "" {
+		var err error
+		if secret, err = randomHex(32); err != nil {
+			return nil, err
+		}
+	}
+
+	subscription := &domain.WebhookSubscription{
+		Name:       request.Name,
+		URL:        request.URL,
+		EventTypes: request.EventTypes,
+		Secret:     secret,
+		Disabled:   request.Disabled,
+	}
+	if err := s.subscriptionRepo.Create(ctx, subscription); err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+func (s *WebhookService) GetSubscription(ctx context.Context, id uint) (*domain.WebhookSubscription, error) {
+	subscription, err := s.subscriptionRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, errors.New("webhook subscription not found")
+	}
+	return subscription, nil
+}
+
+func (s *WebhookService) UpdateSubscription(ctx context.Context, id uint, request *domain.WebhookSubscriptionRequest) (*domain.WebhookSubscription, error) {
+	subscription, err := s.GetSubscription(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateWebhookSubscription(request); err != nil {
+		return nil, err
+	}
+
+	subscription.Name = request.Name
+	subscription.URL = request.URL
+	subscription.EventTypes = request.EventTypes
+	subscription.Disabled = request.Disabled
+	if request.Secret != "" {
+		subscription.Secret = request.Secret
+	}
+	if err := s.subscriptionRepo.Update(ctx, subscription); err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+func (s *WebhookService) DeleteSubscription(ctx context.Context, id uint) error {
+	if _, err := s.GetSubscription(ctx, id); err != nil {
+		return err
+	}
+	return s.subscriptionRepo.Delete(ctx, id)
+}
+
+func (s *WebhookService) ListSubscriptions(ctx context.Context, limit, offset int) ([]domain.WebhookSubscription, error) {
+	return s.subscriptionRepo.List(ctx, limit, offset)
+}
+
+func (s *WebhookService) ListDeliveries(ctx context.Context, subscriptionID uint, limit, offset int) ([]domain.WebhookDelivery, error) {
+	return s.deliveryRepo.ListBySubscription(ctx, subscriptionID, limit, offset)
+}
+
+func (s *WebhookService) Redeliver(ctx context.Context, subscriptionID, deliveryID uint) (*domain.WebhookDelivery, error) {
+	delivery, err := s.deliveryRepo.GetByID(ctx, deliveryID)
+	if err != nil || delivery.SubscriptionID != subscriptionID {
+		return nil, errors.New("webhook delivery not found")
+	}
+
+	subscription, err := s.GetSubscription(ctx, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// A failed delivery gets a fresh set of attempts.
+	if delivery.Status == domain.WebhookDeliveryStatusFailed {
+		delivery.Attempts = 0
+	}
+	if err := s.attempt(ctx, subscription, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// HandleEvent is subscribed to every event on the bus. Deliveries already
+// queued for a redelivered event are left alone.
+func (s *WebhookService) HandleEvent(ctx context.Context, event *domain.Event) error {
+	subscriptions, err := s.subscriptionRepo.ListEnabled(ctx)
+	if err != nil {
+		return err
+	}
+
+	var payload []byte
+	for i := range subscriptions {
+		subscription := &subscriptions[i]
+		if !slices.Contains(subscription.EventTypes, event.Type) && !slices.Contains(subscription.EventTypes, "*") {
+			continue
+		}
+
+		if payload == nil {
+			payload, err = json.Marshal(event.Message())
+			if err != nil {
+				return err
+			}
+		}
+
+		delivery := &domain.WebhookDelivery{
+			SubscriptionID: subscription.ID,
+			EventID:        event.ID,
+			EventType:      event.Type,
+			Payload:        payload,
+			Status:         domain.WebhookDeliveryStatusPending,
+			NextAttemptAt:  time.Now(),
+		}
+		if err := s.deliveryRepo.Create(ctx, delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SendTransactionNotification queues nothing: every transaction change is
+// recorded as a transaction event with the change, and delivered from there.
+func (s *WebhookService) SendTransactionNotification(ctx context.Context, transaction *domain.Transaction) error {
+	return nil
+}
+
+// SendErrorNotification records the error as a chargepoint.error event, to be
+// delivered to the subscriptions wanting it.
+func (s *WebhookService) SendErrorNotification(ctx context.Context, chargePointID uint, message string) error {
+	return s.events.Publish(ctx, domain.EventChargePointError, chargePointID, &domain.ChargePointEventData{
+		ChargePointID: chargePointID,
+		Error:         message,
+	})
+}
+
+// SendStatusNotification queues nothing: status changes are delivered from
+// the connector.status_changed events.
+func (s *WebhookService) SendStatusNotification(ctx context.Context, chargePointID uint, connectorID int, status string) error {
+	return nil
+}
+
+func (s *WebhookService) DeliverDue(ctx context.Context) (int, error) {
+	deliveries, err := s.deliveryRepo.ClaimDue(ctx, time.Now(), s.config.BatchSize, s.config.Lease)
+	if err != nil {
+		return 0, err
+	}
+
+	subscriptions := make(map[uint]*domain.WebhookSubscription)
+	for i := range deliveries {
+		delivery := &deliveries[i]
+
+		subscription, ok := subscriptions[delivery.SubscriptionID]
+		if !ok {
+			subscription, err = s.subscriptionRepo.GetByID(ctx, delivery.SubscriptionID)
+			if err != nil {
+				subscription = nil
+			}
+			subscriptions[delivery.SubscriptionID] = subscription
+		}
+
+		if subscription == nil || subscription.Disabled {
+			delivery.Status = domain.WebhookDeliveryStatusFailed
+			delivery.LastError = "subscription is disabled"
+			if err := s.deliveryRepo.Update(ctx, delivery); err != nil {
+				log.Printf("Error updating webhook delivery %d: %v", delivery.ID, err)
+			}
+			continue
+		}
+
+		if err := s.attempt(ctx, subscription, delivery); err != nil {
+			log.Printf("Error updating webhook delivery %d: %v", delivery.ID, err)
+		}
+	}
+	return len(deliveries), nil
+}
+
+// attempt sends the delivery once and records the outcome: delivered on a
+// 2xx, otherwise retried later or, out of attempts, failed.
+func (s *WebhookService) attempt(ctx context.Context, subscription *domain.WebhookSubscription, delivery *domain.WebhookDelivery) error {
+	sendCtx, cancel := context.WithTimeout(ctx, s.config.Timeout)
+	response, err := s.sender.Send(sendCtx, &domain.WebhookRequest{
+		URL:        subscription.URL,
+		Secret:     subscription.Secret,
+		EventType:  delivery.EventType,
+		DeliveryID: delivery.ID,
+		Payload:    delivery.Payload,
+	})
+	cancel()
+
+	delivery.Attempts++
+	delivery.ResponseCode = 0
+	delivery.ResponseBody = ""
+	if response != nil {
+		delivery.ResponseCode = response.StatusCode
+		delivery.ResponseBody = response.Body
+		if response.StatusCode >= 300 {
+			err = fmt.Errorf("HTTP %d", response.StatusCode)
+		}
+	}
+
+	if err == nil {
+		now := time.Now()
+		delivery.Status = domain.WebhookDeliveryStatusDelivered
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+		return s.deliveryRepo.Update(ctx, delivery)
+	}
+
+	delivery.LastError = err.Error()
+	if s.config.MaxAttempts > 0 && delivery.Attempts >= s.config.MaxAttempts {
+		delivery.Status = domain.WebhookDeliveryStatusFailed
+		log.Printf("Giving up webhook delivery %d to %s after %d attempts: %v", delivery.ID, subscription.URL, delivery.Attempts, err)
+	} else {
+		delivery.Status = domain.WebhookDeliveryStatusPending
+		delivery.NextAttemptAt = time.Now().Add(retryBackoff(s.config.RetryBackoff, delivery.Attempts))
+	}
+	return s.deliveryRepo.Update(ctx, delivery)
+}
+
+func validateWebhookSubscription(request *domain.WebhookSubscriptionRequest) error {
+	target, err := url.Parse(request.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+
+	if len(request.EventTypes) == 0 {
+		return errors.New("at least one event type is required")
+	}
+	for _, eventType := range request.EventTypes {
+		if eventType != "*" && !slices.Contains(domain.EventTypes, eventType) {
+			return fmt.Errorf("unknown event type %q", eventType)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/config"
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+type fakeWebhookSubscriptionRepo struct {
+	domain.WebhookSubscriptionRepository
+	subscriptions map[uint]*domain.WebhookSubscription
+}
+
+func (r *fakeWebhookSubscriptionRepo) GetByID(ctx context.Context, id uint) (*domain.WebhookSubscription, error) {
+	subscription, ok := r.subscriptions[id]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return subscription, nil
+}
+
+// fakeWebhookDeliveryRepo hands out its deliveries once and keeps what the
+// service records about them.
+type fakeWebhookDeliveryRepo struct {
+	domain.WebhookDeliveryRepository
+	due     []domain.WebhookDelivery
+	updated map[uint]domain.WebhookDelivery
+}
+
+func (r *fakeWebhookDeliveryRepo) ClaimDue(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]domain.WebhookDelivery, error) {
+	due := r.due
+	r.due = nil
+	return due, nil
+}
+
+func (r *fakeWebhookDeliveryRepo) Update(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	r.updated[delivery.ID] = *delivery
+	return nil
+}
+
+type fakeWebhookSender struct {
+	response *domain.WebhookResponse
+	err      error
+	sent     int
+}
+
+func (s *fakeWebhookSender) Send(ctx context.Context, request *domain.WebhookRequest) (*domain.WebhookResponse, error) {
+	s.sent++
+	return s.response, s.err
+}
+
+func TestWebhookDeliverDue(t *testing.T) {
+	webhookConfig := config.WebhookConfig{
+		Timeout:      time.Second,
+		BatchSize:    10,
+		Lease:        time.Minute,
+		RetryBackoff: time.Minute,
+		MaxAttempts:  3,
+	}
+
+	tests := []struct {
+		name         string
+		attempts     int
+		disabled     bool
+		response     *domain.WebhookResponse
+		sendErr      error
+		wantSent     int
+		wantStatus   string
+		wantAttempts int
+		wantCode     int
+		wantBackoff  time.Duration
+	}{
+		{
+			name:       "delivered",
+			response:   &domain.WebhookResponse{StatusCode: 204},
+			wantSent:   1,
+			wantStatus: domain.WebhookDeliveryStatusDelivered, wantAttempts: 1, wantCode: 204,
+		},
+		{
+			name:       "server error retried",
+			response:   &domain.WebhookResponse{StatusCode: 500, Body: "oops"},
+			wantSent:   1,
+			wantStatus: domain.WebhookDeliveryStatusPending, wantAttempts: 1, wantCode: 500, wantBackoff: time.Minute,
+		},
+		{
+			name:       "unreachable retried with longer backoff",
+			attempts:   1,
+			sendErr:    errors.New("connection refused"),
+			wantSent:   1,
+			wantStatus: domain.WebhookDeliveryStatusPending, wantAttempts: 2, wantBackoff: 2 * time.Minute,
+		},
+		{
+			name:       "given up after the last attempt",
+			attempts:   2,
+			response:   &domain.WebhookResponse{StatusCode: 404},
+			wantSent:   1,
+			wantStatus: domain.WebhookDeliveryStatusFailed, wantAttempts: 3, wantCode: 404,
+		},
+		{
+			name:       "disabled subscription",
+			disabled:   true,
+			wantStatus: domain.WebhookDeliveryStatusFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subscriptionRepo := &fakeWebhookSubscriptionRepo{subscriptions: map[uint]*domain.WebhookSubscription{
+				1: {ID: 1, URL: "https://example.com/hook", Disabled: tt.disabled},
+			}}
+			deliveryRepo := &fakeWebhookDeliveryRepo{
+				due: []domain.WebhookDelivery{{
+					ID:             1,
+					SubscriptionID: 1,
+					Status:         domain.WebhookDeliveryStatusPending,
+					Attempts:       tt.attempts,
+				}},
+				updated: make(map[uint]domain.WebhookDelivery),
+			}
+			sender := &fakeWebhookSender{response: tt.response, err: tt.sendErr}
+			service := NewWebhookService(subscriptionRepo, deliveryRepo, sender, nil, webhookConfig)
+
+			before := time.Now()
+			count, err := service.DeliverDue(context.Background())
+			if err != nil {
+				t.Fatalf("DeliverDue: %v", err)
+			}
+			if count != 1 {
+				t.Fatalf("attempted %d deliveries, want 1", count)
+			}
+			if sender.sent != tt.wantSent {
+				t.Errorf("sent %d requests, want %d", sender.sent, tt.wantSent)
+			}
+
+			delivery, ok := deliveryRepo.updated[1]
+			if !ok {
+				t.Fatal("delivery not updated")
+			}
+			if delivery.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", delivery.Status, tt.wantStatus)
+			}
+			if delivery.Attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", delivery.Attempts, tt.wantAttempts)
+			}
+			if delivery.ResponseCode != tt.wantCode {
+				t.Errorf("response code = %d, want %d", delivery.ResponseCode, tt.wantCode)
+			}
+			if (delivery.Status == domain.WebhookDeliveryStatusDelivered) != (delivery.LastError == "") {
+				t.Errorf("last error = %q with status %s", delivery.LastError, delivery.Status)
+			}
+			if tt.wantBackoff > 0 {
+				if next := delivery.NextAttemptAt.Sub(before); next < tt.wantBackoff || next > tt.wantBackoff+time.Second {
+					t.Errorf("next attempt in %s, want %s", next, tt.wantBackoff)
+				}
+			}
+		})
+	}
+}
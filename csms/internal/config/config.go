@@ -7,13 +7,24 @@ import (
 )
 
 type Config struct {
-	Server     ServerConfig     `mapstructure:"server"`
-	Database   DatabaseConfig   `mapstructure:"database"`
-	Redis      RedisConfig      `mapstructure:"redis"`
-	JWT        JWTConfig        `mapstructure:"jwt"`
-	Logging    LoggingConfig    `mapstructure:"logging"`
-	Monitoring MonitoringConfig `mapstructure:"monitoring"`
-	Tariff     TariffConfig     `mapstructure:"tariff"`
+	Server        ServerConfig        `mapstructure:"server"`
+	Database      DatabaseConfig      `mapstructure:"database"`
+	Redis         RedisConfig         `mapstructure:"redis"`
+	JWT           JWTConfig           `mapstructure:"jwt"`
+	Logging       LoggingConfig       `mapstructure:"logging"`
+	Monitoring    MonitoringConfig    `mapstructure:"monitoring"`
+	Tariff        TariffConfig        `mapstructure:"tariff"`
+	OCPP          OCPPConfig          `mapstructure:"ocpp"`
+	Transaction   TransactionConfig   `mapstructure:"transaction"`
+	Authorization AuthorizationConfig `mapstructure:"authorization"`
+	Invoice       InvoiceConfig       `mapstructure:"invoice"`
+	Payment       PaymentConfig       `mapstructure:"payment"`
+	Guest         GuestConfig         `mapstructure:"guest"`
+	OCPI          OCPIConfig          `mapstructure:"ocpi"`
+	Cluster       ClusterConfig       `mapstructure:"cluster"`
+	Events        EventsConfig        `mapstructure:"events"`
+	Webhook       WebhookConfig       `mapstructure:"webhook"`
+	Maintenance   MaintenanceConfig   `mapstructure:"maintenance"`
 }
 
 type ServerConfig struct {
@@ -56,13 +67,159 @@ type LoggingConfig struct {
 	Output string `mapstructure:"output"`
 }
 
+// MonitoringConfig configures the Prometheus metrics, served at /metrics on
+// their own port.
 type MonitoringConfig struct {
 	Enabled bool   `mapstructure:"enabled"`
 	Port    string `mapstructure:"port"`
 }
 
 type TariffConfig struct {
-	PricePerKwh float64 `mapstructure:"price_per_kwh"`
+	PricePerKwh  float64 `mapstructure:"price_per_kwh"`
+	Currency     string  `mapstructure:"currency"`
+	TaxRate      float64 `mapstructure:"tax_rate"`
+	TaxInclusive bool    `mapstructure:"tax_inclusive"`
+	Rounding     string  `mapstructure:"rounding"`
+}
+
+type OCPPConfig struct {
+	MessageCacheTTL time.Duration `mapstructure:"message_cache_ttl"`
+	CallTimeout     time.Duration `mapstructure:"call_timeout"`
+}
+
+type TransactionConfig struct {
+	StuckCheckInterval        time.Duration `mapstructure:"stuck_check_interval"`
+	MeterInactivityTimeout    time.Duration `mapstructure:"meter_inactivity_timeout"`
+	AvailableConnectorTimeout time.Duration `mapstructure:"available_connector_timeout"`
+}
+
+type AuthorizationConfig struct {
+	GroupConcurrentTx bool `mapstructure:"group_concurrent_tx"`
+	// Backends are asked in order until one knows the tag.
+	Backends []AuthorizerConfig `mapstructure:"backends"`
+	// UserEmail is the system user owning the id tags of drivers accepted by
+	// an external authorization service.
+	UserEmail string                   `mapstructure:"user_email"`
+	Cache     AuthorizationCacheConfig `mapstructure:"cache"`
+}
+
+// AuthorizationCacheConfig configures the cache backend of the chain. Store
+// is memory, or redis to share decisions between instances; a cluster
+// requires redis.
+type AuthorizationCacheConfig struct {
+	Store string        `mapstructure:"store"`
+	TTL   time.Duration `mapstructure:"ttl"`
+}
+
+type AuthorizerConfig struct {
+	// Type is local, cache, webhook or roaming.
+	Type    string        `mapstructure:"type"`
+	Timeout time.Duration `mapstructure:"timeout"`
+	// OnError is next to ask the next backend when this one fails, or reject.
+	OnError string `mapstructure:"on_error"`
+	// URL and Secret of the webhook; requests are signed with HMAC-SHA256
+	// when a secret is set.
+	URL    string `mapstructure:"url"`
+	Secret string `mapstructure:"secret"`
+}
+
+type InvoiceConfig struct {
+	ReceiptPrefix  string        `mapstructure:"receipt_prefix"`
+	InvoicePrefix  string        `mapstructure:"invoice_prefix"`
+	CompanyName    string        `mapstructure:"company_name"`
+	CompanyAddress string        `mapstructure:"company_address"`
+	CompanyTaxID   string        `mapstructure:"company_tax_id"`
+	Monthly        bool          `mapstructure:"monthly"`
+	CheckInterval  time.Duration `mapstructure:"check_interval"`
+}
+
+type PaymentConfig struct {
+	Provider       string        `mapstructure:"provider"`
+	WebhookSecret  string        `mapstructure:"webhook_secret"`
+	PreAuthAmount  int64         `mapstructure:"pre_auth_amount"`
+	PendingTimeout time.Duration `mapstructure:"pending_timeout"`
+}
+
+type GuestConfig struct {
+	PublicBaseURL string        `mapstructure:"public_base_url"`
+	UserEmail     string        `mapstructure:"user_email"`
+	UserGroup     string        `mapstructure:"user_group"`
+	TagTTL        time.Duration `mapstructure:"tag_ttl"`
+	SessionTTL    time.Duration `mapstructure:"session_ttl"`
+}
+
+type OCPIConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	BaseURL       string        `mapstructure:"base_url"`
+	CountryCode   string        `mapstructure:"country_code"`
+	PartyID       string        `mapstructure:"party_id"`
+	BusinessName  string        `mapstructure:"business_name"`
+	PushQueueSize int           `mapstructure:"push_queue_size"`
+	PushTimeout   time.Duration `mapstructure:"push_timeout"`
+	// AuthorizeTimeout bounds a real-time token authorization, which the
+	// charge point is waiting on.
+	AuthorizeTimeout time.Duration `mapstructure:"authorize_timeout"`
+	UserEmail        string        `mapstructure:"user_email"`
+}
+
+// ClusterConfig lets several CSMS instances share the charge point
+// connections behind a load balancer. Directory is redis, or memory for
+// several nodes in one process.
+type ClusterConfig struct {
+	Enabled   bool   `mapstructure:"enabled"`
+	Directory string `mapstructure:"directory"`
+	// NodeID names this instance; a random one is used when empty.
+	NodeID            string        `mapstructure:"node_id"`
+	HeartbeatInterval time.Duration `mapstructure:"heartbeat_interval"`
+	// NodeTTL is how long the connections of a node that stopped
+	// heartbeating are still routed to it.
+	NodeTTL time.Duration `mapstructure:"node_ttl"`
+}
+
+// EventsConfig configures the dispatch of domain events from the outbox.
+type EventsConfig struct {
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+	BatchSize    int           `mapstructure:"batch_size"`
+	// Lease is how long an instance holds the events it is dispatching.
+	Lease time.Duration `mapstructure:"lease"`
+	// RetryBackoff is the delay before the first retry; it doubles with every
+	// further attempt.
+	RetryBackoff time.Duration `mapstructure:"retry_backoff"`
+	MaxAttempts  int           `mapstructure:"max_attempts"`
+	// Sinks are external systems every event is delivered to: log, redis.
+	Sinks       []string `mapstructure:"sinks"`
+	RedisStream string   `mapstructure:"redis_stream"`
+	// StreamInterval is how often /api/v1/events and the live transaction
+	// streams look for new events.
+	StreamInterval time.Duration `mapstructure:"stream_interval"`
+	// StreamLag is how old events must be before they are streamed, so
+	// events committed out of order are not skipped.
+	StreamLag time.Duration `mapstructure:"stream_lag"`
+	// Retention is how long dispatched and failed events are kept; 0 keeps
+	// them forever. They are pruned every PruneInterval.
+	Retention     time.Duration `mapstructure:"retention"`
+	PruneInterval time.Duration `mapstructure:"prune_interval"`
+}
+
+// WebhookConfig configures the delivery of events to webhook subscriptions.
+type WebhookConfig struct {
+	Timeout      time.Duration `mapstructure:"timeout"`
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+	BatchSize    int           `mapstructure:"batch_size"`
+	// Lease is how long an instance holds the deliveries it is attempting.
+	Lease time.Duration `mapstructure:"lease"`
+	// RetryBackoff is the delay before the first retry; it doubles with every
+	// further attempt.
+	RetryBackoff time.Duration `mapstructure:"retry_backoff"`
+	MaxAttempts  int           `mapstructure:"max_attempts"`
+}
+
+// MaintenanceConfig configures alerting and maintenance tickets.
+type MaintenanceConfig struct {
+	// CheckInterval is how often the alert rules are evaluated.
+	CheckInterval time.Duration `mapstructure:"check_interval"`
+	// Notifiers are told about ticket changes: log, events.
+	Notifiers []string `mapstructure:"notifiers"`
 }
 
 func Load(configPath string) (*Config, error) {
@@ -118,4 +275,79 @@ func setDefaults() {
 	viper.SetDefault("monitoring.port", "9090")
 
 	viper.SetDefault("tariff.price_per_kwh", 1500.0)
+	viper.SetDefault("tariff.currency", "IDR")
+	viper.SetDefault("tariff.tax_rate", 0.0)
+	viper.SetDefault("tariff.tax_inclusive", false)
+	viper.SetDefault("tariff.rounding", "half_up")
+
+	viper.SetDefault("ocpp.message_cache_ttl", "10m")
+	viper.SetDefault("ocpp.call_timeout", "30s")
+
+	viper.SetDefault("transaction.stuck_check_interval", "5m")
+	viper.SetDefault("transaction.meter_inactivity_timeout", "2h")
+	viper.SetDefault("transaction.available_connector_timeout", "15m")
+
+	viper.SetDefault("authorization.group_concurrent_tx", false)
+	viper.SetDefault("authorization.backends", []map[string]interface{}{
+		{"type": "cache", "timeout": "500ms", "on_error": "next"},
+		{"type": "local", "timeout": "2s", "on_error": "next"},
+		{"type": "roaming", "timeout": "10s", "on_error": "next"},
+	})
+	viper.SetDefault("authorization.user_email", "external@csms.local")
+	viper.SetDefault("authorization.cache.store", "memory")
+	viper.SetDefault("authorization.cache.ttl", "5m")
+
+	viper.SetDefault("invoice.receipt_prefix", "RCP")
+	viper.SetDefault("invoice.invoice_prefix", "INV")
+	viper.SetDefault("invoice.company_name", "CSMS")
+	viper.SetDefault("invoice.monthly", true)
+	viper.SetDefault("invoice.check_interval", "1h")
+
+	viper.SetDefault("payment.provider", "fake")
+	viper.SetDefault("payment.pre_auth_amount", 20000000)
+	viper.SetDefault("payment.pending_timeout", "5m")
+
+	viper.SetDefault("guest.public_base_url", "http://localhost:5173")
+	viper.SetDefault("guest.user_email", "guest@csms.local")
+	viper.SetDefault("guest.user_group", "guest")
+	viper.SetDefault("guest.tag_ttl", "12h")
+	viper.SetDefault("guest.session_ttl", "48h")
+
+	viper.SetDefault("ocpi.enabled", false)
+	viper.SetDefault("ocpi.base_url", "http://localhost:8080/ocpi")
+	viper.SetDefault("ocpi.country_code", "ID")
+	viper.SetDefault("ocpi.party_id", "CSM")
+	viper.SetDefault("ocpi.business_name", "CSMS")
+	viper.SetDefault("ocpi.push_queue_size", 1000)
+	viper.SetDefault("ocpi.push_timeout", "10s")
+	viper.SetDefault("ocpi.authorize_timeout", "5s")
+	viper.SetDefault("ocpi.user_email", "roaming@csms.local")
+
+	viper.SetDefault("cluster.enabled", false)
+	viper.SetDefault("cluster.directory", "redis")
+	viper.SetDefault("cluster.node_id", "")
+	viper.SetDefault("cluster.heartbeat_interval", "10s")
+	viper.SetDefault("cluster.node_ttl", "30s")
+
+	viper.SetDefault("events.poll_interval", "1s")
+	viper.SetDefault("events.batch_size", 100)
+	viper.SetDefault("events.lease", "1m")
+	viper.SetDefault("events.retry_backoff", "5s")
+	viper.SetDefault("events.max_attempts", 20)
+	viper.SetDefault("events.sinks", []string{})
+	viper.SetDefault("events.redis_stream", "csms:events")
+	viper.SetDefault("events.stream_interval", "500ms")
+	viper.SetDefault("events.stream_lag", "2s")
+	viper.SetDefault("events.retention", "168h")
+	viper.SetDefault("events.prune_interval", "1h")
+
+	viper.SetDefault("webhook.timeout", "10s")
+	viper.SetDefault("webhook.poll_interval", "5s")
+	viper.SetDefault("webhook.batch_size", 50)
+	viper.SetDefault("webhook.lease", "2m")
+	viper.SetDefault("webhook.retry_backoff", "30s")
+	viper.SetDefault("webhook.max_attempts", 10)
+
+	viper.SetDefault("maintenance.check_interval", "1m")
+	viper.SetDefault("maintenance.notifiers", []string{"log", "events"})
 }
@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"time"
+)
+
+// AccessRule restricts where and when an idTag, or every tag of a parent
+// group, may charge. A tag with rules is only accepted when at least one of
+// them permits the session.
+type AccessRule struct {
+	ID                uint      `json:"id" gorm:"primaryKey"`
+	Name              string    `json:"name"`
+	IDTagID           *uint     `json:"idTagId" gorm:"index"`
+	ParentIDTag       string    `json:"parentIdTag" gorm:"index"`
+	ChargePointID     *uint     `json:"chargePointId"`
+	Site              string    `json:"site"`
+	ChargePointGroup  string    `json:"chargePointGroup"`
+	StartTime         string    `json:"startTime"`
+	EndTime           string    `json:"endTime"`
+	TimeZone          string    `json:"timeZone"`
+	MaxSessionMinutes int       `json:"maxSessionMinutes"`
+	Disabled          bool      `json:"disabled"`
+	CreatedAt         time.Time `json:"createdAt"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+}
@@ -0,0 +1,93 @@
+package domain
+
+import (
+	"time"
+)
+
+// TransactionAdjustment records a cost correction applied to a completed
+// transaction. The original figures are kept so the change can be traced and
+// reverted.
+type TransactionAdjustment struct {
+	ID                     uint      `json:"id" gorm:"primaryKey"`
+	TransactionID          uint      `json:"transactionId" gorm:"not null;index"`
+	BatchID                string    `json:"batchId" gorm:"index"`
+	TariffID               uint      `json:"tariffId" gorm:"not null"`
+	Currency               string    `json:"currency"`
+	OriginalTariffID       *uint     `json:"originalTariffId"`
+	OriginalCurrency       string    `json:"originalCurrency"`
+	OriginalEnergyConsumed float64   `json:"originalEnergyConsumed"`
+	OriginalEnergyCost     int64     `json:"originalEnergyCost"`
+	OriginalTimeCost       int64     `json:"originalTimeCost"`
+	OriginalFlatCost       int64     `json:"originalFlatCost"`
+	OriginalIdleCost       int64     `json:"originalIdleCost"`
+	OriginalNetAmount      int64     `json:"originalNetAmount"`
+	OriginalTaxAmount      int64     `json:"originalTaxAmount"`
+	OriginalGrossAmount    int64     `json:"originalGrossAmount"`
+	EnergyConsumed         float64   `json:"energyConsumed"`
+	EnergyCost             int64     `json:"energyCost"`
+	TimeCost               int64     `json:"timeCost"`
+	FlatCost               int64     `json:"flatCost"`
+	IdleCost               int64     `json:"idleCost"`
+	NetAmount              int64     `json:"netAmount"`
+	TaxAmount              int64     `json:"taxAmount"`
+	GrossAmount            int64     `json:"grossAmount"`
+	Reason                 string    `json:"reason"`
+	UserID                 *uint     `json:"userId"`
+	CreatedAt              time.Time `json:"createdAt"`
+}
+
+// TransactionFilter selects completed transactions by charge point, site,
+// user group and start time. Empty fields do not filter.
+type TransactionFilter struct {
+	IDs           []uint     `json:"ids"`
+	ChargePointID *uint      `json:"chargePointId"`
+	Site          string     `json:"site"`
+	UserGroup     string     `json:"userGroup"`
+	From          *time.Time `json:"from"`
+	To            *time.Time `json:"to"`
+	// Unsettled keeps only the transactions neither paid by card nor
+	// charged to a wallet.
+	Unsettled bool `json:"-"`
+}
+
+// RecalculationRequest reprices the selected transactions with the given
+// tariff version. Nothing is written unless Apply is set.
+type RecalculationRequest struct {
+	TariffID uint              `json:"tariffId" binding:"required"`
+	Filter   TransactionFilter `json:"filter"`
+	Reason   string            `json:"reason"`
+	Apply    bool              `json:"apply"`
+}
+
+// RecalculationReport lists the difference recalculation makes per
+// transaction. Amounts are gross, in minor units of their currency.
+// TotalDifference sums the changes that are or would be applied per
+// currency; Settled counts the changed transactions that were already paid
+// for, whose difference is booked on their wallet or card payment.
+type RecalculationReport struct {
+	BatchID         string              `json:"batchId,omitempty"`
+	TariffID        uint                `json:"tariffId"`
+	Applied         bool                `json:"applied"`
+	Count           int                 `json:"count"`
+	Changed         int                 `json:"changed"`
+	Settled         int                 `json:"settled"`
+	TotalDifference map[string]int64    `json:"totalDifference"`
+	Items           []RecalculationItem `json:"items"`
+}
+
+// RecalculationItem is the recalculation of one transaction. Difference is
+// only set when both amounts are in the same currency. SettledBy tells how
+// a changed transaction was already paid, and so where its difference is
+// booked.
+type RecalculationItem struct {
+	TransactionID          uint    `json:"transactionId"`
+	OCPPTransactionID      int     `json:"ocppTransactionId"`
+	OriginalEnergyConsumed float64 `json:"originalEnergyConsumed"`
+	EnergyConsumed         float64 `json:"energyConsumed"`
+	OriginalCurrency       string  `json:"originalCurrency"`
+	OriginalGrossAmount    int64   `json:"originalGrossAmount"`
+	Currency               string  `json:"currency"`
+	GrossAmount            int64   `json:"grossAmount"`
+	Difference             int64   `json:"difference"`
+	SettledBy              string  `json:"settledBy,omitempty"`
+}
@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"time"
+)
+
+// TagDecision is what a backend of the authorization chain knows about a
+// tag. IDTag is nil when the backend keeps no IDTag for it, e.g. an external
+// authorization service; the chain then creates one to record transactions
+// against.
+type TagDecision struct {
+	Status      string     `json:"status"`
+	ExpiryDate  *time.Time `json:"expiryDate,omitempty"`
+	ParentIDTag string     `json:"parentIdTag,omitempty"`
+	IDTag       *IDTag     `json:"idTag,omitempty"`
+	OCPIPartyID *uint      `json:"ocpiPartyId,omitempty"`
+}
+
+// AuthorizerStats counts the outcomes of one backend of the authorization
+// chain since the server started.
+type AuthorizerStats struct {
+	Backend          string  `json:"backend"`
+	Requests         int64   `json:"requests"`
+	Accepted         int64   `json:"accepted"`
+	Rejected         int64   `json:"rejected"`
+	Unknown          int64   `json:"unknown"`
+	Errors           int64   `json:"errors"`
+	Timeouts         int64   `json:"timeouts"`
+	AverageLatencyMs float64 `json:"averageLatencyMs"`
+}
+
+// AuthorizationCacheStats counts the lookups of the authorization cache since
+// the server started.
+type AuthorizationCacheStats struct {
+	Store         string  `json:"store"`
+	TTLSeconds    float64 `json:"ttlSeconds"`
+	Hits          int64   `json:"hits"`
+	Misses        int64   `json:"misses"`
+	Stores        int64   `json:"stores"`
+	Invalidations int64   `json:"invalidations"`
+	Errors        int64   `json:"errors"`
+	HitRate       float64 `json:"hitRate"`
+}
@@ -0,0 +1,92 @@
+package domain
+
+import "time"
+
+// ConnectorStatusChange records a connector entering a status or starting
+// to report another error code.
+type ConnectorStatusChange struct {
+	ID              uint   `json:"id" gorm:"primaryKey"`
+	ChargePointID   uint   `json:"chargePointId" gorm:"not null;index:idx_status_change_connector"`
+	ConnectorID     int    `json:"connectorId" gorm:"not null;index:idx_status_change_connector"`
+	FromStatus      string `json:"fromStatus"`
+	ToStatus        string `json:"toStatus" gorm:"not null"`
+	ErrorCode       string `json:"errorCode"`
+	Info            string `json:"info"`
+	VendorID        string `json:"vendorId"`
+	VendorErrorCode string `json:"vendorErrorCode"`
+	// ReportedAt is the station's timestamp of the change, if it sent one.
+	ReportedAt *time.Time `json:"reportedAt"`
+	// ChangedAt is when the CSMS received the change; reports use it, as
+	// station clocks drift.
+	ChangedAt time.Time `json:"changedAt" gorm:"not null;index:idx_status_change_connector"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ChargePointConnectionChange records a charge point's websocket connecting
+// or disconnecting. While it is offline its connectors are down whatever
+// status they last reported.
+type ChargePointConnectionChange struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	ChargePointID uint      `json:"chargePointId" gorm:"not null;index:idx_connection_change_charge_point"`
+	Online        bool      `json:"online"`
+	ChangedAt     time.Time `json:"changedAt" gorm:"not null;index:idx_connection_change_charge_point"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// StatusHistoryFilter selects a connector's status changes in [From, To).
+type StatusHistoryFilter struct {
+	ChargePointID uint
+	ConnectorID   int
+	From          time.Time
+	To            time.Time
+	Limit         int
+	Offset        int
+}
+
+// AvailabilityFilter selects the connectors and period of an availability
+// report. Empty fields do not filter.
+type AvailabilityFilter struct {
+	ChargePointID uint
+	Site          string
+	From          time.Time
+	To            time.Time
+}
+
+// AvailabilityStats are the availability KPIs over a period. Time before the
+// first known status is not monitored and does not count. Time the charge
+// point was disconnected is counted as Offline, and time it reported itself
+// Faulted or Unavailable, as connector 0, in that status, whatever the
+// connector's status. Uptime is the monitored time outside Offline, Faulted and
+// Unavailable. MTBF is uptime per failure,
+// a failure being a change into Faulted; MTTR is the time spent Faulted per
+// fault. Both are nil when there was nothing to divide by.
+type AvailabilityStats struct {
+	MonitoredSeconds    int64            `json:"monitoredSeconds"`
+	UptimeSeconds       int64            `json:"uptimeSeconds"`
+	TimeInStatus        map[string]int64 `json:"timeInStatus"`
+	AvailabilityPercent float64          `json:"availabilityPercent"`
+	Failures            int              `json:"failures"`
+	MTBFSeconds         *int64           `json:"mtbfSeconds"`
+	MTTRSeconds         *int64           `json:"mttrSeconds"`
+}
+
+type ConnectorAvailability struct {
+	ChargePointID   uint   `json:"chargePointId"`
+	ChargePointCode string `json:"chargePointCode"`
+	Site            string `json:"site"`
+	ConnectorID     int    `json:"connectorId"`
+	AvailabilityStats
+}
+
+type SiteAvailability struct {
+	Site       string `json:"site"`
+	Connectors int    `json:"connectors"`
+	AvailabilityStats
+}
+
+type AvailabilityReport struct {
+	From       time.Time               `json:"from"`
+	To         time.Time               `json:"to"`
+	Connectors []ConnectorAvailability `json:"connectors"`
+	Sites      []SiteAvailability      `json:"sites"`
+}
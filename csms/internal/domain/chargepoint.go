@@ -5,38 +5,97 @@ import (
 )
 
 type ChargePoint struct {
-	ID                      uint      `json:"id" gorm:"primaryKey"`
-	ChargePointCode         string    `json:"chargePointCode" gorm:"uniqueIndex;not null"`
-	ChargeBoxSerialNumber   string    `json:"chargeBoxSerialNumber"`
-	ChargePointModel        string    `json:"chargePointModel" gorm:"not null"`
-	ChargePointVendor       string    `json:"chargePointVendor"`
-	ChargePointSerialNumber string    `json:"chargePointSerialNumber"`
-	FirmwareVersion         string    `json:"firmwareVersion"`
-	Iccid                   string    `json:"iccid"`
-	Imsi                    string    `json:"imsi"`
-	MeterType               string    `json:"meterType"`
-	MeterSerialNumber       string    `json:"meterSerialNumber"`
-	Status                  string    `json:"status" gorm:"default:'Available'"`
-	LastHeartbeat           time.Time `json:"lastHeartbeat"`
-	LastBootNotification    time.Time `json:"lastBootNotification"`
-	CreatedAt               time.Time `json:"createdAt"`
-	UpdatedAt               time.Time `json:"updatedAt"`
+	ID                      uint   `json:"id" gorm:"primaryKey"`
+	ChargePointCode         string `json:"chargePointCode" gorm:"uniqueIndex;not null"`
+	ChargeBoxSerialNumber   string `json:"chargeBoxSerialNumber"`
+	ChargePointModel        string `json:"chargePointModel" gorm:"not null"`
+	ChargePointVendor       string `json:"chargePointVendor"`
+	ChargePointSerialNumber string `json:"chargePointSerialNumber"`
+	FirmwareVersion         string `json:"firmwareVersion"`
+	Iccid                   string `json:"iccid"`
+	Imsi                    string `json:"imsi"`
+	MeterType               string `json:"meterType"`
+	MeterSerialNumber       string `json:"meterSerialNumber"`
+	// Status is the charge point's own status, as reported for connector 0;
+	// AggregateStatus sums it up together with its connectors' statuses.
+	Status          string `json:"status" gorm:"default:'Available'"`
+	AggregateStatus string `json:"aggregateStatus" gorm:"default:'Available'"`
+	// StatusChangedAt is when Status last changed; ErrorCode is the error
+	// the charge point last reported for connector 0.
+	StatusChangedAt      *time.Time `json:"statusChangedAt"`
+	ErrorCode            string     `json:"errorCode"`
+	Site                 string     `json:"site" gorm:"index"`
+	ChargePointGroup     string     `json:"chargePointGroup" gorm:"index"`
+	Address              string     `json:"address"`
+	City                 string     `json:"city"`
+	PostalCode           string     `json:"postalCode"`
+	Country              string     `json:"country"`
+	Latitude             float64    `json:"latitude"`
+	Longitude            float64    `json:"longitude"`
+	LastHeartbeat        time.Time  `json:"lastHeartbeat"`
+	LastBootNotification time.Time  `json:"lastBootNotification"`
+	// Online tells whether the charge point's websocket is connected, since
+	// OnlineChangedAt.
+	Online          bool       `json:"online"`
+	OnlineChangedAt *time.Time `json:"onlineChangedAt"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
 
 	Connectors   []Connector   `json:"connectors" gorm:"foreignKey:ChargePointID"`
 	Transactions []Transaction `json:"transactions" gorm:"foreignKey:ChargePointID"`
 }
 
+// ChargePointStatuses lists the statuses a connector may report.
+var ChargePointStatuses = []string{
+	ChargePointStatusAvailable,
+	ChargePointStatusPreparing,
+	ChargePointStatusCharging,
+	ChargePointStatusSuspendedEV,
+	ChargePointStatusSuspendedEVSE,
+	ChargePointStatusFinishing,
+	ChargePointStatusReserved,
+	ChargePointStatusUnavailable,
+	ChargePointStatusFaulted,
+}
+
+// StationStatuses lists the statuses a charge point may report for itself,
+// as connector 0.
+var StationStatuses = []string{
+	ChargePointStatusAvailable,
+	ChargePointStatusUnavailable,
+	ChargePointStatusFaulted,
+}
+
+// ChargePointLocation is the address and position of a charge point, as
+// published to roaming partners.
+type ChargePointLocation struct {
+	Address    string  `json:"address" binding:"required"`
+	City       string  `json:"city" binding:"required"`
+	PostalCode string  `json:"postalCode"`
+	Country    string  `json:"country" binding:"required,len=3"`
+	Latitude   float64 `json:"latitude" binding:"min=-90,max=90"`
+	Longitude  float64 `json:"longitude" binding:"min=-180,max=180"`
+}
+
 type Connector struct {
-	ID              uint      `json:"id" gorm:"primaryKey"`
-	ChargePointID   uint      `json:"chargePointId" gorm:"not null"`
-	ConnectorID     int       `json:"connectorId" gorm:"not null"`
-	Status          string    `json:"status" gorm:"default:'Available'"`
-	ErrorCode       string    `json:"errorCode"`
-	Info            string    `json:"info"`
-	VendorID        string    `json:"vendorId"`
-	VendorErrorCode string    `json:"vendorErrorCode"`
-	CreatedAt       time.Time `json:"createdAt"`
-	UpdatedAt       time.Time `json:"updatedAt"`
+	ID              uint    `json:"id" gorm:"primaryKey"`
+	ChargePointID   uint    `json:"chargePointId" gorm:"not null"`
+	ConnectorID     int     `json:"connectorId" gorm:"not null"`
+	PublicCode      *string `json:"publicCode" gorm:"uniqueIndex"`
+	Standard        string  `json:"standard" gorm:"default:'IEC_62196_T2'"`
+	Format          string  `json:"format" gorm:"default:'SOCKET'"`
+	PowerType       string  `json:"powerType" gorm:"default:'AC_3_PHASE'"`
+	MaxVoltage      int     `json:"maxVoltage" gorm:"default:400"`
+	MaxAmperage     int     `json:"maxAmperage" gorm:"default:32"`
+	Status          string  `json:"status" gorm:"default:'Available'"`
+	ErrorCode       string  `json:"errorCode"`
+	Info            string  `json:"info"`
+	VendorID        string  `json:"vendorId"`
+	VendorErrorCode string  `json:"vendorErrorCode"`
+	// StatusChangedAt is when the connector entered its current status.
+	StatusChangedAt *time.Time `json:"statusChangedAt"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
 
 	ChargePoint ChargePoint `json:"chargePoint" gorm:"foreignKey:ChargePointID"`
 }
@@ -49,6 +108,7 @@ type User struct {
 	Phone     string    `json:"phone"`
 	Role      string    `json:"role" gorm:"default:'customer'"`
 	Status    string    `json:"status" gorm:"default:'active'"`
+	Group     string    `json:"group" gorm:"column:user_group;index"`
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 
@@ -56,13 +116,15 @@ type User struct {
 }
 
 type IDTag struct {
-	ID         uint      `json:"id" gorm:"primaryKey"`
-	Tag        string    `json:"tag" gorm:"uniqueIndex;not null"`
-	Status     string    `json:"status" gorm:"default:'Accepted'"`
-	ExpiryDate time.Time `json:"expiryDate"`
-	UserID     uint      `json:"userId" gorm:"not null"`
-	CreatedAt  time.Time `json:"createdAt"`
-	UpdatedAt  time.Time `json:"updatedAt"`
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Tag         string    `json:"tag" gorm:"uniqueIndex;not null"`
+	ParentIDTag string    `json:"parentIdTag" gorm:"index"`
+	Status      string    `json:"status" gorm:"default:'Accepted'"`
+	ExpiryDate  time.Time `json:"expiryDate"`
+	UserID      uint      `json:"userId" gorm:"not null"`
+	Source      string    `json:"source" gorm:"default:'local'"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
 
 	User         User          `json:"user" gorm:"foreignKey:UserID"`
 	Transactions []Transaction `json:"transactions" gorm:"foreignKey:IDTagID"`
@@ -78,9 +140,25 @@ type Transaction struct {
 	StopMeterValue    float64    `json:"stopMeterValue"`
 	CurrentMeterValue float64    `json:"currentMeterValue"`
 	EnergyConsumed    float64    `json:"energyConsumed"`
-	TotalCost         float64    `json:"totalCost"`
+	TariffID          *uint      `json:"tariffId"`
+	Currency          string     `json:"currency"`
+	EnergyCost        int64      `json:"energyCost"`
+	TimeCost          int64      `json:"timeCost"`
+	FlatCost          int64      `json:"flatCost"`
+	IdleCost          int64      `json:"idleCost"`
+	NetAmount         int64      `json:"netAmount"`
+	TaxAmount         int64      `json:"taxAmount"`
+	GrossAmount       int64      `json:"grossAmount"`
+	TaxRate           float64    `json:"taxRate"`
+	TaxInclusive      bool       `json:"taxInclusive"`
 	StartTime         time.Time  `json:"startTime"`
 	StopTime          *time.Time `json:"stopTime"`
+	LastMeterTime     *time.Time `json:"lastMeterTime"`
+	ChargingEndTime   *time.Time `json:"chargingEndTime"`
+	MaxEndTime        *time.Time `json:"maxEndTime"`
+	StopRequestedAt   *time.Time `json:"stopRequestedAt"`
+	PaymentID         *uint      `json:"paymentId"`
+	OCPIPartyID       *uint      `json:"ocpiPartyId" gorm:"index"`
 	Status            string     `json:"status" gorm:"default:'Active'"`
 	Reason            string     `json:"reason"`
 	CreatedAt         time.Time  `json:"createdAt"`
@@ -88,6 +166,19 @@ type Transaction struct {
 
 	IDTag       IDTag       `json:"idTag" gorm:"foreignKey:IDTagID"`
 	ChargePoint ChargePoint `json:"chargePoint" gorm:"foreignKey:ChargePointID"`
+	Payment     *Payment    `json:"payment,omitempty" gorm:"foreignKey:PaymentID"`
+}
+
+type TransactionAudit struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	TransactionID  uint      `json:"transactionId" gorm:"not null;index"`
+	Action         string    `json:"action" gorm:"not null"`
+	FromStatus     string    `json:"fromStatus"`
+	ToStatus       string    `json:"toStatus"`
+	StopMeterValue *float64  `json:"stopMeterValue"`
+	Reason         string    `json:"reason"`
+	UserID         *uint     `json:"userId"`
+	CreatedAt      time.Time `json:"createdAt"`
 }
 
 type OCPPMessage struct {
@@ -132,10 +223,11 @@ type IDTagInfo struct {
 }
 
 type StartTransactionRequest struct {
-	ConnectorId   int    `json:"connectorId"`
-	IDTag         string `json:"idTag"`
-	MeterStart    int    `json:"meterStart"`
-	ReservationId *int   `json:"reservationId,omitempty"`
+	ConnectorId   int       `json:"connectorId"`
+	IDTag         string    `json:"idTag"`
+	MeterStart    int       `json:"meterStart"`
+	ReservationId *int      `json:"reservationId,omitempty"`
+	Timestamp     time.Time `json:"timestamp"`
 }
 
 type StartTransactionResponse struct {
@@ -144,23 +236,26 @@ type StartTransactionResponse struct {
 }
 
 type StopTransactionRequest struct {
-	TransactionId int    `json:"transactionId"`
-	IDTag         string `json:"idTag"`
-	MeterStop     int    `json:"meterStop"`
-	Reason        string `json:"reason"`
+	TransactionId int       `json:"transactionId"`
+	IDTag         string    `json:"idTag"`
+	MeterStop     int       `json:"meterStop"`
+	Reason        string    `json:"reason"`
+	Timestamp     time.Time `json:"timestamp"`
 }
 
 type StopTransactionResponse struct {
-	Status string `json:"status"`
+	Status    string     `json:"status"`
+	IDTagInfo *IDTagInfo `json:"idTagInfo,omitempty"`
 }
 
 type StatusNotificationRequest struct {
-	ConnectorId     int    `json:"connectorId"`
-	Status          string `json:"status"`
-	ErrorCode       string `json:"errorCode"`
-	Info            string `json:"info"`
-	VendorId        string `json:"vendorId"`
-	VendorErrorCode string `json:"vendorErrorCode"`
+	ConnectorId     int       `json:"connectorId"`
+	Status          string    `json:"status"`
+	ErrorCode       string    `json:"errorCode"`
+	Info            string    `json:"info"`
+	VendorId        string    `json:"vendorId"`
+	VendorErrorCode string    `json:"vendorErrorCode"`
+	Timestamp       time.Time `json:"timestamp"`
 }
 
 type MeterValuesRequest struct {
@@ -14,18 +14,214 @@ const (
 	UserStatusBlocked  = "blocked"
 )
 
+// OCPP 1.6 ChargePointStatus values, reported by StatusNotification for
+// each connector and, as connector 0, for the charge point itself.
 const (
-	ChargePointStatusAvailable   = "Available"
-	ChargePointStatusOccupied    = "Occupied"
-	ChargePointStatusFaulted     = "Faulted"
-	ChargePointStatusUnavailable = "Unavailable"
-	ChargePointStatusReserved    = "Reserved"
+	ChargePointStatusAvailable     = "Available"
+	ChargePointStatusPreparing     = "Preparing"
+	ChargePointStatusCharging      = "Charging"
+	ChargePointStatusSuspendedEV   = "SuspendedEV"
+	ChargePointStatusSuspendedEVSE = "SuspendedEVSE"
+	ChargePointStatusFinishing     = "Finishing"
+	ChargePointStatusReserved      = "Reserved"
+	ChargePointStatusUnavailable   = "Unavailable"
+	ChargePointStatusFaulted       = "Faulted"
 )
 
+// ChargePointStatusOccupied is not an OCPP 1.6 status but the aggregate
+// status of a charge point with no free connector that is not entirely out
+// of order.
+const ChargePointStatusOccupied = "Occupied"
+
+// AvailabilityStatusOffline is not an OCPP 1.6 status but the time a
+// connector spent on a disconnected charge point in availability reports.
+const AvailabilityStatusOffline = "Offline"
+
 const (
 	TransactionStatusActive    = "Active"
 	TransactionStatusCompleted = "Completed"
 	TransactionStatusCancelled = "Cancelled"
 	TransactionStatusFailed    = "Failed"
 	TransactionStatusPending   = "Pending"
+	TransactionStatusStuck     = "Stuck"
+)
+
+// Reasons recorded on transactions closed by the CSMS rather than by a
+// StopTransaction from the charge point.
+const (
+	TransactionReasonRebooted   = "StaleOnBootNotification"
+	TransactionReasonSuperseded = "SupersededByNewTransaction"
+)
+
+const (
+	TransactionAuditMarkedStuck = "MarkedStuck"
+	TransactionAuditForceClosed = "ForceClosed"
+	TransactionAuditRecovered   = "Recovered"
+)
+
+const (
+	TariffComponentEnergy = "Energy"
+	TariffComponentTime   = "Time"
+	TariffComponentFlat   = "Flat"
+	TariffComponentIdle   = "Idle"
+)
+
+// Rounding modes for converting computed prices to minor currency units.
+const (
+	RoundingHalfUp   = "half_up"
+	RoundingHalfEven = "half_even"
+	RoundingUp       = "up"
+	RoundingDown     = "down"
+)
+
+const (
+	InvoiceTypeReceipt      = "Receipt"
+	InvoiceTypeConsolidated = "Consolidated"
+)
+
+const (
+	WalletEntryTopUp  = "TopUp"
+	WalletEntryCharge = "Charge"
+)
+
+// Ways a transaction may already have been paid for.
+const (
+	SettledByWallet  = "wallet"
+	SettledByPayment = "payment"
+)
+
+const (
+	PaymentStatusAuthorized = "Authorized"
+	PaymentStatusCaptured   = "Captured"
+	PaymentStatusCancelled  = "Cancelled"
+	PaymentStatusRefunded   = "Refunded"
+	PaymentStatusFailed     = "Failed"
+)
+
+// Sources of id tags: issued by the operator, created for a guest session,
+// standing in for the token of a roaming driver, or for a tag accepted by an
+// external authorization service.
+const (
+	IDTagSourceLocal    = "local"
+	IDTagSourceGuest    = "guest"
+	IDTagSourceOCPI     = "ocpi"
+	IDTagSourceExternal = "external"
+)
+
+// Backends of the authorization chain.
+const (
+	AuthorizerLocal   = "local"
+	AuthorizerCache   = "cache"
+	AuthorizerWebhook = "webhook"
+	AuthorizerRoaming = "roaming"
 )
+
+// What the authorization chain does when a backend fails: ask the next one,
+// or reject the tag.
+const (
+	AuthorizerOnErrorNext   = "next"
+	AuthorizerOnErrorReject = "reject"
+)
+
+const (
+	GuestSessionStarting  = "Starting"
+	GuestSessionCharging  = "Charging"
+	GuestSessionCompleted = "Completed"
+	GuestSessionFailed    = "Failed"
+)
+
+const OCPIVersion221 = "2.2.1"
+
+const (
+	OCPIPartyStatusPending    = "Pending"
+	OCPIPartyStatusRegistered = "Registered"
+)
+
+// OCPI module identifiers and interface roles.
+const (
+	OCPIModuleCredentials = "credentials"
+	OCPIModuleLocations   = "locations"
+	OCPIModuleSessions    = "sessions"
+	OCPIModuleCDRs        = "cdrs"
+	OCPIModuleTariffs     = "tariffs"
+	OCPIModuleTokens      = "tokens"
+
+	OCPIRoleSender   = "SENDER"
+	OCPIRoleReceiver = "RECEIVER"
+)
+
+// OCPI token whitelist types, telling whether a token may be accepted without
+// asking its eMSP.
+const (
+	OCPIWhitelistAlways         = "ALWAYS"
+	OCPIWhitelistAllowed        = "ALLOWED"
+	OCPIWhitelistAllowedOffline = "ALLOWED_OFFLINE"
+	OCPIWhitelistNever          = "NEVER"
+)
+
+// OCPI real-time authorization outcomes.
+const (
+	OCPIAllowed    = "ALLOWED"
+	OCPIBlocked    = "BLOCKED"
+	OCPIExpired    = "EXPIRED"
+	OCPINoCredit   = "NO_CREDIT"
+	OCPINotAllowed = "NOT_ALLOWED"
+)
+
+// Types of domain events
+const (
+	EventTransactionStarted     = "transaction.started"
+	EventTransactionStopped     = "transaction.stopped"
+	EventTransactionStuck       = "transaction.stuck"
+	EventTransactionMeterValues = "transaction.meter_values"
+	EventConnectorStatusChanged = "connector.status_changed"
+	EventConnectorFaulted       = "connector.faulted"
+	EventChargePointOnline      = "chargepoint.online"
+	EventChargePointOffline     = "chargepoint.offline"
+	EventChargePointStatus      = "chargepoint.status_changed"
+	EventChargePointError       = "chargepoint.error"
+	EventTicketOpened           = "ticket.opened"
+	EventTicketUpdated          = "ticket.updated"
+	EventTicketResolved         = "ticket.resolved"
+)
+
+// Outbox states of an event. Failed events ran out of attempts.
+const (
+	EventStatusPending    = "Pending"
+	EventStatusDispatched = "Dispatched"
+	EventStatusFailed     = "Failed"
+)
+
+// States of a webhook delivery. Failed deliveries ran out of attempts; an
+// admin can still redeliver them.
+const (
+	WebhookDeliveryStatusPending   = "Pending"
+	WebhookDeliveryStatusDelivered = "Delivered"
+	WebhookDeliveryStatusFailed    = "Failed"
+)
+
+// Conditions of alert rules
+const (
+	AlertRuleStatusDuration = "status_duration"
+	AlertRuleRepeatedError  = "repeated_error"
+	AlertRuleOffline        = "offline"
+)
+
+// Maintenance ticket states. Resolved tickets may be reopened; closed ones
+// are final.
+const (
+	TicketStatusOpen       = "Open"
+	TicketStatusInProgress = "InProgress"
+	TicketStatusResolved   = "Resolved"
+	TicketStatusClosed     = "Closed"
+)
+
+// Severities of alert rules and tickets
+const (
+	SeverityCritical = "Critical"
+	SeverityMajor    = "Major"
+	SeverityMinor    = "Minor"
+)
+
+// ConnectorErrorCodeNoError is the error code of a connector without error.
+const ConnectorErrorCodeNoError = "NoError"
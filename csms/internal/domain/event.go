@@ -0,0 +1,109 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event is a domain event kept in the outbox until it has been dispatched to
+// every subscriber and sink. It is recorded in the same database transaction
+// as the change it describes, and IDs increase in the order events were
+// recorded.
+type Event struct {
+	ID            uint            `json:"id" gorm:"primaryKey"`
+	Type          string          `json:"type" gorm:"not null;index"`
+	ChargePointID *uint           `json:"chargePointId,omitempty" gorm:"index"`
+	Data          json.RawMessage `json:"data" gorm:"type:jsonb"`
+	Status        string          `json:"status" gorm:"default:'Pending';index:idx_event_due,priority:1"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt time.Time       `json:"nextAttemptAt" gorm:"index:idx_event_due,priority:2"`
+	LastError     string          `json:"lastError,omitempty"`
+	DispatchedAt  *time.Time      `json:"dispatchedAt,omitempty"`
+	CreatedAt     time.Time       `json:"createdAt"`
+}
+
+// EventTypes lists every type of domain event.
+var EventTypes = []string{
+	EventTransactionStarted,
+	EventTransactionStopped,
+	EventTransactionStuck,
+	EventTransactionMeterValues,
+	EventConnectorStatusChanged,
+	EventConnectorFaulted,
+	EventChargePointOnline,
+	EventChargePointOffline,
+	EventChargePointStatus,
+	EventChargePointError,
+	EventTicketOpened,
+	EventTicketUpdated,
+	EventTicketResolved,
+}
+
+// EventMessage is an event as external consumers receive it.
+type EventMessage struct {
+	ID            uint            `json:"id"`
+	Type          string          `json:"type"`
+	ChargePointID *uint           `json:"chargePointId,omitempty"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	Data          json.RawMessage `json:"data"`
+}
+
+func (e *Event) Message() EventMessage {
+	return EventMessage{
+		ID:            e.ID,
+		Type:          e.Type,
+		ChargePointID: e.ChargePointID,
+		CreatedAt:     e.CreatedAt,
+		Data:          e.Data,
+	}
+}
+
+// EventFilter selects events by charge point and type; empty lists select
+// everything.
+type EventFilter struct {
+	ChargePointIDs []uint
+	Types          []string
+}
+
+// TransactionEventData is the data of the transaction events.
+type TransactionEventData struct {
+	ID             uint       `json:"id"`
+	TransactionID  int        `json:"transactionId"`
+	ChargePointID  uint       `json:"chargePointId"`
+	ConnectorID    int        `json:"connectorId"`
+	IDTag          string     `json:"idTag"`
+	Status         string     `json:"status"`
+	StartTime      time.Time  `json:"startTime"`
+	StopTime       *time.Time `json:"stopTime,omitempty"`
+	MeterStart     float64    `json:"meterStart"`
+	MeterStop      float64    `json:"meterStop,omitempty"`
+	MeterValue     float64    `json:"meterValue"`
+	EnergyConsumed float64    `json:"energyConsumed"`
+	Currency       string     `json:"currency,omitempty"`
+	GrossAmount    int64      `json:"grossAmount"`
+	Reason         string     `json:"reason,omitempty"`
+}
+
+// ChargePointEventData is the data of the charge point events.
+type ChargePointEventData struct {
+	ChargePointID   uint   `json:"chargePointId"`
+	ChargePointCode string `json:"chargePointCode"`
+	Status          string `json:"status,omitempty"`
+	PreviousStatus  string `json:"previousStatus,omitempty"`
+	AggregateStatus string `json:"aggregateStatus,omitempty"`
+	// PreviousAggregateStatus is set on status changes.
+	PreviousAggregateStatus string `json:"previousAggregateStatus,omitempty"`
+	// Error is set on errors.
+	Error string `json:"error,omitempty"`
+}
+
+// ConnectorEventData is the data of the connector events.
+type ConnectorEventData struct {
+	ChargePointID   uint   `json:"chargePointId"`
+	ConnectorID     int    `json:"connectorId"`
+	Status          string `json:"status"`
+	PreviousStatus  string `json:"previousStatus,omitempty"`
+	ErrorCode       string `json:"errorCode,omitempty"`
+	Info            string `json:"info,omitempty"`
+	VendorErrorCode string `json:"vendorErrorCode,omitempty"`
+}
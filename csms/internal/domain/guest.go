@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"time"
+)
+
+// GuestSession is a card-paid session started by a guest from a connector's
+// public QR link. The guest holds Token to follow and stop the session; the
+// receipt is served once through ReceiptToken.
+type GuestSession struct {
+	ID              uint       `json:"id" gorm:"primaryKey"`
+	Token           string     `json:"-" gorm:"uniqueIndex;not null"`
+	ChargePointID   uint       `json:"chargePointId" gorm:"not null"`
+	ConnectorID     uint       `json:"connectorId" gorm:"not null"`
+	IDTag           string     `json:"idTag" gorm:"not null;index"`
+	PaymentID       uint       `json:"paymentId" gorm:"not null"`
+	ReceiptToken    *string    `json:"-" gorm:"uniqueIndex"`
+	ReceiptViewedAt *time.Time `json:"receiptViewedAt"`
+	ExpiresAt       time.Time  `json:"expiresAt"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
+
+	Connector Connector `json:"connector" gorm:"foreignKey:ConnectorID"`
+	Payment   Payment   `json:"payment" gorm:"foreignKey:PaymentID"`
+}
+
+// GuestConnector is what a guest sees after scanning a connector's QR code.
+type GuestConnector struct {
+	PublicCode      string `json:"publicCode"`
+	ChargePointCode string `json:"chargePointCode"`
+	ConnectorID     int    `json:"connectorId"`
+	Status          string `json:"status"`
+	Site            string `json:"site"`
+	Currency        string `json:"currency"`
+	PreAuthAmount   int64  `json:"preAuthAmount"`
+}
+
+type GuestSessionRequest struct {
+	PaymentMethod string `json:"paymentMethod" binding:"required"`
+	Amount        int64  `json:"amount"`
+}
+
+// GuestSessionStatus is the progress of a guest session. Token is only
+// returned when the session is created.
+type GuestSessionStatus struct {
+	Token            string     `json:"token,omitempty"`
+	Status           string     `json:"status"`
+	ChargePointCode  string     `json:"chargePointCode"`
+	ConnectorID      int        `json:"connectorId"`
+	PaymentStatus    string     `json:"paymentStatus"`
+	Currency         string     `json:"currency"`
+	AuthorizedAmount int64      `json:"authorizedAmount"`
+	StartTime        *time.Time `json:"startTime,omitempty"`
+	StopTime         *time.Time `json:"stopTime,omitempty"`
+	EnergyConsumed   float64    `json:"energyConsumed"`
+	GrossAmount      int64      `json:"grossAmount"`
+	ReceiptURL       string     `json:"receiptUrl,omitempty"`
+	ExpiresAt        time.Time  `json:"expiresAt"`
+}
+
+// ConnectorLink is the public QR URL of a connector.
+type ConnectorLink struct {
+	ConnectorID uint   `json:"connectorId"`
+	PublicCode  string `json:"publicCode"`
+	URL         string `json:"url"`
+}
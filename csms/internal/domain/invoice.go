@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"time"
+)
+
+// Invoice is a receipt for one completed transaction or a consolidated
+// invoice of a fleet account for a month. Numbers are sequential per series
+// and never reused. Amounts are in minor units of Currency.
+type Invoice struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	Number        string     `json:"number" gorm:"uniqueIndex;not null"`
+	Type          string     `json:"type" gorm:"not null;index"`
+	TransactionID *uint      `json:"transactionId" gorm:"uniqueIndex"`
+	UserID        *uint      `json:"userId" gorm:"index"`
+	FleetGroup    string     `json:"fleetGroup" gorm:"uniqueIndex:idx_invoice_fleet_period"`
+	PeriodStart   *time.Time `json:"periodStart" gorm:"uniqueIndex:idx_invoice_fleet_period"`
+	PeriodEnd     *time.Time `json:"periodEnd"`
+	CustomerName  string     `json:"customerName"`
+	CustomerEmail string     `json:"customerEmail"`
+	CustomerPhone string     `json:"customerPhone"`
+	Currency      string     `json:"currency"`
+	TaxRate       float64    `json:"taxRate"`
+	TaxInclusive  bool       `json:"taxInclusive"`
+	NetAmount     int64      `json:"netAmount"`
+	TaxAmount     int64      `json:"taxAmount"`
+	GrossAmount   int64      `json:"grossAmount"`
+	IssuedAt      time.Time  `json:"issuedAt"`
+	HTML          string     `json:"-" gorm:"type:text"`
+	PDF           []byte     `json:"-"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+
+	Lines []InvoiceLine `json:"lines" gorm:"foreignKey:InvoiceID;constraint:OnDelete:CASCADE"`
+}
+
+// InvoiceLine is one priced item. UnitPrice is in major units, Amount in
+// minor units.
+type InvoiceLine struct {
+	ID            uint    `json:"id" gorm:"primaryKey"`
+	InvoiceID     uint    `json:"invoiceId" gorm:"not null;index"`
+	TransactionID *uint   `json:"transactionId"`
+	Description   string  `json:"description"`
+	Quantity      float64 `json:"quantity"`
+	Unit          string  `json:"unit"`
+	UnitPrice     float64 `json:"unitPrice"`
+	Amount        int64   `json:"amount"`
+}
+
+// InvoiceSequence holds the last number issued in a series, e.g. INV-2026.
+type InvoiceSequence struct {
+	Series string `gorm:"primaryKey"`
+	Last   int64  `gorm:"not null"`
+}
+
+// ConsolidatedInvoiceRequest asks for the invoice of a fleet account covering
+// the transactions started in a month ("2006-01").
+type ConsolidatedInvoiceRequest struct {
+	FleetGroup string `json:"fleetGroup" binding:"required"`
+	Month      string `json:"month" binding:"required"`
+	UserID     *uint  `json:"userId"`
+}
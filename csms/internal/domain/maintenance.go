@@ -0,0 +1,105 @@
+package domain
+
+import "time"
+
+// AlertRule opens a maintenance ticket when its condition holds for a charge
+// point or connector:
+//   - status_duration: a connector has been in Status for ThresholdMinutes
+//   - repeated_error: a connector reported ErrorCode Count times within
+//     WindowMinutes
+//   - offline: a charge point has been disconnected for ThresholdMinutes
+//
+// The ticket is resolved automatically once the condition no longer holds.
+type AlertRule struct {
+	ID               uint      `json:"id" gorm:"primaryKey"`
+	Name             string    `json:"name" gorm:"not null"`
+	Type             string    `json:"type" gorm:"not null"`
+	Status           string    `json:"status"`
+	ErrorCode        string    `json:"errorCode"`
+	ThresholdMinutes int       `json:"thresholdMinutes"`
+	Count            int       `json:"count"`
+	WindowMinutes    int       `json:"windowMinutes"`
+	Severity         string    `json:"severity" gorm:"default:'Major'"`
+	Disabled         bool      `json:"disabled"`
+	CreatedAt        time.Time `json:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt"`
+}
+
+// MaintenanceTicket tracks a problem with a charge point, or one of its
+// connectors, until it is fixed. Tickets opened by an alert rule reference
+// it; ConnectorID is 0 for the charge point as a whole.
+type MaintenanceTicket struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	ChargePointID uint       `json:"chargePointId" gorm:"not null;index"`
+	ConnectorID   int        `json:"connectorId"`
+	AlertRuleID   *uint      `json:"alertRuleId" gorm:"index"`
+	Title         string     `json:"title" gorm:"not null"`
+	Description   string     `json:"description"`
+	Severity      string     `json:"severity"`
+	Status        string     `json:"status" gorm:"default:'Open';index"`
+	AssigneeID    *uint      `json:"assigneeId" gorm:"index"`
+	AutoResolved  bool       `json:"autoResolved"`
+	ResolvedAt    *time.Time `json:"resolvedAt"`
+	ClosedAt      *time.Time `json:"closedAt"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+
+	ChargePoint *ChargePoint    `json:"chargePoint,omitempty" gorm:"foreignKey:ChargePointID"`
+	Assignee    *User           `json:"assignee,omitempty" gorm:"foreignKey:AssigneeID"`
+	Comments    []TicketComment `json:"comments,omitempty" gorm:"foreignKey:TicketID;constraint:OnDelete:CASCADE"`
+}
+
+// TicketComment is a note on a ticket. Status changes and assignments are
+// recorded as comments too; UserID is nil for those made by the system.
+type TicketComment struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	TicketID  uint      `json:"ticketId" gorm:"not null;index"`
+	UserID    *uint     `json:"userId"`
+	Body      string    `json:"body" gorm:"not null"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ConnectorError is an error code a connector started reporting. Unlike the
+// code on the connector, which the next StatusNotification overwrites, these
+// are kept for alerting and diagnostics.
+type ConnectorError struct {
+	ID              uint      `json:"id" gorm:"primaryKey"`
+	ChargePointID   uint      `json:"chargePointId" gorm:"not null;index:idx_connector_error,priority:1"`
+	ConnectorID     int       `json:"connectorId" gorm:"index:idx_connector_error,priority:2"`
+	ErrorCode       string    `json:"errorCode" gorm:"not null;index"`
+	VendorErrorCode string    `json:"vendorErrorCode"`
+	Info            string    `json:"info"`
+	Status          string    `json:"status"`
+	CreatedAt       time.Time `json:"createdAt" gorm:"index"`
+}
+
+// ConnectorErrorCount is how often a connector reported an error code.
+type ConnectorErrorCount struct {
+	ChargePointID uint
+	ConnectorID   int
+	Count         int
+}
+
+// TicketFilter selects tickets; zero values select everything.
+type TicketFilter struct {
+	Status        string
+	ChargePointID uint
+	AssigneeID    uint
+	Limit         int
+	Offset        int
+}
+
+// TicketRequest opens a ticket by hand.
+type TicketRequest struct {
+	ChargePointID uint   `json:"chargePointId" binding:"required"`
+	ConnectorID   int    `json:"connectorId"`
+	Title         string `json:"title" binding:"required"`
+	Description   string `json:"description"`
+	Severity      string `json:"severity"`
+}
+
+// TicketAlert is a ticket change reported to the ticket notifiers.
+type TicketAlert struct {
+	Event  string             `json:"event"`
+	Ticket *MaintenanceTicket `json:"ticket"`
+}
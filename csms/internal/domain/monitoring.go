@@ -0,0 +1,13 @@
+package domain
+
+// GroupCount is the number of records sharing a value, e.g. a status.
+type GroupCount struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+}
+
+// TransactionTotals sums up a set of transactions. EnergyConsumed is in kWh.
+type TransactionTotals struct {
+	Count          int64   `json:"count"`
+	EnergyConsumed float64 `json:"energyConsumed"`
+}
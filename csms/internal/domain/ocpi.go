@@ -0,0 +1,295 @@
+package domain
+
+import (
+	"time"
+)
+
+// OCPIParty is a roaming partner, typically an eMSP or hub, registered through
+// the OCPI credentials handshake. Token is what the party sends to call us:
+// the registration token (A) until the handshake completes, then token C.
+// RemoteToken is token B, which we send when calling the party.
+type OCPIParty struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Name        string    `json:"name"`
+	CountryCode string    `json:"countryCode"`
+	PartyID     string    `json:"partyId"`
+	Role        string    `json:"role"`
+	Token       string    `json:"-" gorm:"uniqueIndex;not null"`
+	RemoteToken string    `json:"-"`
+	VersionsURL string    `json:"versionsUrl"`
+	Version     string    `json:"version"`
+	Status      string    `json:"status" gorm:"not null"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+
+	Endpoints []OCPIEndpoint `json:"endpoints" gorm:"foreignKey:PartyID;constraint:OnDelete:CASCADE"`
+}
+
+// OCPIEndpoint is a module endpoint published by a party.
+type OCPIEndpoint struct {
+	ID         uint   `json:"-" gorm:"primaryKey"`
+	PartyID    uint   `json:"-" gorm:"not null;index"`
+	Identifier string `json:"identifier"`
+	Role       string `json:"role"`
+	URL        string `json:"url"`
+}
+
+// RoamingToken is a token of a roaming driver, pushed to us by its eMSP or
+// learnt from a real-time authorization. Tokens are kept apart from IDTags;
+// an IDTag with source "ocpi" stands in for the token once it is used, so
+// transactions can refer to it.
+type RoamingToken struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	OCPIPartyID  uint      `json:"ocpiPartyId" gorm:"not null;index"`
+	CountryCode  string    `json:"countryCode" gorm:"not null;uniqueIndex:idx_roaming_token"`
+	PartyID      string    `json:"partyId" gorm:"not null;uniqueIndex:idx_roaming_token"`
+	UID          string    `json:"uid" gorm:"not null;uniqueIndex:idx_roaming_token;index"`
+	Type         string    `json:"type" gorm:"not null;uniqueIndex:idx_roaming_token"`
+	ContractID   string    `json:"contractId"`
+	VisualNumber string    `json:"visualNumber"`
+	Issuer       string    `json:"issuer"`
+	GroupID      string    `json:"groupId"`
+	Valid        bool      `json:"valid"`
+	Whitelist    string    `json:"whitelist"`
+	Language     string    `json:"language"`
+	LastUpdated  time.Time `json:"lastUpdated"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// RoamingAuthorization is the outcome of authorizing a roaming token: the
+// IDTag standing in for it, with its status set to the decision, and the
+// party the session is billed to.
+type RoamingAuthorization struct {
+	IDTag       *IDTag
+	OCPIPartyID uint
+}
+
+// OCPIRegistration is returned once when a party is created; TokenA must be
+// handed to the party to start the credentials handshake.
+type OCPIRegistration struct {
+	Party       *OCPIParty `json:"party"`
+	TokenA      string     `json:"tokenA"`
+	VersionsURL string     `json:"versionsUrl"`
+}
+
+type OCPIPartyRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// OCPIQuery holds the paging parameters of OCPI list requests.
+type OCPIQuery struct {
+	DateFrom *time.Time
+	DateTo   *time.Time
+	Offset   int
+	Limit    int
+}
+
+// OCPI 2.2.1 objects. Field names follow the specification.
+
+type OCPIVersion struct {
+	Version string `json:"version"`
+	URL     string `json:"url"`
+}
+
+type OCPIVersionDetails struct {
+	Version   string         `json:"version"`
+	Endpoints []OCPIEndpoint `json:"endpoints"`
+}
+
+type OCPICredentials struct {
+	Token string                `json:"token"`
+	URL   string                `json:"url"`
+	Roles []OCPICredentialsRole `json:"roles"`
+}
+
+type OCPICredentialsRole struct {
+	Role            string              `json:"role"`
+	BusinessDetails OCPIBusinessDetails `json:"business_details"`
+	PartyID         string              `json:"party_id"`
+	CountryCode     string              `json:"country_code"`
+}
+
+type OCPIBusinessDetails struct {
+	Name    string `json:"name"`
+	Website string `json:"website,omitempty"`
+}
+
+type OCPIGeoLocation struct {
+	Latitude  string `json:"latitude"`
+	Longitude string `json:"longitude"`
+}
+
+type OCPILocation struct {
+	CountryCode string          `json:"country_code"`
+	PartyID     string          `json:"party_id"`
+	ID          string          `json:"id"`
+	Publish     bool            `json:"publish"`
+	Name        string          `json:"name,omitempty"`
+	Address     string          `json:"address"`
+	City        string          `json:"city"`
+	PostalCode  string          `json:"postal_code,omitempty"`
+	Country     string          `json:"country"`
+	Coordinates OCPIGeoLocation `json:"coordinates"`
+	EVSEs       []OCPIEVSE      `json:"evses"`
+	TimeZone    string          `json:"time_zone,omitempty"`
+	LastUpdated time.Time       `json:"last_updated"`
+}
+
+type OCPIEVSE struct {
+	UID         string          `json:"uid"`
+	EVSEID      string          `json:"evse_id,omitempty"`
+	Status      string          `json:"status"`
+	Connectors  []OCPIConnector `json:"connectors"`
+	LastUpdated time.Time       `json:"last_updated"`
+}
+
+type OCPIConnector struct {
+	ID          string    `json:"id"`
+	Standard    string    `json:"standard"`
+	Format      string    `json:"format"`
+	PowerType   string    `json:"power_type"`
+	MaxVoltage  int       `json:"max_voltage"`
+	MaxAmperage int       `json:"max_amperage"`
+	TariffIDs   []string  `json:"tariff_ids,omitempty"`
+	LastUpdated time.Time `json:"last_updated"`
+}
+
+type OCPIPrice struct {
+	ExclVat float64  `json:"excl_vat"`
+	InclVat *float64 `json:"incl_vat,omitempty"`
+}
+
+type OCPICdrToken struct {
+	CountryCode string `json:"country_code"`
+	PartyID     string `json:"party_id"`
+	UID         string `json:"uid"`
+	Type        string `json:"type"`
+	ContractID  string `json:"contract_id"`
+}
+
+type OCPISession struct {
+	CountryCode   string       `json:"country_code"`
+	PartyID       string       `json:"party_id"`
+	ID            string       `json:"id"`
+	StartDateTime time.Time    `json:"start_date_time"`
+	EndDateTime   *time.Time   `json:"end_date_time,omitempty"`
+	Kwh           float64      `json:"kwh"`
+	CdrToken      OCPICdrToken `json:"cdr_token"`
+	AuthMethod    string       `json:"auth_method"`
+	LocationID    string       `json:"location_id"`
+	EVSEUID       string       `json:"evse_uid"`
+	ConnectorID   string       `json:"connector_id"`
+	Currency      string       `json:"currency"`
+	TotalCost     *OCPIPrice   `json:"total_cost,omitempty"`
+	Status        string       `json:"status"`
+	LastUpdated   time.Time    `json:"last_updated"`
+}
+
+type OCPICdrLocation struct {
+	ID                 string          `json:"id"`
+	Name               string          `json:"name,omitempty"`
+	Address            string          `json:"address"`
+	City               string          `json:"city"`
+	PostalCode         string          `json:"postal_code,omitempty"`
+	Country            string          `json:"country"`
+	Coordinates        OCPIGeoLocation `json:"coordinates"`
+	EVSEUID            string          `json:"evse_uid"`
+	EVSEID             string          `json:"evse_id"`
+	ConnectorID        string          `json:"connector_id"`
+	ConnectorStandard  string          `json:"connector_standard"`
+	ConnectorFormat    string          `json:"connector_format"`
+	ConnectorPowerType string          `json:"connector_power_type"`
+}
+
+type OCPICdrDimension struct {
+	Type   string  `json:"type"`
+	Volume float64 `json:"volume"`
+}
+
+type OCPIChargingPeriod struct {
+	StartDateTime time.Time          `json:"start_date_time"`
+	Dimensions    []OCPICdrDimension `json:"dimensions"`
+	TariffID      string             `json:"tariff_id,omitempty"`
+}
+
+type OCPICDR struct {
+	CountryCode      string               `json:"country_code"`
+	PartyID          string               `json:"party_id"`
+	ID               string               `json:"id"`
+	StartDateTime    time.Time            `json:"start_date_time"`
+	EndDateTime      time.Time            `json:"end_date_time"`
+	SessionID        string               `json:"session_id"`
+	CdrToken         OCPICdrToken         `json:"cdr_token"`
+	AuthMethod       string               `json:"auth_method"`
+	CdrLocation      OCPICdrLocation      `json:"cdr_location"`
+	Currency         string               `json:"currency"`
+	ChargingPeriods  []OCPIChargingPeriod `json:"charging_periods"`
+	TotalCost        OCPIPrice            `json:"total_cost"`
+	TotalEnergy      float64              `json:"total_energy"`
+	TotalTime        float64              `json:"total_time"`
+	TotalParkingTime float64              `json:"total_parking_time,omitempty"`
+	LastUpdated      time.Time            `json:"last_updated"`
+}
+
+type OCPIToken struct {
+	CountryCode  string    `json:"country_code"`
+	PartyID      string    `json:"party_id"`
+	UID          string    `json:"uid"`
+	Type         string    `json:"type"`
+	ContractID   string    `json:"contract_id"`
+	VisualNumber string    `json:"visual_number,omitempty"`
+	Issuer       string    `json:"issuer"`
+	GroupID      string    `json:"group_id,omitempty"`
+	Valid        bool      `json:"valid"`
+	Whitelist    string    `json:"whitelist"`
+	Language     string    `json:"language,omitempty"`
+	LastUpdated  time.Time `json:"last_updated"`
+}
+
+type OCPILocationReferences struct {
+	LocationID string   `json:"location_id"`
+	EVSEUIDs   []string `json:"evse_uids,omitempty"`
+}
+
+type OCPIAuthorizationInfo struct {
+	Allowed                string                  `json:"allowed"`
+	Token                  OCPIToken               `json:"token"`
+	Location               *OCPILocationReferences `json:"location,omitempty"`
+	AuthorizationReference string                  `json:"authorization_reference,omitempty"`
+}
+
+type OCPIDisplayText struct {
+	Language string `json:"language"`
+	Text     string `json:"text"`
+}
+
+type OCPIPriceComponent struct {
+	Type     string   `json:"type"`
+	Price    float64  `json:"price"`
+	Vat      *float64 `json:"vat,omitempty"`
+	StepSize int      `json:"step_size"`
+}
+
+type OCPITariffRestrictions struct {
+	StartTime string   `json:"start_time,omitempty"`
+	EndTime   string   `json:"end_time,omitempty"`
+	DayOfWeek []string `json:"day_of_week,omitempty"`
+}
+
+type OCPITariffElement struct {
+	PriceComponents []OCPIPriceComponent    `json:"price_components"`
+	Restrictions    *OCPITariffRestrictions `json:"restrictions,omitempty"`
+}
+
+type OCPITariff struct {
+	CountryCode   string              `json:"country_code"`
+	PartyID       string              `json:"party_id"`
+	ID            string              `json:"id"`
+	Currency      string              `json:"currency"`
+	TariffAltText []OCPIDisplayText   `json:"tariff_alt_text,omitempty"`
+	Elements      []OCPITariffElement `json:"elements"`
+	StartDateTime *time.Time          `json:"start_date_time,omitempty"`
+	EndDateTime   *time.Time          `json:"end_date_time,omitempty"`
+	LastUpdated   time.Time           `json:"last_updated"`
+}
@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"time"
+)
+
+// Payment is a card payment for an ad-hoc session. The card is pre-authorized
+// before the charge point is asked to start, and the final cost of the
+// transaction is captured once it stops. Amounts are in minor units.
+type Payment struct {
+	ID               uint      `json:"id" gorm:"primaryKey"`
+	Provider         string    `json:"provider" gorm:"not null"`
+	Reference        string    `json:"reference" gorm:"uniqueIndex;not null"`
+	Status           string    `json:"status" gorm:"not null;index"`
+	ChargePointID    uint      `json:"chargePointId" gorm:"not null;index"`
+	ConnectorID      int       `json:"connectorId" gorm:"not null"`
+	IDTag            string    `json:"idTag" gorm:"not null"`
+	UserID           *uint     `json:"userId" gorm:"index"`
+	TransactionID    *uint     `json:"transactionId" gorm:"uniqueIndex"`
+	Currency         string    `json:"currency" gorm:"not null"`
+	AuthorizedAmount int64     `json:"authorizedAmount"`
+	CapturedAmount   int64     `json:"capturedAmount"`
+	RefundedAmount   int64     `json:"refundedAmount"`
+	FailureReason    string    `json:"failureReason"`
+	CreatedAt        time.Time `json:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt"`
+}
+
+// PaidSessionRequest asks for a session on a connector paid by card.
+// PaymentMethod is the provider's token for the card.
+type PaidSessionRequest struct {
+	ChargePointID uint   `json:"chargePointId" binding:"required"`
+	ConnectorID   int    `json:"connectorId" binding:"required"`
+	IDTag         string `json:"idTag" binding:"required"`
+	PaymentMethod string `json:"paymentMethod" binding:"required"`
+	Amount        int64  `json:"amount"`
+}
+
+type RefundRequest struct {
+	Amount int64  `json:"amount" binding:"required,gt=0"`
+	Reason string `json:"reason"`
+}
+
+// PaymentIntentRequest asks a payment provider to hold an amount on a card.
+type PaymentIntentRequest struct {
+	Amount        int64
+	Currency      string
+	PaymentMethod string
+	Description   string
+}
+
+type PaymentIntent struct {
+	Reference string
+	Status    string
+	Amount    int64
+}
+
+// PaymentEvent is a verified status change reported by a provider webhook.
+type PaymentEvent struct {
+	Reference string `json:"reference"`
+	Status    string `json:"status"`
+	Amount    int64  `json:"amount"`
+}
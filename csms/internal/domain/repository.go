@@ -2,8 +2,16 @@ package domain
 
 import (
 	"context"
+	"time"
 )
 
+// UnitOfWork runs a function in one database transaction. Repositories
+// called with the context it passes take part in the transaction, so their
+// writes and the events recorded with them commit or roll back together.
+type UnitOfWork interface {
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
 type ChargePointRepository interface {
 	Create(ctx context.Context, cp *ChargePoint) error
 	GetByID(ctx context.Context, id uint) (*ChargePoint, error)
@@ -11,22 +19,79 @@ type ChargePointRepository interface {
 	Update(ctx context.Context, cp *ChargePoint) error
 	Delete(ctx context.Context, id uint) error
 	List(ctx context.Context, limit, offset int) ([]ChargePoint, error)
-	UpdateStatus(ctx context.Context, id uint, status string) error
+	// UpdateStatus sets the charge point's own status, changed at the time.
+	UpdateStatus(ctx context.Context, id uint, status string, at time.Time) error
+	UpdateErrorCode(ctx context.Context, id uint, errorCode string) error
+	// ListInStatusSince returns the charge points in the status, as their
+	// own status, since before.
+	ListInStatusSince(ctx context.Context, status string, before time.Time) ([]ChargePoint, error)
+	UpdateAggregateStatus(ctx context.Context, id uint, status string) error
 	UpdateHeartbeat(ctx context.Context, id uint) error
+	UpdateConnection(ctx context.Context, id uint, online bool, at time.Time) error
+	CountOnline(ctx context.Context) (online, total int64, err error)
+	CountByAggregateStatus(ctx context.Context) ([]GroupCount, error)
+	// ListOfflineSince returns the charge points disconnected since before.
+	ListOfflineSince(ctx context.Context, before time.Time) ([]ChargePoint, error)
+	// ListOnlineSince returns the charge points connected since before.
+	ListOnlineSince(ctx context.Context, before time.Time) ([]ChargePoint, error)
+	UpdateSite(ctx context.Context, id uint, site, group string) error
+	UpdateLocation(ctx context.Context, id uint, location *ChargePointLocation) error
+	// ListUpdated pages through charge points with their connectors, limited
+	// to those updated within the query's date range, and returns the total.
+	ListUpdated(ctx context.Context, query OCPIQuery) ([]ChargePoint, int64, error)
 }
 
 type ConnectorRepository interface {
 	Create(ctx context.Context, connector *Connector) error
 	GetByID(ctx context.Context, id uint) (*Connector, error)
 	GetByChargePointAndConnectorID(ctx context.Context, chargePointID uint, connectorID int) (*Connector, error)
+	GetByPublicCode(ctx context.Context, publicCode string) (*Connector, error)
 	Update(ctx context.Context, connector *Connector) error
 	Delete(ctx context.Context, id uint) error
+	// ListByChargePoint returns the charge point's connectors. Connector 0
+	// stands for the charge point itself and is never listed.
 	ListByChargePoint(ctx context.Context, chargePointID uint) ([]Connector, error)
 	UpdateStatus(ctx context.Context, id uint, status string) error
+	CountByStatus(ctx context.Context) ([]GroupCount, error)
+	// ListInStatusSince returns the connectors in the status since before.
+	ListInStatusSince(ctx context.Context, status string, before time.Time) ([]Connector, error)
+	// ListWithChargePoint returns the connectors of the charge point or site
+	// with their charge point; zero values select everything.
+	ListWithChargePoint(ctx context.Context, chargePointID uint, site string) ([]Connector, error)
+}
+
+type ConnectorStatusChangeRepository interface {
+	Create(ctx context.Context, change *ConnectorStatusChange) error
+	List(ctx context.Context, filter StatusHistoryFilter) ([]ConnectorStatusChange, error)
+	// ListBetween returns the changes of the charge points' connectors in
+	// [from, to), oldest first.
+	ListBetween(ctx context.Context, chargePointIDs []uint, from, to time.Time) ([]ConnectorStatusChange, error)
+	// ListLatestBefore returns the last change of each of the charge points'
+	// connectors before the time.
+	ListLatestBefore(ctx context.Context, chargePointIDs []uint, before time.Time) ([]ConnectorStatusChange, error)
+}
+
+type ChargePointConnectionChangeRepository interface {
+	Create(ctx context.Context, change *ChargePointConnectionChange) error
+	// ListBetween returns the changes of the charge points in [from, to),
+	// oldest first.
+	ListBetween(ctx context.Context, chargePointIDs []uint, from, to time.Time) ([]ChargePointConnectionChange, error)
+	// ListLatestBefore returns the last change of each of the charge points
+	// before the time.
+	ListLatestBefore(ctx context.Context, chargePointIDs []uint, before time.Time) ([]ChargePointConnectionChange, error)
+}
+
+type ConnectorErrorRepository interface {
+	Create(ctx context.Context, connectorError *ConnectorError) error
+	// CountSince counts the errors with the code per connector since the
+	// given time.
+	CountSince(ctx context.Context, errorCode string, since time.Time) ([]ConnectorErrorCount, error)
+	CountByConnectorSince(ctx context.Context, chargePointID uint, connectorID int, errorCode string, since time.Time) (int64, error)
 }
 
 type TransactionRepository interface {
 	Create(ctx context.Context, transaction *Transaction) error
+	NextTransactionID(ctx context.Context) (int, error)
 	GetByID(ctx context.Context, id uint) (*Transaction, error)
 	GetByTransactionID(ctx context.Context, transactionID int) (*Transaction, error)
 	Update(ctx context.Context, transaction *Transaction) error
@@ -35,6 +100,28 @@ type TransactionRepository interface {
 	ListByChargePoint(ctx context.Context, chargePointID uint) ([]Transaction, error)
 	ListByUser(ctx context.Context, idTag string) ([]Transaction, error)
 	GetActiveByConnector(ctx context.Context, chargePointID uint, connectorID int) (*Transaction, error)
+	ListActiveByChargePoint(ctx context.Context, chargePointID uint) ([]Transaction, error)
+	ListByStatus(ctx context.Context, status string) ([]Transaction, error)
+	CountActiveByIDTags(ctx context.Context, idTagIDs []uint) (int64, error)
+	ListCompleted(ctx context.Context, filter TransactionFilter) ([]Transaction, error)
+	// SumCompleted totals the completed transactions ListCompleted would
+	// return.
+	SumCompleted(ctx context.Context, filter TransactionFilter) (*TransactionTotals, error)
+	CountByStatus(ctx context.Context) ([]GroupCount, error)
+	// ListByParty pages through the transactions of a roaming party's drivers
+	// and returns the total.
+	ListByParty(ctx context.Context, partyID uint, completedOnly bool, query OCPIQuery) ([]Transaction, int64, error)
+}
+
+type TransactionAuditRepository interface {
+	Create(ctx context.Context, audit *TransactionAudit) error
+	ListByTransaction(ctx context.Context, transactionID uint) ([]TransactionAudit, error)
+}
+
+type TransactionAdjustmentRepository interface {
+	// Apply stores the adjustment together with the updated transaction.
+	Apply(ctx context.Context, transaction *Transaction, adjustment *TransactionAdjustment) error
+	ListByTransaction(ctx context.Context, transactionID uint) ([]TransactionAdjustment, error)
 }
 
 type UserRepository interface {
@@ -45,6 +132,9 @@ type UserRepository interface {
 	Delete(ctx context.Context, id uint) error
 	List(ctx context.Context, limit, offset int) ([]User, error)
 	UpdateStatus(ctx context.Context, id uint, status string) error
+	ListGroups(ctx context.Context) ([]string, error)
+	CountByRole(ctx context.Context) ([]GroupCount, error)
+	CountByStatus(ctx context.Context) ([]GroupCount, error)
 }
 
 type OCPPMessageRepository interface {
@@ -62,4 +152,170 @@ type IDTagRepository interface {
 	Delete(ctx context.Context, id uint) error
 	List(ctx context.Context, limit, offset int) ([]IDTag, error)
 	ListByUser(ctx context.Context, userID uint) ([]IDTag, error)
+	ListByParent(ctx context.Context, parentIDTag string) ([]IDTag, error)
+}
+
+type AccessRuleRepository interface {
+	Create(ctx context.Context, rule *AccessRule) error
+	GetByID(ctx context.Context, id uint) (*AccessRule, error)
+	Update(ctx context.Context, rule *AccessRule) error
+	Delete(ctx context.Context, id uint) error
+	List(ctx context.Context, limit, offset int) ([]AccessRule, error)
+	ListForTag(ctx context.Context, idTagID uint, parentIDTag string) ([]AccessRule, error)
+}
+
+type TariffRepository interface {
+	Create(ctx context.Context, tariff *Tariff) error
+	GetByID(ctx context.Context, id uint) (*Tariff, error)
+	Update(ctx context.Context, tariff *Tariff) error
+	// Supersede creates the next version of a tariff and ends the validity of
+	// the previous one at the given time.
+	Supersede(ctx context.Context, previous *Tariff, next *Tariff, at time.Time) error
+	// InUse reports whether transactions or cost adjustments were priced
+	// with the tariff.
+	InUse(ctx context.Context, id uint) (bool, error)
+	Delete(ctx context.Context, id uint) error
+	List(ctx context.Context, limit, offset int) ([]Tariff, error)
+	ListValidAt(ctx context.Context, at time.Time) ([]Tariff, error)
+	// ListPublic pages through tariffs not limited to a user group, which are
+	// the ones published to roaming partners, and returns the total.
+	ListPublic(ctx context.Context, query OCPIQuery) ([]Tariff, int64, error)
+}
+
+type InvoiceRepository interface {
+	// Create assigns the next number of the series and stores the invoice.
+	Create(ctx context.Context, invoice *Invoice, series string) error
+	GetByID(ctx context.Context, id uint) (*Invoice, error)
+	UpdateDocuments(ctx context.Context, invoice *Invoice) error
+	GetReceiptByTransaction(ctx context.Context, transactionID uint) (*Invoice, error)
+	GetConsolidated(ctx context.Context, fleetGroup string, periodStart time.Time) (*Invoice, error)
+	List(ctx context.Context, limit, offset int) ([]Invoice, error)
+}
+
+type WalletRepository interface {
+	Create(ctx context.Context, wallet *Wallet) error
+	GetByID(ctx context.Context, id uint) (*Wallet, error)
+	GetByUserID(ctx context.Context, userID uint) (*Wallet, error)
+	Update(ctx context.Context, wallet *Wallet) error
+	List(ctx context.Context, limit, offset int) ([]Wallet, error)
+	// AddEntry books the entry and updates the balance in one database
+	// transaction.
+	AddEntry(ctx context.Context, walletID uint, entry *WalletEntry) (*Wallet, error)
+	// ChargeTransaction debits the wallet so that the transaction is charged
+	// amount in total, booking only the difference to earlier charges.
+	ChargeTransaction(ctx context.Context, walletID uint, transactionID uint, amount int64, description string) (*Wallet, error)
+	// ChargedAmount is what wallets were charged for the transaction in total.
+	ChargedAmount(ctx context.Context, transactionID uint) (int64, error)
+	ListEntries(ctx context.Context, walletID uint, limit, offset int) ([]WalletEntry, error)
+}
+
+type PaymentRepository interface {
+	Create(ctx context.Context, payment *Payment) error
+	Update(ctx context.Context, payment *Payment) error
+	GetByID(ctx context.Context, id uint) (*Payment, error)
+	GetByReference(ctx context.Context, reference string) (*Payment, error)
+	// GetPending returns the latest authorized payment created since the given
+	// time that is not linked to a transaction yet.
+	GetPending(ctx context.Context, chargePointID uint, connectorID int, idTag string, since time.Time) (*Payment, error)
+	// ListExpired returns authorized payments without transaction created
+	// before the given time.
+	ListExpired(ctx context.Context, before time.Time) ([]Payment, error)
+	List(ctx context.Context, limit, offset int) ([]Payment, error)
+}
+
+type GuestSessionRepository interface {
+	Create(ctx context.Context, session *GuestSession) error
+	Update(ctx context.Context, session *GuestSession) error
+	GetByToken(ctx context.Context, token string) (*GuestSession, error)
+	GetByReceiptToken(ctx context.Context, receiptToken string) (*GuestSession, error)
+	// MarkReceiptViewed records the first use of the receipt link and fails
+	// when it was already used.
+	MarkReceiptViewed(ctx context.Context, id uint, at time.Time) error
+}
+
+type OCPIPartyRepository interface {
+	Create(ctx context.Context, party *OCPIParty) error
+	// Update saves the party and replaces its endpoints.
+	Update(ctx context.Context, party *OCPIParty) error
+	Delete(ctx context.Context, id uint) error
+	GetByID(ctx context.Context, id uint) (*OCPIParty, error)
+	GetByToken(ctx context.Context, token string) (*OCPIParty, error)
+	List(ctx context.Context, limit, offset int) ([]OCPIParty, error)
+	ListRegistered(ctx context.Context) ([]OCPIParty, error)
+}
+
+type RoamingTokenRepository interface {
+	// Upsert creates the token or replaces the one with the same country
+	// code, party ID, UID and type.
+	Upsert(ctx context.Context, token *RoamingToken) error
+	Get(ctx context.Context, countryCode, partyID, uid, tokenType string) (*RoamingToken, error)
+	// GetByUID returns the most recently updated token with the UID.
+	GetByUID(ctx context.Context, uid string) (*RoamingToken, error)
+	GetByPartyAndUID(ctx context.Context, ocpiPartyID uint, uid string) (*RoamingToken, error)
+}
+
+// EventRepository is the outbox of domain events.
+type EventRepository interface {
+	Create(ctx context.Context, event *Event) error
+	// ClaimDue returns pending events due for dispatch, oldest first, and
+	// holds them for the lease so other instances skip them meanwhile.
+	ClaimDue(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]Event, error)
+	MarkDispatched(ctx context.Context, id uint, at time.Time) error
+	// ListAfter returns the events recorded after the given ID and no later
+	// than until that match the filter, whatever their dispatch state,
+	// oldest first.
+	ListAfter(ctx context.Context, afterID uint, until time.Time, filter EventFilter, limit int) ([]Event, error)
+	// LastID returns the ID of the latest event recorded no later than
+	// until, 0 if there is none.
+	LastID(ctx context.Context, until time.Time) (uint, error)
+	// DeleteBefore deletes the events recorded before the given time that
+	// are no longer pending and returns how many it deleted.
+	DeleteBefore(ctx context.Context, before time.Time) (int64, error)
+	// Reschedule records a failed dispatch, setting the event's status,
+	// attempts and next attempt.
+	Reschedule(ctx context.Context, event *Event) error
+}
+
+type WebhookSubscriptionRepository interface {
+	Create(ctx context.Context, subscription *WebhookSubscription) error
+	GetByID(ctx context.Context, id uint) (*WebhookSubscription, error)
+	Update(ctx context.Context, subscription *WebhookSubscription) error
+	Delete(ctx context.Context, id uint) error
+	List(ctx context.Context, limit, offset int) ([]WebhookSubscription, error)
+	ListEnabled(ctx context.Context) ([]WebhookSubscription, error)
+}
+
+type WebhookDeliveryRepository interface {
+	// Create records a delivery unless the subscription already has one for
+	// the event.
+	Create(ctx context.Context, delivery *WebhookDelivery) error
+	GetByID(ctx context.Context, id uint) (*WebhookDelivery, error)
+	Update(ctx context.Context, delivery *WebhookDelivery) error
+	ListBySubscription(ctx context.Context, subscriptionID uint, limit, offset int) ([]WebhookDelivery, error)
+	// ClaimDue returns pending deliveries due for an attempt, oldest first,
+	// and holds them for the lease so other instances skip them meanwhile.
+	ClaimDue(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]WebhookDelivery, error)
+}
+
+type AlertRuleRepository interface {
+	Create(ctx context.Context, rule *AlertRule) error
+	GetByID(ctx context.Context, id uint) (*AlertRule, error)
+	Update(ctx context.Context, rule *AlertRule) error
+	Delete(ctx context.Context, id uint) error
+	List(ctx context.Context, limit, offset int) ([]AlertRule, error)
+	ListEnabled(ctx context.Context) ([]AlertRule, error)
+}
+
+type MaintenanceTicketRepository interface {
+	Create(ctx context.Context, ticket *MaintenanceTicket) error
+	GetByID(ctx context.Context, id uint) (*MaintenanceTicket, error)
+	Update(ctx context.Context, ticket *MaintenanceTicket) error
+	List(ctx context.Context, filter TicketFilter) ([]MaintenanceTicket, error)
+	// ListActiveByAlertRule returns the rule's tickets still open or in
+	// progress.
+	ListActiveByAlertRule(ctx context.Context, alertRuleID uint) ([]MaintenanceTicket, error)
+	// GetLatestByAlert returns the last ticket the rule opened for a charge
+	// point or connector.
+	GetLatestByAlert(ctx context.Context, alertRuleID, chargePointID uint, connectorID int) (*MaintenanceTicket, error)
+	AddComment(ctx context.Context, comment *TicketComment) error
 }
@@ -2,6 +2,7 @@ package domain
 
 import (
 	"context"
+	"time"
 )
 
 type ChargePointService interface {
@@ -11,7 +12,15 @@ type ChargePointService interface {
 	GetChargePointByCode(ctx context.Context, code string) (*ChargePoint, error)
 	ListChargePoints(ctx context.Context, limit, offset int) ([]ChargePoint, error)
 	UpdateHeartbeat(ctx context.Context, chargePointID uint) error
+	// UpdateConnection records that the charge point's websocket connected
+	// or disconnected.
+	UpdateConnection(ctx context.Context, chargePointCode string, online bool) error
+	// ListOnlineSince returns the charge points recorded as connected since
+	// before.
+	ListOnlineSince(ctx context.Context, before time.Time) ([]ChargePoint, error)
 	DeleteChargePoint(ctx context.Context, id uint) error
+	UpdateChargePointSite(ctx context.Context, id uint, site, group string) error
+	UpdateChargePointLocation(ctx context.Context, id uint, location *ChargePointLocation) error
 }
 
 type TransactionService interface {
@@ -22,6 +31,44 @@ type TransactionService interface {
 	ListTransactionsByChargePoint(ctx context.Context, chargePointID uint) ([]Transaction, error)
 	ListTransactionsByUser(ctx context.Context, idTag string) ([]Transaction, error)
 	UpdateMeterValues(ctx context.Context, request *MeterValuesRequest, chargePointID uint) error
+	CloseStaleTransactions(ctx context.Context, chargePointID uint, reason string) error
+	DetectStuckTransactions(ctx context.Context) (int, error)
+	ForceCloseTransaction(ctx context.Context, id uint, meterStop float64, reason string, userID uint) (*Transaction, error)
+	ListTransactionAudits(ctx context.Context, id uint) ([]TransactionAudit, error)
+}
+
+// ChargePointCommander sends CSMS-initiated OCPP calls to connected charge
+// points.
+type ChargePointCommander interface {
+	RemoteStartTransaction(ctx context.Context, chargePointID uint, connectorID int, idTag string) error
+	RemoteStopTransaction(ctx context.Context, chargePointID uint, transactionID int) error
+}
+
+// PaymentGateway is a card payment provider. Amounts are in minor units.
+type PaymentGateway interface {
+	Name() string
+	// PreAuthorize holds the amount on the card without charging it.
+	PreAuthorize(ctx context.Context, request *PaymentIntentRequest) (*PaymentIntent, error)
+	// Capture charges the amount. Further captures add to it, together up to
+	// the pre-authorized amount.
+	Capture(ctx context.Context, reference string, amount int64) error
+	// Cancel releases a pre-authorization that will not be captured.
+	Cancel(ctx context.Context, reference string) error
+	Refund(ctx context.Context, reference string, amount int64) error
+	// VerifyWebhook checks the signature of a webhook call and decodes it.
+	VerifyWebhook(payload []byte, signature string) (*PaymentEvent, error)
+}
+
+// OCPIClient calls the OCPI endpoints of a roaming party. Token is the
+// plain token; the client encodes it for the Authorization header.
+type OCPIClient interface {
+	GetVersions(ctx context.Context, url, token string) ([]OCPIVersion, error)
+	GetVersionDetails(ctx context.Context, url, token string) (*OCPIVersionDetails, error)
+	// Send calls a module endpoint with a JSON body, e.g. to push an object.
+	Send(ctx context.Context, method, url, token string, body interface{}) error
+	// AuthorizeToken asks the eMSP for a real-time authorization; url is its
+	// tokens/{uid}/authorize endpoint.
+	AuthorizeToken(ctx context.Context, url, token string, location *OCPILocationReferences) (*OCPIAuthorizationInfo, error)
 }
 
 type UserService interface {
@@ -47,12 +94,263 @@ type ConnectorService interface {
 	GetConnectorByChargePointAndID(ctx context.Context, chargePointID uint, connectorID int) (*Connector, error)
 }
 
+type AvailabilityService interface {
+	GetStatusHistory(ctx context.Context, filter StatusHistoryFilter) ([]ConnectorStatusChange, error)
+	GetAvailabilityReport(ctx context.Context, filter AvailabilityFilter) (*AvailabilityReport, error)
+}
+
 type NotificationService interface {
 	SendTransactionNotification(ctx context.Context, transaction *Transaction) error
 	SendErrorNotification(ctx context.Context, chargePointID uint, error string) error
-	SendStatusNotification(ctx context.Context, chargePointID uint, status string) error
+	SendStatusNotification(ctx context.Context, chargePointID uint, connectorID int, status string) error
+}
+
+type CostRecalculationService interface {
+	RecalculateCosts(ctx context.Context, request *RecalculationRequest, userID *uint) (*RecalculationReport, error)
+	ListAdjustments(ctx context.Context, transactionID uint) ([]TransactionAdjustment, error)
+}
+
+type InvoiceService interface {
+	// GetReceipt returns the receipt of a completed transaction, issuing it
+	// on first request.
+	GetReceipt(ctx context.Context, transactionID uint) (*Invoice, error)
+	CreateConsolidatedInvoice(ctx context.Context, request *ConsolidatedInvoiceRequest) (*Invoice, error)
+	// IssueMonthlyInvoices issues the consolidated invoices of the previous
+	// month for every fleet account that has none yet.
+	IssueMonthlyInvoices(ctx context.Context, now time.Time) (int, error)
+	GetInvoice(ctx context.Context, id uint) (*Invoice, error)
+	ListInvoices(ctx context.Context, limit, offset int) ([]Invoice, error)
+}
+
+type WalletService interface {
+	CreateWallet(ctx context.Context, wallet *Wallet) error
+	GetWallet(ctx context.Context, id uint) (*Wallet, error)
+	GetWalletByUser(ctx context.Context, userID uint) (*Wallet, error)
+	UpdateMinBalance(ctx context.Context, id uint, minBalance int64) (*Wallet, error)
+	ListWallets(ctx context.Context, limit, offset int) ([]Wallet, error)
+	TopUp(ctx context.Context, id uint, request *TopUpRequest) (*Wallet, error)
+	ListEntries(ctx context.Context, id uint, limit, offset int) ([]WalletEntry, error)
+}
+
+type PaymentService interface {
+	// StartPaidSession pre-authorizes the card and asks the charge point to
+	// start; the pre-authorization is released if it refuses.
+	StartPaidSession(ctx context.Context, request *PaidSessionRequest, userID *uint) (*Payment, error)
+	// AttachTransaction links a newly started transaction to the payment
+	// waiting for it, returning nil when the session is not paid by card.
+	AttachTransaction(ctx context.Context, transaction *Transaction, idTag string) (*Payment, error)
+	// CapturePayment captures the final cost of a completed transaction.
+	CapturePayment(ctx context.Context, transaction *Transaction) (*Payment, error)
+	RefundPayment(ctx context.Context, id uint, request *RefundRequest) (*Payment, error)
+	HandleWebhook(ctx context.Context, payload []byte, signature string) error
+	// ReleaseExpiredPayments cancels pre-authorizations whose session never
+	// started.
+	ReleaseExpiredPayments(ctx context.Context, now time.Time) (int, error)
+	GetPayment(ctx context.Context, id uint) (*Payment, error)
+	ListPayments(ctx context.Context, limit, offset int) ([]Payment, error)
+}
+
+// GuestService runs ad-hoc sessions for guests without an RFID card, who
+// start charging from a connector's public QR link and pay by card.
+type GuestService interface {
+	GetConnectorLink(ctx context.Context, connectorID uint) (*ConnectorLink, error)
+	GetConnector(ctx context.Context, publicCode string) (*GuestConnector, error)
+	StartSession(ctx context.Context, publicCode string, request *GuestSessionRequest) (*GuestSessionStatus, error)
+	GetSession(ctx context.Context, token string) (*GuestSessionStatus, error)
+	StopSession(ctx context.Context, token string) (*GuestSessionStatus, error)
+	GetReceipt(ctx context.Context, receiptToken string) (*Invoice, error)
+}
+
+// OCPIService is the CPO side of OCPI 2.2.1: the credentials handshake with
+// roaming parties and the Locations, Sessions, CDRs and Tariffs modules.
+type OCPIService interface {
+	CreateParty(ctx context.Context, request *OCPIPartyRequest) (*OCPIRegistration, error)
+	ListParties(ctx context.Context, limit, offset int) ([]OCPIParty, error)
+	DeleteParty(ctx context.Context, id uint) error
+	// Authenticate returns the party sending the token. Pending parties are
+	// only allowed to use the versions and credentials modules.
+	Authenticate(ctx context.Context, token string) (*OCPIParty, error)
+
+	Versions() []OCPIVersion
+	VersionDetails() *OCPIVersionDetails
+	Credentials(party *OCPIParty) *OCPICredentials
+	// RegisterCredentials completes or renews the handshake: it fetches the
+	// party's endpoints with its token B and returns a new token C.
+	RegisterCredentials(ctx context.Context, party *OCPIParty, credentials *OCPICredentials) (*OCPICredentials, error)
+	UnregisterCredentials(ctx context.Context, party *OCPIParty) error
+
+	ListLocations(ctx context.Context, query OCPIQuery) ([]OCPILocation, int64, error)
+	GetLocation(ctx context.Context, locationID string) (*OCPILocation, error)
+	ListSessions(ctx context.Context, party *OCPIParty, query OCPIQuery) ([]OCPISession, int64, error)
+	ListCDRs(ctx context.Context, party *OCPIParty, query OCPIQuery) ([]OCPICDR, int64, error)
+	ListTariffs(ctx context.Context, query OCPIQuery) ([]OCPITariff, int64, error)
+}
+
+// RoamingTokenService keeps the tokens eMSPs push through the OCPI Tokens
+// module and authorizes roaming drivers against them.
+type RoamingTokenService interface {
+	// AuthorizeToken decides on a token we did not issue. Whitelisted tokens
+	// are decided locally, others are sent to their eMSP for real-time
+	// authorization; unknown tokens are offered to every registered eMSP.
+	// It fails when no party knows the token.
+	AuthorizeToken(ctx context.Context, uid string, chargePointID uint) (*RoamingAuthorization, error)
+	GetToken(ctx context.Context, countryCode, partyID, uid, tokenType string) (*OCPIToken, error)
+	// PutToken stores a token pushed by the party.
+	PutToken(ctx context.Context, party *OCPIParty, token *OCPIToken) error
+}
+
+// TagAuthorizer is a backend of the authorization chain. It returns a nil
+// decision when it does not know the tag, leaving it to the next backend.
+type TagAuthorizer interface {
+	Name() string
+	AuthorizeTag(ctx context.Context, tag string, chargePointID uint) (*TagDecision, error)
+}
+
+// AuthorizationChain asks its backends in order until one knows the tag.
+// Authorize and StartTransaction both resolve tags through it.
+type AuthorizationChain interface {
+	// AuthorizeTag returns the first decision, or nil when no backend knows
+	// the tag. It fails when a backend configured to reject on errors fails.
+	AuthorizeTag(ctx context.Context, tag string, chargePointID uint) (*TagDecision, error)
+	Stats() []AuthorizerStats
+}
+
+// ConnectionDirectory records which CSMS node holds the websocket of each
+// charge point and carries messages between nodes, so a command issued on any
+// node reaches the charge point.
+type ConnectionDirectory interface {
+	// Register records that the node holds the charge point's socket,
+	// taking it over from any other node.
+	Register(ctx context.Context, chargePointCode, nodeID string) error
+	// Unregister forgets the charge point unless another node took it over.
+	Unregister(ctx context.Context, chargePointCode, nodeID string) error
+	// Owner returns the live node holding the charge point, or "".
+	Owner(ctx context.Context, chargePointCode string) (string, error)
+	// Heartbeat keeps the node and its charge points alive for the node
+	// TTL; entries of a node that stops heartbeating expire.
+	Heartbeat(ctx context.Context, nodeID string, chargePointCodes []string) error
+	// Leave drops the node and its charge points at once.
+	Leave(ctx context.Context, nodeID string, chargePointCodes []string) error
+	Publish(ctx context.Context, nodeID string, message []byte) error
+	// Subscribe delivers the messages published to the node until the
+	// context is cancelled.
+	Subscribe(ctx context.Context, nodeID string) (<-chan []byte, error)
 }
 
+// TagDecisionStore keeps the decisions of the authorization cache, in
+// process or shared between instances. Get returns nil on a miss.
+type TagDecisionStore interface {
+	Name() string
+	Get(ctx context.Context, tag string) (*TagDecision, error)
+	Set(ctx context.Context, tag string, decision *TagDecision, ttl time.Duration) error
+	Delete(ctx context.Context, tag string) error
+}
+
+// AuthorizationCache is the cache backend of the authorization chain. It
+// remembers the decisions of the backends after it until they expire or the
+// tag changes.
+type AuthorizationCache interface {
+	TagAuthorizer
+	Store(ctx context.Context, tag string, decision *TagDecision)
+	// Invalidate forgets the decision on a tag, e.g. after it was updated.
+	Invalidate(ctx context.Context, tag string)
+	Stats() AuthorizationCacheStats
+}
+
+// WebhookSender posts signed webhook requests. Only transport failures are
+// errors; any HTTP answer is returned as the response.
+type WebhookSender interface {
+	Send(ctx context.Context, request *WebhookRequest) (*WebhookResponse, error)
+}
+
+// WebhookService manages webhook subscriptions and delivers the domain
+// events they subscribed to.
+type WebhookService interface {
+	CreateSubscription(ctx context.Context, request *WebhookSubscriptionRequest) (*WebhookSubscription, error)
+	GetSubscription(ctx context.Context, id uint) (*WebhookSubscription, error)
+	UpdateSubscription(ctx context.Context, id uint, request *WebhookSubscriptionRequest) (*WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id uint) error
+	ListSubscriptions(ctx context.Context, limit, offset int) ([]WebhookSubscription, error)
+	ListDeliveries(ctx context.Context, subscriptionID uint, limit, offset int) ([]WebhookDelivery, error)
+	// Redeliver sends a delivery again right away, whatever its state.
+	Redeliver(ctx context.Context, subscriptionID, deliveryID uint) (*WebhookDelivery, error)
+	// HandleEvent queues a delivery of the event to every subscription
+	// wanting it.
+	HandleEvent(ctx context.Context, event *Event) error
+	// DeliverDue attempts the deliveries that are due and returns how many
+	// it attempted.
+	DeliverDue(ctx context.Context) (int, error)
+	NotificationService
+}
+
+// EventHandler reacts to a dispatched event. Returning an error makes the
+// dispatcher retry the event, so handlers must tolerate duplicates.
+type EventHandler func(ctx context.Context, event *Event) error
+
+// EventSink delivers dispatched events to an external system.
+type EventSink interface {
+	Name() string
+	Deliver(ctx context.Context, event *Event) error
+}
+
+// EventBus records domain events in the outbox and dispatches them to
+// in-process subscribers and sinks at least once.
+type EventBus interface {
+	// Publish records an event; chargePointID is 0 for events about no
+	// charge point. Within UnitOfWork.Do it is part of the transaction.
+	Publish(ctx context.Context, eventType string, chargePointID uint, data interface{}) error
+	// Subscribe registers a handler for an event type, or "*" for all.
+	Subscribe(eventType string, handler EventHandler)
+	// Dispatch delivers the events that are due and returns how many it
+	// handled, delivered or not.
+	Dispatch(ctx context.Context) (int, error)
+}
+
+// TransactionFeed streams the state of a transaction as it changes, e.g. its
+// running cost on every meter update.
+type TransactionFeed interface {
+	SubscribeTransaction(id uint) (<-chan Transaction, func())
+}
+
+// TicketNotifier tells people about maintenance ticket changes, e.g. the
+// technician a ticket was assigned to.
+type TicketNotifier interface {
+	Name() string
+	NotifyTicket(ctx context.Context, alert *TicketAlert) error
+}
+
+// MaintenanceService manages alert rules and the maintenance tickets they,
+// or operators, open.
+type MaintenanceService interface {
+	CreateAlertRule(ctx context.Context, rule *AlertRule) error
+	GetAlertRule(ctx context.Context, id uint) (*AlertRule, error)
+	UpdateAlertRule(ctx context.Context, rule *AlertRule) error
+	DeleteAlertRule(ctx context.Context, id uint) error
+	ListAlertRules(ctx context.Context, limit, offset int) ([]AlertRule, error)
+
+	CreateTicket(ctx context.Context, request *TicketRequest, userID uint) (*MaintenanceTicket, error)
+	GetTicket(ctx context.Context, id uint) (*MaintenanceTicket, error)
+	ListTickets(ctx context.Context, filter TicketFilter) ([]MaintenanceTicket, error)
+	// AssignTicket assigns the ticket to a technician or admin, or nobody
+	// when assigneeID is nil.
+	AssignTicket(ctx context.Context, id uint, assigneeID *uint, userID uint) (*MaintenanceTicket, error)
+	TransitionTicket(ctx context.Context, id uint, status, comment string, userID uint) (*MaintenanceTicket, error)
+	AddComment(ctx context.Context, id uint, body string, userID uint) (*TicketComment, error)
+
+	// EvaluateAlerts opens tickets for the alert rules whose condition holds
+	// and resolves those whose condition cleared.
+	EvaluateAlerts(ctx context.Context, now time.Time) (opened, resolved int, err error)
+}
+
+// EventFeed streams domain events as they are recorded, e.g. to the
+// dashboard.
+type EventFeed interface {
+	// SubscribeEvents streams the events matching the filter recorded after
+	// lastEventID, or from now on if it is 0, until ctx is done.
+	SubscribeEvents(ctx context.Context, filter EventFilter, lastEventID uint) (<-chan Event, error)
+}
+
+// MonitoringService reports on the state of the system for the REST API.
 type MonitoringService interface {
 	GetSystemStatus(ctx context.Context) (map[string]interface{}, error)
 	GetChargePointMetrics(ctx context.Context, chargePointID uint) (map[string]interface{}, error)
@@ -60,8 +358,18 @@ type MonitoringService interface {
 	GetUserMetrics(ctx context.Context) (map[string]interface{}, error)
 }
 
+// MetricsRecorder records operational metrics, e.g. for Prometheus to
+// scrape.
+type MetricsRecorder interface {
+	// ObserveOCPPMessage records a CALL from a charge point; result is "ok",
+	// the OCPP error code answered or "unanswered".
+	ObserveOCPPMessage(action, result string, duration time.Duration)
+	ObserveHTTPRequest(method, route string, status int, duration time.Duration)
+	AddEnergyDelivered(kWh float64)
+}
+
 type IDTagService interface {
-	Authorize(ctx context.Context, request *AuthorizeRequest) (*AuthorizeResponse, error)
+	Authorize(ctx context.Context, request *AuthorizeRequest, chargePointID uint) (*AuthorizeResponse, error)
 	CreateIDTag(ctx context.Context, idTag *IDTag) error
 	GetIDTag(ctx context.Context, id uint) (*IDTag, error)
 	GetByTag(ctx context.Context, tag string) (*IDTag, error)
@@ -70,3 +378,23 @@ type IDTagService interface {
 	ListIDTags(ctx context.Context, limit, offset int) ([]IDTag, error)
 	ListByUser(ctx context.Context, userID uint) ([]IDTag, error)
 }
+
+type AccessRuleService interface {
+	CreateAccessRule(ctx context.Context, rule *AccessRule) error
+	GetAccessRule(ctx context.Context, id uint) (*AccessRule, error)
+	UpdateAccessRule(ctx context.Context, rule *AccessRule) error
+	DeleteAccessRule(ctx context.Context, id uint) error
+	ListAccessRules(ctx context.Context, limit, offset int) ([]AccessRule, error)
+}
+
+type TariffService interface {
+	CreateTariff(ctx context.Context, tariff *Tariff) error
+	GetTariff(ctx context.Context, id uint) (*Tariff, error)
+	UpdateTariff(ctx context.Context, tariff *Tariff) error
+	DeleteTariff(ctx context.Context, id uint) error
+	ListTariffs(ctx context.Context, limit, offset int) ([]Tariff, error)
+	PriceTransaction(ctx context.Context, transaction *Transaction, until time.Time) (*PriceBreakdown, error)
+	// SessionCurrency is the currency a session of the id tag started on the
+	// charge point at the given time is priced in.
+	SessionCurrency(ctx context.Context, chargePointID uint, idTag *IDTag, at time.Time) (string, error)
+}
@@ -0,0 +1,75 @@
+package domain
+
+import (
+	"time"
+)
+
+// Tariff is a priced set of components applied to charging sessions. A tariff
+// can be limited to a charge point, a site or a user group and to a validity
+// window; the most specific valid tariff is used for a session. Element prices
+// are in major units of the currency; tax settings default to the tariff
+// configuration when unset.
+type Tariff struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	Code          string     `json:"code" gorm:"not null;index"`
+	Version       int        `json:"version" gorm:"not null;default:1"`
+	Name          string     `json:"name"`
+	Currency      string     `json:"currency"`
+	TimeZone      string     `json:"timeZone"`
+	ValidFrom     *time.Time `json:"validFrom"`
+	ValidTo       *time.Time `json:"validTo"`
+	ChargePointID *uint      `json:"chargePointId" gorm:"index"`
+	Site          string     `json:"site" gorm:"index"`
+	UserGroup     string     `json:"userGroup" gorm:"index"`
+	Priority      int        `json:"priority"`
+	TaxRate       *float64   `json:"taxRate"`
+	TaxInclusive  *bool      `json:"taxInclusive"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+
+	Elements []TariffElement `json:"elements" gorm:"foreignKey:TariffID;constraint:OnDelete:CASCADE"`
+}
+
+// TariffElement prices one component of a session. Energy is priced per kWh,
+// Time and Idle per minute and Flat once per session. StartTime, EndTime and
+// DaysOfWeek restrict the element to a period of the day in the tariff's time
+// zone; elements without them apply at any time.
+type TariffElement struct {
+	ID           uint    `json:"id" gorm:"primaryKey"`
+	TariffID     uint    `json:"tariffId" gorm:"not null;index"`
+	Component    string  `json:"component" gorm:"not null"`
+	Price        float64 `json:"price"`
+	StartTime    string  `json:"startTime"`
+	EndTime      string  `json:"endTime"`
+	DaysOfWeek   string  `json:"daysOfWeek"`
+	GraceMinutes int     `json:"graceMinutes"`
+}
+
+// PriceBreakdown is the cost of a session split by component. Amounts are in
+// minor units of the currency, e.g. cents. Component amounts are as priced by
+// the tariff, so they include tax when TaxInclusive is set.
+type PriceBreakdown struct {
+	TariffID     *uint       `json:"tariffId"`
+	Currency     string      `json:"currency"`
+	EnergyCost   int64       `json:"energyCost"`
+	TimeCost     int64       `json:"timeCost"`
+	FlatCost     int64       `json:"flatCost"`
+	IdleCost     int64       `json:"idleCost"`
+	NetAmount    int64       `json:"netAmount"`
+	TaxAmount    int64       `json:"taxAmount"`
+	GrossAmount  int64       `json:"grossAmount"`
+	TaxRate      float64     `json:"taxRate"`
+	TaxInclusive bool        `json:"taxInclusive"`
+	Lines        []PriceLine `json:"lines"`
+}
+
+// PriceLine is the part of a component priced by a single tariff element.
+// UnitPrice is in major units, Amount in minor units.
+type PriceLine struct {
+	Component   string  `json:"component"`
+	Description string  `json:"description"`
+	Quantity    float64 `json:"quantity"`
+	Unit        string  `json:"unit"`
+	UnitPrice   float64 `json:"unitPrice"`
+	Amount      int64   `json:"amount"`
+}
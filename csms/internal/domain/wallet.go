@@ -0,0 +1,39 @@
+package domain
+
+import (
+	"time"
+)
+
+// Wallet is the prepaid balance of a user. Users without a wallet are not
+// subject to balance checks. Amounts are in minor units of Currency.
+type Wallet struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	UserID     uint      `json:"userId" gorm:"uniqueIndex;not null"`
+	Currency   string    `json:"currency" gorm:"not null"`
+	Balance    int64     `json:"balance"`
+	MinBalance int64     `json:"minBalance"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+
+	User User `json:"user" gorm:"foreignKey:UserID"`
+}
+
+// WalletEntry is a ledger line: positive amounts credit the wallet, negative
+// ones debit it.
+type WalletEntry struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	WalletID      uint      `json:"walletId" gorm:"not null;index"`
+	Type          string    `json:"type" gorm:"not null"`
+	Amount        int64     `json:"amount"`
+	BalanceAfter  int64     `json:"balanceAfter"`
+	TransactionID *uint     `json:"transactionId" gorm:"index"`
+	Reference     string    `json:"reference"`
+	Description   string    `json:"description"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+type TopUpRequest struct {
+	Amount      int64  `json:"amount" binding:"required,gt=0"`
+	Reference   string `json:"reference"`
+	Description string `json:"description"`
+}
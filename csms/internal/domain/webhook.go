@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WebhookSubscription tells an external system about domain events of the
+// given types. Deliveries are signed with the secret.
+type WebhookSubscription struct {
+	ID         uint              `json:"id" gorm:"primaryKey"`
+	Name       string            `json:"name"`
+	URL        string            `json:"url" gorm:"not null"`
+	EventTypes []string          `json:"eventTypes" gorm:"type:jsonb;serializer:json"`
+	Secret     string            `json:"-" gorm:"not null"`
+	Disabled   bool              `json:"disabled"`
+	Deliveries []WebhookDelivery `json:"-" gorm:"foreignKey:SubscriptionID;constraint:OnDelete:CASCADE"`
+	CreatedAt  time.Time         `json:"createdAt"`
+	UpdatedAt  time.Time         `json:"updatedAt"`
+}
+
+// WebhookSubscriptionRequest creates or updates a subscription. An empty
+// secret is generated on create and kept on update.
+type WebhookSubscriptionRequest struct {
+	Name       string   `json:"name"`
+	URL        string   `json:"url" binding:"required"`
+	EventTypes []string `json:"eventTypes" binding:"required"`
+	Secret     string   `json:"secret"`
+	Disabled   bool     `json:"disabled"`
+}
+
+// WebhookDelivery is one event sent to one subscription, with the outcome
+// of its last attempt.
+type WebhookDelivery struct {
+	ID             uint            `json:"id" gorm:"primaryKey"`
+	SubscriptionID uint            `json:"subscriptionId" gorm:"not null;uniqueIndex:idx_webhook_delivery_event,priority:1"`
+	EventID        uint            `json:"eventId" gorm:"not null;uniqueIndex:idx_webhook_delivery_event,priority:2"`
+	EventType      string          `json:"eventType" gorm:"not null"`
+	Payload        json.RawMessage `json:"payload" gorm:"type:jsonb"`
+	Status         string          `json:"status" gorm:"default:'Pending';index:idx_webhook_delivery_due,priority:1"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"nextAttemptAt" gorm:"index:idx_webhook_delivery_due,priority:2"`
+	ResponseCode   int             `json:"responseCode,omitempty"`
+	ResponseBody   string          `json:"responseBody,omitempty"`
+	LastError      string          `json:"lastError,omitempty"`
+	DeliveredAt    *time.Time      `json:"deliveredAt,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	UpdatedAt      time.Time       `json:"updatedAt"`
+}
+
+// WebhookRequest is a signed POST of a delivery.
+type WebhookRequest struct {
+	URL        string
+	Secret     string
+	EventType  string
+	DeliveryID uint
+	Payload    []byte
+}
+
+// WebhookResponse is what the subscriber answered.
+type WebhookResponse struct {
+	StatusCode int
+	Body       string
+}
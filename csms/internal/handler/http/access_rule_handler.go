@@ -0,0 +1,126 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+type AccessRuleHandler struct {
+	accessRuleService domain.AccessRuleService
+}
+
+func NewAccessRuleHandler(accessRuleService domain.AccessRuleService) *AccessRuleHandler {
+	return &AccessRuleHandler{
+		accessRuleService: accessRuleService,
+	}
+}
+
+func (h *AccessRuleHandler) GetAccessRules(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	limit := 100
+	offset := 0
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil {
+			limit = l
+		}
+	}
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if o, err := strconv.Atoi(offsetStr); err == nil {
+			offset = o
+		}
+	}
+
+	rules, err := h.accessRuleService.ListAccessRules(ctx, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get access rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+func (h *AccessRuleHandler) GetAccessRule(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid access rule ID"})
+		return
+	}
+
+	rule, err := h.accessRuleService.GetAccessRule(ctx, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Access rule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+func (h *AccessRuleHandler) CreateAccessRule(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var rule domain.AccessRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "msg": err.Error()})
+		return
+	}
+
+	err := h.accessRuleService.CreateAccessRule(ctx, &rule)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+func (h *AccessRuleHandler) UpdateAccessRule(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid access rule ID"})
+		return
+	}
+
+	var rule domain.AccessRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	rule.ID = uint(id)
+	err = h.accessRuleService.UpdateAccessRule(ctx, &rule)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+func (h *AccessRuleHandler) DeleteAccessRule(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid access rule ID"})
+		return
+	}
+
+	err = h.accessRuleService.DeleteAccessRule(ctx, uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete access rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Access rule deleted successfully"})
+}
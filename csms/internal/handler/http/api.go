@@ -37,13 +37,43 @@ func SetupRoutes(
 	userService domain.UserService,
 	idTagService domain.IDTagService,
 	authService domain.AuthService,
+	accessRuleService domain.AccessRuleService,
+	tariffService domain.TariffService,
+	transactionFeed domain.TransactionFeed,
+	recalculationService domain.CostRecalculationService,
+	invoiceService domain.InvoiceService,
+	walletService domain.WalletService,
+	paymentService domain.PaymentService,
+	guestService domain.GuestService,
+	ocpiService domain.OCPIService,
+	authorizationChain domain.AuthorizationChain,
+	authorizationCache domain.AuthorizationCache,
+	webhookService domain.WebhookService,
+	eventFeed domain.EventFeed,
+	maintenanceService domain.MaintenanceService,
+	availabilityService domain.AvailabilityService,
+	monitoringService domain.MonitoringService,
 ) {
 	authHandler := NewAuthHandler(authService)
 	dashboardHandler := NewDashboardHandler(chargePointService, transactionService, userService)
 	chargePointHandler := NewChargePointHandler(chargePointService)
-	transactionHandler := NewTransactionHandler(transactionService)
+	transactionHandler := NewTransactionHandler(transactionService, transactionFeed)
 	userHandler := NewUserHandler(userService)
 	idTagHandler := NewIDTagHandler(idTagService)
+	accessRuleHandler := NewAccessRuleHandler(accessRuleService)
+	tariffHandler := NewTariffHandler(tariffService, transactionService)
+	recalculationHandler := NewRecalculationHandler(recalculationService)
+	invoiceHandler := NewInvoiceHandler(invoiceService, transactionService)
+	walletHandler := NewWalletHandler(walletService)
+	paymentHandler := NewPaymentHandler(paymentService)
+	guestHandler := NewGuestHandler(guestService)
+	ocpiPartyHandler := NewOCPIPartyHandler(ocpiService)
+	authorizationHandler := NewAuthorizationHandler(authorizationChain, authorizationCache)
+	webhookHandler := NewWebhookHandler(webhookService)
+	eventHandler := NewEventHandler(eventFeed)
+	maintenanceHandler := NewMaintenanceHandler(maintenanceService)
+	availabilityHandler := NewAvailabilityHandler(availabilityService)
+	monitoringHandler := NewMonitoringHandler(monitoringService)
 
 	auth := router.Group("/api/v1/auth")
 	{
@@ -52,6 +82,17 @@ func SetupRoutes(
 		auth.GET("/me", authHandler.Me)
 	}
 
+	router.POST("/api/v1/payments/webhook", paymentHandler.HandleWebhook)
+
+	public := router.Group("/api/v1/public")
+	{
+		public.GET("/connectors/:code", guestHandler.GetConnector)
+		public.POST("/connectors/:code/sessions", guestHandler.StartSession)
+		public.GET("/sessions/:token", guestHandler.GetSession)
+		public.POST("/sessions/:token/stop", guestHandler.StopSession)
+		public.GET("/receipts/:token", guestHandler.GetReceipt)
+	}
+
 	api := router.Group("/api/v1")
 	api.Use(AuthMiddleware(authService))
 	{
@@ -61,18 +102,53 @@ func SetupRoutes(
 			dashboard.GET("/weekly-chart", dashboardHandler.GetWeeklyChart)
 		}
 
+		api.GET("/events", eventHandler.StreamEvents)
+
 		chargePoints := api.Group("/charge-points")
 		{
 			chargePoints.GET("", chargePointHandler.GetChargePoints)
 			chargePoints.GET("/:id", chargePointHandler.GetChargePoint)
 			chargePoints.PATCH("/:id/status", chargePointHandler.UpdateChargePointStatus)
+			chargePoints.PATCH("/:id/site", RoleMiddleware("admin"), chargePointHandler.UpdateChargePointSite)
+			chargePoints.PUT("/:id/location", RoleMiddleware("admin"), chargePointHandler.UpdateChargePointLocation)
 			chargePoints.POST("/:id/commands", chargePointHandler.SendRemoteCommand)
+			chargePoints.GET("/:id/connectors/:connectorId/history", availabilityHandler.GetStatusHistory)
 		}
 
+		api.GET("/connectors/:id/link", RoleMiddleware("admin"), guestHandler.GetConnectorLink)
+
 		transactions := api.Group("/transactions")
 		{
 			transactions.GET("", transactionHandler.GetTransactions)
 			transactions.GET("/:id", transactionHandler.GetTransaction)
+			transactions.GET("/:id/price", tariffHandler.GetTransactionPrice)
+			transactions.GET("/:id/live", transactionHandler.StreamTransaction)
+			transactions.GET("/:id/receipt", invoiceHandler.GetTransactionReceipt)
+			transactions.GET("/:id/audit", RoleMiddleware("admin"), transactionHandler.GetTransactionAudits)
+			transactions.GET("/:id/adjustments", RoleMiddleware("admin"), recalculationHandler.GetTransactionAdjustments)
+			transactions.POST("/recalculate", RoleMiddleware("admin"), recalculationHandler.RecalculateCosts)
+			transactions.POST("/:id/force-close", RoleMiddleware("admin"), transactionHandler.ForceCloseTransaction)
+		}
+
+		api.GET("/wallet", walletHandler.GetMyWallet)
+
+		wallets := api.Group("/wallets")
+		wallets.Use(RoleMiddleware("admin"))
+		{
+			wallets.GET("", walletHandler.GetWallets)
+			wallets.GET("/:id", walletHandler.GetWallet)
+			wallets.POST("", walletHandler.CreateWallet)
+			wallets.PATCH("/:id", walletHandler.UpdateWallet)
+			wallets.POST("/:id/top-ups", walletHandler.TopUpWallet)
+			wallets.GET("/:id/entries", walletHandler.GetWalletEntries)
+		}
+
+		payments := api.Group("/payments")
+		{
+			payments.POST("/sessions", paymentHandler.StartPaidSession)
+			payments.GET("", RoleMiddleware("admin"), paymentHandler.GetPayments)
+			payments.GET("/:id", RoleMiddleware("admin"), paymentHandler.GetPayment)
+			payments.POST("/:id/refunds", RoleMiddleware("admin"), paymentHandler.RefundPayment)
 		}
 
 		users := api.Group("/users")
@@ -95,5 +171,92 @@ func SetupRoutes(
 			idTags.PUT("/:id", idTagHandler.UpdateIDTag)
 			idTags.DELETE("/:id", idTagHandler.DeleteIDTag)
 		}
+
+		authorization := api.Group("/authorization")
+		authorization.Use(RoleMiddleware("admin"))
+		{
+			authorization.GET("/backends", authorizationHandler.GetBackendStats)
+			authorization.GET("/cache", authorizationHandler.GetCacheStats)
+		}
+
+		accessRules := api.Group("/access-rules")
+		accessRules.Use(RoleMiddleware("admin"))
+		{
+			accessRules.GET("", accessRuleHandler.GetAccessRules)
+			accessRules.GET("/:id", accessRuleHandler.GetAccessRule)
+			accessRules.POST("", accessRuleHandler.CreateAccessRule)
+			accessRules.PUT("/:id", accessRuleHandler.UpdateAccessRule)
+			accessRules.DELETE("/:id", accessRuleHandler.DeleteAccessRule)
+		}
+
+		invoices := api.Group("/invoices")
+		invoices.Use(RoleMiddleware("admin"))
+		{
+			invoices.GET("", invoiceHandler.GetInvoices)
+			invoices.GET("/:id", invoiceHandler.GetInvoice)
+			invoices.POST("/consolidated", invoiceHandler.CreateConsolidatedInvoice)
+		}
+
+		ocpiParties := api.Group("/ocpi/parties")
+		ocpiParties.Use(RoleMiddleware("admin"))
+		{
+			ocpiParties.GET("", ocpiPartyHandler.GetParties)
+			ocpiParties.POST("", ocpiPartyHandler.CreateParty)
+			ocpiParties.DELETE("/:id", ocpiPartyHandler.DeleteParty)
+		}
+
+		webhooks := api.Group("/webhooks")
+		webhooks.Use(RoleMiddleware("admin"))
+		{
+			webhooks.GET("", webhookHandler.GetSubscriptions)
+			webhooks.GET("/:id", webhookHandler.GetSubscription)
+			webhooks.POST("", webhookHandler.CreateSubscription)
+			webhooks.PUT("/:id", webhookHandler.UpdateSubscription)
+			webhooks.DELETE("/:id", webhookHandler.DeleteSubscription)
+			webhooks.GET("/:id/deliveries", webhookHandler.GetDeliveries)
+			webhooks.POST("/:id/deliveries/:deliveryId/redeliver", webhookHandler.Redeliver)
+		}
+
+		api.GET("/reports/availability", RoleMiddleware("admin"), availabilityHandler.GetAvailabilityReport)
+
+		monitoring := api.Group("/monitoring")
+		monitoring.Use(RoleMiddleware("admin"))
+		{
+			monitoring.GET("/system", monitoringHandler.GetSystemStatus)
+			monitoring.GET("/charge-points/:id", monitoringHandler.GetChargePointMetrics)
+			monitoring.GET("/transactions", monitoringHandler.GetTransactionMetrics)
+			monitoring.GET("/users", monitoringHandler.GetUserMetrics)
+		}
+
+		alertRules := api.Group("/alert-rules")
+		alertRules.Use(RoleMiddleware("admin"))
+		{
+			alertRules.GET("", maintenanceHandler.GetAlertRules)
+			alertRules.GET("/:id", maintenanceHandler.GetAlertRule)
+			alertRules.POST("", maintenanceHandler.CreateAlertRule)
+			alertRules.PUT("/:id", maintenanceHandler.UpdateAlertRule)
+			alertRules.DELETE("/:id", maintenanceHandler.DeleteAlertRule)
+		}
+
+		tickets := api.Group("/tickets")
+		tickets.Use(RoleMiddleware("admin", "technician"))
+		{
+			tickets.GET("", maintenanceHandler.GetTickets)
+			tickets.GET("/:id", maintenanceHandler.GetTicket)
+			tickets.POST("", maintenanceHandler.CreateTicket)
+			tickets.POST("/:id/assign", maintenanceHandler.AssignTicket)
+			tickets.POST("/:id/transition", maintenanceHandler.TransitionTicket)
+			tickets.POST("/:id/comments", maintenanceHandler.AddTicketComment)
+		}
+
+		tariffs := api.Group("/tariffs")
+		tariffs.Use(RoleMiddleware("admin"))
+		{
+			tariffs.GET("", tariffHandler.GetTariffs)
+			tariffs.GET("/:id", tariffHandler.GetTariff)
+			tariffs.POST("", tariffHandler.CreateTariff)
+			tariffs.PUT("/:id", tariffHandler.UpdateTariff)
+			tariffs.DELETE("/:id", tariffHandler.DeleteTariff)
+		}
 	}
 }
@@ -0,0 +1,31 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+type AuthorizationHandler struct {
+	authorizationChain domain.AuthorizationChain
+	authorizationCache domain.AuthorizationCache
+}
+
+func NewAuthorizationHandler(authorizationChain domain.AuthorizationChain, authorizationCache domain.AuthorizationCache) *AuthorizationHandler {
+	return &AuthorizationHandler{
+		authorizationChain: authorizationChain,
+		authorizationCache: authorizationCache,
+	}
+}
+
+// GetBackendStats returns the counters of each backend of the authorization
+// chain, in the order they are asked.
+func (h *AuthorizationHandler) GetBackendStats(c *gin.Context) {
+	c.JSON(http.StatusOK, h.authorizationChain.Stats())
+}
+
+// GetCacheStats returns the hit rate of the authorization cache.
+func (h *AuthorizationHandler) GetCacheStats(c *gin.Context) {
+	c.JSON(http.StatusOK, h.authorizationCache.Stats())
+}
@@ -0,0 +1,124 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+// defaultReportPeriod is the period reported on when the query names none.
+const defaultReportPeriod = 30 * 24 * time.Hour
+
+type AvailabilityHandler struct {
+	availabilityService domain.AvailabilityService
+}
+
+func NewAvailabilityHandler(availabilityService domain.AvailabilityService) *AvailabilityHandler {
+	return &AvailabilityHandler{
+		availabilityService: availabilityService,
+	}
+}
+
+// GetStatusHistory lists a connector's status changes, newest first,
+// optionally within the RFC 3339 from and to query parameters.
+func (h *AvailabilityHandler) GetStatusHistory(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	chargePointID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid charge point ID"})
+		return
+	}
+	connectorID, err := strconv.Atoi(c.Param("connectorId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid connector ID"})
+		return
+	}
+
+	filter := domain.StatusHistoryFilter{
+		ChargePointID: uint(chargePointID),
+		ConnectorID:   connectorID,
+		Limit:         100,
+	}
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil {
+			filter.Limit = l
+		}
+	}
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if o, err := strconv.Atoi(offsetStr); err == nil {
+			filter.Offset = o
+		}
+	}
+	if filter.From, err = parseTimeQuery(c, "from"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from time"})
+		return
+	}
+	if filter.To, err = parseTimeQuery(c, "to"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to time"})
+		return
+	}
+
+	changes, err := h.availabilityService.GetStatusHistory(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get status history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, changes)
+}
+
+// GetAvailabilityReport reports connector and site availability between the
+// RFC 3339 from and to query parameters, by default over the last 30 days.
+func (h *AvailabilityHandler) GetAvailabilityReport(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	filter := domain.AvailabilityFilter{
+		Site: c.Query("site"),
+	}
+	if idStr := c.Query("chargePointId"); idStr != "" {
+		id, err := strconv.ParseUint(idStr, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid charge point ID"})
+			return
+		}
+		filter.ChargePointID = uint(id)
+	}
+
+	var err error
+	if filter.From, err = parseTimeQuery(c, "from"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from time"})
+		return
+	}
+	if filter.To, err = parseTimeQuery(c, "to"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to time"})
+		return
+	}
+	if filter.To.IsZero() {
+		filter.To = time.Now()
+	}
+	if filter.From.IsZero() {
+		filter.From = filter.To.Add(-defaultReportPeriod)
+	}
+
+	report, err := h.availabilityService.GetAvailabilityReport(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// parseTimeQuery parses an RFC 3339 query parameter; a missing one is the
+// zero time.
+func parseTimeQuery(c *gin.Context, name string) (time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -83,13 +83,67 @@ func (h *ChargePointHandler) UpdateChargePointStatus(c *gin.Context) {
 
 	err = h.chargePointService.UpdateChargePointStatus(ctx, uint(id), request.Status)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update status"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Status updated successfully"})
 }
 
+func (h *ChargePointHandler) UpdateChargePointSite(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid charge point ID"})
+		return
+	}
+
+	var request struct {
+		Site             string `json:"site"`
+		ChargePointGroup string `json:"chargePointGroup"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	err = h.chargePointService.UpdateChargePointSite(ctx, uint(id), request.Site, request.ChargePointGroup)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update site"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Site updated successfully"})
+}
+
+func (h *ChargePointHandler) UpdateChargePointLocation(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid charge point ID"})
+		return
+	}
+
+	var request domain.ChargePointLocation
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "msg": err.Error()})
+		return
+	}
+
+	err = h.chargePointService.UpdateChargePointLocation(ctx, uint(id), &request)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update location"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Location updated successfully"})
+}
+
 func (h *ChargePointHandler) SendRemoteCommand(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.ParseUint(idStr, 10, 32)
@@ -38,7 +38,7 @@ func (h *DashboardHandler) GetDashboardStats(c *gin.Context) {
 	onlineCount := 0
 	offlineCount := 0
 	for _, cp := range chargePoints {
-		if cp.Status == "Available" || cp.Status == "Charging" {
+		if cp.Online {
 			onlineCount++
 		} else {
 			offlineCount++
@@ -0,0 +1,175 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+var eventUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool {
+		return true
+	},
+}
+
+type EventHandler struct {
+	eventFeed domain.EventFeed
+}
+
+func NewEventHandler(eventFeed domain.EventFeed) *EventHandler {
+	return &EventHandler{
+		eventFeed: eventFeed,
+	}
+}
+
+// StreamEvents pushes domain events as they are recorded, over a WebSocket
+// when the request is an upgrade and as server-sent events otherwise. The
+// chargePointId and type query parameters take comma-separated lists to
+// filter on; a Last-Event-ID header or lastEventId parameter resumes after
+// that event.
+func (h *EventHandler) StreamEvents(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	filter, err := eventFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	lastEventIDStr := c.GetHeader("Last-Event-ID")
+	if lastEventIDStr == "" {
+		lastEventIDStr = c.Query("lastEventId")
+	}
+	var lastEventID uint64
+	if lastEventIDStr != "" {
+		if lastEventID, err = strconv.ParseUint(lastEventIDStr, 10, 32); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid last event ID"})
+			return
+		}
+	}
+
+	if websocket.IsWebSocketUpgrade(c.Request) {
+		h.streamWebSocket(c, filter, uint(lastEventID))
+		return
+	}
+
+	events, err := h.eventFeed.SubscribeEvents(ctx, filter, uint(lastEventID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to subscribe to events"})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Writer.WriteHeader(http.StatusOK)
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(30 * time.Second)
+	defer keepAlive.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": ping\n\n")
+			return true
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			data, err := json.Marshal(event.Message())
+			if err != nil {
+				return false
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+			return true
+		}
+	})
+}
+
+// streamWebSocket sends every event as a JSON text message. The client is
+// not expected to send anything; reading only notices it going away.
+func (h *EventHandler) streamWebSocket(c *gin.Context, filter domain.EventFilter, lastEventID uint) {
+	conn, err := eventUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	events, err := h.eventFeed.SubscribeEvents(ctx, filter, lastEventID)
+	if err != nil {
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "failed to subscribe to events"))
+		return
+	}
+
+	keepAlive := time.NewTicker(30 * time.Second)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-keepAlive.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second)); err != nil {
+				return
+			}
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := conn.WriteJSON(event.Message()); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func eventFilter(c *gin.Context) (domain.EventFilter, error) {
+	var filter domain.EventFilter
+
+	if value := c.Query("chargePointId"); value != "" {
+		for _, idStr := range strings.Split(value, ",") {
+			id, err := strconv.ParseUint(strings.TrimSpace(idStr), 10, 32)
+			if err != nil {
+				return filter, fmt.Errorf("invalid charge point ID %q", idStr)
+			}
+			filter.ChargePointIDs = append(filter.ChargePointIDs, uint(id))
+		}
+	}
+
+	if value := c.Query("type"); value != "" {
+		for _, eventType := range strings.Split(value, ",") {
+			eventType = strings.TrimSpace(eventType)
+			if !slices.Contains(domain.EventTypes, eventType) {
+				return filter, fmt.Errorf("unknown event type %q", eventType)
+			}
+			filter.Types = append(filter.Types, eventType)
+		}
+	}
+
+	return filter, nil
+}
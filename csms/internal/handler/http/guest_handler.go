@@ -0,0 +1,110 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+// GuestHandler serves the public guest charging flow. Its routes are not
+// behind the auth middleware; guests are identified by the session token
+// returned when they start charging.
+type GuestHandler struct {
+	guestService domain.GuestService
+}
+
+func NewGuestHandler(guestService domain.GuestService) *GuestHandler {
+	return &GuestHandler{
+		guestService: guestService,
+	}
+}
+
+func (h *GuestHandler) GetConnector(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	connector, err := h.guestService.GetConnector(ctx, c.Param("code"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Connector not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, connector)
+}
+
+func (h *GuestHandler) StartSession(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var request domain.GuestSessionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "msg": err.Error()})
+		return
+	}
+
+	session, err := h.guestService.StartSession(ctx, c.Param("code"), &request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, session)
+}
+
+func (h *GuestHandler) GetSession(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	session, err := h.guestService.GetSession(ctx, c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
+
+func (h *GuestHandler) StopSession(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	session, err := h.guestService.StopSession(ctx, c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
+
+// GetReceipt serves the receipt of a guest session as PDF, or as HTML or JSON
+// with ?format=html|json. The link works once.
+func (h *GuestHandler) GetReceipt(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	receipt, err := h.guestService.GetReceipt(ctx, c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+		return
+	}
+
+	writeInvoice(c, receipt)
+}
+
+// GetConnectorLink returns the public QR URL of a connector for operators to
+// print on the charge point.
+func (h *GuestHandler) GetConnectorLink(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid connector ID"})
+		return
+	}
+
+	link, err := h.guestService.GetConnectorLink(ctx, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, link)
+}
@@ -0,0 +1,141 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+type InvoiceHandler struct {
+	invoiceService     domain.InvoiceService
+	transactionService domain.TransactionService
+}
+
+func NewInvoiceHandler(invoiceService domain.InvoiceService, transactionService domain.TransactionService) *InvoiceHandler {
+	return &InvoiceHandler{
+		invoiceService:     invoiceService,
+		transactionService: transactionService,
+	}
+}
+
+// GetTransactionReceipt serves the receipt of a completed transaction as PDF,
+// or as HTML or JSON with ?format=html|json. Drivers may only fetch their own
+// receipts.
+func (h *InvoiceHandler) GetTransactionReceipt(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction ID"})
+		return
+	}
+
+	// Checked before asking for the receipt, which issues it on first use.
+	user := c.MustGet("user").(*domain.User)
+	if user.Role != "admin" {
+		transaction, err := h.transactionService.GetTransaction(ctx, uint(id))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+			return
+		}
+		if transaction.IDTag.UserID != user.ID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			return
+		}
+	}
+
+	invoice, err := h.invoiceService.GetReceipt(ctx, uint(id))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	writeInvoice(c, invoice)
+}
+
+func (h *InvoiceHandler) GetInvoices(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	limit := 100
+	offset := 0
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil {
+			limit = l
+		}
+	}
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if o, err := strconv.Atoi(offsetStr); err == nil {
+			offset = o
+		}
+	}
+
+	invoices, err := h.invoiceService.ListInvoices(ctx, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get invoices"})
+		return
+	}
+
+	c.JSON(http.StatusOK, invoices)
+}
+
+// GetInvoice returns the invoice as JSON, or the document with
+// ?format=pdf|html.
+func (h *InvoiceHandler) GetInvoice(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invoice ID"})
+		return
+	}
+
+	invoice, err := h.invoiceService.GetInvoice(ctx, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+		return
+	}
+
+	if c.Query("format") == "" {
+		c.JSON(http.StatusOK, invoice)
+		return
+	}
+
+	writeInvoice(c, invoice)
+}
+
+func (h *InvoiceHandler) CreateConsolidatedInvoice(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var request domain.ConsolidatedInvoiceRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "msg": err.Error()})
+		return
+	}
+
+	invoice, err := h.invoiceService.CreateConsolidatedInvoice(ctx, &request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, invoice)
+}
+
+func writeInvoice(c *gin.Context, invoice *domain.Invoice) {
+	switch c.DefaultQuery("format", "pdf") {
+	case "json":
+		c.JSON(http.StatusOK, invoice)
+	case "html":
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(invoice.HTML))
+	case "pdf":
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pdf"`, invoice.Number))
+		c.Data(http.StatusOK, "application/pdf", invoice.PDF)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format, expected pdf, html or json"})
+	}
+}
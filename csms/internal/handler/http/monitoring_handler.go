@@ -0,0 +1,67 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+type MonitoringHandler struct {
+	monitoringService domain.MonitoringService
+}
+
+func NewMonitoringHandler(monitoringService domain.MonitoringService) *MonitoringHandler {
+	return &MonitoringHandler{
+		monitoringService: monitoringService,
+	}
+}
+
+func (h *MonitoringHandler) GetSystemStatus(c *gin.Context) {
+	status, err := h.monitoringService.GetSystemStatus(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get system status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+func (h *MonitoringHandler) GetChargePointMetrics(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid charge point ID"})
+		return
+	}
+
+	metrics, err := h.monitoringService.GetChargePointMetrics(ctx, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, metrics)
+}
+
+func (h *MonitoringHandler) GetTransactionMetrics(c *gin.Context) {
+	metrics, err := h.monitoringService.GetTransactionMetrics(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get transaction metrics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, metrics)
+}
+
+func (h *MonitoringHandler) GetUserMetrics(c *gin.Context) {
+	metrics, err := h.monitoringService.GetUserMetrics(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user metrics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, metrics)
+}
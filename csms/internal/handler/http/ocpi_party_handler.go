@@ -0,0 +1,81 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+type OCPIPartyHandler struct {
+	ocpiService domain.OCPIService
+}
+
+func NewOCPIPartyHandler(ocpiService domain.OCPIService) *OCPIPartyHandler {
+	return &OCPIPartyHandler{
+		ocpiService: ocpiService,
+	}
+}
+
+func (h *OCPIPartyHandler) GetParties(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	limit := 100
+	offset := 0
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil {
+			limit = l
+		}
+	}
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if o, err := strconv.Atoi(offsetStr); err == nil {
+			offset = o
+		}
+	}
+
+	parties, err := h.ocpiService.ListParties(ctx, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get parties"})
+		return
+	}
+
+	c.JSON(http.StatusOK, parties)
+}
+
+// CreateParty registers a roaming partner. The response holds the token A to
+// hand to the partner; it is not shown again.
+func (h *OCPIPartyHandler) CreateParty(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var request domain.OCPIPartyRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "msg": err.Error()})
+		return
+	}
+
+	registration, err := h.ocpiService.CreateParty(ctx, &request)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create party"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, registration)
+}
+
+func (h *OCPIPartyHandler) DeleteParty(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid party ID"})
+		return
+	}
+
+	if err := h.ocpiService.DeleteParty(ctx, uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Party deleted successfully"})
+}
@@ -0,0 +1,135 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+// PaymentSignatureHeader carries the provider's webhook signature.
+const PaymentSignatureHeader = "X-Payment-Signature"
+
+type PaymentHandler struct {
+	paymentService domain.PaymentService
+}
+
+func NewPaymentHandler(paymentService domain.PaymentService) *PaymentHandler {
+	return &PaymentHandler{
+		paymentService: paymentService,
+	}
+}
+
+// StartPaidSession pre-authorizes the card and starts charging. Drivers can
+// only use their own id tags.
+func (h *PaymentHandler) StartPaidSession(c *gin.Context) {
+	ctx := c.Request.Context()
+	user := c.MustGet("user").(*domain.User)
+
+	var request domain.PaidSessionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "msg": err.Error()})
+		return
+	}
+
+	var userID *uint
+	if user.Role != "admin" {
+		userID = &user.ID
+	}
+
+	payment, err := h.paymentService.StartPaidSession(ctx, &request, userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, payment)
+}
+
+func (h *PaymentHandler) GetPayments(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	limit := 100
+	offset := 0
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil {
+			limit = l
+		}
+	}
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if o, err := strconv.Atoi(offsetStr); err == nil {
+			offset = o
+		}
+	}
+
+	payments, err := h.paymentService.ListPayments(ctx, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get payments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, payments)
+}
+
+func (h *PaymentHandler) GetPayment(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment ID"})
+		return
+	}
+
+	payment, err := h.paymentService.GetPayment(ctx, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, payment)
+}
+
+func (h *PaymentHandler) RefundPayment(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payment ID"})
+		return
+	}
+
+	var request domain.RefundRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "msg": err.Error()})
+		return
+	}
+
+	payment, err := h.paymentService.RefundPayment(ctx, uint(id), &request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, payment)
+}
+
+// HandleWebhook receives provider notifications. It is not behind the auth
+// middleware; the payload signature is verified instead.
+func (h *PaymentHandler) HandleWebhook(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	payload, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if err := h.paymentService.HandleWebhook(ctx, payload, c.GetHeader(PaymentSignatureHeader)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
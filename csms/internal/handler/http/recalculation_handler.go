@@ -0,0 +1,60 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+type RecalculationHandler struct {
+	recalculationService domain.CostRecalculationService
+}
+
+func NewRecalculationHandler(recalculationService domain.CostRecalculationService) *RecalculationHandler {
+	return &RecalculationHandler{
+		recalculationService: recalculationService,
+	}
+}
+
+// RecalculateCosts reprices completed transactions. It is a dry run returning
+// the diff report unless the body sets "apply": true.
+func (h *RecalculationHandler) RecalculateCosts(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var request domain.RecalculationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "msg": err.Error()})
+		return
+	}
+
+	user := c.MustGet("user").(*domain.User)
+
+	report, err := h.recalculationService.RecalculateCosts(ctx, &request, &user.ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func (h *RecalculationHandler) GetTransactionAdjustments(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction ID"})
+		return
+	}
+
+	adjustments, err := h.recalculationService.ListAdjustments(ctx, uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get transaction adjustments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, adjustments)
+}
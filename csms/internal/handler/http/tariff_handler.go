@@ -0,0 +1,156 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+type TariffHandler struct {
+	tariffService      domain.TariffService
+	transactionService domain.TransactionService
+}
+
+func NewTariffHandler(tariffService domain.TariffService, transactionService domain.TransactionService) *TariffHandler {
+	return &TariffHandler{
+		tariffService:      tariffService,
+		transactionService: transactionService,
+	}
+}
+
+func (h *TariffHandler) GetTariffs(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	limit := 100
+	offset := 0
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil {
+			limit = l
+		}
+	}
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if o, err := strconv.Atoi(offsetStr); err == nil {
+			offset = o
+		}
+	}
+
+	tariffs, err := h.tariffService.ListTariffs(ctx, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get tariffs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tariffs)
+}
+
+func (h *TariffHandler) GetTariff(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tariff ID"})
+		return
+	}
+
+	tariff, err := h.tariffService.GetTariff(ctx, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tariff not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tariff)
+}
+
+func (h *TariffHandler) CreateTariff(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var tariff domain.Tariff
+	if err := c.ShouldBindJSON(&tariff); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "msg": err.Error()})
+		return
+	}
+
+	err := h.tariffService.CreateTariff(ctx, &tariff)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, tariff)
+}
+
+func (h *TariffHandler) UpdateTariff(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tariff ID"})
+		return
+	}
+
+	var tariff domain.Tariff
+	if err := c.ShouldBindJSON(&tariff); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	tariff.ID = uint(id)
+	err = h.tariffService.UpdateTariff(ctx, &tariff)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tariff)
+}
+
+func (h *TariffHandler) DeleteTariff(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tariff ID"})
+		return
+	}
+
+	err = h.tariffService.DeleteTariff(ctx, uint(id))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tariff deleted successfully"})
+}
+
+// GetTransactionPrice returns the cost breakdown of a transaction, priced up
+// to now while it is still running.
+func (h *TariffHandler) GetTransactionPrice(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction ID"})
+		return
+	}
+
+	transaction, err := h.transactionService.GetTransaction(ctx, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return
+	}
+
+	breakdown, err := h.tariffService.PriceTransaction(ctx, transaction, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to price transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, breakdown)
+}
@@ -1,8 +1,10 @@
 package http
 
 import (
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/malikkhoiri/csms/internal/domain"
@@ -10,11 +12,13 @@ import (
 
 type TransactionHandler struct {
 	transactionService domain.TransactionService
+	transactionFeed    domain.TransactionFeed
 }
 
-func NewTransactionHandler(transactionService domain.TransactionService) *TransactionHandler {
+func NewTransactionHandler(transactionService domain.TransactionService, transactionFeed domain.TransactionFeed) *TransactionHandler {
 	return &TransactionHandler{
 		transactionService: transactionService,
+		transactionFeed:    transactionFeed,
 	}
 }
 
@@ -61,3 +65,112 @@ func (h *TransactionHandler) GetTransaction(c *gin.Context) {
 
 	c.JSON(http.StatusOK, transaction)
 }
+
+func (h *TransactionHandler) ForceCloseTransaction(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction ID"})
+		return
+	}
+
+	var request struct {
+		MeterStop *float64 `json:"meterStop" binding:"required"`
+		Reason    string   `json:"reason" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	user := c.MustGet("user").(*domain.User)
+
+	transaction, err := h.transactionService.ForceCloseTransaction(ctx, uint(id), *request.MeterStop, request.Reason, user.ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, transaction)
+}
+
+func (h *TransactionHandler) GetTransactionAudits(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction ID"})
+		return
+	}
+
+	audits, err := h.transactionService.ListTransactionAudits(ctx, uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get transaction audit trail"})
+		return
+	}
+
+	c.JSON(http.StatusOK, audits)
+}
+
+// StreamTransaction pushes the transaction as server-sent events: its current
+// state first, then every update with the running cost until it completes.
+func (h *TransactionHandler) StreamTransaction(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transaction ID"})
+		return
+	}
+
+	updates, unsubscribe := h.transactionFeed.SubscribeTransaction(uint(id))
+	defer unsubscribe()
+
+	transaction, err := h.transactionService.GetTransaction(ctx, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.SSEvent("transaction", transaction)
+	c.Writer.Flush()
+
+	if transactionEnded(transaction.Status) {
+		return
+	}
+
+	keepAlive := time.NewTicker(30 * time.Second)
+	defer keepAlive.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-keepAlive.C:
+			c.SSEvent("ping", time.Now().UTC())
+			return true
+		case update := <-updates:
+			c.SSEvent("transaction", update)
+			return !transactionEnded(update.Status)
+		}
+	})
+}
+
+// transactionEnded reports whether a transaction no longer changes on its
+// own, so its stream can end. A stuck transaction only moves on by operator
+// action or when the station speaks up again.
+func transactionEnded(status string) bool {
+	switch status {
+	case domain.TransactionStatusCompleted, domain.TransactionStatusCancelled,
+		domain.TransactionStatusFailed, domain.TransactionStatusStuck:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,495 @@
+package ocpi
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+// OCPI status codes used in response envelopes.
+const (
+	statusSuccess         = 1000
+	statusClientError     = 2000
+	statusInvalidParams   = 2001
+	statusUnknownLocation = 2003
+	statusUnknownToken    = 2004
+	statusServerError     = 3000
+	statusClientAPIError  = 3001
+)
+
+const (
+	defaultLimit = 50
+	maxLimit     = 100
+)
+
+// Handler serves the OCPI 2.2.1 CPO interface: versions, credentials, the
+// Locations, Sessions, CDRs and Tariffs sender modules and the Tokens
+// receiver module.
+type Handler struct {
+	ocpiService         domain.OCPIService
+	roamingTokenService domain.RoamingTokenService
+}
+
+func NewHandler(ocpiService domain.OCPIService, roamingTokenService domain.RoamingTokenService) *Handler {
+	return &Handler{
+		ocpiService:         ocpiService,
+		roamingTokenService: roamingTokenService,
+	}
+}
+
+// response is the envelope around every OCPI response.
+type response struct {
+	Data          interface{} `json:"data,omitempty"`
+	StatusCode    int         `json:"status_code"`
+	StatusMessage string      `json:"status_message,omitempty"`
+	Timestamp     time.Time   `json:"timestamp"`
+}
+
+func success(c *gin.Context, data interface{}) {
+	c.JSON(http.StatusOK, response{
+		Data:          data,
+		StatusCode:    statusSuccess,
+		StatusMessage: "Success",
+		Timestamp:     time.Now().UTC(),
+	})
+}
+
+func failure(c *gin.Context, httpStatus, statusCode int, message string) {
+	c.AbortWithStatusJSON(httpStatus, response{
+		StatusCode:    statusCode,
+		StatusMessage: message,
+		Timestamp:     time.Now().UTC(),
+	})
+}
+
+func SetupRoutes(router *gin.Engine, ocpiService domain.OCPIService, roamingTokenService domain.RoamingTokenService) {
+	handler := NewHandler(ocpiService, roamingTokenService)
+
+	root := router.Group("/ocpi")
+	root.Use(correlationHeaders(), handler.authenticate)
+	{
+		root.GET("/versions", handler.GetVersions)
+
+		version := root.Group("/" + domain.OCPIVersion221)
+		{
+			version.GET("", handler.GetVersionDetails)
+			version.GET("/credentials", handler.GetCredentials)
+			version.POST("/credentials", handler.PostCredentials)
+			version.PUT("/credentials", handler.PutCredentials)
+			version.DELETE("/credentials", handler.DeleteCredentials)
+
+			modules := version.Group("")
+			modules.Use(registered)
+			{
+				modules.GET("/locations", handler.GetLocations)
+				modules.GET("/locations/:location_id", handler.GetLocation)
+				modules.GET("/locations/:location_id/:evse_uid", handler.GetEVSE)
+				modules.GET("/locations/:location_id/:evse_uid/:connector_id", handler.GetConnector)
+				modules.GET("/sessions", handler.GetSessions)
+				modules.GET("/cdrs", handler.GetCDRs)
+				modules.GET("/tariffs", handler.GetTariffs)
+				modules.GET("/tokens/:country_code/:party_id/:uid", handler.GetToken)
+				modules.PUT("/tokens/:country_code/:party_id/:uid", handler.PutToken)
+				modules.PATCH("/tokens/:country_code/:party_id/:uid", handler.PatchToken)
+			}
+		}
+	}
+}
+
+// correlationHeaders echoes the request and correlation IDs, as OCPI
+// requires.
+func correlationHeaders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, header := range []string{"X-Request-ID", "X-Correlation-ID"} {
+			if value := c.GetHeader(header); value != "" {
+				c.Header(header, value)
+			}
+		}
+		c.Next()
+	}
+}
+
+// authenticate resolves the calling party from the "Token" authorization
+// header. OCPI 2.2.1 sends the token base64 encoded; raw tokens from 2.1
+// style clients are accepted as well.
+func (h *Handler) authenticate(c *gin.Context) {
+	header := c.GetHeader("Authorization")
+	token, ok := strings.CutPrefix(header, "Token ")
+	if !ok || token == "" {
+		failure(c, http.StatusUnauthorized, statusClientError, "Authorization header required")
+		return
+	}
+
+	candidates := []string{token}
+	if decoded, err := base64.StdEncoding.DecodeString(token); err == nil {
+		candidates = append([]string{string(decoded)}, candidates...)
+	}
+
+	for _, candidate := range candidates {
+		if party, err := h.ocpiService.Authenticate(c.Request.Context(), candidate); err == nil {
+			c.Set("party", party)
+			c.Next()
+			return
+		}
+	}
+
+	failure(c, http.StatusUnauthorized, statusClientError, "Invalid token")
+}
+
+// registered only lets parties that completed the credentials handshake
+// through.
+func registered(c *gin.Context) {
+	if party(c).Status != domain.OCPIPartyStatusRegistered {
+		failure(c, http.StatusForbidden, statusClientError, "Credentials handshake not completed")
+		return
+	}
+	c.Next()
+}
+
+func party(c *gin.Context) *domain.OCPIParty {
+	return c.MustGet("party").(*domain.OCPIParty)
+}
+
+func (h *Handler) GetVersions(c *gin.Context) {
+	success(c, h.ocpiService.Versions())
+}
+
+func (h *Handler) GetVersionDetails(c *gin.Context) {
+	success(c, h.ocpiService.VersionDetails())
+}
+
+func (h *Handler) GetCredentials(c *gin.Context) {
+	success(c, h.ocpiService.Credentials(party(c)))
+}
+
+// PostCredentials completes the handshake started with token A.
+func (h *Handler) PostCredentials(c *gin.Context) {
+	if party(c).Status == domain.OCPIPartyStatusRegistered {
+		failure(c, http.StatusMethodNotAllowed, statusClientError, "Already registered, use PUT to update credentials")
+		return
+	}
+	h.registerCredentials(c)
+}
+
+// PutCredentials renews the credentials of a registered party.
+func (h *Handler) PutCredentials(c *gin.Context) {
+	if party(c).Status != domain.OCPIPartyStatusRegistered {
+		failure(c, http.StatusMethodNotAllowed, statusClientError, "Not registered, use POST to register")
+		return
+	}
+	h.registerCredentials(c)
+}
+
+func (h *Handler) registerCredentials(c *gin.Context) {
+	var credentials domain.OCPICredentials
+	if err := c.ShouldBindJSON(&credentials); err != nil {
+		failure(c, http.StatusBadRequest, statusInvalidParams, "Invalid credentials object")
+		return
+	}
+
+	result, err := h.ocpiService.RegisterCredentials(c.Request.Context(), party(c), &credentials)
+	if err != nil {
+		failure(c, http.StatusBadRequest, statusClientAPIError, err.Error())
+		return
+	}
+
+	success(c, result)
+}
+
+func (h *Handler) DeleteCredentials(c *gin.Context) {
+	if party(c).Status != domain.OCPIPartyStatusRegistered {
+		failure(c, http.StatusMethodNotAllowed, statusClientError, "Not registered")
+		return
+	}
+
+	if err := h.ocpiService.UnregisterCredentials(c.Request.Context(), party(c)); err != nil {
+		failure(c, http.StatusInternalServerError, statusServerError, "Failed to delete credentials")
+		return
+	}
+
+	success(c, nil)
+}
+
+func (h *Handler) GetLocations(c *gin.Context) {
+	query, ok := parseQuery(c)
+	if !ok {
+		return
+	}
+
+	locations, total, err := h.ocpiService.ListLocations(c.Request.Context(), query)
+	if err != nil {
+		failure(c, http.StatusInternalServerError, statusServerError, "Failed to get locations")
+		return
+	}
+
+	paginate(c, query, total)
+	success(c, locations)
+}
+
+func (h *Handler) GetLocation(c *gin.Context) {
+	location, ok := h.location(c)
+	if !ok {
+		return
+	}
+
+	success(c, location)
+}
+
+func (h *Handler) GetEVSE(c *gin.Context) {
+	location, ok := h.location(c)
+	if !ok {
+		return
+	}
+
+	evse := findEVSE(location, c.Param("evse_uid"))
+	if evse == nil {
+		failure(c, http.StatusNotFound, statusUnknownLocation, "Unknown EVSE")
+		return
+	}
+
+	success(c, evse)
+}
+
+func (h *Handler) GetConnector(c *gin.Context) {
+	location, ok := h.location(c)
+	if !ok {
+		return
+	}
+
+	evse := findEVSE(location, c.Param("evse_uid"))
+	if evse == nil {
+		failure(c, http.StatusNotFound, statusUnknownLocation, "Unknown EVSE")
+		return
+	}
+
+	for _, connector := range evse.Connectors {
+		if connector.ID == c.Param("connector_id") {
+			success(c, connector)
+			return
+		}
+	}
+
+	failure(c, http.StatusNotFound, statusUnknownLocation, "Unknown connector")
+}
+
+func (h *Handler) location(c *gin.Context) (*domain.OCPILocation, bool) {
+	location, err := h.ocpiService.GetLocation(c.Request.Context(), c.Param("location_id"))
+	if err != nil {
+		failure(c, http.StatusNotFound, statusUnknownLocation, "Unknown location")
+		return nil, false
+	}
+	return location, true
+}
+
+func findEVSE(location *domain.OCPILocation, uid string) *domain.OCPIEVSE {
+	for i := range location.EVSEs {
+		if location.EVSEs[i].UID == uid {
+			return &location.EVSEs[i]
+		}
+	}
+	return nil
+}
+
+func (h *Handler) GetSessions(c *gin.Context) {
+	query, ok := parseQuery(c)
+	if !ok {
+		return
+	}
+
+	sessions, total, err := h.ocpiService.ListSessions(c.Request.Context(), party(c), query)
+	if err != nil {
+		failure(c, http.StatusInternalServerError, statusServerError, "Failed to get sessions")
+		return
+	}
+
+	paginate(c, query, total)
+	success(c, sessions)
+}
+
+func (h *Handler) GetCDRs(c *gin.Context) {
+	query, ok := parseQuery(c)
+	if !ok {
+		return
+	}
+
+	cdrs, total, err := h.ocpiService.ListCDRs(c.Request.Context(), party(c), query)
+	if err != nil {
+		failure(c, http.StatusInternalServerError, statusServerError, "Failed to get CDRs")
+		return
+	}
+
+	paginate(c, query, total)
+	success(c, cdrs)
+}
+
+func (h *Handler) GetTariffs(c *gin.Context) {
+	query, ok := parseQuery(c)
+	if !ok {
+		return
+	}
+
+	tariffs, total, err := h.ocpiService.ListTariffs(c.Request.Context(), query)
+	if err != nil {
+		failure(c, http.StatusInternalServerError, statusServerError, "Failed to get tariffs")
+		return
+	}
+
+	paginate(c, query, total)
+	success(c, tariffs)
+}
+
+func (h *Handler) GetToken(c *gin.Context) {
+	if !ownsTokenPath(c) {
+		return
+	}
+
+	token, err := h.roamingTokenService.GetToken(c.Request.Context(), c.Param("country_code"), c.Param("party_id"), c.Param("uid"), tokenType(c))
+	if err != nil {
+		failure(c, http.StatusNotFound, statusUnknownToken, "Unknown token")
+		return
+	}
+
+	success(c, token)
+}
+
+// PutToken stores a token pushed by the eMSP. The path identifies the
+// token; the same fields in the body are ignored.
+func (h *Handler) PutToken(c *gin.Context) {
+	if !ownsTokenPath(c) {
+		return
+	}
+
+	var token domain.OCPIToken
+	if err := c.ShouldBindJSON(&token); err != nil {
+		failure(c, http.StatusBadRequest, statusInvalidParams, "Invalid token object")
+		return
+	}
+
+	h.saveToken(c, &token)
+}
+
+// PatchToken applies the fields in the body to a stored token.
+func (h *Handler) PatchToken(c *gin.Context) {
+	if !ownsTokenPath(c) {
+		return
+	}
+
+	token, err := h.roamingTokenService.GetToken(c.Request.Context(), c.Param("country_code"), c.Param("party_id"), c.Param("uid"), tokenType(c))
+	if err != nil {
+		failure(c, http.StatusNotFound, statusUnknownToken, "Unknown token")
+		return
+	}
+
+	if err := c.ShouldBindJSON(token); err != nil {
+		failure(c, http.StatusBadRequest, statusInvalidParams, "Invalid token object")
+		return
+	}
+
+	h.saveToken(c, token)
+}
+
+func (h *Handler) saveToken(c *gin.Context, token *domain.OCPIToken) {
+	token.CountryCode = c.Param("country_code")
+	token.PartyID = c.Param("party_id")
+	token.UID = c.Param("uid")
+	token.Type = tokenType(c)
+
+	if err := h.roamingTokenService.PutToken(c.Request.Context(), party(c), token); err != nil {
+		failure(c, http.StatusBadRequest, statusInvalidParams, err.Error())
+		return
+	}
+
+	success(c, nil)
+}
+
+// ownsTokenPath checks that the token path names the calling party, which
+// may only read and store its own tokens.
+func ownsTokenPath(c *gin.Context) bool {
+	caller := party(c)
+	if !strings.EqualFold(c.Param("country_code"), caller.CountryCode) || !strings.EqualFold(c.Param("party_id"), caller.PartyID) {
+		failure(c, http.StatusNotFound, statusInvalidParams, "Token path does not match the authenticated party")
+		return false
+	}
+	return true
+}
+
+// tokenType reads the token type parameter, which defaults to RFID.
+func tokenType(c *gin.Context) string {
+	return c.DefaultQuery("type", "RFID")
+}
+
+// parseQuery reads the paging parameters of a list request. Limits above
+// the maximum are lowered to it, as the specification allows.
+func parseQuery(c *gin.Context) (domain.OCPIQuery, bool) {
+	query := domain.OCPIQuery{Limit: defaultLimit}
+
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		offset, err := strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			failure(c, http.StatusBadRequest, statusInvalidParams, "Invalid offset")
+			return query, false
+		}
+		query.Offset = offset
+	}
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 {
+			failure(c, http.StatusBadRequest, statusInvalidParams, "Invalid limit")
+			return query, false
+		}
+		query.Limit = min(limit, maxLimit)
+	}
+
+	for param, target := range map[string]**time.Time{"date_from": &query.DateFrom, "date_to": &query.DateTo} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t, err = time.Parse("2006-01-02T15:04:05", value)
+		}
+		if err != nil {
+			failure(c, http.StatusBadRequest, statusInvalidParams, "Invalid "+param)
+			return query, false
+		}
+		*target = &t
+	}
+
+	return query, true
+}
+
+// paginate sets the paging headers and, when there are more objects, a Link
+// to the next page.
+func paginate(c *gin.Context, query domain.OCPIQuery, total int64) {
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	c.Header("X-Limit", strconv.Itoa(query.Limit))
+
+	next := query.Offset + query.Limit
+	if int64(next) >= total {
+		return
+	}
+
+	params := url.Values{}
+	params.Set("offset", strconv.Itoa(next))
+	params.Set("limit", strconv.Itoa(query.Limit))
+	if query.DateFrom != nil {
+		params.Set("date_from", query.DateFrom.Format(time.RFC3339))
+	}
+	if query.DateTo != nil {
+		params.Set("date_to", query.DateTo.Format(time.RFC3339))
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	c.Header("Link", fmt.Sprintf(`<%s://%s%s?%s>; rel="next"`, scheme, c.Request.Host, c.Request.URL.Path, params.Encode()))
+}
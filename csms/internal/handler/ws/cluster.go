@@ -0,0 +1,256 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+const (
+	clusterCall   = "call"
+	clusterResult = "result"
+)
+
+// remoteCallMargin is how much longer than the charge point a node waits for
+// the node holding its socket, which times out first.
+const remoteCallMargin = 5 * time.Second
+
+// clusterMessage travels between nodes: a call for a charge point held by
+// the receiving node, or the result of one.
+type clusterMessage struct {
+	Type            string          `json:"type"`
+	RequestID       string          `json:"requestId"`
+	From            string          `json:"from"`
+	ChargePointCode string          `json:"chargePointCode,omitempty"`
+	Action          string          `json:"action,omitempty"`
+	Payload         json.RawMessage `json:"payload,omitempty"`
+	Error           string          `json:"error,omitempty"`
+}
+
+// Run keeps this node in the cluster until the context is cancelled: it
+// answers the calls routed to it, heartbeats its connections and records
+// offline the charge points of nodes that went away. A single instance only
+// records offline the charge points left online by its previous run.
+func (m *ConnectionManager) Run(ctx context.Context) {
+	if m.directory == nil {
+		m.releaseStale(ctx, m.startedAt)
+		return
+	}
+
+	log.Printf("Joining the cluster as node %s", m.nodeID)
+	defer m.leave()
+
+	for {
+		if err := m.serve(ctx); err != nil {
+			log.Printf("Cluster node %s: %v", m.nodeID, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(m.heartbeatInterval):
+		}
+	}
+}
+
+// serve subscribes to the node's channel and handles its messages until the
+// subscription ends.
+func (m *ConnectionManager) serve(ctx context.Context) error {
+	messages, err := m.directory.Subscribe(ctx, m.nodeID)
+	if err != nil {
+		return fmt.Errorf("subscribing: %w", err)
+	}
+
+	m.heartbeat(ctx)
+	ticker := time.NewTicker(m.heartbeatInterval)
+	defer ticker.Stop()
+	// Entries of a node that went away expire after the node TTL; its
+	// charge points are then held by no node.
+	release := time.NewTicker(m.nodeTTL)
+	defer release.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			m.heartbeat(ctx)
+		case <-release.C:
+			m.releaseStale(ctx, time.Now().Add(-m.nodeTTL))
+		case message, ok := <-messages:
+			if !ok {
+				return errors.New("subscription closed")
+			}
+			m.handleClusterMessage(ctx, message)
+		}
+	}
+}
+
+func (m *ConnectionManager) heartbeat(ctx context.Context) {
+	if err := m.directory.Heartbeat(ctx, m.nodeID, m.connectedCodes()); err != nil {
+		log.Printf("Error heartbeating node %s: %v", m.nodeID, err)
+	}
+}
+
+// leave drops the node's entries so other nodes stop routing to it before
+// they would expire.
+func (m *ConnectionManager) leave() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := m.directory.Leave(ctx, m.nodeID, m.connectedCodes()); err != nil {
+		log.Printf("Error leaving the cluster: %v", err)
+	}
+}
+
+func (m *ConnectionManager) connectedCodes() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	codes := make([]string, 0, len(m.connections))
+	for code := range m.connections {
+		codes = append(codes, code)
+	}
+	return codes
+}
+
+func (m *ConnectionManager) handleClusterMessage(ctx context.Context, raw []byte) {
+	var message clusterMessage
+	if err := json.Unmarshal(raw, &message); err != nil {
+		log.Printf("Malformed cluster message: %v", err)
+		return
+	}
+
+	switch message.Type {
+	case clusterCall:
+		go m.answerRemoteCall(ctx, message)
+	case clusterResult:
+		m.remoteMu.Lock()
+		results, ok := m.remote[message.RequestID]
+		m.remoteMu.Unlock()
+		if ok {
+			select {
+			case results <- message:
+			default:
+			}
+		}
+	}
+}
+
+// answerRemoteCall sends a call routed from another node to the charge point
+// and publishes its answer back. It never routes further, so a stale
+// directory entry cannot bounce a call between nodes.
+func (m *ConnectionManager) answerRemoteCall(ctx context.Context, message clusterMessage) {
+	result := clusterMessage{
+		Type:      clusterResult,
+		RequestID: message.RequestID,
+		From:      m.nodeID,
+	}
+
+	m.mu.RLock()
+	cpConn := m.connections[message.ChargePointCode]
+	m.mu.RUnlock()
+
+	if cpConn == nil {
+		result.Error = errNotConnected.Error()
+	} else if payload, err := m.callLocal(ctx, cpConn, message.Action, message.Payload); err != nil {
+		result.Error = err.Error()
+	} else {
+		result.Payload = payload
+	}
+
+	raw, err := json.Marshal(result)
+	if err != nil {
+		log.Printf("Error encoding cluster result: %v", err)
+		return
+	}
+	if err := m.directory.Publish(ctx, message.From, raw); err != nil {
+		log.Printf("Error returning %s result to node %s: %v", message.Action, message.From, err)
+	}
+}
+
+// callRemote routes a call to the node holding the charge point's socket and
+// waits for its result.
+func (m *ConnectionManager) callRemote(ctx context.Context, cpCode, action string, payload interface{}) (json.RawMessage, error) {
+	if m.directory == nil {
+		return nil, errNotConnected
+	}
+
+	owner, err := m.directory.Owner(ctx, cpCode)
+	if err != nil {
+		return nil, fmt.Errorf("looking up %s in the connection directory: %w", cpCode, err)
+	}
+	if owner == "" || owner == m.nodeID {
+		return nil, errNotConnected
+	}
+
+	rawPayload, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	requestID, err := newMessageID()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(clusterMessage{
+		Type:            clusterCall,
+		RequestID:       requestID,
+		From:            m.nodeID,
+		ChargePointCode: cpCode,
+		Action:          action,
+		Payload:         rawPayload,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(chan clusterMessage, 1)
+	m.remoteMu.Lock()
+	m.remote[requestID] = results
+	m.remoteMu.Unlock()
+
+	defer func() {
+		m.remoteMu.Lock()
+		delete(m.remote, requestID)
+		m.remoteMu.Unlock()
+	}()
+
+	if err := m.directory.Publish(ctx, owner, raw); err != nil {
+		return nil, fmt.Errorf("routing %s to node %s: %w", action, owner, err)
+	}
+	log.Printf("OCPP CALL for %s routed to node %s: Action=%s", cpCode, owner, action)
+
+	timeout := time.NewTimer(m.callTimeout + remoteCallMargin)
+	defer timeout.Stop()
+
+	select {
+	case result := <-results:
+		switch {
+		case result.Error == errNotConnected.Error():
+			return nil, errNotConnected
+		case result.Error != "":
+			return nil, errors.New(result.Error)
+		}
+		return result.Payload, nil
+	case <-timeout.C:
+		return nil, fmt.Errorf("%s timed out waiting for node %s", action, owner)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func newNodeID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "csms"
+	}
+	suffix, err := newMessageID()
+	if err != nil {
+		return hostname
+	}
+	return hostname + "-" + suffix[:8]
+}
@@ -0,0 +1,218 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/malikkhoiri/csms/internal/config"
+	"github.com/malikkhoiri/csms/internal/domain"
+	"github.com/malikkhoiri/csms/internal/infrastructure/cluster"
+)
+
+const testNodeTTL = 100 * time.Millisecond
+
+// fakeChargePointService records the connection state the nodes report.
+type fakeChargePointService struct {
+	domain.ChargePointService
+
+	mu     sync.Mutex
+	codes  map[uint]string
+	online map[string]bool
+}
+
+func newFakeChargePointService(codes map[uint]string) *fakeChargePointService {
+	return &fakeChargePointService{codes: codes, online: make(map[string]bool)}
+}
+
+func (s *fakeChargePointService) GetChargePoint(ctx context.Context, id uint) (*domain.ChargePoint, error) {
+	code, ok := s.codes[id]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return &domain.ChargePoint{ID: id, ChargePointCode: code}, nil
+}
+
+func (s *fakeChargePointService) UpdateConnection(ctx context.Context, chargePointCode string, online bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.online[chargePointCode] = online
+	return nil
+}
+
+func (s *fakeChargePointService) ListOnlineSince(ctx context.Context, before time.Time) ([]domain.ChargePoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var chargePoints []domain.ChargePoint
+	for code, online := range s.online {
+		if online {
+			chargePoints = append(chargePoints, domain.ChargePoint{ChargePointCode: code})
+		}
+	}
+	return chargePoints, nil
+}
+
+func (s *fakeChargePointService) isOnline(code string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.online[code]
+}
+
+func newTestNode(nodeID string, service domain.ChargePointService, directory domain.ConnectionDirectory) *ConnectionManager {
+	return NewConnectionManager(service, directory,
+		config.OCPPConfig{CallTimeout: time.Second},
+		config.ClusterConfig{NodeID: nodeID, HeartbeatInterval: testNodeTTL / 4, NodeTTL: testNodeTTL},
+	)
+}
+
+// connectChargePoint connects a simulated charge point to the node. It
+// accepts every call sent to it.
+func connectChargePoint(t *testing.T, node *ConnectionManager, code string) {
+	t.Helper()
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		cpConn := node.register(code, conn)
+		defer node.unregister(cpConn)
+
+		for {
+			_, raw, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var message []json.RawMessage
+			if err := json.Unmarshal(raw, &message); err == nil {
+				node.handleResponse(cpConn, message)
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dialing: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	go func() {
+		for {
+			_, raw, err := client.ReadMessage()
+			if err != nil {
+				return
+			}
+			var call []json.RawMessage
+			if err := json.Unmarshal(raw, &call); err != nil || len(call) < 2 {
+				continue
+			}
+			reply, _ := json.Marshal([]interface{}{CallResult, call[1], map[string]string{"status": "Accepted"}})
+			client.WriteMessage(websocket.TextMessage, reply)
+		}
+	}()
+
+	waitFor(t, func() bool { return node.Count() > 0 })
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestClusterRoutesCallToOwner(t *testing.T) {
+	directory := cluster.NewMemoryDirectory(testNodeTTL)
+	service := newFakeChargePointService(map[uint]string{1: "CP1"})
+	nodeA := newTestNode("a", service, directory)
+	nodeB := newTestNode("b", service, directory)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go nodeA.Run(ctx)
+	go nodeB.Run(ctx)
+
+	connectChargePoint(t, nodeB, "CP1")
+
+	// The owner only answers once it is subscribed.
+	waitFor(t, func() bool {
+		return nodeA.RemoteStartTransaction(ctx, 1, 1, "TAG") == nil
+	})
+	if nodeA.Count() != 0 {
+		t.Errorf("node a holds %d connections, want 0", nodeA.Count())
+	}
+}
+
+func TestClusterReleasesDepartedNode(t *testing.T) {
+	directory := cluster.NewMemoryDirectory(testNodeTTL)
+	service := newFakeChargePointService(nil)
+	nodeA := newTestNode("a", service, directory)
+	nodeB := newTestNode("b", service, directory)
+
+	ctx := context.Background()
+	nodeA.heartbeat(ctx)
+	nodeB.heartbeat(ctx)
+	// Node b stops without unregistering its charge point.
+	nodeB.register("CP1", nil)
+
+	nodeA.releaseStale(ctx, time.Now())
+	if !service.isOnline("CP1") {
+		t.Fatal("charge point of a live node released")
+	}
+
+	time.Sleep(testNodeTTL + 10*time.Millisecond)
+	nodeA.heartbeat(ctx)
+	nodeA.releaseStale(ctx, time.Now())
+	if service.isOnline("CP1") {
+		t.Error("charge point of a departed node still online")
+	}
+}
+
+func TestClusterKeepsReconnectedChargePointOnline(t *testing.T) {
+	tests := []struct {
+		name    string
+		release bool
+	}{
+		{name: "old connection closes"},
+		{name: "old node releases stale charge points", release: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			directory := cluster.NewMemoryDirectory(testNodeTTL)
+			service := newFakeChargePointService(nil)
+			nodeA := newTestNode("a", service, directory)
+			nodeB := newTestNode("b", service, directory)
+
+			ctx := context.Background()
+			nodeA.heartbeat(ctx)
+			nodeB.heartbeat(ctx)
+
+			oldConn := nodeA.register("CP1", nil)
+			nodeB.register("CP1", nil)
+			nodeA.unregister(oldConn)
+			if tt.release {
+				nodeA.releaseStale(ctx, time.Now())
+			}
+
+			if !service.isOnline("CP1") {
+				t.Error("reconnected charge point recorded offline")
+			}
+		})
+	}
+}
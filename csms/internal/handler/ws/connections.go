@@ -0,0 +1,328 @@
+package ws
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/malikkhoiri/csms/internal/config"
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+const Call uint16 = 2
+
+var errNotConnected = errors.New("charge point is not connected")
+
+// chargePointConn is the websocket of a connected charge point. Writes are
+// serialized because CSMS-initiated calls are sent from other goroutines than
+// the one answering the charge point.
+type chargePointConn struct {
+	*websocket.Conn
+	code string
+
+	writeMu   sync.Mutex
+	pendingMu sync.Mutex
+	pending   map[string]chan callResponse
+
+	// reply is how the CALL being handled was answered. Only the goroutine
+	// reading the charge point's messages uses it.
+	reply string
+}
+
+type callResponse struct {
+	payload          json.RawMessage
+	errorCode        string
+	errorDescription string
+}
+
+func (c *chargePointConn) WriteMessage(messageType int, data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.Conn.WriteMessage(messageType, data)
+}
+
+// ConnectionManager keeps track of connected charge points and sends them
+// calls, matching the CALLRESULT or CALLERROR to the waiting caller. With a
+// directory it is one node of a cluster: calls for charge points connected to
+// other nodes are routed to them.
+type ConnectionManager struct {
+	mu                 sync.RWMutex
+	connections        map[string]*chargePointConn
+	chargePointService domain.ChargePointService
+	callTimeout        time.Duration
+
+	directory         domain.ConnectionDirectory
+	nodeID            string
+	heartbeatInterval time.Duration
+	nodeTTL           time.Duration
+	startedAt         time.Time
+	remoteMu          sync.Mutex
+	remote            map[string]chan clusterMessage
+}
+
+// NewConnectionManager creates a connection manager. Directory is nil when
+// the CSMS runs as a single instance.
+func NewConnectionManager(
+	chargePointService domain.ChargePointService,
+	directory domain.ConnectionDirectory,
+	ocppConfig config.OCPPConfig,
+	clusterConfig config.ClusterConfig,
+) *ConnectionManager {
+	nodeID := clusterConfig.NodeID
+	if nodeID == "" {
+		nodeID = newNodeID()
+	}
+
+	return &ConnectionManager{
+		connections:        make(map[string]*chargePointConn),
+		chargePointService: chargePointService,
+		callTimeout:        ocppConfig.CallTimeout,
+		directory:          directory,
+		nodeID:             nodeID,
+		heartbeatInterval:  clusterConfig.HeartbeatInterval,
+		nodeTTL:            clusterConfig.NodeTTL,
+		startedAt:          time.Now(),
+		remote:             make(map[string]chan clusterMessage),
+	}
+}
+
+// Count returns the number of charge points connected to this node.
+func (m *ConnectionManager) Count() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.connections)
+}
+
+// register tracks a new connection, replacing an older one of the same charge
+// point.
+func (m *ConnectionManager) register(code string, conn *websocket.Conn) *chargePointConn {
+	cpConn := &chargePointConn{
+		Conn:    conn,
+		code:    code,
+		pending: make(map[string]chan callResponse),
+	}
+
+	m.mu.Lock()
+	m.connections[code] = cpConn
+	m.mu.Unlock()
+
+	if m.directory != nil {
+		if err := m.directory.Register(context.Background(), code, m.nodeID); err != nil {
+			log.Printf("Error registering %s in the connection directory: %v", code, err)
+		}
+	}
+	m.updateConnection(code, true)
+
+	return cpConn
+}
+
+// unregister forgets a closed connection. The charge point is only recorded
+// offline when no newer connection replaced it, here or on another node.
+func (m *ConnectionManager) unregister(cpConn *chargePointConn) {
+	m.mu.Lock()
+	current := m.connections[cpConn.code] == cpConn
+	if current {
+		delete(m.connections, cpConn.code)
+	}
+	m.mu.Unlock()
+
+	if !current {
+		return
+	}
+	if m.directory != nil {
+		ctx := context.Background()
+		if err := m.directory.Unregister(ctx, cpConn.code, m.nodeID); err != nil {
+			log.Printf("Error unregistering %s from the connection directory: %v", cpConn.code, err)
+		}
+		if owner, err := m.directory.Owner(ctx, cpConn.code); err == nil && owner != "" && owner != m.nodeID {
+			return
+		}
+	}
+	m.updateConnection(cpConn.code, false)
+}
+
+// releaseStale records offline the charge points recorded online since
+// before that no node holds a connection of, such as those of a node that
+// stopped without unregistering them.
+func (m *ConnectionManager) releaseStale(ctx context.Context, before time.Time) {
+	chargePoints, err := m.chargePointService.ListOnlineSince(ctx, before)
+	if err != nil {
+		log.Printf("Error listing connected charge points: %v", err)
+		return
+	}
+
+	for _, chargePoint := range chargePoints {
+		code := chargePoint.ChargePointCode
+		m.mu.RLock()
+		local := m.connections[code] != nil
+		m.mu.RUnlock()
+		if local {
+			continue
+		}
+		if m.directory != nil {
+			owner, err := m.directory.Owner(ctx, code)
+			if err != nil || owner != "" {
+				continue
+			}
+		}
+		log.Printf("Charge point %s is recorded online but not connected to any node", code)
+		m.updateConnection(code, false)
+	}
+}
+
+func (m *ConnectionManager) updateConnection(code string, online bool) {
+	if err := m.chargePointService.UpdateConnection(context.Background(), code, online); err != nil {
+		log.Printf("Error recording connection state of %s: %v", code, err)
+	}
+}
+
+// call sends a CALL to the charge point and waits for its answer. Charge
+// points connected to another node are called through it.
+func (m *ConnectionManager) call(ctx context.Context, cpCode, action string, payload interface{}) (json.RawMessage, error) {
+	m.mu.RLock()
+	cpConn := m.connections[cpCode]
+	m.mu.RUnlock()
+	if cpConn == nil {
+		return m.callRemote(ctx, cpCode, action, payload)
+	}
+
+	return m.callLocal(ctx, cpConn, action, payload)
+}
+
+// callLocal sends a CALL over a socket held by this node.
+func (m *ConnectionManager) callLocal(ctx context.Context, cpConn *chargePointConn, action string, payload interface{}) (json.RawMessage, error) {
+	cpCode := cpConn.code
+
+	messageID, err := newMessageID()
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make(chan callResponse, 1)
+	cpConn.pendingMu.Lock()
+	cpConn.pending[messageID] = responses
+	cpConn.pendingMu.Unlock()
+
+	defer func() {
+		cpConn.pendingMu.Lock()
+		delete(cpConn.pending, messageID)
+		cpConn.pendingMu.Unlock()
+	}()
+
+	message, err := json.Marshal([]interface{}{Call, messageID, action, payload})
+	if err != nil {
+		return nil, err
+	}
+	if err := cpConn.WriteMessage(websocket.TextMessage, message); err != nil {
+		return nil, err
+	}
+	log.Printf("OCPP CALL sent to %s: ID=%s, Action=%s", cpCode, messageID, action)
+
+	timeout := time.NewTimer(m.callTimeout)
+	defer timeout.Stop()
+
+	select {
+	case response := <-responses:
+		if response.errorCode != "" {
+			return nil, fmt.Errorf("%s failed: %s %s", action, response.errorCode, response.errorDescription)
+		}
+		return response.payload, nil
+	case <-timeout.C:
+		return nil, fmt.Errorf("%s timed out after %s", action, m.callTimeout)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// handleResponse delivers a CALLRESULT or CALLERROR received from the charge
+// point to the call waiting for it.
+func (m *ConnectionManager) handleResponse(cpConn *chargePointConn, message []json.RawMessage) {
+	var messageType uint16
+	var messageID string
+	if len(message) < 3 || json.Unmarshal(message[0], &messageType) != nil || json.Unmarshal(message[1], &messageID) != nil {
+		log.Printf("Malformed OCPP response from %s", cpConn.code)
+		return
+	}
+
+	response := callResponse{}
+	switch messageType {
+	case CallResult:
+		response.payload = message[2]
+	case CallError:
+		json.Unmarshal(message[2], &response.errorCode)
+		if len(message) > 3 {
+			json.Unmarshal(message[3], &response.errorDescription)
+		}
+		if response.errorCode == "" {
+			response.errorCode = "GenericError"
+		}
+	}
+
+	cpConn.pendingMu.Lock()
+	responses, ok := cpConn.pending[messageID]
+	cpConn.pendingMu.Unlock()
+	if !ok {
+		log.Printf("Unexpected OCPP response from %s: ID=%s", cpConn.code, messageID)
+		return
+	}
+
+	responses <- response
+}
+
+func (m *ConnectionManager) RemoteStartTransaction(ctx context.Context, chargePointID uint, connectorID int, idTag string) error {
+	payload := map[string]interface{}{
+		"idTag": idTag,
+	}
+	if connectorID > 0 {
+		payload["connectorId"] = connectorID
+	}
+	return m.sendStatusCall(ctx, chargePointID, "RemoteStartTransaction", payload)
+}
+
+func (m *ConnectionManager) RemoteStopTransaction(ctx context.Context, chargePointID uint, transactionID int) error {
+	payload := map[string]interface{}{
+		"transactionId": transactionID,
+	}
+	return m.sendStatusCall(ctx, chargePointID, "RemoteStopTransaction", payload)
+}
+
+// sendStatusCall sends a call whose answer is {"status": "Accepted" | ...}
+// and fails unless the charge point accepted it.
+func (m *ConnectionManager) sendStatusCall(ctx context.Context, chargePointID uint, action string, payload interface{}) error {
+	chargePoint, err := m.chargePointService.GetChargePoint(ctx, chargePointID)
+	if err != nil {
+		return errors.New("charge point not found")
+	}
+
+	result, err := m.call(ctx, chargePoint.ChargePointCode, action, payload)
+	if err != nil {
+		return err
+	}
+
+	var response struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(result, &response); err != nil {
+		return fmt.Errorf("invalid %s response: %v", action, err)
+	}
+	if response.Status != "Accepted" {
+		return fmt.Errorf("%s %s by charge point %s", action, response.Status, chargePoint.ChargePointCode)
+	}
+
+	return nil
+}
+
+func newMessageID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
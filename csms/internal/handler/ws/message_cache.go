@@ -0,0 +1,88 @@
+package ws
+
+import (
+	"sync"
+	"time"
+)
+
+// responseCache remembers the CALLRESULT sent for each CALL so that a charge
+// point retransmitting a message it did not get an answer for receives the
+// original response instead of having the action executed a second time.
+// The cache is held by each node: a CALL retried after the charge point
+// reconnected to another node of a cluster is executed again.
+type responseCache struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	entries   map[string]*cachedResponse
+	lastPrune time.Time
+}
+
+type cachedResponse struct {
+	fingerprint string
+	reply       []byte
+	expiresAt   time.Time
+}
+
+func newResponseCache(ttl time.Duration) *responseCache {
+	return &responseCache{
+		ttl:     ttl,
+		entries: make(map[string]*cachedResponse),
+	}
+}
+
+// begin records an incoming CALL. It returns the cached reply when the same
+// message (same ID and same content) has already been answered.
+func (c *responseCache) begin(cpCode, messageID, fingerprint string) ([]byte, bool) {
+	if c.ttl <= 0 {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	key := cacheKey(cpCode, messageID)
+	if entry, ok := c.entries[key]; ok && now.Before(entry.expiresAt) {
+		if entry.fingerprint == fingerprint && entry.reply != nil {
+			return entry.reply, true
+		}
+	}
+
+	c.entries[key] = &cachedResponse{
+		fingerprint: fingerprint,
+		expiresAt:   now.Add(c.ttl),
+	}
+	c.prune(now)
+	return nil, false
+}
+
+// store attaches the reply sent for a CALL previously passed to begin.
+func (c *responseCache) store(cpCode, messageID string, reply []byte) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, ok := c.entries[cacheKey(cpCode, messageID)]; ok {
+		entry.reply = reply
+	}
+}
+
+func (c *responseCache) prune(now time.Time) {
+	if now.Sub(c.lastPrune) < time.Minute {
+		return
+	}
+	c.lastPrune = now
+
+	for key, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+func cacheKey(cpCode, messageID string) string {
+	return cpCode + "|" + messageID
+}
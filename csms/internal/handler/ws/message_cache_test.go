@@ -0,0 +1,50 @@
+package ws
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResponseCache(t *testing.T) {
+	tests := []struct {
+		name        string
+		ttl         time.Duration
+		stored      bool
+		expire      bool
+		cpCode      string
+		messageID   string
+		fingerprint string
+		wantReplay  bool
+	}{
+		{name: "answered retry", ttl: time.Minute, stored: true, cpCode: "CP1", messageID: "1", fingerprint: "a", wantReplay: true},
+		{name: "retry before answer", ttl: time.Minute, cpCode: "CP1", messageID: "1", fingerprint: "a"},
+		{name: "different content", ttl: time.Minute, stored: true, cpCode: "CP1", messageID: "1", fingerprint: "b"},
+		{name: "different message", ttl: time.Minute, stored: true, cpCode: "CP1", messageID: "2", fingerprint: "a"},
+		{name: "different charge point", ttl: time.Minute, stored: true, cpCode: "CP2", messageID: "1", fingerprint: "a"},
+		{name: "expired", ttl: time.Minute, stored: true, expire: true, cpCode: "CP1", messageID: "1", fingerprint: "a"},
+		{name: "disabled", ttl: 0, stored: true, cpCode: "CP1", messageID: "1", fingerprint: "a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := newResponseCache(tt.ttl)
+			if _, ok := cache.begin("CP1", "1", "a"); ok {
+				t.Fatal("first call replayed")
+			}
+			if tt.stored {
+				cache.store("CP1", "1", []byte("reply"))
+			}
+			if tt.expire {
+				cache.entries[cacheKey("CP1", "1")].expiresAt = time.Now().Add(-time.Second)
+			}
+
+			reply, ok := cache.begin(tt.cpCode, tt.messageID, tt.fingerprint)
+			if ok != tt.wantReplay {
+				t.Fatalf("replayed = %v, want %v", ok, tt.wantReplay)
+			}
+			if ok && string(reply) != "reply" {
+				t.Errorf("reply = %q, want %q", reply, "reply")
+			}
+		})
+	}
+}
@@ -2,14 +2,19 @@ package ws
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"github.com/malikkhoiri/csms/internal/config"
 	"github.com/malikkhoiri/csms/internal/domain"
 )
 
@@ -20,7 +25,10 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-const CallResult uint16 = 3
+const (
+	CallResult uint16 = 3
+	CallError  uint16 = 4
+)
 
 type OCPPHandler struct {
 	chargePointService domain.ChargePointService
@@ -28,6 +36,9 @@ type OCPPHandler struct {
 	userService        domain.UserService
 	connectorService   domain.ConnectorService
 	idTagService       domain.IDTagService
+	connections        *ConnectionManager
+	responseCache      *responseCache
+	metrics            domain.MetricsRecorder
 }
 
 func NewOCPPHandler(
@@ -36,6 +47,9 @@ func NewOCPPHandler(
 	userService domain.UserService,
 	connectorService domain.ConnectorService,
 	idTagService domain.IDTagService,
+	connections *ConnectionManager,
+	metrics domain.MetricsRecorder,
+	ocppConfig config.OCPPConfig,
 ) *OCPPHandler {
 	return &OCPPHandler{
 		chargePointService: chargePointService,
@@ -43,6 +57,9 @@ func NewOCPPHandler(
 		userService:        userService,
 		connectorService:   connectorService,
 		idTagService:       idTagService,
+		connections:        connections,
+		responseCache:      newResponseCache(ocppConfig.MessageCacheTTL),
+		metrics:            metrics,
 	}
 }
 
@@ -59,10 +76,13 @@ func (h *OCPPHandler) HandleWebSocket(c *gin.Context) {
 	log.Printf("Connected CP: %s", cpCode)
 	log.Println("Subprotocol:", conn.Subprotocol())
 
-	h.handleOCPPMessages(conn, cpCode)
+	cpConn := h.connections.register(cpCode, conn)
+	defer h.connections.unregister(cpConn)
+
+	h.handleOCPPMessages(cpConn, cpCode)
 }
 
-func (h *OCPPHandler) handleOCPPMessages(conn *websocket.Conn, cpCode string) {
+func (h *OCPPHandler) handleOCPPMessages(conn *chargePointConn, cpCode string) {
 	for {
 		_, msg, err := conn.ReadMessage()
 		if err != nil {
@@ -74,13 +94,24 @@ func (h *OCPPHandler) handleOCPPMessages(conn *websocket.Conn, cpCode string) {
 	}
 }
 
-func (h *OCPPHandler) processOCPPMessage(conn *websocket.Conn, msg []byte, cpCode string) {
+func (h *OCPPHandler) processOCPPMessage(conn *chargePointConn, msg []byte, cpCode string) {
 	var ocppMsg []interface{}
 	if err := json.Unmarshal(msg, &ocppMsg); err != nil {
 		log.Println("Invalid JSON:", err)
 		return
 	}
 
+	// Answers to calls we sent the charge point
+	if len(ocppMsg) > 0 {
+		if messageType, ok := ocppMsg[0].(float64); ok &&
+			(uint16(messageType) == CallResult || uint16(messageType) == CallError) {
+			var rawMsg []json.RawMessage
+			json.Unmarshal(msg, &rawMsg)
+			h.connections.handleResponse(conn, rawMsg)
+			return
+		}
+	}
+
 	// Expecting a CALL message [2, messageId, action, payload]
 	if len(ocppMsg) >= 4 && int(ocppMsg[0].(float64)) == 2 {
 		messageID := ocppMsg[1].(string)
@@ -89,11 +120,47 @@ func (h *OCPPHandler) processOCPPMessage(conn *websocket.Conn, msg []byte, cpCod
 
 		log.Printf("OCPP CALL received: ID=%s, Action=%s", messageID, action)
 
+		if !slices.Contains(uncachedActions, action) {
+			if reply, ok := h.responseCache.begin(cpCode, messageID, callFingerprint(action, payload)); ok {
+				conn.WriteMessage(websocket.TextMessage, reply)
+				log.Printf("Replayed cached response for retried %s: ID=%s", action, messageID)
+				return
+			}
+		}
+
+		start := time.Now()
+		conn.reply = "unanswered"
 		h.handleOCPPAction(conn, action, messageID, payload, cpCode)
+		metricAction := action
+		if !slices.Contains(handledActions, action) {
+			metricAction = "other"
+		}
+		h.metrics.ObserveOCPPMessage(metricAction, conn.reply, time.Since(start))
 	}
 }
 
-func (h *OCPPHandler) handleOCPPAction(conn *websocket.Conn, action, messageID string, payload map[string]interface{}, cpCode string) {
+// handledActions are the actions handleOCPPAction knows; metrics lump the
+// others together, as charge points may send anything.
+var handledActions = []string{
+	"BootNotification",
+	"Heartbeat",
+	"Authorize",
+	"StartTransaction",
+	"StopTransaction",
+	"StatusNotification",
+	"MeterValues",
+}
+
+// uncachedActions are always handled, never answered from the response
+// cache. A rebooted station restarts its message IDs, so its next
+// BootNotification may look like a retry of the last one, and a Heartbeat
+// must report the current time.
+var uncachedActions = []string{
+	"BootNotification",
+	"Heartbeat",
+}
+
+func (h *OCPPHandler) handleOCPPAction(conn *chargePointConn, action, messageID string, payload map[string]interface{}, cpCode string) {
 	switch action {
 	case "BootNotification":
 		h.handleBootNotification(conn, messageID, payload, cpCode)
@@ -114,7 +181,7 @@ func (h *OCPPHandler) handleOCPPAction(conn *websocket.Conn, action, messageID s
 	}
 }
 
-func (h *OCPPHandler) handleBootNotification(conn *websocket.Conn, messageID string, payload map[string]interface{}, cpCode string) {
+func (h *OCPPHandler) handleBootNotification(conn *chargePointConn, messageID string, payload map[string]interface{}, cpCode string) {
 	ctx := context.Background()
 
 	request := &domain.BootNotificationRequest{
@@ -132,25 +199,29 @@ func (h *OCPPHandler) handleBootNotification(conn *websocket.Conn, messageID str
 	response, err := h.chargePointService.RegisterChargePoint(ctx, request, cpCode)
 	if err != nil {
 		log.Printf("Error registering charge point: %v", err)
+		h.sendCallError(conn, messageID, "InternalError", err.Error())
 		return
 	}
 
-	ocppResponse := []interface{}{
-		CallResult,
-		messageID,
-		response,
+	// A (re)booted station has no session running anymore; whatever is still
+	// active is closed now and corrected if its StopTransaction is queued.
+	if chargePoint, err := h.chargePointService.GetChargePointByCode(ctx, cpCode); err == nil {
+		if err := h.transactionService.CloseStaleTransactions(ctx, chargePoint.ID, domain.TransactionReasonRebooted); err != nil {
+			log.Printf("Error closing stale transactions: %v", err)
+		}
 	}
-	replyBytes, _ := json.Marshal(ocppResponse)
-	conn.WriteMessage(websocket.TextMessage, replyBytes)
+
+	h.sendCallResult(conn, cpCode, messageID, response)
 	log.Println("Sent BootNotification response")
 }
 
-func (h *OCPPHandler) handleHeartbeat(conn *websocket.Conn, messageID string, cpCode string) {
+func (h *OCPPHandler) handleHeartbeat(conn *chargePointConn, messageID string, cpCode string) {
 	ctx := context.Background()
 
 	chargePoint, err := h.chargePointService.GetChargePointByCode(ctx, cpCode)
 	if err != nil {
 		log.Printf("Charge point not found for heartbeat: %s", cpCode)
+		h.sendCallError(conn, messageID, "GenericError", "charge point not registered")
 		return
 	}
 
@@ -161,45 +232,42 @@ func (h *OCPPHandler) handleHeartbeat(conn *websocket.Conn, messageID string, cp
 	response := map[string]interface{}{
 		"currentTime": time.Now().UTC().Format(time.RFC3339),
 	}
-	ocppResponse := []interface{}{
-		CallResult,
-		messageID,
-		response,
-	}
-	replyBytes, _ := json.Marshal(ocppResponse)
-	conn.WriteMessage(websocket.TextMessage, replyBytes)
+	h.sendCallResult(conn, cpCode, messageID, response)
 	log.Println("Sent Heartbeat response")
 }
 
-func (h *OCPPHandler) handleAuthorize(conn *websocket.Conn, messageID string, payload map[string]interface{}, cpCode string) {
+func (h *OCPPHandler) handleAuthorize(conn *chargePointConn, messageID string, payload map[string]interface{}, cpCode string) {
 	ctx := context.Background()
 
+	chargePoint, err := h.chargePointService.GetChargePointByCode(ctx, cpCode)
+	if err != nil {
+		log.Printf("Charge point not found for authorize: %s", cpCode)
+		h.sendCallError(conn, messageID, "GenericError", "charge point not registered")
+		return
+	}
+
 	request := &domain.AuthorizeRequest{
 		IDTag: getString(payload, "idTag"),
 	}
 
-	response, err := h.idTagService.Authorize(ctx, request)
+	response, err := h.idTagService.Authorize(ctx, request, chargePoint.ID)
 	if err != nil {
 		log.Printf("Error authorizing user: %v", err)
+		h.sendCallError(conn, messageID, "InternalError", err.Error())
 		return
 	}
 
-	ocppResponse := []interface{}{
-		CallResult,
-		messageID,
-		response,
-	}
-	replyBytes, _ := json.Marshal(ocppResponse)
-	conn.WriteMessage(websocket.TextMessage, replyBytes)
+	h.sendCallResult(conn, cpCode, messageID, response)
 	log.Println("Sent Authorize response")
 }
 
-func (h *OCPPHandler) handleStartTransaction(conn *websocket.Conn, messageID string, payload map[string]interface{}, cpCode string) {
+func (h *OCPPHandler) handleStartTransaction(conn *chargePointConn, messageID string, payload map[string]interface{}, cpCode string) {
 	ctx := context.Background()
 
 	chargePoint, err := h.chargePointService.GetChargePointByCode(ctx, cpCode)
 	if err != nil {
 		log.Printf("Charge point not found for start transaction: %s", cpCode)
+		h.sendCallError(conn, messageID, "GenericError", "charge point not registered")
 		return
 	}
 
@@ -208,30 +276,27 @@ func (h *OCPPHandler) handleStartTransaction(conn *websocket.Conn, messageID str
 		ConnectorId: int(payload["connectorId"].(float64)),
 		IDTag:       getString(payload, "idTag"),
 		MeterStart:  meterStart,
+		Timestamp:   parseTimestamp(getString(payload, "timestamp")),
 	}
 
 	response, err := h.transactionService.StartTransaction(ctx, request, chargePoint.ID)
 	if err != nil {
 		log.Printf("Error starting transaction: %v", err)
+		h.sendCallError(conn, messageID, "InternalError", err.Error())
 		return
 	}
 
-	ocppResponse := []interface{}{
-		CallResult,
-		messageID,
-		response,
-	}
-	replyBytes, _ := json.Marshal(ocppResponse)
-	conn.WriteMessage(websocket.TextMessage, replyBytes)
+	h.sendCallResult(conn, cpCode, messageID, response)
 	log.Println("Sent StartTransaction response")
 }
 
-func (h *OCPPHandler) handleStopTransaction(conn *websocket.Conn, messageID string, payload map[string]interface{}, cpCode string) {
+func (h *OCPPHandler) handleStopTransaction(conn *chargePointConn, messageID string, payload map[string]interface{}, cpCode string) {
 	ctx := context.Background()
 
 	chargePoint, err := h.chargePointService.GetChargePointByCode(ctx, cpCode)
 	if err != nil {
 		log.Printf("Charge point not found for stop transaction: %s", cpCode)
+		h.sendCallError(conn, messageID, "GenericError", "charge point not registered")
 		return
 	}
 
@@ -240,30 +305,27 @@ func (h *OCPPHandler) handleStopTransaction(conn *websocket.Conn, messageID stri
 		IDTag:         getString(payload, "idTag"),
 		MeterStop:     int(payload["meterStop"].(float64)),
 		Reason:        getString(payload, "reason"),
+		Timestamp:     parseTimestamp(getString(payload, "timestamp")),
 	}
 
 	response, err := h.transactionService.StopTransaction(ctx, request, chargePoint.ID)
 	if err != nil {
 		log.Printf("Error stopping transaction: %v", err)
+		h.sendCallError(conn, messageID, "InternalError", err.Error())
 		return
 	}
 
-	ocppResponse := []interface{}{
-		CallResult,
-		messageID,
-		response,
-	}
-	replyBytes, _ := json.Marshal(ocppResponse)
-	conn.WriteMessage(websocket.TextMessage, replyBytes)
+	h.sendCallResult(conn, cpCode, messageID, response)
 	log.Println("Sent StopTransaction response")
 }
 
-func (h *OCPPHandler) handleStatusNotification(conn *websocket.Conn, messageID string, payload map[string]interface{}, cpCode string) {
+func (h *OCPPHandler) handleStatusNotification(conn *chargePointConn, messageID string, payload map[string]interface{}, cpCode string) {
 	ctx := context.Background()
 
 	chargePoint, err := h.chargePointService.GetChargePointByCode(ctx, cpCode)
 	if err != nil {
 		log.Printf("Charge point not found for status notification: %s", cpCode)
+		h.sendCallError(conn, messageID, "GenericError", "charge point not registered")
 		return
 	}
 
@@ -274,34 +336,39 @@ func (h *OCPPHandler) handleStatusNotification(conn *websocket.Conn, messageID s
 		Info:            getString(payload, "info"),
 		VendorId:        getString(payload, "vendorId"),
 		VendorErrorCode: getString(payload, "vendorErrorCode"),
+		Timestamp:       parseTimestamp(getString(payload, "timestamp")),
+	}
+
+	statuses := domain.ChargePointStatuses
+	if request.ConnectorId == 0 {
+		statuses = domain.StationStatuses
+	}
+	if !slices.Contains(statuses, request.Status) {
+		h.sendCallError(conn, messageID, "PropertyConstraintViolation", fmt.Sprintf("invalid status %q for connector %d", request.Status, request.ConnectorId))
+		return
 	}
 
 	if err := h.connectorService.UpdateConnectorStatus(ctx, request, chargePoint.ID); err != nil {
 		log.Printf("Error updating connector status: %v", err)
 	}
 
-	ocppResponse := []interface{}{
-		CallResult,
-		messageID,
-		map[string]interface{}{},
-	}
-	replyBytes, _ := json.Marshal(ocppResponse)
-	conn.WriteMessage(websocket.TextMessage, replyBytes)
+	h.sendCallResult(conn, cpCode, messageID, map[string]interface{}{})
 	log.Println("Sent StatusNotification response")
 }
 
-func (h *OCPPHandler) handleMeterValues(conn *websocket.Conn, messageID string, payload map[string]interface{}, cpCode string) {
+func (h *OCPPHandler) handleMeterValues(conn *chargePointConn, messageID string, payload map[string]interface{}, cpCode string) {
 	ctx := context.Background()
 
 	chargePoint, err := h.chargePointService.GetChargePointByCode(ctx, cpCode)
 	if err != nil {
 		log.Printf("Charge point not found for meter values: %s", cpCode)
+		h.sendCallError(conn, messageID, "GenericError", "charge point not registered")
 		return
 	}
 
 	request := &domain.MeterValuesRequest{
 		ConnectorId: int(payload["connectorId"].(float64)),
-		MeterValue:  []domain.MeterValue{},
+		MeterValue:  parseMeterValues(payload["meterValue"]),
 	}
 
 	if transactionId, exists := payload["transactionId"]; exists && transactionId != nil {
@@ -313,14 +380,97 @@ func (h *OCPPHandler) handleMeterValues(conn *websocket.Conn, messageID string,
 		log.Printf("Error updating meter values: %v", err)
 	}
 
+	h.sendCallResult(conn, cpCode, messageID, map[string]interface{}{})
+	log.Println("Sent MeterValues response")
+}
+
+// sendCallResult writes a CALLRESULT and keeps it for replay should the
+// charge point retransmit the same CALL.
+func (h *OCPPHandler) sendCallResult(conn *chargePointConn, cpCode, messageID string, response interface{}) {
 	ocppResponse := []interface{}{
 		CallResult,
 		messageID,
+		response,
+	}
+	replyBytes, _ := json.Marshal(ocppResponse)
+	h.responseCache.store(cpCode, messageID, replyBytes)
+	conn.WriteMessage(websocket.TextMessage, replyBytes)
+	conn.reply = "ok"
+}
+
+// sendCallError answers a CALL that could not be processed. Errors are not
+// cached so a retransmission is processed again.
+func (h *OCPPHandler) sendCallError(conn *chargePointConn, messageID, errorCode, description string) {
+	ocppResponse := []interface{}{
+		CallError,
+		messageID,
+		errorCode,
+		description,
 		map[string]interface{}{},
 	}
 	replyBytes, _ := json.Marshal(ocppResponse)
 	conn.WriteMessage(websocket.TextMessage, replyBytes)
-	log.Println("Sent MeterValues response")
+	conn.reply = errorCode
+}
+
+func callFingerprint(action string, payload map[string]interface{}) string {
+	payloadBytes, _ := json.Marshal(payload)
+	sum := sha256.Sum256(append([]byte(action+"|"), payloadBytes...))
+	return hex.EncodeToString(sum[:])
+}
+
+func parseTimestamp(value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+
+	timestamp, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		log.Printf("Invalid timestamp %q: %v", value, err)
+		return time.Time{}
+	}
+	return timestamp
+}
+
+func parseMeterValues(value interface{}) []domain.MeterValue {
+	items, ok := value.([]interface{})
+	if !ok {
+		return []domain.MeterValue{}
+	}
+
+	meterValues := make([]domain.MeterValue, 0, len(items))
+	for _, item := range items {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		meterValue := domain.MeterValue{
+			Timestamp:    getString(entry, "timestamp"),
+			SampledValue: []domain.SampledValue{},
+		}
+
+		samples, _ := entry["sampledValue"].([]interface{})
+		for _, sample := range samples {
+			sampled, ok := sample.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			meterValue.SampledValue = append(meterValue.SampledValue, domain.SampledValue{
+				Value:     getString(sampled, "value"),
+				Context:   getString(sampled, "context"),
+				Format:    getString(sampled, "format"),
+				Measurand: getString(sampled, "measurand"),
+				Phase:     getString(sampled, "phase"),
+				Location:  getString(sampled, "location"),
+				Unit:      getString(sampled, "unit"),
+			})
+		}
+
+		meterValues = append(meterValues, meterValue)
+	}
+
+	return meterValues
 }
 
 func getString(m map[string]interface{}, key string) string {
@@ -0,0 +1,106 @@
+package authorization
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of the request body.
+const SignatureHeader = "X-CSMS-Signature"
+
+// Webhook asks an external HTTP service whether a tag may charge. It posts
+// the tag and charge point and expects an OCPP status back; "Unknown" or a
+// 404 leave the tag to the next backend.
+type Webhook struct {
+	httpClient *http.Client
+	url        string
+	secret     string
+}
+
+type webhookRequest struct {
+	IDTag         string `json:"idTag"`
+	ChargePointID uint   `json:"chargePointId"`
+}
+
+type webhookResponse struct {
+	Status      string     `json:"status"`
+	ExpiryDate  *time.Time `json:"expiryDate"`
+	ParentIDTag string     `json:"parentIdTag"`
+}
+
+func NewWebhook(url, secret string) domain.TagAuthorizer {
+	return &Webhook{
+		httpClient: &http.Client{},
+		url:        url,
+		secret:     secret,
+	}
+}
+
+func (w *Webhook) Name() string {
+	return domain.AuthorizerWebhook
+}
+
+func (w *Webhook) AuthorizeTag(ctx context.Context, tag string, chargePointID uint) (*domain.TagDecision, error) {
+	payload, err := json.Marshal(webhookRequest{IDTag: tag, ChargePointID: chargePointID})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.secret != "" {
+		req.Header.Set(SignatureHeader, Sign(w.secret, payload))
+	}
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("authorization webhook: HTTP %d", resp.StatusCode)
+	}
+
+	var body webhookResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("authorization webhook: invalid body: %w", err)
+	}
+
+	switch body.Status {
+	case "", "Unknown":
+		return nil, nil
+	case domain.AuthorizeStatusAccepted, domain.AuthorizeStatusBlocked,
+		domain.AuthorizeStatusExpired, domain.AuthorizeStatusInvalid:
+	default:
+		return nil, fmt.Errorf("authorization webhook: unknown status %q", body.Status)
+	}
+
+	return &domain.TagDecision{
+		Status:      body.Status,
+		ExpiryDate:  body.ExpiryDate,
+		ParentIDTag: body.ParentIDTag,
+	}, nil
+}
+
+// Sign returns the signature of a webhook body.
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
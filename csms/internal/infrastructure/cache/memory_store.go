@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+// MemoryStore keeps authorization decisions in process. Entries are copied
+// in and out so callers cannot change what the store holds.
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]memoryEntry
+	lastSweep time.Time
+}
+
+type memoryEntry struct {
+	decision  []byte
+	expiresAt time.Time
+}
+
+func NewMemoryStore() domain.TagDecisionStore {
+	return &MemoryStore{
+		entries: make(map[string]memoryEntry),
+	}
+}
+
+func (s *MemoryStore) Name() string {
+	return "memory"
+}
+
+func (s *MemoryStore) Get(ctx context.Context, tag string) (*domain.TagDecision, error) {
+	s.mu.Lock()
+	entry, ok := s.entries[tag]
+	if ok && time.Now().After(entry.expiresAt) {
+		delete(s.entries, tag)
+		ok = false
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		return nil, nil
+	}
+
+	var decision domain.TagDecision
+	if err := json.Unmarshal(entry.decision, &decision); err != nil {
+		return nil, err
+	}
+	return &decision, nil
+}
+
+func (s *MemoryStore) Set(ctx context.Context, tag string, decision *domain.TagDecision, ttl time.Duration) error {
+	payload, err := json.Marshal(decision)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Expired entries of tags not presented again are swept once a minute.
+	now := time.Now()
+	if now.Sub(s.lastSweep) > time.Minute {
+		for key, entry := range s.entries {
+			if now.After(entry.expiresAt) {
+				delete(s.entries, key)
+			}
+		}
+		s.lastSweep = now
+	}
+	s.entries[tag] = memoryEntry{
+		decision:  payload,
+		expiresAt: now.Add(ttl),
+	}
+	return nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, tag string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, tag)
+	return nil
+}
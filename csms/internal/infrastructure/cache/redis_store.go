@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const redisKeyPrefix = "csms:authorization:"
+
+// RedisStore keeps authorization decisions in Redis, shared by every CSMS
+// instance, so an invalidation on one instance applies to all of them.
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) domain.TagDecisionStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Name() string {
+	return "redis"
+}
+
+func (s *RedisStore) Get(ctx context.Context, tag string) (*domain.TagDecision, error) {
+	payload, err := s.client.Get(ctx, redisKeyPrefix+tag).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var decision domain.TagDecision
+	if err := json.Unmarshal(payload, &decision); err != nil {
+		return nil, err
+	}
+	return &decision, nil
+}
+
+func (s *RedisStore) Set(ctx context.Context, tag string, decision *domain.TagDecision, ttl time.Duration) error {
+	payload, err := json.Marshal(decision)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, redisKeyPrefix+tag, payload, ttl).Err()
+}
+
+func (s *RedisStore) Delete(ctx context.Context, tag string) error {
+	return s.client.Del(ctx, redisKeyPrefix+tag).Err()
+}
@@ -0,0 +1,145 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+// MemoryDirectory is an in-process stand-in for the Redis directory. Every
+// node sharing it behaves like an instance of a cluster, which makes routing
+// and node failures reproducible without a Redis server.
+type MemoryDirectory struct {
+	ttl time.Duration
+
+	mu          sync.Mutex
+	owners      map[string]string
+	nodes       map[string]time.Time
+	subscribers map[string][]chan []byte
+}
+
+func NewMemoryDirectory(ttl time.Duration) domain.ConnectionDirectory {
+	return &MemoryDirectory{
+		ttl:         ttl,
+		owners:      make(map[string]string),
+		nodes:       make(map[string]time.Time),
+		subscribers: make(map[string][]chan []byte),
+	}
+}
+
+func (d *MemoryDirectory) Register(ctx context.Context, chargePointCode, nodeID string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.owners[chargePointCode] = nodeID
+	return nil
+}
+
+func (d *MemoryDirectory) Unregister(ctx context.Context, chargePointCode, nodeID string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.owners[chargePointCode] == nodeID {
+		delete(d.owners, chargePointCode)
+	}
+	return nil
+}
+
+func (d *MemoryDirectory) Owner(ctx context.Context, chargePointCode string) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	nodeID, ok := d.owners[chargePointCode]
+	if !ok {
+		return "", nil
+	}
+	if !d.alive(nodeID) {
+		delete(d.owners, chargePointCode)
+		return "", nil
+	}
+	return nodeID, nil
+}
+
+func (d *MemoryDirectory) Heartbeat(ctx context.Context, nodeID string, chargePointCodes []string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	d.nodes[nodeID] = now.Add(d.ttl)
+	for node, expiresAt := range d.nodes {
+		if now.After(expiresAt) {
+			d.drop(node)
+		}
+	}
+	return nil
+}
+
+func (d *MemoryDirectory) Leave(ctx context.Context, nodeID string, chargePointCodes []string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.drop(nodeID)
+	return nil
+}
+
+func (d *MemoryDirectory) Publish(ctx context.Context, nodeID string, message []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	subscribers := d.subscribers[nodeID]
+	if len(subscribers) == 0 {
+		return fmt.Errorf("node %s is not listening", nodeID)
+	}
+	for _, subscriber := range subscribers {
+		select {
+		case subscriber <- message:
+		default:
+			return fmt.Errorf("node %s is not keeping up", nodeID)
+		}
+	}
+	return nil
+}
+
+func (d *MemoryDirectory) Subscribe(ctx context.Context, nodeID string) (<-chan []byte, error) {
+	messages := make(chan []byte, 64)
+
+	d.mu.Lock()
+	d.subscribers[nodeID] = append(d.subscribers[nodeID], messages)
+	d.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		subscribers := d.subscribers[nodeID]
+		for i, subscriber := range subscribers {
+			if subscriber == messages {
+				d.subscribers[nodeID] = append(subscribers[:i], subscribers[i+1:]...)
+				break
+			}
+		}
+		close(messages)
+	}()
+
+	return messages, nil
+}
+
+func (d *MemoryDirectory) alive(nodeID string) bool {
+	expiresAt, ok := d.nodes[nodeID]
+	return ok && time.Now().Before(expiresAt)
+}
+
+// drop forgets the node and every charge point it held.
+func (d *MemoryDirectory) drop(nodeID string) {
+	delete(d.nodes, nodeID)
+	for chargePointCode, owner := range d.owners {
+		if owner == nodeID {
+			delete(d.owners, chargePointCode)
+		}
+	}
+}
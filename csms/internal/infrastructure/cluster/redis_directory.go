@@ -0,0 +1,156 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	chargePointKeyPrefix = "csms:chargepoint:"
+	nodeKeyPrefix        = "csms:node:"
+	nodeChannelPrefix    = "csms:node:channel:"
+)
+
+// Charge point entries are only changed by the node that holds them, so a
+// node going away late cannot remove the entry of the node that took over.
+var (
+	unregisterScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+	refreshScript = redis.NewScript(`
+for _, key in ipairs(KEYS) do
+	if redis.call("GET", key) == ARGV[1] then
+		redis.call("PEXPIRE", key, ARGV[2])
+	end
+end
+return 0`)
+)
+
+// RedisDirectory shares the connection directory between CSMS instances. A
+// charge point key holds the node owning the socket; node keys expire when
+// their node stops heartbeating, and so do the charge point keys it no
+// longer refreshes. Nodes talk over one pub/sub channel each.
+type RedisDirectory struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func NewRedisDirectory(client *redis.Client, ttl time.Duration) domain.ConnectionDirectory {
+	return &RedisDirectory{
+		client: client,
+		ttl:    ttl,
+	}
+}
+
+func (d *RedisDirectory) Register(ctx context.Context, chargePointCode, nodeID string) error {
+	return d.client.Set(ctx, chargePointKeyPrefix+chargePointCode, nodeID, d.ttl).Err()
+}
+
+func (d *RedisDirectory) Unregister(ctx context.Context, chargePointCode, nodeID string) error {
+	return unregisterScript.Run(ctx, d.client, []string{chargePointKeyPrefix + chargePointCode}, nodeID).Err()
+}
+
+func (d *RedisDirectory) Owner(ctx context.Context, chargePointCode string) (string, error) {
+	nodeID, err := d.client.Get(ctx, chargePointKeyPrefix+chargePointCode).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	alive, err := d.client.Exists(ctx, nodeKeyPrefix+nodeID).Result()
+	if err != nil {
+		return "", err
+	}
+	if alive == 0 {
+		// The node died before its entries expired
+		if err := d.Unregister(ctx, chargePointCode, nodeID); err != nil {
+			return "", err
+		}
+		return "", nil
+	}
+	return nodeID, nil
+}
+
+func (d *RedisDirectory) Heartbeat(ctx context.Context, nodeID string, chargePointCodes []string) error {
+	if err := d.client.Set(ctx, nodeKeyPrefix+nodeID, time.Now().UTC().Format(time.RFC3339), d.ttl).Err(); err != nil {
+		return err
+	}
+	if len(chargePointCodes) == 0 {
+		return nil
+	}
+	return refreshScript.Run(ctx, d.client, chargePointKeys(chargePointCodes), nodeID, d.ttl.Milliseconds()).Err()
+}
+
+func (d *RedisDirectory) Leave(ctx context.Context, nodeID string, chargePointCodes []string) error {
+	if err := d.client.Del(ctx, nodeKeyPrefix+nodeID).Err(); err != nil {
+		return err
+	}
+	for _, key := range chargePointKeys(chargePointCodes) {
+		if err := unregisterScript.Run(ctx, d.client, []string{key}, nodeID).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *RedisDirectory) Publish(ctx context.Context, nodeID string, message []byte) error {
+	receivers, err := d.client.Publish(ctx, nodeChannelPrefix+nodeID, message).Result()
+	if err != nil {
+		return err
+	}
+	if receivers == 0 {
+		return fmt.Errorf("node %s is not listening", nodeID)
+	}
+	return nil
+}
+
+func (d *RedisDirectory) Subscribe(ctx context.Context, nodeID string) (<-chan []byte, error) {
+	pubsub := d.client.Subscribe(ctx, nodeChannelPrefix+nodeID)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	messages := make(chan []byte, 64)
+	go func() {
+		defer close(messages)
+		defer pubsub.Close()
+
+		incoming := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case message, ok := <-incoming:
+				if !ok {
+					return
+				}
+				select {
+				case messages <- []byte(message.Payload):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return messages, nil
+}
+
+func chargePointKeys(chargePointCodes []string) []string {
+	keys := make([]string, 0, len(chargePointCodes))
+	for _, code := range chargePointCodes {
+		keys = append(keys, chargePointKeyPrefix+code)
+	}
+	return keys
+}
@@ -2,6 +2,8 @@ package database
 
 import (
 	"fmt"
+	"math"
+	"strings"
 
 	"github.com/malikkhoiri/csms/internal/config"
 	"github.com/malikkhoiri/csms/internal/domain"
@@ -39,6 +41,10 @@ func NewPostgresDB(cfg *config.DatabaseConfig) (*PostgresDB, error) {
 		return nil, fmt.Errorf("failed to auto migrate: %w", err)
 	}
 
+	if err := migrateSequences(db); err != nil {
+		return nil, fmt.Errorf("failed to migrate sequences: %w", err)
+	}
+
 	return &PostgresDB{DB: db}, nil
 }
 
@@ -49,10 +55,69 @@ func autoMigrate(db *gorm.DB) error {
 		&domain.ChargePoint{},
 		&domain.Connector{},
 		&domain.Transaction{},
+		&domain.TransactionAudit{},
+		&domain.TransactionAdjustment{},
+		&domain.AccessRule{},
+		&domain.Tariff{},
+		&domain.TariffElement{},
+		&domain.Invoice{},
+		&domain.InvoiceLine{},
+		&domain.InvoiceSequence{},
+		&domain.Wallet{},
+		&domain.WalletEntry{},
+		&domain.Payment{},
+		&domain.GuestSession{},
+		&domain.OCPIParty{},
+		&domain.OCPIEndpoint{},
+		&domain.RoamingToken{},
+		&domain.Event{},
+		&domain.WebhookSubscription{},
+		&domain.WebhookDelivery{},
+		&domain.ConnectorError{},
+		&domain.ConnectorStatusChange{},
+		&domain.ChargePointConnectionChange{},
+		&domain.AlertRule{},
+		&domain.MaintenanceTicket{},
+		&domain.TicketComment{},
 		&domain.OCPPMessage{},
 	)
 }
 
+// migrateSequences creates the sequence OCPP transaction ids are drawn from.
+// It starts past the ids issued before it existed, which were unix timestamps.
+func migrateSequences(db *gorm.DB) error {
+	if err := db.Exec("CREATE SEQUENCE IF NOT EXISTS ocpp_transaction_id_seq AS integer").Error; err != nil {
+		return err
+	}
+	return db.Exec(`SELECT setval('ocpp_transaction_id_seq', m.max_id)
+		FROM (SELECT MAX(transaction_id) AS max_id FROM transactions) m
+		WHERE m.max_id > (SELECT last_value FROM ocpp_transaction_id_seq)`).Error
+}
+
+// MigrateLegacyCosts moves the costs of transactions recorded before amounts
+// were kept in minor currency units. Their total_cost, in major units of the
+// configured currency and without tax, becomes their energy, net and gross
+// amount, and the old column is dropped so this runs only once.
+func MigrateLegacyCosts(db *gorm.DB, currency string) error {
+	if !db.Migrator().HasColumn(&domain.Transaction{}, "total_cost") {
+		return nil
+	}
+
+	currency = strings.ToUpper(currency)
+	scale := math.Pow10(domain.CurrencyExponent(currency))
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`UPDATE transactions
+			SET currency = ?, energy_cost = ROUND(total_cost * ?), net_amount = ROUND(total_cost * ?),
+				gross_amount = ROUND(total_cost * ?), tax_amount = 0
+			WHERE COALESCE(currency, '') = '' AND total_cost IS NOT NULL`,
+			currency, scale, scale, scale).Error
+		if err != nil {
+			return err
+		}
+		return tx.Migrator().DropColumn(&domain.Transaction{}, "total_cost")
+	})
+}
+
 func (p *PostgresDB) Close() error {
 	sqlDB, err := p.DB.DB()
 	if err != nil {
@@ -0,0 +1,24 @@
+package events
+
+import (
+	"context"
+	"log"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+// LogSink writes every event to the log.
+type LogSink struct{}
+
+func NewLogSink() domain.EventSink {
+	return &LogSink{}
+}
+
+func (s *LogSink) Name() string {
+	return "log"
+}
+
+func (s *LogSink) Deliver(ctx context.Context, event *domain.Event) error {
+	log.Printf("Event %d %s: %s", event.ID, event.Type, event.Data)
+	return nil
+}
@@ -0,0 +1,45 @@
+package events
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisSink appends events to a Redis stream. The outbox ID is part of every
+// entry so consumers can drop the duplicates of a redelivery.
+type RedisSink struct {
+	client *redis.Client
+	stream string
+}
+
+func NewRedisSink(client *redis.Client, stream string) domain.EventSink {
+	return &RedisSink{
+		client: client,
+		stream: stream,
+	}
+}
+
+func (s *RedisSink) Name() string {
+	return "redis"
+}
+
+func (s *RedisSink) Deliver(ctx context.Context, event *domain.Event) error {
+	values := map[string]interface{}{
+		"id":        strconv.FormatUint(uint64(event.ID), 10),
+		"type":      event.Type,
+		"data":      string(event.Data),
+		"createdAt": event.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+	if event.ChargePointID != nil {
+		values["chargePointId"] = strconv.FormatUint(uint64(*event.ChargePointID), 10)
+	}
+
+	return s.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.stream,
+		Values: values,
+	}).Err()
+}
@@ -0,0 +1,163 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "csms"
+
+// countedEvents is how many recently counted transaction events are
+// remembered to skip their retried dispatches.
+const countedEvents = 4096
+
+// Prometheus records the CSMS metrics in its own registry, along with the
+// Go runtime, process and database pool metrics.
+type Prometheus struct {
+	registry *prometheus.Registry
+
+	ocppMessages *prometheus.CounterVec
+	ocppDuration *prometheus.HistogramVec
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+	energy       prometheus.Counter
+
+	countedMu sync.Mutex
+	counted   map[uint]struct{}
+	// countedOrder holds the IDs in counted, oldest first.
+	countedOrder []uint
+}
+
+// NewPrometheus creates the metrics. connectedStations and
+// activeTransactions are read at every scrape.
+func NewPrometheus(db *sql.DB, connectedStations func() float64, activeTransactions func() float64) *Prometheus {
+	p := &Prometheus{
+		registry: prometheus.NewRegistry(),
+		ocppMessages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "ocpp_messages_total",
+			Help:      "OCPP calls received from charge points, by action and result.",
+		}, []string{"action", "result"}),
+		ocppDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "ocpp_handler_duration_seconds",
+			Help:      "Time taken to handle OCPP calls, by action.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"action"}),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests served, by method, route and status code.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time taken to serve HTTP requests, by method and route.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		energy: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "energy_delivered_kwh_total",
+			Help:      "Energy delivered by completed transactions, in kWh.",
+		}),
+		counted: make(map[uint]struct{}, countedEvents),
+	}
+
+	p.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewDBStatsCollector(db, "postgres"),
+		p.ocppMessages,
+		p.ocppDuration,
+		p.httpRequests,
+		p.httpDuration,
+		p.energy,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "connected_charge_points",
+			Help:      "Charge points connected to this instance.",
+		}, connectedStations),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "active_transactions",
+			Help:      "Transactions currently active.",
+		}, activeTransactions),
+	)
+	return p
+}
+
+func (p *Prometheus) ObserveOCPPMessage(action, result string, duration time.Duration) {
+	p.ocppMessages.WithLabelValues(action, result).Inc()
+	p.ocppDuration.WithLabelValues(action).Observe(duration.Seconds())
+}
+
+func (p *Prometheus) ObserveHTTPRequest(method, route string, status int, duration time.Duration) {
+	p.httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	p.httpDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+func (p *Prometheus) AddEnergyDelivered(kWh float64) {
+	if kWh > 0 {
+		p.energy.Add(kWh)
+	}
+}
+
+// HandleTransactionStopped counts the energy of a stopped transaction. An
+// event dispatched again, e.g. after another handler failed, is only counted
+// once as long as it is among the last events this instance counted.
+func (p *Prometheus) HandleTransactionStopped(ctx context.Context, event *domain.Event) error {
+	if !p.firstCount(event.ID) {
+		return nil
+	}
+
+	var data domain.TransactionEventData
+	if err := json.Unmarshal(event.Data, &data); err == nil {
+		p.AddEnergyDelivered(data.EnergyConsumed)
+	}
+	return nil
+}
+
+// firstCount records the event as counted and tells whether it was not
+// already.
+func (p *Prometheus) firstCount(eventID uint) bool {
+	p.countedMu.Lock()
+	defer p.countedMu.Unlock()
+
+	if _, ok := p.counted[eventID]; ok {
+		return false
+	}
+	if len(p.countedOrder) == countedEvents {
+		delete(p.counted, p.countedOrder[0])
+		p.countedOrder = p.countedOrder[1:]
+	}
+	p.counted[eventID] = struct{}{}
+	p.countedOrder = append(p.countedOrder, eventID)
+	return true
+}
+
+// Handler serves the metrics for Prometheus to scrape.
+func (p *Prometheus) Handler() http.Handler {
+	return promhttp.HandlerFor(p.registry, promhttp.HandlerOpts{Registry: p.registry})
+}
+
+// Serve serves the metrics at /metrics on the port, apart from the API.
+func (p *Prometheus) Serve(port string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", p.Handler())
+	server := &http.Server{
+		Addr:              ":" + port,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return server.ListenAndServe()
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHandleTransactionStoppedCountsEventOnce(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []uint
+		want   float64
+	}{
+		{name: "distinct events", events: []uint{1, 2}, want: 3},
+		{name: "retried event", events: []uint{1, 1}, want: 1.5},
+		{name: "retried after others", events: []uint{1, 2, 1}, want: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPrometheus(nil, func() float64 { return 0 }, func() float64 { return 0 })
+			data, _ := json.Marshal(domain.TransactionEventData{EnergyConsumed: 1.5})
+
+			for _, id := range tt.events {
+				p.HandleTransactionStopped(context.Background(), &domain.Event{ID: id, Data: data})
+			}
+
+			if got := testutil.ToFloat64(p.energy); got != tt.want {
+				t.Errorf("energy = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleTransactionStoppedForgetsOldEvents(t *testing.T) {
+	p := NewPrometheus(nil, func() float64 { return 0 }, func() float64 { return 0 })
+	data, _ := json.Marshal(domain.TransactionEventData{EnergyConsumed: 1})
+
+	for id := uint(1); id <= countedEvents+1; id++ {
+		p.HandleTransactionStopped(context.Background(), &domain.Event{ID: id, Data: data})
+	}
+	if len(p.counted) != countedEvents {
+		t.Errorf("remembered %d events, want %d", len(p.counted), countedEvents)
+	}
+	if _, ok := p.counted[1]; ok {
+		t.Error("oldest event still remembered")
+	}
+}
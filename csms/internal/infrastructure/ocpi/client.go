@@ -0,0 +1,113 @@
+package ocpi
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+// Client calls the OCPI 2.2.1 endpoints of roaming parties.
+type Client struct {
+	httpClient *http.Client
+}
+
+func NewClient(timeout time.Duration) domain.OCPIClient {
+	return &Client{
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// response is the envelope every OCPI response is wrapped in.
+type response struct {
+	Data          json.RawMessage `json:"data"`
+	StatusCode    int             `json:"status_code"`
+	StatusMessage string          `json:"status_message"`
+}
+
+func (c *Client) GetVersions(ctx context.Context, url, token string) ([]domain.OCPIVersion, error) {
+	var versions []domain.OCPIVersion
+	if err := c.do(ctx, http.MethodGet, url, token, nil, &versions); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+func (c *Client) GetVersionDetails(ctx context.Context, url, token string) (*domain.OCPIVersionDetails, error) {
+	var details domain.OCPIVersionDetails
+	if err := c.do(ctx, http.MethodGet, url, token, nil, &details); err != nil {
+		return nil, err
+	}
+	return &details, nil
+}
+
+func (c *Client) Send(ctx context.Context, method, url, token string, body interface{}) error {
+	return c.do(ctx, method, url, token, body, nil)
+}
+
+func (c *Client) AuthorizeToken(ctx context.Context, url, token string, location *domain.OCPILocationReferences) (*domain.OCPIAuthorizationInfo, error) {
+	var info domain.OCPIAuthorizationInfo
+	if err := c.do(ctx, http.MethodPost, url, token, location, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+func (c *Client) do(ctx context.Context, method, url, token string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return err
+	}
+
+	requestID := newRequestID()
+	req.Header.Set("Authorization", "Token "+base64.StdEncoding.EncodeToString([]byte(token)))
+	req.Header.Set("X-Request-ID", requestID)
+	req.Header.Set("X-Correlation-ID", requestID)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var envelope response
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("%s %s: HTTP %d with invalid body: %w", method, url, resp.StatusCode, err)
+	}
+	if resp.StatusCode >= 300 || envelope.StatusCode < 1000 || envelope.StatusCode >= 2000 {
+		return fmt.Errorf("%s %s: HTTP %d, OCPI status %d %s", method, url, resp.StatusCode, envelope.StatusCode, envelope.StatusMessage)
+	}
+
+	if out != nil && len(envelope.Data) > 0 {
+		return json.Unmarshal(envelope.Data, out)
+	}
+	return nil
+}
+
+func newRequestID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,159 @@
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+// DeclinedPaymentMethod is a card token the fake gateway always declines.
+const DeclinedPaymentMethod = "tok_declined"
+
+// FakeGateway is an in-process payment provider for development and tests.
+// It keeps intents in memory and signs webhooks with HMAC-SHA256.
+type FakeGateway struct {
+	mu            sync.Mutex
+	intents       map[string]*fakeIntent
+	webhookSecret string
+}
+
+type fakeIntent struct {
+	status     string
+	authorized int64
+	captured   int64
+	refunded   int64
+}
+
+func NewFakeGateway(webhookSecret string) domain.PaymentGateway {
+	return &FakeGateway{
+		intents:       make(map[string]*fakeIntent),
+		webhookSecret: webhookSecret,
+	}
+}
+
+func (g *FakeGateway) Name() string {
+	return "fake"
+}
+
+func (g *FakeGateway) PreAuthorize(ctx context.Context, request *domain.PaymentIntentRequest) (*domain.PaymentIntent, error) {
+	if request.Amount <= 0 {
+		return nil, errors.New("amount must be positive")
+	}
+	if request.PaymentMethod == "" {
+		return nil, errors.New("payment method is required")
+	}
+	if request.PaymentMethod == DeclinedPaymentMethod {
+		return nil, errors.New("card declined")
+	}
+
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	reference := "fake_pi_" + hex.EncodeToString(buf)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.intents[reference] = &fakeIntent{
+		status:     domain.PaymentStatusAuthorized,
+		authorized: request.Amount,
+	}
+
+	return &domain.PaymentIntent{
+		Reference: reference,
+		Status:    domain.PaymentStatusAuthorized,
+		Amount:    request.Amount,
+	}, nil
+}
+
+func (g *FakeGateway) Capture(ctx context.Context, reference string, amount int64) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	intent, ok := g.intents[reference]
+	if !ok {
+		return fmt.Errorf("unknown payment %s", reference)
+	}
+	if intent.status != domain.PaymentStatusAuthorized && intent.status != domain.PaymentStatusCaptured {
+		return fmt.Errorf("payment %s is %s", reference, intent.status)
+	}
+	if amount < 0 || intent.captured+amount > intent.authorized {
+		return fmt.Errorf("capture amount %d exceeds the capturable %d", amount, intent.authorized-intent.captured)
+	}
+
+	intent.status = domain.PaymentStatusCaptured
+	intent.captured += amount
+	return nil
+}
+
+func (g *FakeGateway) Cancel(ctx context.Context, reference string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	intent, ok := g.intents[reference]
+	if !ok {
+		return fmt.Errorf("unknown payment %s", reference)
+	}
+	if intent.status != domain.PaymentStatusAuthorized {
+		return fmt.Errorf("payment %s is %s", reference, intent.status)
+	}
+
+	intent.status = domain.PaymentStatusCancelled
+	return nil
+}
+
+func (g *FakeGateway) Refund(ctx context.Context, reference string, amount int64) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	intent, ok := g.intents[reference]
+	if !ok {
+		return fmt.Errorf("unknown payment %s", reference)
+	}
+	if intent.status != domain.PaymentStatusCaptured && intent.status != domain.PaymentStatusRefunded {
+		return fmt.Errorf("payment %s is %s", reference, intent.status)
+	}
+	if amount <= 0 || intent.refunded+amount > intent.captured {
+		return fmt.Errorf("refund amount %d exceeds the refundable %d", amount, intent.captured-intent.refunded)
+	}
+
+	intent.refunded += amount
+	if intent.refunded == intent.captured {
+		intent.status = domain.PaymentStatusRefunded
+	}
+	return nil
+}
+
+// VerifyWebhook accepts a JSON PaymentEvent signed with SignWebhook.
+func (g *FakeGateway) VerifyWebhook(payload []byte, signature string) (*domain.PaymentEvent, error) {
+	expected := SignWebhook(g.webhookSecret, payload)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return nil, errors.New("invalid webhook signature")
+	}
+
+	var event domain.PaymentEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	if event.Reference == "" {
+		return nil, errors.New("webhook event without reference")
+	}
+	return &event, nil
+}
+
+// SignWebhook returns the hex HMAC-SHA256 of the payload, as sent by the fake
+// provider in the webhook signature header.
+func SignWebhook(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
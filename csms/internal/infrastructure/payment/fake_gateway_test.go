@@ -0,0 +1,168 @@
+package payment
+
+import (
+	"context"
+	"testing"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+)
+
+func preAuthorize(t *testing.T, gateway domain.PaymentGateway, amount int64) string {
+	t.Helper()
+
+	intent, err := gateway.PreAuthorize(context.Background(), &domain.PaymentIntentRequest{
+		Amount:        amount,
+		Currency:      "EUR",
+		PaymentMethod: "tok_visa",
+	})
+	if err != nil {
+		t.Fatalf("PreAuthorize() error = %v", err)
+	}
+	if intent.Status != domain.PaymentStatusAuthorized || intent.Amount != amount || intent.Reference == "" {
+		t.Fatalf("PreAuthorize() = %+v, want an authorized intent of %d", intent, amount)
+	}
+	return intent.Reference
+}
+
+func TestFakeGatewayPreAuthorize(t *testing.T) {
+	tests := []struct {
+		name    string
+		request domain.PaymentIntentRequest
+		wantErr bool
+	}{
+		{"authorized", domain.PaymentIntentRequest{Amount: 5000, Currency: "EUR", PaymentMethod: "tok_visa"}, false},
+		{"declined card", domain.PaymentIntentRequest{Amount: 5000, Currency: "EUR", PaymentMethod: DeclinedPaymentMethod}, true},
+		{"no payment method", domain.PaymentIntentRequest{Amount: 5000, Currency: "EUR"}, true},
+		{"zero amount", domain.PaymentIntentRequest{Amount: 0, Currency: "EUR", PaymentMethod: "tok_visa"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gateway := NewFakeGateway("secret")
+			_, err := gateway.PreAuthorize(context.Background(), &tt.request)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PreAuthorize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFakeGatewayCapture(t *testing.T) {
+	tests := []struct {
+		name     string
+		captures []int64
+		wantErr  []bool
+	}{
+		{"full amount", []int64{5000}, []bool{false}},
+		{"part of the amount", []int64{3000}, []bool{false}},
+		{"more than authorized", []int64{5001}, []bool{true}},
+		{"further capture within the authorization", []int64{3000, 2000}, []bool{false, false}},
+		{"further capture beyond the authorization", []int64{3000, 2001}, []bool{false, true}},
+		{"negative amount", []int64{-1}, []bool{true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gateway := NewFakeGateway("secret")
+			reference := preAuthorize(t, gateway, 5000)
+
+			for i, amount := range tt.captures {
+				err := gateway.Capture(context.Background(), reference, amount)
+				if (err != nil) != tt.wantErr[i] {
+					t.Errorf("capture %d of %d: error = %v, wantErr %v", i+1, amount, err, tt.wantErr[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFakeGatewayCaptureUnknownOrCancelled(t *testing.T) {
+	gateway := NewFakeGateway("secret")
+	ctx := context.Background()
+
+	if err := gateway.Capture(ctx, "fake_pi_unknown", 100); err == nil {
+		t.Error("Capture() of an unknown payment succeeded")
+	}
+
+	reference := preAuthorize(t, gateway, 5000)
+	if err := gateway.Cancel(ctx, reference); err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+	if err := gateway.Capture(ctx, reference, 100); err == nil {
+		t.Error("Capture() of a cancelled payment succeeded")
+	}
+	if err := gateway.Cancel(ctx, reference); err == nil {
+		t.Error("Cancel() of a cancelled payment succeeded")
+	}
+}
+
+func TestFakeGatewayRefund(t *testing.T) {
+	tests := []struct {
+		name    string
+		refunds []int64
+		wantErr []bool
+	}{
+		{"full refund", []int64{3000}, []bool{false}},
+		{"partial refunds", []int64{1000, 2000}, []bool{false, false}},
+		{"more than captured", []int64{3001}, []bool{true}},
+		{"beyond what is left", []int64{2000, 1001}, []bool{false, true}},
+		{"after a full refund", []int64{3000, 1}, []bool{false, true}},
+		{"zero amount", []int64{0}, []bool{true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gateway := NewFakeGateway("secret")
+			reference := preAuthorize(t, gateway, 5000)
+			if err := gateway.Capture(context.Background(), reference, 3000); err != nil {
+				t.Fatalf("Capture() error = %v", err)
+			}
+
+			for i, amount := range tt.refunds {
+				err := gateway.Refund(context.Background(), reference, amount)
+				if (err != nil) != tt.wantErr[i] {
+					t.Errorf("refund %d of %d: error = %v, wantErr %v", i+1, amount, err, tt.wantErr[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFakeGatewayRefundBeforeCapture(t *testing.T) {
+	gateway := NewFakeGateway("secret")
+	reference := preAuthorize(t, gateway, 5000)
+
+	if err := gateway.Refund(context.Background(), reference, 100); err == nil {
+		t.Error("Refund() of an uncaptured payment succeeded")
+	}
+}
+
+func TestFakeGatewayVerifyWebhook(t *testing.T) {
+	gateway := NewFakeGateway("secret")
+	payload := []byte(`{"reference":"fake_pi_1","status":"Captured"}`)
+
+	tests := []struct {
+		name      string
+		payload   []byte
+		signature string
+		wantErr   bool
+	}{
+		{"valid signature", payload, SignWebhook("secret", payload), false},
+		{"other secret", payload, SignWebhook("other", payload), true},
+		{"tampered payload", []byte(`{"reference":"fake_pi_2","status":"Captured"}`), SignWebhook("secret", payload), true},
+		{"no reference", []byte(`{"status":"Captured"}`), SignWebhook("secret", []byte(`{"status":"Captured"}`)), true},
+		{"invalid json", []byte(`{`), SignWebhook("secret", []byte(`{`)), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := gateway.VerifyWebhook(tt.payload, tt.signature)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyWebhook() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && event.Reference != "fake_pi_1" {
+				t.Errorf("VerifyWebhook() reference = %q, want fake_pi_1", event.Reference)
+			}
+		})
+	}
+}
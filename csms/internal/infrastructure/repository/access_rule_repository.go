@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+	"gorm.io/gorm"
+)
+
+type AccessRuleRepository struct {
+	db *gorm.DB
+}
+
+func NewAccessRuleRepository(db *gorm.DB) domain.AccessRuleRepository {
+	return &AccessRuleRepository{db: db}
+}
+
+func (r *AccessRuleRepository) Create(ctx context.Context, rule *domain.AccessRule) error {
+	return r.db.WithContext(ctx).Create(rule).Error
+}
+
+func (r *AccessRuleRepository) GetByID(ctx context.Context, id uint) (*domain.AccessRule, error) {
+	var rule domain.AccessRule
+	err := r.db.WithContext(ctx).First(&rule, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (r *AccessRuleRepository) Update(ctx context.Context, rule *domain.AccessRule) error {
+	return r.db.WithContext(ctx).Save(rule).Error
+}
+
+func (r *AccessRuleRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&domain.AccessRule{}, id).Error
+}
+
+func (r *AccessRuleRepository) List(ctx context.Context, limit, offset int) ([]domain.AccessRule, error) {
+	var rules []domain.AccessRule
+	err := r.db.WithContext(ctx).Limit(limit).Offset(offset).Find(&rules).Error
+	return rules, err
+}
+
+func (r *AccessRuleRepository) ListForTag(ctx context.Context, idTagID uint, parentIDTag string) ([]domain.AccessRule, error) {
+	var rules []domain.AccessRule
+	err := r.db.WithContext(ctx).
+		Where("disabled = ? AND (id_tag_id = ? OR (parent_id_tag <> '' AND parent_id_tag = ?))", false, idTagID, parentIDTag).
+		Find(&rules).Error
+	return rules, err
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+	"gorm.io/gorm"
+)
+
+type ChargePointConnectionChangeRepository struct {
+	db *gorm.DB
+}
+
+func NewChargePointConnectionChangeRepository(db *gorm.DB) domain.ChargePointConnectionChangeRepository {
+	return &ChargePointConnectionChangeRepository{db: db}
+}
+
+func (r *ChargePointConnectionChangeRepository) Create(ctx context.Context, change *domain.ChargePointConnectionChange) error {
+	return conn(ctx, r.db).Create(change).Error
+}
+
+func (r *ChargePointConnectionChangeRepository) ListBetween(ctx context.Context, chargePointIDs []uint, from, to time.Time) ([]domain.ChargePointConnectionChange, error) {
+	var changes []domain.ChargePointConnectionChange
+	err := r.db.WithContext(ctx).
+		Where("charge_point_id IN ? AND changed_at >= ? AND changed_at < ?", chargePointIDs, from, to).
+		Order("changed_at, id").
+		Find(&changes).Error
+	return changes, err
+}
+
+func (r *ChargePointConnectionChangeRepository) ListLatestBefore(ctx context.Context, chargePointIDs []uint, before time.Time) ([]domain.ChargePointConnectionChange, error) {
+	var changes []domain.ChargePointConnectionChange
+	err := r.db.WithContext(ctx).
+		Select("DISTINCT ON (charge_point_id) *").
+		Where("charge_point_id IN ? AND changed_at < ?", chargePointIDs, before).
+		Order("charge_point_id, changed_at DESC, id DESC").
+		Find(&changes).Error
+	return changes, err
+}
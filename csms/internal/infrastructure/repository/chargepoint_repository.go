@@ -22,7 +22,7 @@ func (r *ChargePointRepository) Create(ctx context.Context, cp *domain.ChargePoi
 
 func (r *ChargePointRepository) GetByID(ctx context.Context, id uint) (*domain.ChargePoint, error) {
 	var cp domain.ChargePoint
-	err := r.db.WithContext(ctx).Preload("Connectors").First(&cp, id).Error
+	err := r.db.WithContext(ctx).Preload("Connectors", "connector_id > 0").First(&cp, id).Error
 	if err != nil {
 		return nil, err
 	}
@@ -31,7 +31,7 @@ func (r *ChargePointRepository) GetByID(ctx context.Context, id uint) (*domain.C
 
 func (r *ChargePointRepository) GetByCode(ctx context.Context, code string) (*domain.ChargePoint, error) {
 	var cp domain.ChargePoint
-	err := r.db.WithContext(ctx).Preload("Connectors").Where("charge_point_code = ?", code).First(&cp).Error
+	err := r.db.WithContext(ctx).Preload("Connectors", "connector_id > 0").Where("charge_point_code = ?", code).First(&cp).Error
 	if err != nil {
 		return nil, err
 	}
@@ -48,14 +48,106 @@ func (r *ChargePointRepository) Delete(ctx context.Context, id uint) error {
 
 func (r *ChargePointRepository) List(ctx context.Context, limit, offset int) ([]domain.ChargePoint, error) {
 	var cps []domain.ChargePoint
-	err := r.db.WithContext(ctx).Preload("Connectors").Limit(limit).Offset(offset).Find(&cps).Error
+	err := r.db.WithContext(ctx).Preload("Connectors", "connector_id > 0").Limit(limit).Offset(offset).Find(&cps).Error
 	return cps, err
 }
 
-func (r *ChargePointRepository) UpdateStatus(ctx context.Context, id uint, status string) error {
-	return r.db.WithContext(ctx).Model(&domain.ChargePoint{}).Where("id = ?", id).Update("status", status).Error
+func (r *ChargePointRepository) UpdateStatus(ctx context.Context, id uint, status string, at time.Time) error {
+	return conn(ctx, r.db).Model(&domain.ChargePoint{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":            status,
+		"status_changed_at": at,
+	}).Error
+}
+
+func (r *ChargePointRepository) UpdateErrorCode(ctx context.Context, id uint, errorCode string) error {
+	return conn(ctx, r.db).Model(&domain.ChargePoint{}).Where("id = ?", id).Update("error_code", errorCode).Error
+}
+
+func (r *ChargePointRepository) ListInStatusSince(ctx context.Context, status string, before time.Time) ([]domain.ChargePoint, error) {
+	var chargePoints []domain.ChargePoint
+	err := conn(ctx, r.db).Where("status = ? AND status_changed_at <= ?", status, before).Find(&chargePoints).Error
+	return chargePoints, err
+}
+
+func (r *ChargePointRepository) UpdateAggregateStatus(ctx context.Context, id uint, status string) error {
+	return conn(ctx, r.db).Model(&domain.ChargePoint{}).Where("id = ?", id).Update("aggregate_status", status).Error
 }
 
 func (r *ChargePointRepository) UpdateHeartbeat(ctx context.Context, id uint) error {
 	return r.db.WithContext(ctx).Model(&domain.ChargePoint{}).Where("id = ?", id).Update("last_heartbeat", time.Now()).Error
 }
+
+func (r *ChargePointRepository) UpdateConnection(ctx context.Context, id uint, online bool, at time.Time) error {
+	return conn(ctx, r.db).Model(&domain.ChargePoint{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"online":            online,
+		"online_changed_at": at,
+	}).Error
+}
+
+func (r *ChargePointRepository) ListOnlineSince(ctx context.Context, before time.Time) ([]domain.ChargePoint, error) {
+	var chargePoints []domain.ChargePoint
+	err := r.db.WithContext(ctx).Where("online = ? AND online_changed_at <= ?", true, before).Find(&chargePoints).Error
+	return chargePoints, err
+}
+
+func (r *ChargePointRepository) ListOfflineSince(ctx context.Context, before time.Time) ([]domain.ChargePoint, error) {
+	var chargePoints []domain.ChargePoint
+	err := r.db.WithContext(ctx).Where("online = ? AND online_changed_at <= ?", false, before).Find(&chargePoints).Error
+	return chargePoints, err
+}
+
+func (r *ChargePointRepository) UpdateSite(ctx context.Context, id uint, site, group string) error {
+	return r.db.WithContext(ctx).Model(&domain.ChargePoint{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"site": site, "charge_point_group": group}).Error
+}
+
+func (r *ChargePointRepository) UpdateLocation(ctx context.Context, id uint, location *domain.ChargePointLocation) error {
+	return r.db.WithContext(ctx).Model(&domain.ChargePoint{}).Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"address":     location.Address,
+			"city":        location.City,
+			"postal_code": location.PostalCode,
+			"country":     location.Country,
+			"latitude":    location.Latitude,
+			"longitude":   location.Longitude,
+		}).Error
+}
+
+func (r *ChargePointRepository) ListUpdated(ctx context.Context, query domain.OCPIQuery) ([]domain.ChargePoint, int64, error) {
+	db := r.db.WithContext(ctx).Model(&domain.ChargePoint{})
+	if query.DateFrom != nil {
+		db = db.Where("updated_at >= ?", *query.DateFrom)
+	}
+	if query.DateTo != nil {
+		db = db.Where("updated_at < ?", *query.DateTo)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var cps []domain.ChargePoint
+	err := db.Preload("Connectors", "connector_id > 0").Order("id").Limit(query.Limit).Offset(query.Offset).Find(&cps).Error
+	return cps, total, err
+}
+
+func (r *ChargePointRepository) CountOnline(ctx context.Context) (int64, int64, error) {
+	var result struct {
+		Online int64
+		Total  int64
+	}
+	err := r.db.WithContext(ctx).Model(&domain.ChargePoint{}).
+		Select("COUNT(*) FILTER (WHERE online) AS online, COUNT(*) AS total").
+		Scan(&result).Error
+	return result.Online, result.Total, err
+}
+
+func (r *ChargePointRepository) CountByAggregateStatus(ctx context.Context) ([]domain.GroupCount, error) {
+	var counts []domain.GroupCount
+	err := r.db.WithContext(ctx).Model(&domain.ChargePoint{}).
+		Select("aggregate_status AS key, COUNT(*) AS count").
+		Group("aggregate_status").
+		Scan(&counts).Error
+	return counts, err
+}
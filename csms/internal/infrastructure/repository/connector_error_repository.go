@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+	"gorm.io/gorm"
+)
+
+type ConnectorErrorRepository struct {
+	db *gorm.DB
+}
+
+func NewConnectorErrorRepository(db *gorm.DB) domain.ConnectorErrorRepository {
+	return &ConnectorErrorRepository{db: db}
+}
+
+func (r *ConnectorErrorRepository) Create(ctx context.Context, connectorError *domain.ConnectorError) error {
+	return conn(ctx, r.db).Create(connectorError).Error
+}
+
+func (r *ConnectorErrorRepository) CountSince(ctx context.Context, errorCode string, since time.Time) ([]domain.ConnectorErrorCount, error) {
+	var counts []domain.ConnectorErrorCount
+	err := r.db.WithContext(ctx).Model(&domain.ConnectorError{}).
+		Select("charge_point_id, connector_id, COUNT(*) AS count").
+		Where("error_code = ? AND created_at >= ?", errorCode, since).
+		Group("charge_point_id, connector_id").
+		Scan(&counts).Error
+	return counts, err
+}
+
+func (r *ConnectorErrorRepository) CountByConnectorSince(ctx context.Context, chargePointID uint, connectorID int, errorCode string, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&domain.ConnectorError{}).
+		Where("charge_point_id = ? AND connector_id = ? AND error_code = ? AND created_at >= ?", chargePointID, connectorID, errorCode, since).
+		Count(&count).Error
+	return count, err
+}
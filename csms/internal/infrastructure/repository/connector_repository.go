@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/malikkhoiri/csms/internal/domain"
 	"gorm.io/gorm"
@@ -16,12 +17,12 @@ func NewConnectorRepository(db *gorm.DB) domain.ConnectorRepository {
 }
 
 func (r *ConnectorRepository) Create(ctx context.Context, connector *domain.Connector) error {
-	return r.db.WithContext(ctx).Create(connector).Error
+	return conn(ctx, r.db).Create(connector).Error
 }
 
 func (r *ConnectorRepository) GetByID(ctx context.Context, id uint) (*domain.Connector, error) {
 	var connector domain.Connector
-	err := r.db.WithContext(ctx).First(&connector, id).Error
+	err := conn(ctx, r.db).First(&connector, id).Error
 	if err != nil {
 		return nil, err
 	}
@@ -30,7 +31,16 @@ func (r *ConnectorRepository) GetByID(ctx context.Context, id uint) (*domain.Con
 
 func (r *ConnectorRepository) GetByChargePointAndConnectorID(ctx context.Context, chargePointID uint, connectorID int) (*domain.Connector, error) {
 	var connector domain.Connector
-	err := r.db.WithContext(ctx).Where("charge_point_id = ? AND connector_id = ?", chargePointID, connectorID).First(&connector).Error
+	err := conn(ctx, r.db).Where("charge_point_id = ? AND connector_id = ?", chargePointID, connectorID).First(&connector).Error
+	if err != nil {
+		return nil, err
+	}
+	return &connector, nil
+}
+
+func (r *ConnectorRepository) GetByPublicCode(ctx context.Context, publicCode string) (*domain.Connector, error) {
+	var connector domain.Connector
+	err := conn(ctx, r.db).Preload("ChargePoint").Where("public_code = ?", publicCode).First(&connector).Error
 	if err != nil {
 		return nil, err
 	}
@@ -38,19 +48,51 @@ func (r *ConnectorRepository) GetByChargePointAndConnectorID(ctx context.Context
 }
 
 func (r *ConnectorRepository) Update(ctx context.Context, connector *domain.Connector) error {
-	return r.db.WithContext(ctx).Save(connector).Error
+	return conn(ctx, r.db).Save(connector).Error
 }
 
 func (r *ConnectorRepository) Delete(ctx context.Context, id uint) error {
-	return r.db.WithContext(ctx).Delete(&domain.Connector{}, id).Error
+	return conn(ctx, r.db).Delete(&domain.Connector{}, id).Error
 }
 
 func (r *ConnectorRepository) ListByChargePoint(ctx context.Context, chargePointID uint) ([]domain.Connector, error) {
 	var connectors []domain.Connector
-	err := r.db.WithContext(ctx).Where("charge_point_id = ?", chargePointID).Find(&connectors).Error
+	err := conn(ctx, r.db).Where("charge_point_id = ? AND connector_id > 0", chargePointID).Order("connector_id").Find(&connectors).Error
 	return connectors, err
 }
 
 func (r *ConnectorRepository) UpdateStatus(ctx context.Context, id uint, status string) error {
-	return r.db.WithContext(ctx).Model(&domain.Connector{}).Where("id = ?", id).Update("status", status).Error
+	return conn(ctx, r.db).Model(&domain.Connector{}).Where("id = ?", id).Update("status", status).Error
+}
+
+func (r *ConnectorRepository) ListInStatusSince(ctx context.Context, status string, before time.Time) ([]domain.Connector, error) {
+	var connectors []domain.Connector
+	err := conn(ctx, r.db).Where("status = ? AND status_changed_at <= ?", status, before).Find(&connectors).Error
+	return connectors, err
+}
+
+func (r *ConnectorRepository) ListWithChargePoint(ctx context.Context, chargePointID uint, site string) ([]domain.Connector, error) {
+	query := r.db.WithContext(ctx).Preload("ChargePoint").
+		Joins("JOIN charge_points ON charge_points.id = connectors.charge_point_id").
+		Where("connectors.connector_id > 0")
+	if chargePointID != 0 {
+		query = query.Where("connectors.charge_point_id = ?", chargePointID)
+	}
+	if site != "" {
+		query = query.Where("charge_points.site = ?", site)
+	}
+
+	var connectors []domain.Connector
+	err := query.Order("connectors.charge_point_id, connectors.connector_id").Find(&connectors).Error
+	return connectors, err
+}
+
+func (r *ConnectorRepository) CountByStatus(ctx context.Context) ([]domain.GroupCount, error) {
+	var counts []domain.GroupCount
+	err := r.db.WithContext(ctx).Model(&domain.Connector{}).
+		Select("status AS key, COUNT(*) AS count").
+		Where("connector_id > 0").
+		Group("status").
+		Scan(&counts).Error
+	return counts, err
 }
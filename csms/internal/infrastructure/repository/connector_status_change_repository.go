@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+	"gorm.io/gorm"
+)
+
+type ConnectorStatusChangeRepository struct {
+	db *gorm.DB
+}
+
+func NewConnectorStatusChangeRepository(db *gorm.DB) domain.ConnectorStatusChangeRepository {
+	return &ConnectorStatusChangeRepository{db: db}
+}
+
+func (r *ConnectorStatusChangeRepository) Create(ctx context.Context, change *domain.ConnectorStatusChange) error {
+	return conn(ctx, r.db).Create(change).Error
+}
+
+func (r *ConnectorStatusChangeRepository) List(ctx context.Context, filter domain.StatusHistoryFilter) ([]domain.ConnectorStatusChange, error) {
+	query := r.db.WithContext(ctx).
+		Where("charge_point_id = ? AND connector_id = ?", filter.ChargePointID, filter.ConnectorID)
+	if !filter.From.IsZero() {
+		query = query.Where("changed_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("changed_at < ?", filter.To)
+	}
+
+	var changes []domain.ConnectorStatusChange
+	err := query.Order("changed_at DESC, id DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&changes).Error
+	return changes, err
+}
+
+func (r *ConnectorStatusChangeRepository) ListBetween(ctx context.Context, chargePointIDs []uint, from, to time.Time) ([]domain.ConnectorStatusChange, error) {
+	var changes []domain.ConnectorStatusChange
+	err := r.db.WithContext(ctx).
+		Where("charge_point_id IN ? AND changed_at >= ? AND changed_at < ?", chargePointIDs, from, to).
+		Order("changed_at, id").
+		Find(&changes).Error
+	return changes, err
+}
+
+func (r *ConnectorStatusChangeRepository) ListLatestBefore(ctx context.Context, chargePointIDs []uint, before time.Time) ([]domain.ConnectorStatusChange, error) {
+	var changes []domain.ConnectorStatusChange
+	err := r.db.WithContext(ctx).
+		Select("DISTINCT ON (charge_point_id, connector_id) *").
+		Where("charge_point_id IN ? AND changed_at < ?", chargePointIDs, before).
+		Order("charge_point_id, connector_id, changed_at DESC, id DESC").
+		Find(&changes).Error
+	return changes, err
+}
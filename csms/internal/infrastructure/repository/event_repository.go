@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type EventRepository struct {
+	db *gorm.DB
+}
+
+func NewEventRepository(db *gorm.DB) domain.EventRepository {
+	return &EventRepository{db: db}
+}
+
+func (r *EventRepository) Create(ctx context.Context, event *domain.Event) error {
+	return conn(ctx, r.db).Create(event).Error
+}
+
+func (r *EventRepository) ClaimDue(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]domain.Event, error) {
+	var events []domain.Event
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", domain.EventStatusPending, now).
+			Order("id").Limit(limit).Find(&events).Error; err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, 0, len(events))
+		for _, event := range events {
+			ids = append(ids, event.ID)
+		}
+		return tx.Model(&domain.Event{}).Where("id IN ?", ids).Update("next_attempt_at", now.Add(lease)).Error
+	})
+	return events, err
+}
+
+func (r *EventRepository) MarkDispatched(ctx context.Context, id uint, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&domain.Event{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":        domain.EventStatusDispatched,
+		"dispatched_at": at,
+		"last_error":    "",
+	}).Error
+}
+
+func (r *EventRepository) ListAfter(ctx context.Context, afterID uint, until time.Time, filter domain.EventFilter, limit int) ([]domain.Event, error) {
+	query := r.db.WithContext(ctx).Where("id > ? AND created_at <= ?", afterID, until)
+	if len(filter.ChargePointIDs) > 0 {
+		query = query.Where("charge_point_id IN ?", filter.ChargePointIDs)
+	}
+	if len(filter.Types) > 0 {
+		query = query.Where("type IN ?", filter.Types)
+	}
+
+	var events []domain.Event
+	err := query.Order("id").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+func (r *EventRepository) LastID(ctx context.Context, until time.Time) (uint, error) {
+	var id uint
+	err := r.db.WithContext(ctx).Model(&domain.Event{}).Where("created_at <= ?", until).
+		Select("COALESCE(MAX(id), 0)").Scan(&id).Error
+	return id, err
+}
+
+func (r *EventRepository) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("created_at < ? AND status <> ?", before, domain.EventStatusPending).
+		Delete(&domain.Event{})
+	return result.RowsAffected, result.Error
+}
+
+func (r *EventRepository) Reschedule(ctx context.Context, event *domain.Event) error {
+	return r.db.WithContext(ctx).Model(&domain.Event{}).Where("id = ?", event.ID).Updates(map[string]interface{}{
+		"status":          event.Status,
+		"attempts":        event.Attempts,
+		"next_attempt_at": event.NextAttemptAt,
+		"last_error":      event.LastError,
+	}).Error
+}
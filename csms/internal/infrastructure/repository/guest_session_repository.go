@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+	"gorm.io/gorm"
+)
+
+type GuestSessionRepository struct {
+	db *gorm.DB
+}
+
+func NewGuestSessionRepository(db *gorm.DB) domain.GuestSessionRepository {
+	return &GuestSessionRepository{db: db}
+}
+
+func (r *GuestSessionRepository) Create(ctx context.Context, session *domain.GuestSession) error {
+	return r.db.WithContext(ctx).Omit("Connector", "Payment").Create(session).Error
+}
+
+func (r *GuestSessionRepository) Update(ctx context.Context, session *domain.GuestSession) error {
+	return r.db.WithContext(ctx).Omit("Connector", "Payment").Save(session).Error
+}
+
+func (r *GuestSessionRepository) GetByToken(ctx context.Context, token string) (*domain.GuestSession, error) {
+	var session domain.GuestSession
+	err := r.db.WithContext(ctx).Preload("Connector.ChargePoint").Preload("Payment").
+		Where("token = ?", token).First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *GuestSessionRepository) GetByReceiptToken(ctx context.Context, receiptToken string) (*domain.GuestSession, error) {
+	var session domain.GuestSession
+	err := r.db.WithContext(ctx).Preload("Payment").Where("receipt_token = ?", receiptToken).First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *GuestSessionRepository) MarkReceiptViewed(ctx context.Context, id uint, at time.Time) error {
+	result := r.db.WithContext(ctx).Model(&domain.GuestSession{}).
+		Where("id = ? AND receipt_viewed_at IS NULL", id).
+		Update("receipt_viewed_at", at)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("receipt link already used")
+	}
+	return nil
+}
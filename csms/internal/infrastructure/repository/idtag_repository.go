@@ -56,3 +56,9 @@ func (r *IDTagRepository) ListByUser(ctx context.Context, userID uint) ([]domain
 	err := r.db.WithContext(ctx).Preload("User").Where("user_id = ?", userID).Find(&idTags).Error
 	return idTags, err
 }
+
+func (r *IDTagRepository) ListByParent(ctx context.Context, parentIDTag string) ([]domain.IDTag, error) {
+	var idTags []domain.IDTag
+	err := r.db.WithContext(ctx).Preload("User").Where("parent_id_tag = ?", parentIDTag).Find(&idTags).Error
+	return idTags, err
+}
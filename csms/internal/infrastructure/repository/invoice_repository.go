@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type InvoiceRepository struct {
+	db *gorm.DB
+}
+
+func NewInvoiceRepository(db *gorm.DB) domain.InvoiceRepository {
+	return &InvoiceRepository{db: db}
+}
+
+// Create locks the series row while the invoice is inserted, so numbers stay
+// gapless under concurrent requests: a failed insert rolls the counter back.
+func (r *InvoiceRepository) Create(ctx context.Context, invoice *domain.Invoice, series string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		sequence := domain.InvoiceSequence{Series: series}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&sequence).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("series = ?", series).First(&sequence).Error; err != nil {
+			return err
+		}
+
+		sequence.Last++
+		if err := tx.Model(&sequence).Update("last", sequence.Last).Error; err != nil {
+			return err
+		}
+
+		invoice.Number = fmt.Sprintf("%s-%06d", series, sequence.Last)
+		return tx.Create(invoice).Error
+	})
+}
+
+func (r *InvoiceRepository) GetByID(ctx context.Context, id uint) (*domain.Invoice, error) {
+	var invoice domain.Invoice
+	err := r.db.WithContext(ctx).Preload("Lines").First(&invoice, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+func (r *InvoiceRepository) UpdateDocuments(ctx context.Context, invoice *domain.Invoice) error {
+	return r.db.WithContext(ctx).Model(invoice).
+		Updates(map[string]interface{}{"html": invoice.HTML, "pdf": invoice.PDF}).Error
+}
+
+func (r *InvoiceRepository) GetReceiptByTransaction(ctx context.Context, transactionID uint) (*domain.Invoice, error) {
+	var invoice domain.Invoice
+	err := r.db.WithContext(ctx).Preload("Lines").
+		Where("type = ? AND transaction_id = ?", domain.InvoiceTypeReceipt, transactionID).
+		First(&invoice).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+func (r *InvoiceRepository) GetConsolidated(ctx context.Context, fleetGroup string, periodStart time.Time) (*domain.Invoice, error) {
+	var invoice domain.Invoice
+	err := r.db.WithContext(ctx).Preload("Lines").
+		Where("type = ? AND fleet_group = ? AND period_start = ?", domain.InvoiceTypeConsolidated, fleetGroup, periodStart).
+		First(&invoice).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// List omits the rendered documents, which are only needed for downloads.
+func (r *InvoiceRepository) List(ctx context.Context, limit, offset int) ([]domain.Invoice, error) {
+	var invoices []domain.Invoice
+	err := r.db.WithContext(ctx).Omit("html", "pdf").
+		Order("id DESC").Limit(limit).Offset(offset).Find(&invoices).Error
+	return invoices, err
+}
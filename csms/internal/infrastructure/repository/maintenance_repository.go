@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+	"gorm.io/gorm"
+)
+
+type AlertRuleRepository struct {
+	db *gorm.DB
+}
+
+func NewAlertRuleRepository(db *gorm.DB) domain.AlertRuleRepository {
+	return &AlertRuleRepository{db: db}
+}
+
+func (r *AlertRuleRepository) Create(ctx context.Context, rule *domain.AlertRule) error {
+	return r.db.WithContext(ctx).Create(rule).Error
+}
+
+func (r *AlertRuleRepository) GetByID(ctx context.Context, id uint) (*domain.AlertRule, error) {
+	var rule domain.AlertRule
+	err := r.db.WithContext(ctx).First(&rule, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (r *AlertRuleRepository) Update(ctx context.Context, rule *domain.AlertRule) error {
+	return r.db.WithContext(ctx).Save(rule).Error
+}
+
+func (r *AlertRuleRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&domain.AlertRule{}, id).Error
+}
+
+func (r *AlertRuleRepository) List(ctx context.Context, limit, offset int) ([]domain.AlertRule, error) {
+	var rules []domain.AlertRule
+	err := r.db.WithContext(ctx).Order("id").Limit(limit).Offset(offset).Find(&rules).Error
+	return rules, err
+}
+
+func (r *AlertRuleRepository) ListEnabled(ctx context.Context) ([]domain.AlertRule, error) {
+	var rules []domain.AlertRule
+	err := r.db.WithContext(ctx).Where("disabled = ?", false).Find(&rules).Error
+	return rules, err
+}
+
+type MaintenanceTicketRepository struct {
+	db *gorm.DB
+}
+
+func NewMaintenanceTicketRepository(db *gorm.DB) domain.MaintenanceTicketRepository {
+	return &MaintenanceTicketRepository{db: db}
+}
+
+func (r *MaintenanceTicketRepository) Create(ctx context.Context, ticket *domain.MaintenanceTicket) error {
+	return conn(ctx, r.db).Create(ticket).Error
+}
+
+func (r *MaintenanceTicketRepository) GetByID(ctx context.Context, id uint) (*domain.MaintenanceTicket, error) {
+	var ticket domain.MaintenanceTicket
+	err := conn(ctx, r.db).Preload("ChargePoint").Preload("Assignee").
+		Preload("Comments", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		First(&ticket, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &ticket, nil
+}
+
+func (r *MaintenanceTicketRepository) Update(ctx context.Context, ticket *domain.MaintenanceTicket) error {
+	return conn(ctx, r.db).Omit("ChargePoint", "Assignee", "Comments").Save(ticket).Error
+}
+
+func (r *MaintenanceTicketRepository) List(ctx context.Context, filter domain.TicketFilter) ([]domain.MaintenanceTicket, error) {
+	query := r.db.WithContext(ctx).Preload("ChargePoint").Preload("Assignee")
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.ChargePointID != 0 {
+		query = query.Where("charge_point_id = ?", filter.ChargePointID)
+	}
+	if filter.AssigneeID != 0 {
+		query = query.Where("assignee_id = ?", filter.AssigneeID)
+	}
+
+	var tickets []domain.MaintenanceTicket
+	err := query.Order("id DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&tickets).Error
+	return tickets, err
+}
+
+func (r *MaintenanceTicketRepository) ListActiveByAlertRule(ctx context.Context, alertRuleID uint) ([]domain.MaintenanceTicket, error) {
+	var tickets []domain.MaintenanceTicket
+	err := r.db.WithContext(ctx).
+		Where("alert_rule_id = ? AND status IN ?", alertRuleID, []string{domain.TicketStatusOpen, domain.TicketStatusInProgress}).
+		Find(&tickets).Error
+	return tickets, err
+}
+
+func (r *MaintenanceTicketRepository) GetLatestByAlert(ctx context.Context, alertRuleID, chargePointID uint, connectorID int) (*domain.MaintenanceTicket, error) {
+	var ticket domain.MaintenanceTicket
+	err := r.db.WithContext(ctx).
+		Where("alert_rule_id = ? AND charge_point_id = ? AND connector_id = ?", alertRuleID, chargePointID, connectorID).
+		Order("id DESC").First(&ticket).Error
+	if err != nil {
+		return nil, err
+	}
+	return &ticket, nil
+}
+
+func (r *MaintenanceTicketRepository) AddComment(ctx context.Context, comment *domain.TicketComment) error {
+	return conn(ctx, r.db).Create(comment).Error
+}
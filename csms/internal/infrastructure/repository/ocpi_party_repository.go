@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+	"gorm.io/gorm"
+)
+
+type OCPIPartyRepository struct {
+	db *gorm.DB
+}
+
+func NewOCPIPartyRepository(db *gorm.DB) domain.OCPIPartyRepository {
+	return &OCPIPartyRepository{db: db}
+}
+
+func (r *OCPIPartyRepository) Create(ctx context.Context, party *domain.OCPIParty) error {
+	return r.db.WithContext(ctx).Create(party).Error
+}
+
+func (r *OCPIPartyRepository) Update(ctx context.Context, party *domain.OCPIParty) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("party_id = ?", party.ID).Delete(&domain.OCPIEndpoint{}).Error; err != nil {
+			return err
+		}
+		for i := range party.Endpoints {
+			party.Endpoints[i].ID = 0
+			party.Endpoints[i].PartyID = party.ID
+		}
+		return tx.Save(party).Error
+	})
+}
+
+func (r *OCPIPartyRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("party_id = ?", id).Delete(&domain.OCPIEndpoint{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&domain.OCPIParty{}, id).Error
+	})
+}
+
+func (r *OCPIPartyRepository) GetByID(ctx context.Context, id uint) (*domain.OCPIParty, error) {
+	var party domain.OCPIParty
+	err := r.db.WithContext(ctx).Preload("Endpoints").First(&party, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &party, nil
+}
+
+func (r *OCPIPartyRepository) GetByToken(ctx context.Context, token string) (*domain.OCPIParty, error) {
+	var party domain.OCPIParty
+	err := r.db.WithContext(ctx).Preload("Endpoints").Where("token = ?", token).First(&party).Error
+	if err != nil {
+		return nil, err
+	}
+	return &party, nil
+}
+
+func (r *OCPIPartyRepository) List(ctx context.Context, limit, offset int) ([]domain.OCPIParty, error) {
+	var parties []domain.OCPIParty
+	err := r.db.WithContext(ctx).Preload("Endpoints").Limit(limit).Offset(offset).Find(&parties).Error
+	return parties, err
+}
+
+func (r *OCPIPartyRepository) ListRegistered(ctx context.Context) ([]domain.OCPIParty, error) {
+	var parties []domain.OCPIParty
+	err := r.db.WithContext(ctx).Preload("Endpoints").
+		Where("status = ?", domain.OCPIPartyStatusRegistered).Find(&parties).Error
+	return parties, err
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+	"gorm.io/gorm"
+)
+
+type PaymentRepository struct {
+	db *gorm.DB
+}
+
+func NewPaymentRepository(db *gorm.DB) domain.PaymentRepository {
+	return &PaymentRepository{db: db}
+}
+
+func (r *PaymentRepository) Create(ctx context.Context, payment *domain.Payment) error {
+	return r.db.WithContext(ctx).Create(payment).Error
+}
+
+func (r *PaymentRepository) Update(ctx context.Context, payment *domain.Payment) error {
+	return r.db.WithContext(ctx).Save(payment).Error
+}
+
+func (r *PaymentRepository) GetByID(ctx context.Context, id uint) (*domain.Payment, error) {
+	var payment domain.Payment
+	err := r.db.WithContext(ctx).First(&payment, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+func (r *PaymentRepository) GetByReference(ctx context.Context, reference string) (*domain.Payment, error) {
+	var payment domain.Payment
+	err := r.db.WithContext(ctx).Where("reference = ?", reference).First(&payment).Error
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+func (r *PaymentRepository) GetPending(ctx context.Context, chargePointID uint, connectorID int, idTag string, since time.Time) (*domain.Payment, error) {
+	var payment domain.Payment
+	err := r.db.WithContext(ctx).
+		Where("charge_point_id = ? AND connector_id = ? AND id_tag = ?", chargePointID, connectorID, idTag).
+		Where("status = ? AND transaction_id IS NULL AND created_at >= ?", domain.PaymentStatusAuthorized, since).
+		Order("created_at DESC").
+		First(&payment).Error
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+func (r *PaymentRepository) ListExpired(ctx context.Context, before time.Time) ([]domain.Payment, error) {
+	var payments []domain.Payment
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND transaction_id IS NULL AND created_at < ?", domain.PaymentStatusAuthorized, before).
+		Find(&payments).Error
+	return payments, err
+}
+
+func (r *PaymentRepository) List(ctx context.Context, limit, offset int) ([]domain.Payment, error) {
+	var payments []domain.Payment
+	err := r.db.WithContext(ctx).Order("created_at DESC").Limit(limit).Offset(offset).Find(&payments).Error
+	return payments, err
+}
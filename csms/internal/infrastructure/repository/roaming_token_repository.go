@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RoamingTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewRoamingTokenRepository(db *gorm.DB) domain.RoamingTokenRepository {
+	return &RoamingTokenRepository{db: db}
+}
+
+func (r *RoamingTokenRepository) Upsert(ctx context.Context, token *domain.RoamingToken) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "country_code"}, {Name: "party_id"}, {Name: "uid"}, {Name: "type"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"ocpi_party_id", "contract_id", "visual_number", "issuer", "group_id",
+			"valid", "whitelist", "language", "last_updated", "updated_at",
+		}),
+	}).Create(token).Error
+}
+
+func (r *RoamingTokenRepository) Get(ctx context.Context, countryCode, partyID, uid, tokenType string) (*domain.RoamingToken, error) {
+	var token domain.RoamingToken
+	err := r.db.WithContext(ctx).
+		Where("country_code = ? AND party_id = ? AND uid = ? AND type = ?", countryCode, partyID, uid, tokenType).
+		First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *RoamingTokenRepository) GetByUID(ctx context.Context, uid string) (*domain.RoamingToken, error) {
+	var token domain.RoamingToken
+	err := r.db.WithContext(ctx).Where("uid = ?", uid).Order("last_updated DESC").First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *RoamingTokenRepository) GetByPartyAndUID(ctx context.Context, ocpiPartyID uint, uid string) (*domain.RoamingToken, error) {
+	var token domain.RoamingToken
+	err := r.db.WithContext(ctx).Where("ocpi_party_id = ? AND uid = ?", ocpiPartyID, uid).
+		Order("last_updated DESC").First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+	"gorm.io/gorm"
+)
+
+type TariffRepository struct {
+	db *gorm.DB
+}
+
+func NewTariffRepository(db *gorm.DB) domain.TariffRepository {
+	return &TariffRepository{db: db}
+}
+
+func (r *TariffRepository) Create(ctx context.Context, tariff *domain.Tariff) error {
+	return r.db.WithContext(ctx).Create(tariff).Error
+}
+
+func (r *TariffRepository) GetByID(ctx context.Context, id uint) (*domain.Tariff, error) {
+	var tariff domain.Tariff
+	err := r.db.WithContext(ctx).Preload("Elements").First(&tariff, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &tariff, nil
+}
+
+// Update saves the tariff and replaces its elements with the given ones.
+func (r *TariffRepository) Update(ctx context.Context, tariff *domain.Tariff) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("tariff_id = ?", tariff.ID).Delete(&domain.TariffElement{}).Error; err != nil {
+			return err
+		}
+		for i := range tariff.Elements {
+			tariff.Elements[i].ID = 0
+			tariff.Elements[i].TariffID = tariff.ID
+		}
+		return tx.Session(&gorm.Session{FullSaveAssociations: true}).Save(tariff).Error
+	})
+}
+
+func (r *TariffRepository) Supersede(ctx context.Context, previous *domain.Tariff, next *domain.Tariff, at time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if previous.ValidTo == nil || previous.ValidTo.After(at) {
+			if err := tx.Model(previous).Update("valid_to", at).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(next).Error
+	})
+}
+
+func (r *TariffRepository) InUse(ctx context.Context, id uint) (bool, error) {
+	var transactions int64
+	if err := r.db.WithContext(ctx).Model(&domain.Transaction{}).Where("tariff_id = ?", id).Count(&transactions).Error; err != nil {
+		return false, err
+	}
+	if transactions > 0 {
+		return true, nil
+	}
+
+	var adjustments int64
+	err := r.db.WithContext(ctx).Model(&domain.TransactionAdjustment{}).
+		Where("tariff_id = ? OR original_tariff_id = ?", id, id).Count(&adjustments).Error
+	return adjustments > 0, err
+}
+
+func (r *TariffRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("tariff_id = ?", id).Delete(&domain.TariffElement{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&domain.Tariff{}, id).Error
+	})
+}
+
+func (r *TariffRepository) List(ctx context.Context, limit, offset int) ([]domain.Tariff, error) {
+	var tariffs []domain.Tariff
+	err := r.db.WithContext(ctx).Preload("Elements").Limit(limit).Offset(offset).Find(&tariffs).Error
+	return tariffs, err
+}
+
+func (r *TariffRepository) ListValidAt(ctx context.Context, at time.Time) ([]domain.Tariff, error) {
+	var tariffs []domain.Tariff
+	err := r.db.WithContext(ctx).Preload("Elements").
+		Where("(valid_from IS NULL OR valid_from <= ?) AND (valid_to IS NULL OR valid_to > ?)", at, at).
+		Find(&tariffs).Error
+	return tariffs, err
+}
+
+func (r *TariffRepository) ListPublic(ctx context.Context, query domain.OCPIQuery) ([]domain.Tariff, int64, error) {
+	db := r.db.WithContext(ctx).Model(&domain.Tariff{}).Where("user_group = ''")
+	if query.DateFrom != nil {
+		db = db.Where("updated_at >= ?", *query.DateFrom)
+	}
+	if query.DateTo != nil {
+		db = db.Where("updated_at < ?", *query.DateTo)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var tariffs []domain.Tariff
+	err := db.Preload("Elements").Order("id").Limit(query.Limit).Offset(query.Offset).Find(&tariffs).Error
+	return tariffs, total, err
+}
@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+	"gorm.io/gorm"
+)
+
+type TransactionAdjustmentRepository struct {
+	db *gorm.DB
+}
+
+func NewTransactionAdjustmentRepository(db *gorm.DB) domain.TransactionAdjustmentRepository {
+	return &TransactionAdjustmentRepository{db: db}
+}
+
+func (r *TransactionAdjustmentRepository) Apply(ctx context.Context, transaction *domain.Transaction, adjustment *domain.TransactionAdjustment) error {
+	return conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(adjustment).Error; err != nil {
+			return err
+		}
+		return tx.Save(transaction).Error
+	})
+}
+
+func (r *TransactionAdjustmentRepository) ListByTransaction(ctx context.Context, transactionID uint) ([]domain.TransactionAdjustment, error) {
+	var adjustments []domain.TransactionAdjustment
+	err := r.db.WithContext(ctx).Where("transaction_id = ?", transactionID).Order("created_at").Find(&adjustments).Error
+	return adjustments, err
+}
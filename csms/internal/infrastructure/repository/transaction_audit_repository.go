@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+	"gorm.io/gorm"
+)
+
+type TransactionAuditRepository struct {
+	db *gorm.DB
+}
+
+func NewTransactionAuditRepository(db *gorm.DB) domain.TransactionAuditRepository {
+	return &TransactionAuditRepository{db: db}
+}
+
+func (r *TransactionAuditRepository) Create(ctx context.Context, audit *domain.TransactionAudit) error {
+	return conn(ctx, r.db).Create(audit).Error
+}
+
+func (r *TransactionAuditRepository) ListByTransaction(ctx context.Context, transactionID uint) ([]domain.TransactionAudit, error) {
+	var audits []domain.TransactionAudit
+	err := conn(ctx, r.db).Where("transaction_id = ?", transactionID).Order("created_at").Find(&audits).Error
+	return audits, err
+}
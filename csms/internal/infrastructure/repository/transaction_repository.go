@@ -16,12 +16,19 @@ func NewTransactionRepository(db *gorm.DB) domain.TransactionRepository {
 }
 
 func (r *TransactionRepository) Create(ctx context.Context, transaction *domain.Transaction) error {
-	return r.db.WithContext(ctx).Create(transaction).Error
+	return conn(ctx, r.db).Create(transaction).Error
+}
+
+// NextTransactionID draws the id a charge point refers to the transaction by.
+func (r *TransactionRepository) NextTransactionID(ctx context.Context) (int, error) {
+	var id int
+	err := conn(ctx, r.db).Raw("SELECT nextval('ocpp_transaction_id_seq')").Scan(&id).Error
+	return id, err
 }
 
 func (r *TransactionRepository) GetByID(ctx context.Context, id uint) (*domain.Transaction, error) {
 	var transaction domain.Transaction
-	err := r.db.WithContext(ctx).Preload("ChargePoint").Preload("IDTag").First(&transaction, id).Error
+	err := conn(ctx, r.db).Preload("ChargePoint").Preload("IDTag").Preload("Payment").First(&transaction, id).Error
 	if err != nil {
 		return nil, err
 	}
@@ -30,7 +37,7 @@ func (r *TransactionRepository) GetByID(ctx context.Context, id uint) (*domain.T
 
 func (r *TransactionRepository) GetByTransactionID(ctx context.Context, transactionID int) (*domain.Transaction, error) {
 	var transaction domain.Transaction
-	err := r.db.WithContext(ctx).Preload("ChargePoint").Preload("IDTag").Where("transaction_id = ?", transactionID).First(&transaction).Error
+	err := conn(ctx, r.db).Preload("ChargePoint").Preload("IDTag").Preload("Payment").Where("transaction_id = ?", transactionID).First(&transaction).Error
 	if err != nil {
 		return nil, err
 	}
@@ -38,38 +45,140 @@ func (r *TransactionRepository) GetByTransactionID(ctx context.Context, transact
 }
 
 func (r *TransactionRepository) Update(ctx context.Context, transaction *domain.Transaction) error {
-	return r.db.WithContext(ctx).Save(transaction).Error
+	return conn(ctx, r.db).Save(transaction).Error
 }
 
 func (r *TransactionRepository) Delete(ctx context.Context, id uint) error {
-	return r.db.WithContext(ctx).Delete(&domain.Transaction{}, id).Error
+	return conn(ctx, r.db).Delete(&domain.Transaction{}, id).Error
 }
 
 func (r *TransactionRepository) List(ctx context.Context, limit, offset int) ([]domain.Transaction, error) {
 	var transactions []domain.Transaction
-	err := r.db.WithContext(ctx).Preload("ChargePoint").Preload("IDTag").Limit(limit).Offset(offset).Find(&transactions).Error
+	err := conn(ctx, r.db).Preload("ChargePoint").Preload("IDTag").Limit(limit).Offset(offset).Find(&transactions).Error
 	return transactions, err
 }
 
 func (r *TransactionRepository) ListByChargePoint(ctx context.Context, chargePointID uint) ([]domain.Transaction, error) {
 	var transactions []domain.Transaction
-	err := r.db.WithContext(ctx).Preload("ChargePoint").Preload("IDTag").Where("charge_point_id = ?", chargePointID).Find(&transactions).Error
+	err := conn(ctx, r.db).Preload("ChargePoint").Preload("IDTag").Where("charge_point_id = ?", chargePointID).Find(&transactions).Error
 	return transactions, err
 }
 
 func (r *TransactionRepository) ListByUser(ctx context.Context, idTag string) ([]domain.Transaction, error) {
 	var transactions []domain.Transaction
-	err := r.db.WithContext(ctx).Preload("ChargePoint").Preload("IDTag").Where("id_tag_id = ?", idTag).Find(&transactions).Error
+	err := conn(ctx, r.db).Preload("ChargePoint").Preload("IDTag").Where("id_tag_id = ?", idTag).Find(&transactions).Error
 	return transactions, err
 }
 
 func (r *TransactionRepository) GetActiveByConnector(ctx context.Context, chargePointID uint, connectorID int) (*domain.Transaction, error) {
 	var transaction domain.Transaction
-	err := r.db.WithContext(ctx).Preload("ChargePoint").Preload("IDTag").
+	err := conn(ctx, r.db).Preload("ChargePoint").Preload("IDTag").
 		Where("charge_point_id = ? AND connector_id = ? AND status = ?", chargePointID, connectorID, domain.TransactionStatusActive).
+		Order("start_time DESC").
 		First(&transaction).Error
 	if err != nil {
 		return nil, err
 	}
 	return &transaction, nil
 }
+
+func (r *TransactionRepository) ListActiveByChargePoint(ctx context.Context, chargePointID uint) ([]domain.Transaction, error) {
+	var transactions []domain.Transaction
+	err := conn(ctx, r.db).Preload("ChargePoint").Preload("IDTag").
+		Where("charge_point_id = ? AND status = ?", chargePointID, domain.TransactionStatusActive).
+		Find(&transactions).Error
+	return transactions, err
+}
+
+func (r *TransactionRepository) ListByStatus(ctx context.Context, status string) ([]domain.Transaction, error) {
+	var transactions []domain.Transaction
+	err := conn(ctx, r.db).Preload("ChargePoint").Preload("IDTag").Where("status = ?", status).Find(&transactions).Error
+	return transactions, err
+}
+
+func (r *TransactionRepository) CountActiveByIDTags(ctx context.Context, idTagIDs []uint) (int64, error) {
+	var count int64
+	err := conn(ctx, r.db).Model(&domain.Transaction{}).
+		Where("id_tag_id IN ? AND status = ?", idTagIDs, domain.TransactionStatusActive).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *TransactionRepository) ListCompleted(ctx context.Context, filter domain.TransactionFilter) ([]domain.Transaction, error) {
+	var transactions []domain.Transaction
+	err := completedQuery(conn(ctx, r.db), filter).Preload("ChargePoint").Preload("IDTag").Preload("Payment").
+		Order("transactions.start_time").Find(&transactions).Error
+	return transactions, err
+}
+
+func (r *TransactionRepository) SumCompleted(ctx context.Context, filter domain.TransactionFilter) (*domain.TransactionTotals, error) {
+	var totals domain.TransactionTotals
+	err := completedQuery(r.db.WithContext(ctx).Model(&domain.Transaction{}), filter).
+		Select("COUNT(*) AS count, COALESCE(SUM(transactions.energy_consumed), 0) AS energy_consumed").
+		Scan(&totals).Error
+	return &totals, err
+}
+
+func (r *TransactionRepository) CountByStatus(ctx context.Context) ([]domain.GroupCount, error) {
+	var counts []domain.GroupCount
+	err := r.db.WithContext(ctx).Model(&domain.Transaction{}).
+		Select("status AS key, COUNT(*) AS count").
+		Group("status").
+		Scan(&counts).Error
+	return counts, err
+}
+
+// completedQuery selects the completed transactions matching the filter.
+func completedQuery(query *gorm.DB, filter domain.TransactionFilter) *gorm.DB {
+	query = query.Where("transactions.status = ?", domain.TransactionStatusCompleted)
+
+	if len(filter.IDs) > 0 {
+		query = query.Where("transactions.id IN ?", filter.IDs)
+	}
+	if filter.ChargePointID != nil {
+		query = query.Where("transactions.charge_point_id = ?", *filter.ChargePointID)
+	}
+	if filter.Site != "" {
+		query = query.Joins("JOIN charge_points ON charge_points.id = transactions.charge_point_id").
+			Where("charge_points.site = ?", filter.Site)
+	}
+	if filter.UserGroup != "" {
+		query = query.Joins("JOIN id_tags ON id_tags.id = transactions.id_tag_id").
+			Joins("JOIN users ON users.id = id_tags.user_id").
+			Where("users.user_group = ?", filter.UserGroup)
+	}
+	if filter.From != nil {
+		query = query.Where("transactions.start_time >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("transactions.start_time < ?", *filter.To)
+	}
+	if filter.Unsettled {
+		query = query.Where("transactions.payment_id IS NULL").
+			Where("NOT EXISTS (SELECT 1 FROM wallet_entries WHERE wallet_entries.transaction_id = transactions.id AND wallet_entries.type = ?)", domain.WalletEntryCharge)
+	}
+	return query
+}
+
+func (r *TransactionRepository) ListByParty(ctx context.Context, partyID uint, completedOnly bool, query domain.OCPIQuery) ([]domain.Transaction, int64, error) {
+	db := conn(ctx, r.db).Model(&domain.Transaction{}).Where("ocpi_party_id = ?", partyID)
+	if completedOnly {
+		db = db.Where("status = ?", domain.TransactionStatusCompleted)
+	}
+	if query.DateFrom != nil {
+		db = db.Where("updated_at >= ?", *query.DateFrom)
+	}
+	if query.DateTo != nil {
+		db = db.Where("updated_at < ?", *query.DateTo)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var transactions []domain.Transaction
+	err := db.Preload("ChargePoint").Preload("IDTag").Order("id").
+		Limit(query.Limit).Offset(query.Offset).Find(&transactions).Error
+	return transactions, total, err
+}
@@ -54,3 +54,30 @@ func (r *UserRepository) List(ctx context.Context, limit, offset int) ([]domain.
 func (r *UserRepository) UpdateStatus(ctx context.Context, id uint, status string) error {
 	return r.db.WithContext(ctx).Model(&domain.User{}).Where("id = ?", id).Update("status", status).Error
 }
+
+func (r *UserRepository) ListGroups(ctx context.Context) ([]string, error) {
+	var groups []string
+	err := r.db.WithContext(ctx).Model(&domain.User{}).
+		Where("user_group <> ''").
+		Distinct().Order("user_group").
+		Pluck("user_group", &groups).Error
+	return groups, err
+}
+
+func (r *UserRepository) CountByRole(ctx context.Context) ([]domain.GroupCount, error) {
+	var counts []domain.GroupCount
+	err := r.db.WithContext(ctx).Model(&domain.User{}).
+		Select("role AS key, COUNT(*) AS count").
+		Group("role").
+		Scan(&counts).Error
+	return counts, err
+}
+
+func (r *UserRepository) CountByStatus(ctx context.Context) ([]domain.GroupCount, error) {
+	var counts []domain.GroupCount
+	err := r.db.WithContext(ctx).Model(&domain.User{}).
+		Select("status AS key, COUNT(*) AS count").
+		Group("status").
+		Scan(&counts).Error
+	return counts, err
+}
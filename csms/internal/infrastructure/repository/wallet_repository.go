@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WalletRepository struct {
+	db *gorm.DB
+}
+
+func NewWalletRepository(db *gorm.DB) domain.WalletRepository {
+	return &WalletRepository{db: db}
+}
+
+func (r *WalletRepository) Create(ctx context.Context, wallet *domain.Wallet) error {
+	return conn(ctx, r.db).Omit("User").Create(wallet).Error
+}
+
+func (r *WalletRepository) GetByID(ctx context.Context, id uint) (*domain.Wallet, error) {
+	var wallet domain.Wallet
+	err := conn(ctx, r.db).Preload("User").First(&wallet, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+func (r *WalletRepository) GetByUserID(ctx context.Context, userID uint) (*domain.Wallet, error) {
+	var wallet domain.Wallet
+	err := conn(ctx, r.db).Where("user_id = ?", userID).First(&wallet).Error
+	if err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// Update saves the wallet settings; the balance only changes through entries.
+func (r *WalletRepository) Update(ctx context.Context, wallet *domain.Wallet) error {
+	return conn(ctx, r.db).Model(wallet).Update("min_balance", wallet.MinBalance).Error
+}
+
+func (r *WalletRepository) List(ctx context.Context, limit, offset int) ([]domain.Wallet, error) {
+	var wallets []domain.Wallet
+	err := conn(ctx, r.db).Preload("User").Limit(limit).Offset(offset).Find(&wallets).Error
+	return wallets, err
+}
+
+func (r *WalletRepository) AddEntry(ctx context.Context, walletID uint, entry *domain.WalletEntry) (*domain.Wallet, error) {
+	var wallet domain.Wallet
+	err := conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&wallet, walletID).Error; err != nil {
+			return err
+		}
+		return addEntry(tx, &wallet, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+func (r *WalletRepository) ChargeTransaction(ctx context.Context, walletID uint, transactionID uint, amount int64, description string) (*domain.Wallet, error) {
+	var wallet domain.Wallet
+	err := conn(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&wallet, walletID).Error; err != nil {
+			return err
+		}
+
+		var charged int64
+		if err := tx.Model(&domain.WalletEntry{}).
+			Where("wallet_id = ? AND transaction_id = ?", walletID, transactionID).
+			Select("COALESCE(SUM(amount), 0)").Scan(&charged).Error; err != nil {
+			return err
+		}
+
+		// Charges are booked as negative amounts.
+		difference := -amount - charged
+		if difference == 0 {
+			return nil
+		}
+
+		return addEntry(tx, &wallet, &domain.WalletEntry{
+			Type:          domain.WalletEntryCharge,
+			Amount:        difference,
+			TransactionID: &transactionID,
+			Description:   description,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+func (r *WalletRepository) ChargedAmount(ctx context.Context, transactionID uint) (int64, error) {
+	var charged int64
+	err := conn(ctx, r.db).Model(&domain.WalletEntry{}).
+		Where("transaction_id = ? AND type = ?", transactionID, domain.WalletEntryCharge).
+		Select("COALESCE(SUM(amount), 0)").Scan(&charged).Error
+	// Charges are booked as negative amounts.
+	return -charged, err
+}
+
+// addEntry books an entry on a wallet locked by the surrounding transaction.
+func addEntry(tx *gorm.DB, wallet *domain.Wallet, entry *domain.WalletEntry) error {
+	wallet.Balance += entry.Amount
+	entry.WalletID = wallet.ID
+	entry.BalanceAfter = wallet.Balance
+
+	if err := tx.Create(entry).Error; err != nil {
+		return err
+	}
+	return tx.Model(wallet).Update("balance", wallet.Balance).Error
+}
+
+func (r *WalletRepository) ListEntries(ctx context.Context, walletID uint, limit, offset int) ([]domain.WalletEntry, error) {
+	var entries []domain.WalletEntry
+	err := conn(ctx, r.db).Where("wallet_id = ?", walletID).
+		Order("id DESC").Limit(limit).Offset(offset).Find(&entries).Error
+	return entries, err
+}
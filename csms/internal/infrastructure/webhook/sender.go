@@ -0,0 +1,61 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/malikkhoiri/csms/internal/domain"
+	"github.com/malikkhoiri/csms/internal/infrastructure/authorization"
+)
+
+const (
+	// EventHeader names the type of the event delivered.
+	EventHeader = "X-CSMS-Event"
+	// DeliveryHeader carries the delivery ID, the same on every attempt.
+	DeliveryHeader = "X-CSMS-Delivery"
+
+	// maxResponseBody is how much of a subscriber's answer is kept.
+	maxResponseBody = 1024
+)
+
+// Sender posts webhook deliveries, signed like the authorization webhook.
+type Sender struct {
+	httpClient *http.Client
+}
+
+func NewSender(timeout time.Duration) domain.WebhookSender {
+	return &Sender{
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+func (s *Sender) Send(ctx context.Context, request *domain.WebhookRequest) (*domain.WebhookResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, request.URL, bytes.NewReader(request.Payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, request.EventType)
+	req.Header.Set(DeliveryHeader, strconv.FormatUint(uint64(request.DeliveryID), 10))
+	req.Header.Set(authorization.SignatureHeader, authorization.Sign(request.Secret, request.Payload))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.WebhookResponse{
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+	}, nil
+}
@@ -0,0 +1,143 @@
+// Package pdf writes simple text-only PDF documents on A4 pages using the
+// standard PDF fonts, which is all receipts and invoices need.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const (
+	pageWidth     = 595.28
+	pageHeight    = 841.89
+	margin        = 50.0
+	lineSpacing   = 1.4
+	fontRegular   = "F1"
+	fontBold      = "F2"
+	fontMonospace = "F3"
+)
+
+type textLine struct {
+	font string
+	size float64
+	x    float64
+	y    float64
+	text string
+}
+
+// Document collects lines of text top to bottom and breaks pages
+// automatically.
+type Document struct {
+	pages [][]textLine
+	y     float64
+}
+
+func New() *Document {
+	d := &Document{}
+	d.newPage()
+	return d
+}
+
+// Text writes a line in Helvetica.
+func (d *Document) Text(text string, size float64) {
+	d.write(fontRegular, size, text)
+}
+
+// Bold writes a line in Helvetica Bold.
+func (d *Document) Bold(text string, size float64) {
+	d.write(fontBold, size, text)
+}
+
+// Mono writes a line in Courier, which keeps padded columns aligned.
+func (d *Document) Mono(text string, size float64) {
+	d.write(fontMonospace, size, text)
+}
+
+// Space moves down by the given number of points.
+func (d *Document) Space(height float64) {
+	d.y -= height
+}
+
+func (d *Document) write(font string, size float64, text string) {
+	height := size * lineSpacing
+	if d.y-height < margin {
+		d.newPage()
+	}
+	d.y -= height
+
+	page := len(d.pages) - 1
+	d.pages[page] = append(d.pages[page], textLine{font: font, size: size, x: margin, y: d.y, text: text})
+}
+
+func (d *Document) newPage() {
+	d.pages = append(d.pages, []textLine{})
+	d.y = pageHeight - margin
+}
+
+// Bytes renders the document.
+func (d *Document) Bytes() []byte {
+	var buf bytes.Buffer
+	offsets := []int{}
+
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+
+	// Objects 1-5 are the catalog, the page tree and the fonts; every page
+	// then takes a page object followed by its content stream.
+	kids := []string{}
+	for i := range d.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 6+2*i))
+	}
+
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+
+	for i, lines := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] "+
+			"/Resources << /Font << /%s 3 0 R /%s 4 0 R /%s 5 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, fontRegular, fontBold, fontMonospace, 7+2*i))
+
+		var content bytes.Buffer
+		for _, line := range lines {
+			fmt.Fprintf(&content, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", line.font, line.size, line.x, line.y, escape(line.text))
+		}
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return buf.Bytes()
+}
+
+// escape converts text to WinAnsi bytes, replacing characters outside
+// Latin-1, and escapes the string delimiters.
+func escape(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteByte(byte(r))
+		case r < 32:
+			b.WriteByte(' ')
+		case r < 256:
+			b.WriteByte(byte(r))
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
@@ -1,7 +1,13 @@
 package server
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"math"
+	"slices"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -9,9 +15,19 @@ import (
 	"github.com/malikkhoiri/csms/internal/config"
 	"github.com/malikkhoiri/csms/internal/domain"
 	"github.com/malikkhoiri/csms/internal/handler/http"
+	ocpihttp "github.com/malikkhoiri/csms/internal/handler/ocpi"
 	"github.com/malikkhoiri/csms/internal/handler/ws"
+	"github.com/malikkhoiri/csms/internal/infrastructure/authorization"
+	"github.com/malikkhoiri/csms/internal/infrastructure/cache"
+	"github.com/malikkhoiri/csms/internal/infrastructure/cluster"
 	"github.com/malikkhoiri/csms/internal/infrastructure/database"
+	"github.com/malikkhoiri/csms/internal/infrastructure/events"
+	"github.com/malikkhoiri/csms/internal/infrastructure/metrics"
+	"github.com/malikkhoiri/csms/internal/infrastructure/ocpi"
+	"github.com/malikkhoiri/csms/internal/infrastructure/payment"
 	"github.com/malikkhoiri/csms/internal/infrastructure/repository"
+	"github.com/malikkhoiri/csms/internal/infrastructure/webhook"
+	"github.com/redis/go-redis/v9"
 )
 
 type Server struct {
@@ -19,12 +35,41 @@ type Server struct {
 	port   string
 	config *config.Config
 
-	chargePointService domain.ChargePointService
-	transactionService domain.TransactionService
-	userService        domain.UserService
-	connectorService   domain.ConnectorService
-	idTagService       domain.IDTagService
-	authService        domain.AuthService
+	chargePointService   domain.ChargePointService
+	transactionService   domain.TransactionService
+	userService          domain.UserService
+	connectorService     domain.ConnectorService
+	idTagService         domain.IDTagService
+	authService          domain.AuthService
+	accessRuleService    domain.AccessRuleService
+	tariffService        domain.TariffService
+	transactionFeed      *service.TransactionBroadcaster
+	recalculationService domain.CostRecalculationService
+	invoiceService       domain.InvoiceService
+	walletService        domain.WalletService
+	paymentService       domain.PaymentService
+	guestService         domain.GuestService
+	ocpiService          domain.OCPIService
+	roamingTokenService  domain.RoamingTokenService
+	authorizationChain   domain.AuthorizationChain
+	authorizationCache   domain.AuthorizationCache
+	webhookService       domain.WebhookService
+	eventFeed            *service.EventFeed
+	maintenanceService   domain.MaintenanceService
+	availabilityService  domain.AvailabilityService
+	monitoringService    domain.MonitoringService
+	metrics              *metrics.Prometheus
+
+	connections *ws.ConnectionManager
+
+	stuckTransactionMonitor *service.StuckTransactionMonitor
+	monthlyInvoiceJob       *service.MonthlyInvoiceJob
+	paymentExpiryJob        *service.PaymentExpiryJob
+	eventDispatcher         *service.EventDispatcher
+	eventRetentionJob       *service.EventRetentionJob
+	webhookDeliveryJob      *service.WebhookDeliveryJob
+	alertMonitor            *service.AlertMonitor
+	ocpiPusher              *service.OCPIPusher
 }
 
 func NewServer(cfg *config.Config) (*Server, error) {
@@ -43,31 +88,209 @@ func NewServer(cfg *config.Config) (*Server, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := database.MigrateLegacyCosts(postgresDB.DB, cfg.Tariff.Currency); err != nil {
+		return nil, fmt.Errorf("failed to migrate transaction costs: %w", err)
+	}
 
 	chargePointRepo := repository.NewChargePointRepository(postgresDB.DB)
 	connectorRepo := repository.NewConnectorRepository(postgresDB.DB)
 	transactionRepo := repository.NewTransactionRepository(postgresDB.DB)
 	userRepo := repository.NewUserRepository(postgresDB.DB)
 	idTagRepo := repository.NewIDTagRepository(postgresDB.DB)
+	transactionAuditRepo := repository.NewTransactionAuditRepository(postgresDB.DB)
+	accessRuleRepo := repository.NewAccessRuleRepository(postgresDB.DB)
+	tariffRepo := repository.NewTariffRepository(postgresDB.DB)
+	transactionAdjustmentRepo := repository.NewTransactionAdjustmentRepository(postgresDB.DB)
+	invoiceRepo := repository.NewInvoiceRepository(postgresDB.DB)
+	walletRepo := repository.NewWalletRepository(postgresDB.DB)
+	paymentRepo := repository.NewPaymentRepository(postgresDB.DB)
+	guestSessionRepo := repository.NewGuestSessionRepository(postgresDB.DB)
+	ocpiPartyRepo := repository.NewOCPIPartyRepository(postgresDB.DB)
+	roamingTokenRepo := repository.NewRoamingTokenRepository(postgresDB.DB)
+	eventRepo := repository.NewEventRepository(postgresDB.DB)
+	unitOfWork := repository.NewUnitOfWork(postgresDB.DB)
+	webhookSubscriptionRepo := repository.NewWebhookSubscriptionRepository(postgresDB.DB)
+	webhookDeliveryRepo := repository.NewWebhookDeliveryRepository(postgresDB.DB)
+	connectorErrorRepo := repository.NewConnectorErrorRepository(postgresDB.DB)
+	statusChangeRepo := repository.NewConnectorStatusChangeRepository(postgresDB.DB)
+	connectionChangeRepo := repository.NewChargePointConnectionChangeRepository(postgresDB.DB)
+	alertRuleRepo := repository.NewAlertRuleRepository(postgresDB.DB)
+	ticketRepo := repository.NewMaintenanceTicketRepository(postgresDB.DB)
+
+	paymentGateway, err := newPaymentGateway(cfg.Payment)
+	if err != nil {
+		return nil, err
+	}
 
-	chargePointService := service.NewChargePointService(chargePointRepo, connectorRepo)
-	transactionService := service.NewTransactionService(transactionRepo, chargePointRepo, idTagRepo, cfg.Tariff)
+	// Redis is only connected when something is configured to use it
+	var redisClient *redis.Client
+	if cfg.Authorization.Cache.Store == "redis" ||
+		(cfg.Cluster.Enabled && cfg.Cluster.Directory == "redis") ||
+		slices.Contains(cfg.Events.Sinks, "redis") {
+		redisClient, err = database.NewRedisClient(&cfg.Redis)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	directory, err := newConnectionDirectory(cfg.Cluster, redisClient)
+	if err != nil {
+		return nil, err
+	}
+
+	eventSinks, err := newEventSinks(cfg.Events, redisClient)
+	if err != nil {
+		return nil, err
+	}
+	eventBus := service.NewEventBus(eventRepo, eventSinks, cfg.Events)
+	webhookService := service.NewWebhookService(webhookSubscriptionRepo, webhookDeliveryRepo, webhook.NewSender(cfg.Webhook.Timeout), eventBus, cfg.Webhook)
+	eventBus.Subscribe("*", webhookService.HandleEvent)
+
+	ticketNotifiers, err := newTicketNotifiers(cfg.Maintenance, eventBus)
+	if err != nil {
+		return nil, err
+	}
+	maintenanceService := service.NewMaintenanceService(alertRuleRepo, ticketRepo, chargePointRepo, connectorRepo, connectorErrorRepo, userRepo, unitOfWork, ticketNotifiers)
+
+	chargePointService := service.NewChargePointService(chargePointRepo, connectorRepo, connectionChangeRepo, unitOfWork, eventBus)
+	connections := ws.NewConnectionManager(chargePointService, directory, cfg.OCPP, cfg.Cluster)
+
+	sqlDB, err := postgresDB.DB.DB()
+	if err != nil {
+		return nil, err
+	}
+	prometheusMetrics := metrics.NewPrometheus(
+		sqlDB,
+		func() float64 { return float64(connections.Count()) },
+		activeTransactionsGauge(transactionRepo),
+	)
+	router.Use(http.MetricsMiddleware(prometheusMetrics))
+	eventBus.Subscribe(domain.EventTransactionStopped, prometheusMetrics.HandleTransactionStopped)
+	tariffService := service.NewTariffService(tariffRepo, chargePointRepo, userRepo, cfg.Tariff)
+	eventFeed := service.NewEventFeed(eventRepo, cfg.Events.StreamInterval, cfg.Events.StreamLag)
+	transactionBroadcaster := service.NewTransactionBroadcaster(eventFeed, transactionRepo)
+	ocpiClient := ocpi.NewClient(cfg.OCPI.PushTimeout)
+	ocpiPusher := service.NewOCPIPusher(ocpiPartyRepo, chargePointRepo, connectorRepo, transactionRepo, roamingTokenRepo, ocpiClient, cfg.OCPI, cfg.Tariff)
+	authorizationStore, err := newTagDecisionStore(cfg.Authorization.Cache, cfg.Cluster, redisClient)
+	if err != nil {
+		return nil, err
+	}
+	authorizationCache := service.NewAuthorizationCache(authorizationStore, cfg.Authorization.Cache.TTL)
+	roamingTokenService := service.NewRoamingTokenService(roamingTokenRepo, ocpiPartyRepo, idTagRepo, userRepo, chargePointRepo, ocpiClient, authorizationCache, cfg.OCPI)
+	authorizationBackends, err := newAuthorizationBackends(cfg.Authorization, idTagRepo, authorizationCache, roamingTokenService)
+	if err != nil {
+		return nil, err
+	}
+	authorizationChain := service.NewAuthorizationChain(idTagRepo, userRepo, authorizationBackends, cfg.Authorization)
+
+	// Roaming partners are told about connector and session changes only
+	// while the OCPI interface is enabled.
+	notifier := service.NewNotificationGroup(webhookService)
+	if cfg.OCPI.Enabled {
+		notifier = service.NewNotificationGroup(webhookService, ocpiPusher)
+	}
+
+	paymentService := service.NewPaymentService(
+		paymentRepo,
+		chargePointRepo,
+		connectorRepo,
+		idTagRepo,
+		paymentGateway,
+		connections,
+		tariffService,
+		cfg.Payment,
+	)
+	transactionService := service.NewTransactionService(
+		transactionRepo,
+		chargePointRepo,
+		connectorRepo,
+		idTagRepo,
+		transactionAuditRepo,
+		accessRuleRepo,
+		walletRepo,
+		tariffService,
+		paymentService,
+		authorizationChain,
+		notifier,
+		unitOfWork,
+		eventBus,
+		connections,
+		cfg.Transaction,
+		cfg.Authorization,
+	)
 	userService := service.NewUserService(userRepo)
-	connectorService := service.NewConnectorService(connectorRepo)
-	idTagService := service.NewIDTagService(idTagRepo)
+	connectorService := service.NewConnectorService(connectorRepo, chargePointRepo, connectorErrorRepo, statusChangeRepo, unitOfWork, eventBus, notifier)
+	idTagService := service.NewIDTagService(idTagRepo, transactionRepo, chargePointRepo, accessRuleRepo, walletRepo, authorizationChain, authorizationCache, cfg.Authorization)
 	authService := service.NewAuthService(userRepo, &cfg.JWT)
+	accessRuleService := service.NewAccessRuleService(accessRuleRepo)
+	recalculationService := service.NewCostRecalculationService(transactionRepo, transactionAdjustmentRepo, tariffRepo, walletRepo, tariffService, paymentService, unitOfWork)
+	invoiceService := service.NewInvoiceService(invoiceRepo, transactionRepo, userRepo, tariffService, cfg.Invoice)
+	walletService := service.NewWalletService(walletRepo, userRepo, cfg.Tariff)
+	guestService := service.NewGuestService(
+		guestSessionRepo,
+		connectorRepo,
+		transactionRepo,
+		idTagRepo,
+		userRepo,
+		paymentService,
+		invoiceService,
+		connections,
+		cfg.Tariff,
+		cfg.Payment,
+		cfg.Guest,
+	)
+	ocpiService := service.NewOCPIService(
+		ocpiPartyRepo,
+		chargePointRepo,
+		connectorRepo,
+		transactionRepo,
+		tariffRepo,
+		roamingTokenRepo,
+		ocpiClient,
+		cfg.OCPI,
+		cfg.Tariff,
+	)
 
 	return &Server{
 		router: router,
 		port:   cfg.Server.Port,
 		config: cfg,
 
-		chargePointService: chargePointService,
-		transactionService: transactionService,
-		userService:        userService,
-		connectorService:   connectorService,
-		idTagService:       idTagService,
-		authService:        authService,
+		chargePointService:   chargePointService,
+		transactionService:   transactionService,
+		userService:          userService,
+		connectorService:     connectorService,
+		idTagService:         idTagService,
+		authService:          authService,
+		accessRuleService:    accessRuleService,
+		tariffService:        tariffService,
+		transactionFeed:      transactionBroadcaster,
+		recalculationService: recalculationService,
+		invoiceService:       invoiceService,
+		walletService:        walletService,
+		paymentService:       paymentService,
+		guestService:         guestService,
+		ocpiService:          ocpiService,
+		roamingTokenService:  roamingTokenService,
+		authorizationChain:   authorizationChain,
+		authorizationCache:   authorizationCache,
+		webhookService:       webhookService,
+		eventFeed:            eventFeed,
+		maintenanceService:   maintenanceService,
+		availabilityService:  service.NewAvailabilityService(statusChangeRepo, connectionChangeRepo, connectorRepo),
+		monitoringService:    service.NewMonitoringService(chargePointRepo, connectorRepo, transactionRepo, userRepo),
+		metrics:              prometheusMetrics,
+
+		connections: connections,
+
+		stuckTransactionMonitor: service.NewStuckTransactionMonitor(transactionService, cfg.Transaction.StuckCheckInterval),
+		monthlyInvoiceJob:       service.NewMonthlyInvoiceJob(invoiceService, cfg.Invoice.CheckInterval),
+		paymentExpiryJob:        service.NewPaymentExpiryJob(paymentService, cfg.Payment.PendingTimeout),
+		eventDispatcher:         service.NewEventDispatcher(eventBus, cfg.Events.PollInterval),
+		eventRetentionJob:       service.NewEventRetentionJob(eventRepo, cfg.Events.Retention, cfg.Events.PruneInterval),
+		webhookDeliveryJob:      service.NewWebhookDeliveryJob(webhookService, cfg.Webhook.PollInterval),
+		alertMonitor:            service.NewAlertMonitor(maintenanceService, cfg.Maintenance.CheckInterval),
+		ocpiPusher:              ocpiPusher,
 	}, nil
 }
 
@@ -79,6 +302,9 @@ func (s *Server) SetupRoutes() {
 		s.userService,
 		s.connectorService,
 		s.idTagService,
+		s.connections,
+		s.metrics,
+		s.config.OCPP,
 	)
 
 	s.router.GET("/health", healthHandler.HealthCheck)
@@ -91,17 +317,202 @@ func (s *Server) SetupRoutes() {
 		s.userService,
 		s.idTagService,
 		s.authService,
+		s.accessRuleService,
+		s.tariffService,
+		s.transactionFeed,
+		s.recalculationService,
+		s.invoiceService,
+		s.walletService,
+		s.paymentService,
+		s.guestService,
+		s.ocpiService,
+		s.authorizationChain,
+		s.authorizationCache,
+		s.webhookService,
+		s.eventFeed,
+		s.maintenanceService,
+		s.availabilityService,
+		s.monitoringService,
 	)
 
+	if s.config.OCPI.Enabled {
+		ocpihttp.SetupRoutes(s.router, s.ocpiService, s.roamingTokenService)
+	}
+
 	// WebSocket OCPP endpoint
 	s.router.GET("/ocpp/*cpID", ocppHandler.HandleWebSocket)
 }
 
 func (s *Server) Start() error {
+	go s.stuckTransactionMonitor.Run(context.Background())
+	go s.paymentExpiryJob.Run(context.Background())
+	go s.eventDispatcher.Run(context.Background())
+	go s.eventRetentionJob.Run(context.Background())
+	go s.webhookDeliveryJob.Run(context.Background())
+	go s.eventFeed.Run(context.Background())
+	go s.transactionFeed.Run(context.Background())
+	go s.alertMonitor.Run(context.Background())
+	if s.config.Invoice.Monthly {
+		go s.monthlyInvoiceJob.Run(context.Background())
+	}
+	if s.config.OCPI.Enabled {
+		go s.ocpiPusher.Run(context.Background())
+	}
+	go s.connections.Run(context.Background())
+	if s.config.Monitoring.Enabled {
+		go func() {
+			log.Printf("Metrics are served on port %s", s.config.Monitoring.Port)
+			if err := s.metrics.Serve(s.config.Monitoring.Port); err != nil {
+				log.Printf("Error serving metrics: %v", err)
+			}
+		}()
+	}
+
 	log.Printf("CSMS server is running on port %s", s.port)
 	return s.router.Run(":" + s.port)
 }
 
+// newPaymentGateway returns the card payment provider selected in the config.
+func newPaymentGateway(paymentConfig config.PaymentConfig) (domain.PaymentGateway, error) {
+	switch paymentConfig.Provider {
+	case "", "fake":
+		return payment.NewFakeGateway(paymentConfig.WebhookSecret), nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider %q", paymentConfig.Provider)
+	}
+}
+
+// newAuthorizationBackends builds the authorization chain configured in the
+// order its backends are asked.
+func newAuthorizationBackends(
+	authorizationConfig config.AuthorizationConfig,
+	idTagRepo domain.IDTagRepository,
+	authorizationCache domain.AuthorizationCache,
+	roamingTokenService domain.RoamingTokenService,
+) ([]service.AuthorizationBackend, error) {
+	backends := make([]service.AuthorizationBackend, 0, len(authorizationConfig.Backends))
+	for _, backend := range authorizationConfig.Backends {
+		var authorizer domain.TagAuthorizer
+		switch backend.Type {
+		case domain.AuthorizerLocal:
+			authorizer = service.NewLocalTagAuthorizer(idTagRepo)
+		case domain.AuthorizerCache:
+			authorizer = authorizationCache
+		case domain.AuthorizerWebhook:
+			if backend.URL == "" {
+				return nil, errors.New("webhook authorization backend needs a url")
+			}
+			authorizer = authorization.NewWebhook(backend.URL, backend.Secret)
+		case domain.AuthorizerRoaming:
+			authorizer = service.NewRoamingTagAuthorizer(roamingTokenService)
+		default:
+			return nil, fmt.Errorf("unknown authorization backend %q", backend.Type)
+		}
+
+		onError := backend.OnError
+		switch onError {
+		case "":
+			onError = domain.AuthorizerOnErrorNext
+		case domain.AuthorizerOnErrorNext, domain.AuthorizerOnErrorReject:
+		default:
+			return nil, fmt.Errorf("unknown on_error policy %q of the %s authorization backend", onError, backend.Type)
+		}
+
+		backends = append(backends, service.AuthorizationBackend{
+			Authorizer: authorizer,
+			Timeout:    backend.Timeout,
+			OnError:    onError,
+		})
+	}
+	return backends, nil
+}
+
+// newTagDecisionStore returns where the authorization cache keeps decisions.
+// A cluster needs the redis store: a tag changed on one instance is only
+// dropped from the cache of that instance, so the others would keep acting
+// on the old decision until it expires.
+func newTagDecisionStore(cacheConfig config.AuthorizationCacheConfig, clusterConfig config.ClusterConfig, redisClient *redis.Client) (domain.TagDecisionStore, error) {
+	switch cacheConfig.Store {
+	case "", "memory":
+		if clusterConfig.Enabled {
+			return nil, errors.New("the authorization cache must use the redis store when the cluster is enabled")
+		}
+		return cache.NewMemoryStore(), nil
+	case "redis":
+		return cache.NewRedisStore(redisClient), nil
+	default:
+		return nil, fmt.Errorf("unknown authorization cache store %q", cacheConfig.Store)
+	}
+}
+
+// newConnectionDirectory returns the directory shared by the nodes of the
+// cluster, or nil when the CSMS runs as a single instance.
+func newConnectionDirectory(clusterConfig config.ClusterConfig, redisClient *redis.Client) (domain.ConnectionDirectory, error) {
+	if !clusterConfig.Enabled {
+		return nil, nil
+	}
+
+	switch clusterConfig.Directory {
+	case "", "redis":
+		return cluster.NewRedisDirectory(redisClient, clusterConfig.NodeTTL), nil
+	case "memory":
+		return cluster.NewMemoryDirectory(clusterConfig.NodeTTL), nil
+	default:
+		return nil, fmt.Errorf("unknown cluster directory %q", clusterConfig.Directory)
+	}
+}
+
+func newEventSinks(eventsConfig config.EventsConfig, redisClient *redis.Client) ([]domain.EventSink, error) {
+	sinks := make([]domain.EventSink, 0, len(eventsConfig.Sinks))
+	for _, name := range eventsConfig.Sinks {
+		switch name {
+		case "log":
+			sinks = append(sinks, events.NewLogSink())
+		case "redis":
+			sinks = append(sinks, events.NewRedisSink(redisClient, eventsConfig.RedisStream))
+		default:
+			return nil, fmt.Errorf("unknown event sink %q", name)
+		}
+	}
+	return sinks, nil
+}
+
+// activeTransactionsGauge counts the active transactions when metrics are
+// scraped.
+func activeTransactionsGauge(transactionRepo domain.TransactionRepository) func() float64 {
+	return func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		counts, err := transactionRepo.CountByStatus(ctx)
+		if err != nil {
+			log.Printf("Error counting active transactions: %v", err)
+			return math.NaN()
+		}
+		for _, count := range counts {
+			if count.Key == domain.TransactionStatusActive {
+				return float64(count.Count)
+			}
+		}
+		return 0
+	}
+}
+
+func newTicketNotifiers(maintenanceConfig config.MaintenanceConfig, eventBus domain.EventBus) ([]domain.TicketNotifier, error) {
+	notifiers := make([]domain.TicketNotifier, 0, len(maintenanceConfig.Notifiers))
+	for _, name := range maintenanceConfig.Notifiers {
+		switch name {
+		case "log":
+			notifiers = append(notifiers, service.NewLogTicketNotifier())
+		case "events":
+			notifiers = append(notifiers, service.NewEventTicketNotifier(eventBus))
+		default:
+			return nil, fmt.Errorf("unknown ticket notifier %q", name)
+		}
+	}
+	return notifiers, nil
+}
+
 func (s *Server) GetRouter() *gin.Engine {
 	return s.router
 }